@@ -69,6 +69,7 @@ var (
 	SystemUserType      = &AssertionType{"system-user", []string{"brand-id", "email"}, assembleSystemUser, 0}
 	ValidationType      = &AssertionType{"validation", []string{"series", "snap-id", "approved-snap-id", "approved-snap-revision"}, assembleValidation, 0}
 	StoreType           = &AssertionType{"store", []string{"store"}, assembleStore, 0}
+	DevicePolicyType    = &AssertionType{"device-policy", []string{"series", "brand-id", "model"}, assembleDevicePolicy, 0}
 
 // ...
 )
@@ -94,6 +95,7 @@ var typeRegistry = map[string]*AssertionType{
 	ValidationType.Name:      ValidationType,
 	RepairType.Name:          RepairType,
 	StoreType.Name:           StoreType,
+	DevicePolicyType.Name:    DevicePolicyType,
 	// no authority
 	DeviceSessionRequestType.Name: DeviceSessionRequestType,
 	SerialRequestType.Name:        SerialRequestType,
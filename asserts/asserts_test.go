@@ -52,6 +52,7 @@ func (as *assertsSuite) TestTypeNames(c *C) {
 		"account-key",
 		"account-key-request",
 		"base-declaration",
+		"device-policy",
 		"device-session-request",
 		"model",
 		"repair",
@@ -889,6 +890,7 @@ func (as *assertsSuite) TestWithAuthority(c *C) {
 		"system-user",
 		"validation",
 		"repair",
+		"device-policy",
 	}
 	c.Check(withAuthority, HasLen, asserts.NumAssertionType-3) // excluding device-session-request, serial-request, account-key-request
 	for _, name := range withAuthority {
@@ -351,6 +351,17 @@ func newExtPGPPrivateKey(exportedPubKeyStream io.Reader, from string, sign func(
 	}, nil
 }
 
+func newExtPGPPrivateKeyFromRSA(rsaPubKey *rsa.PublicKey, from string, sign func(content []byte) ([]byte, error)) *extPGPPrivateKey {
+	pubKey := packet.NewRSAPublicKey(v1FixedTimestamp, rsaPubKey)
+	return &extPGPPrivateKey{
+		pubKey:         RSAPublicKey(rsaPubKey),
+		from:           from,
+		pgpFingerprint: fmt.Sprintf("%X", pubKey.Fingerprint),
+		bitLen:         rsaPubKey.N.BitLen(),
+		doSign:         sign,
+	}
+}
+
 func (expk *extPGPPrivateKey) fingerprint() string {
 	return expk.pgpFingerprint
 }
@@ -36,6 +36,7 @@ type Model struct {
 	classic          bool
 	requiredSnaps    []string
 	sysUserAuthority []string
+	allowedSysctls   []string
 	timestamp        time.Time
 }
 
@@ -130,6 +131,11 @@ func (mod *Model) SystemUserAuthority() []string {
 	return mod.sysUserAuthority
 }
 
+// AllowedSysctls returns the sysctl keys, or key prefixes ending in ".*", that the brand allows to be set through system options in addition to the default ones.
+func (mod *Model) AllowedSysctls() []string {
+	return mod.allowedSysctls
+}
+
 // Timestamp returns the time when the model assertion was issued.
 func (mod *Model) Timestamp() time.Time {
 	return mod.timestamp
@@ -144,6 +150,9 @@ func (mod *Model) checkConsistency(db RODatabase, acck *AccountKey) error {
 // sanity
 var _ consistencyChecker = (*Model)(nil)
 
+// validSysctlPattern matches sysctl keys like "vm.swappiness" or key prefixes like "net.ipv4.*"
+var validSysctlPattern = regexp.MustCompile(`^[a-z0-9_-]+(?:\.[a-z0-9_-]+)*(?:\.\*)?$`)
+
 // limit model to only lowercase for now
 var validModel = regexp.MustCompile("^[a-zA-Z0-9](?:-?[a-zA-Z0-9])*$")
 
@@ -315,6 +324,11 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	allowedSysctls, err := checkStringListMatches(assert.headers, "allowed-sysctls", validSysctlPattern)
+	if err != nil {
+		return nil, err
+	}
+
 	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
 	if err != nil {
 		return nil, err
@@ -333,6 +347,7 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		classic:          classic,
 		requiredSnaps:    reqSnaps,
 		sysUserAuthority: sysUserAuthority,
+		allowedSysctls:   allowedSysctls,
 		timestamp:        timestamp,
 	}, nil
 }
@@ -271,6 +271,20 @@ func (mods *modelSuite) TestDecodeSystemUserAuthorityIsOptional(c *C) {
 	c.Check(model.SystemUserAuthority(), DeepEquals, []string{"foo", "bar"})
 }
 
+func (mods *modelSuite) TestDecodeAllowedSysctls(c *C) {
+	withTimestamp := strings.Replace(modelExample, "TSLINE", mods.tsLine, 1)
+	a, err := asserts.Decode([]byte(withTimestamp))
+	c.Assert(err, IsNil)
+	model := a.(*asserts.Model)
+	c.Check(model.AllowedSysctls(), HasLen, 0)
+
+	encoded := strings.Replace(withTimestamp, sysUserAuths, sysUserAuths+"allowed-sysctls:\n  - vm.overcommit_memory\n  - net.ipv4.*\n", 1)
+	a, err = asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	model = a.(*asserts.Model)
+	c.Check(model.AllowedSysctls(), DeepEquals, []string{"vm.overcommit_memory", "net.ipv4.*"})
+}
+
 func (mods *modelSuite) TestDecodeKernelTrack(c *C) {
 	withTimestamp := strings.Replace(modelExample, "TSLINE", mods.tsLine, 1)
 	encoded := strings.Replace(withTimestamp, "kernel: baz-linux\n", "kernel: baz-linux=18\n", 1)
@@ -332,6 +346,9 @@ func (mods *modelSuite) TestDecodeInvalid(c *C) {
 		{reqSnaps, "required-snaps:\n  -\n    - nested\n", `"required-snaps" header must be a list of strings`},
 		{sysUserAuths, "system-user-authority:\n  a: 1\n", `"system-user-authority" header must be '\*' or a list of account ids`},
 		{sysUserAuths, "system-user-authority:\n  - 5_6\n", `"system-user-authority" header must be '\*' or a list of account ids`},
+		{sysUserAuths, "allowed-sysctls: vm.swappiness\n", `"allowed-sysctls" header must be a list of strings`},
+		{sysUserAuths, "allowed-sysctls:\n  - vm.*.foo\n", `"allowed-sysctls" header contains an invalid element: "vm.\*.foo"`},
+		{sysUserAuths, "allowed-sysctls:\n  - VM\n", `"allowed-sysctls" header contains an invalid element: "VM"`},
 	}
 
 	for _, test := range invalidTests {
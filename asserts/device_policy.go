@@ -0,0 +1,185 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/snap/naming"
+)
+
+// DevicePolicy holds a device-policy assertion, signed by the brand of
+// a model to declare interface policies for named snaps on the devices
+// of that model. Its rules take precedence over the ones of the
+// snap-declarations and of the base-declaration.
+type DevicePolicy struct {
+	assertionBase
+	plugRules map[string]map[string]*PlugRule
+	slotRules map[string]map[string]*SlotRule
+	timestamp time.Time
+}
+
+// Series returns the series of the model the policy applies to.
+func (devpol *DevicePolicy) Series() string {
+	return devpol.HeaderString("series")
+}
+
+// BrandID returns the brand identifier of the model the policy applies to.
+func (devpol *DevicePolicy) BrandID() string {
+	return devpol.HeaderString("brand-id")
+}
+
+// Model returns the name of the model the policy applies to.
+func (devpol *DevicePolicy) Model() string {
+	return devpol.HeaderString("model")
+}
+
+// Timestamp returns the time when the device-policy was issued.
+func (devpol *DevicePolicy) Timestamp() time.Time {
+	return devpol.timestamp
+}
+
+// SnapNames returns the sorted names of the snaps the policy has rules for.
+func (devpol *DevicePolicy) SnapNames() []string {
+	seen := make(map[string]bool, len(devpol.plugRules)+len(devpol.slotRules))
+	for name := range devpol.plugRules {
+		seen[name] = true
+	}
+	for name := range devpol.slotRules {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PlugRule returns the plug-side rule about the given interface for the given snap if one was included in the policy, otherwise it returns nil.
+func (devpol *DevicePolicy) PlugRule(snapName, interfaceName string) *PlugRule {
+	return devpol.plugRules[snapName][interfaceName]
+}
+
+// SlotRule returns the slot-side rule about the given interface for the given snap if one was included in the policy, otherwise it returns nil.
+func (devpol *DevicePolicy) SlotRule(snapName, interfaceName string) *SlotRule {
+	return devpol.slotRules[snapName][interfaceName]
+}
+
+// Implement further consistency checks.
+func (devpol *DevicePolicy) checkConsistency(db RODatabase, acck *AccountKey) error {
+	_, err := db.Find(ModelType, map[string]string{
+		"series":   devpol.Series(),
+		"brand-id": devpol.BrandID(),
+		"model":    devpol.Model(),
+	})
+	if IsNotFound(err) {
+		return fmt.Errorf("device-policy assertion for model %s/%s does not have a matching model assertion", devpol.BrandID(), devpol.Model())
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// sanity
+var _ consistencyChecker = (*DevicePolicy)(nil)
+
+// Prerequisites returns references to this device-policy's prerequisite assertions.
+func (devpol *DevicePolicy) Prerequisites() []*Ref {
+	return []*Ref{
+		{Type: ModelType, PrimaryKey: []string{devpol.Series(), devpol.BrandID(), devpol.Model()}},
+	}
+}
+
+func assembleDevicePolicy(assert assertionBase) (Assertion, error) {
+	err := checkAuthorityMatchesBrand(&assert)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = checkModel(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps, err := checkMap(assert.headers, "snaps")
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) == 0 {
+		return nil, fmt.Errorf(`"snaps" header is mandatory and must list at least one snap`)
+	}
+
+	plugRules := make(map[string]map[string]*PlugRule)
+	slotRules := make(map[string]map[string]*SlotRule)
+	for name, v := range snaps {
+		if err := naming.ValidateSnap(name); err != nil {
+			return nil, fmt.Errorf(`invalid snap name in "snaps" header: %v`, err)
+		}
+		rules, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`rules for snap %q must be a map`, name)
+		}
+		plugs, err := checkMap(rules, "plugs")
+		if err != nil {
+			return nil, fmt.Errorf("in rules for snap %q: %v", name, err)
+		}
+		if plugs != nil {
+			plugRules[name] = make(map[string]*PlugRule, len(plugs))
+			err := compilePlugRules(plugs, func(iface string, rule *PlugRule) {
+				plugRules[name][iface] = rule
+			})
+			if err != nil {
+				return nil, fmt.Errorf("in rules for snap %q: %v", name, err)
+			}
+		}
+		slots, err := checkMap(rules, "slots")
+		if err != nil {
+			return nil, fmt.Errorf("in rules for snap %q: %v", name, err)
+		}
+		if slots != nil {
+			slotRules[name] = make(map[string]*SlotRule, len(slots))
+			err := compileSlotRules(slots, func(iface string, rule *SlotRule) {
+				slotRules[name][iface] = rule
+			})
+			if err != nil {
+				return nil, fmt.Errorf("in rules for snap %q: %v", name, err)
+			}
+		}
+		if plugs == nil && slots == nil {
+			return nil, fmt.Errorf(`rules for snap %q must have "plugs" or "slots"`, name)
+		}
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &DevicePolicy{
+		assertionBase: assert,
+		plugRules:     plugRules,
+		slotRules:     slotRules,
+		timestamp:     timestamp,
+	}, nil
+}
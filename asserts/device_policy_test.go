@@ -0,0 +1,164 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+type devicePolicySuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+var _ = Suite(&devicePolicySuite{})
+
+func (s *devicePolicySuite) SetUpSuite(c *C) {
+	s.ts = time.Now().Truncate(time.Second).UTC()
+	s.tsLine = "timestamp: " + s.ts.Format(time.RFC3339) + "\n"
+}
+
+const devicePolicyExample = "type: device-policy\n" +
+	"authority-id: brand-id1\n" +
+	"series: 16\n" +
+	"brand-id: brand-id1\n" +
+	"model: baz-3000\n" +
+	"snaps:\n" +
+	"  brand-app:\n" +
+	"    plugs:\n" +
+	"      serial-port:\n" +
+	"        allow-installation: true\n" +
+	"        allow-auto-connection: true\n" +
+	"  brand-hw:\n" +
+	"    slots:\n" +
+	"      serial-port:\n" +
+	"        deny-auto-connection: true\n" +
+	"TSLINE" +
+	"body-length: 0\n" +
+	"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+	"\n\n" +
+	"AXNpZw=="
+
+func (s *devicePolicySuite) TestDecodeOK(c *C) {
+	encoded := strings.Replace(devicePolicyExample, "TSLINE", s.tsLine, 1)
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.DevicePolicyType)
+	devpol := a.(*asserts.DevicePolicy)
+	c.Check(devpol.AuthorityID(), Equals, "brand-id1")
+	c.Check(devpol.Timestamp(), Equals, s.ts)
+	c.Check(devpol.Series(), Equals, "16")
+	c.Check(devpol.BrandID(), Equals, "brand-id1")
+	c.Check(devpol.Model(), Equals, "baz-3000")
+	c.Check(devpol.SnapNames(), DeepEquals, []string{"brand-app", "brand-hw"})
+
+	plugRule := devpol.PlugRule("brand-app", "serial-port")
+	c.Assert(plugRule, NotNil)
+	c.Check(plugRule.AllowAutoConnection, HasLen, 1)
+	c.Check(plugRule.AllowAutoConnection[0].SlotAttributes, Equals, asserts.AlwaysMatchAttributes)
+	c.Check(devpol.PlugRule("brand-app", "network"), IsNil)
+	c.Check(devpol.PlugRule("brand-hw", "serial-port"), IsNil)
+
+	slotRule := devpol.SlotRule("brand-hw", "serial-port")
+	c.Assert(slotRule, NotNil)
+	c.Check(slotRule.DenyAutoConnection, HasLen, 1)
+	c.Check(slotRule.DenyAutoConnection[0].PlugAttributes, Equals, asserts.AlwaysMatchAttributes)
+	c.Check(devpol.SlotRule("brand-app", "serial-port"), IsNil)
+
+	c.Check(devpol.Prerequisites(), DeepEquals, []*asserts.Ref{
+		{Type: asserts.ModelType, PrimaryKey: []string{"16", "brand-id1", "baz-3000"}},
+	})
+}
+
+const (
+	devicePolicyErrPrefix = "assertion device-policy: "
+)
+
+func (s *devicePolicySuite) TestDecodeInvalid(c *C) {
+	encoded := strings.Replace(devicePolicyExample, "TSLINE", s.tsLine, 1)
+
+	snapsStanza := encoded[strings.Index(encoded, "snaps:"):strings.Index(encoded, "timestamp:")]
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"brand-id: brand-id1\n", "brand-id: other\n", `authority-id and brand-id must match, device-policy assertions are expected to be signed by the brand: "brand-id1" != "other"`},
+		{"model: baz-3000\n", "", `"model" header is mandatory`},
+		{"model: baz-3000\n", "model: Baz-3000\n", `"model" header cannot contain uppercase letters`},
+		{snapsStanza, "", `"snaps" header is mandatory and must list at least one snap`},
+		{snapsStanza, "snaps: foo\n", `"snaps" header must be a map`},
+		{snapsStanza, "snaps:\n  x:\n    plugs:\n      network: true\n", `invalid snap name in "snaps" header: invalid snap name: "x"`},
+		{snapsStanza, "snaps:\n  brand-app: foo\n", `rules for snap "brand-app" must be a map`},
+		{snapsStanza, "snaps:\n  brand-app:\n    foo: bar\n", `rules for snap "brand-app" must have "plugs" or "slots"`},
+		{snapsStanza, "snaps:\n  brand-app:\n    plugs: foo\n", `in rules for snap "brand-app": "plugs" header must be a map`},
+		{snapsStanza, "snaps:\n  brand-app:\n    slots:\n      network: foo\n", `in rules for snap "brand-app": .*`},
+		{s.tsLine, "", `"timestamp" header is mandatory`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, devicePolicyErrPrefix+test.expectedErr)
+	}
+}
+
+func (s *devicePolicySuite) TestCheck(c *C) {
+	storeDB, db := makeStoreAndCheckDB(c)
+	brandDB := setup3rdPartySigning(c, "my-brand", storeDB, db)
+
+	headers := map[string]interface{}{
+		"series":    "16",
+		"brand-id":  "my-brand",
+		"model":     "my-model",
+		"timestamp": time.Now().Format(time.RFC3339),
+		"snaps": map[string]interface{}{
+			"brand-app": map[string]interface{}{
+				"plugs": map[string]interface{}{
+					"serial-port": map[string]interface{}{
+						"allow-auto-connection": "true",
+					},
+				},
+			},
+		},
+	}
+	devpol, err := brandDB.Sign(asserts.DevicePolicyType, headers, nil, "")
+	c.Assert(err, IsNil)
+
+	err = db.Check(devpol)
+	c.Assert(err, ErrorMatches, `device-policy assertion for model my-brand/my-model does not have a matching model assertion`)
+
+	model, err := brandDB.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "kernel",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(model), IsNil)
+
+	err = db.Check(devpol)
+	c.Assert(err, IsNil)
+}
@@ -166,6 +166,18 @@ func MockRunGPG(mock func(prev GPGRunner, input []byte, args ...string) ([]byte,
 	}
 }
 
+type ExternalKeyMgrRunner func(keyMgrPath string, input []byte, args ...string) ([]byte, error)
+
+func MockRunExtKeyMgr(mock func(prev ExternalKeyMgrRunner, keyMgrPath string, input []byte, args ...string) ([]byte, error)) (restore func()) {
+	prevRunExtKeyMgr := runExtKeyMgr
+	runExtKeyMgr = func(keyMgrPath string, input []byte, args ...string) ([]byte, error) {
+		return mock(prevRunExtKeyMgr, keyMgrPath, input, args...)
+	}
+	return func() {
+		runExtKeyMgr = prevRunExtKeyMgr
+	}
+}
+
 // Headers helpers to test
 var (
 	ParseHeaders = parseHeaders
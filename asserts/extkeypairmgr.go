@@ -0,0 +1,311 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+func runExtKeyMgrImpl(keyMgrPath string, input []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command(keyMgrPath, args...)
+	var outBuf bytes.Buffer
+	var errBuf bytes.Buffer
+
+	if len(input) != 0 {
+		cmd.Stdin = bytes.NewBuffer(input)
+	}
+
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("external keypair manager %q %s failed: %v (%q)", keyMgrPath, strings.Join(args, " "), err, errBuf.Bytes())
+	}
+
+	return outBuf.Bytes(), nil
+}
+
+var runExtKeyMgr = runExtKeyMgrImpl
+
+// ExternalKeyInfo describes a key held by a keypair manager that keeps
+// the private keys outside of snapd, like GnuPG or an external helper.
+type ExternalKeyInfo struct {
+	Name string
+	ID   string
+}
+
+// ExternalKeypairManager is a key pair manager delegating to an external
+// helper program, for example a front-end to a hardware security module
+// or a cloud key management service, such that the private keys never
+// leave it. The helper is invoked as:
+//
+//	helper features
+//	    prints a JSON object {"signing": [...], "public-keys": [...]}
+//	    listing the supported signing mechanisms and public key formats,
+//	    which must include "RSA-PKCS" and "DER" respectively
+//	helper key-names
+//	    prints a JSON object {"key-names": [...]}
+//	helper get-public-key -f DER -k <key-name>
+//	    prints the DER encoded (PKIX) public key of the named key
+//	helper sign -m RSA-PKCS -k <key-name>
+//	    reads a SHA-512 digest on stdin and prints the raw RSASSA-PKCS1-v1_5
+//	    signature of it made with the named key
+//
+// Only RSA keys of at least 4096 bits are supported. Importing keys is not
+// supported.
+type ExternalKeypairManager struct {
+	keyMgrPath string
+	nameToID   map[string]string
+	cache      map[string]*cachedExtKey
+}
+
+type cachedExtKey struct {
+	pubKey  *rsa.PublicKey
+	privKey PrivateKey
+}
+
+// NewExternalKeypairManager creates a new key pair manager delegating to
+// the given external helper program.
+func NewExternalKeypairManager(keyMgrPath string) (*ExternalKeypairManager, error) {
+	em := &ExternalKeypairManager{
+		keyMgrPath: keyMgrPath,
+		nameToID:   make(map[string]string),
+		cache:      make(map[string]*cachedExtKey),
+	}
+	if err := em.checkFeatures(); err != nil {
+		return nil, err
+	}
+	return em, nil
+}
+
+func (em *ExternalKeypairManager) keyMgr(input []byte, args ...string) ([]byte, error) {
+	return runExtKeyMgr(em.keyMgrPath, input, args...)
+}
+
+func (em *ExternalKeypairManager) keyMgrJSON(result interface{}, args ...string) error {
+	out, err := em.keyMgr(nil, args...)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out, result); err != nil {
+		return fmt.Errorf("cannot decode external keypair manager %q %s output: %v", em.keyMgrPath, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func (em *ExternalKeypairManager) checkFeatures() error {
+	var feats struct {
+		Signing    []string `json:"signing"`
+		PublicKeys []string `json:"public-keys"`
+	}
+	if err := em.keyMgrJSON(&feats, "features"); err != nil {
+		return err
+	}
+	if !strutil.ListContains(feats.Signing, "RSA-PKCS") {
+		return fmt.Errorf("external keypair manager %q does not support RSA-PKCS signing", em.keyMgrPath)
+	}
+	if !strutil.ListContains(feats.PublicKeys, "DER") {
+		return fmt.Errorf("external keypair manager %q does not support public keys in DER format", em.keyMgrPath)
+	}
+	return nil
+}
+
+func (em *ExternalKeypairManager) keyNames() ([]string, error) {
+	var knames struct {
+		KeyNames []string `json:"key-names"`
+	}
+	if err := em.keyMgrJSON(&knames, "key-names"); err != nil {
+		return nil, err
+	}
+	return knames.KeyNames, nil
+}
+
+func (em *ExternalKeypairManager) findByName(name string) (*cachedExtKey, error) {
+	if k := em.cache[name]; k != nil {
+		return k, nil
+	}
+
+	out, err := em.keyMgr(nil, "get-public-key", "-f", "DER", "-k", name)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(out)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode public key of external key %q: %v", name, err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("external key %q is not a RSA key", name)
+	}
+
+	signWith := func(content []byte) ([]byte, error) {
+		return em.sign(name, rsaPub, content)
+	}
+	privKey := newExtPGPPrivateKeyFromRSA(rsaPub, "external keypair manager", signWith)
+
+	k := &cachedExtKey{pubKey: rsaPub, privKey: privKey}
+	em.cache[name] = k
+	em.nameToID[name] = privKey.PublicKey().ID()
+	return k, nil
+}
+
+// extSigner is a crypto.Signer delegating to the external helper.
+type extSigner struct {
+	em      *ExternalKeypairManager
+	keyName string
+	pubKey  *rsa.PublicKey
+}
+
+func (s *extSigner) Public() crypto.PublicKey {
+	return s.pubKey
+}
+
+func (s *extSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA512 {
+		return nil, fmt.Errorf("unexpected digest algorithm %v for external signing", opts.HashFunc())
+	}
+	out, err := s.em.keyMgr(digest, "sign", "-m", "RSA-PKCS", "-k", s.keyName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign using external keypair manager: %v", err)
+	}
+	return out, nil
+}
+
+func (em *ExternalKeypairManager) sign(keyName string, pubKey *rsa.PublicKey, content []byte) ([]byte, error) {
+	signer := &extSigner{em: em, keyName: keyName, pubKey: pubKey}
+	privk := packet.NewSignerPrivateKey(v1FixedTimestamp, signer)
+
+	sig := new(packet.Signature)
+	sig.PubKeyAlgo = privk.PubKeyAlgo
+	sig.Hash = openpgpConfig.Hash()
+	sig.CreationTime = time.Now()
+
+	h := openpgpConfig.Hash().New()
+	h.Write(content)
+	if err := sig.Sign(h, privk, openpgpConfig); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := sig.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Put is not supported, keys cannot be imported into the external
+// keypair manager.
+func (em *ExternalKeypairManager) Put(privKey PrivateKey) error {
+	return fmt.Errorf("cannot import private key into external keypair manager")
+}
+
+// Get returns the key pair with the given key id.
+func (em *ExternalKeypairManager) Get(keyID string) (PrivateKey, error) {
+	for name, id := range em.nameToID {
+		if id == keyID {
+			return em.cache[name].privKey, nil
+		}
+	}
+	names, err := em.keyNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		k, err := em.findByName(name)
+		if err != nil {
+			return nil, err
+		}
+		if k.privKey.PublicKey().ID() == keyID {
+			return k.privKey, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot find key %q in external keypair manager", keyID)
+}
+
+// GetByName looks up a key pair by name and returns it.
+func (em *ExternalKeypairManager) GetByName(name string) (PrivateKey, error) {
+	names, err := em.keyNames()
+	if err != nil {
+		return nil, err
+	}
+	if !strutil.ListContains(names, name) {
+		return nil, fmt.Errorf("cannot find key named %q in external keypair manager", name)
+	}
+	k, err := em.findByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return k.privKey, nil
+}
+
+// Export returns the encoded text of the named public key.
+func (em *ExternalKeypairManager) Export(name string) ([]byte, error) {
+	privKey, err := em.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePublicKey(privKey.PublicKey())
+}
+
+// List returns the names and ids of the keys held by the external keypair
+// manager.
+func (em *ExternalKeypairManager) List() ([]ExternalKeyInfo, error) {
+	names, err := em.keyNames()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]ExternalKeyInfo, 0, len(names))
+	for _, name := range names {
+		k, err := em.findByName(name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ExternalKeyInfo{
+			Name: name,
+			ID:   k.privKey.PublicKey().ID(),
+		})
+	}
+	return res, nil
+}
+
+// Generate is not supported, keys need to be created directly with the
+// external keypair manager.
+func (em *ExternalKeypairManager) Generate(passphrase string, name string) error {
+	return fmt.Errorf("cannot generate keys with external keypair manager, create them directly with it")
+}
+
+// Delete is not supported, keys need to be removed directly with the
+// external keypair manager.
+func (em *ExternalKeypairManager) Delete(name string) error {
+	return fmt.Errorf("cannot delete keys with external keypair manager, remove them directly with it")
+}
@@ -0,0 +1,236 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+)
+
+type extKeypairMgrSuite struct {
+	pubKeyDER []byte
+	rsaPriv   *rsa.PrivateKey
+
+	calls   [][]string
+	restore func()
+}
+
+var _ = Suite(&extKeypairMgrSuite{})
+
+func (s *extKeypairMgrSuite) SetUpSuite(c *C) {
+	_, s.rsaPriv = assertstest.ReadPrivKey(assertstest.DevKey)
+	var err error
+	s.pubKeyDER, err = x509.MarshalPKIXPublicKey(&s.rsaPriv.PublicKey)
+	c.Assert(err, IsNil)
+}
+
+func (s *extKeypairMgrSuite) SetUpTest(c *C) {
+	s.calls = nil
+	s.restore = asserts.MockRunExtKeyMgr(func(prev asserts.ExternalKeyMgrRunner, keyMgrPath string, input []byte, args ...string) ([]byte, error) {
+		c.Check(keyMgrPath, Equals, "keymgr")
+		s.calls = append(s.calls, args)
+		switch args[0] {
+		case "features":
+			return []byte(`{"signing":["RSA-PKCS"],"public-keys":["DER"]}`), nil
+		case "key-names":
+			return []byte(`{"key-names":["default"]}`), nil
+		case "get-public-key":
+			c.Check(args, DeepEquals, []string{"get-public-key", "-f", "DER", "-k", "default"})
+			return s.pubKeyDER, nil
+		case "sign":
+			c.Check(args, DeepEquals, []string{"sign", "-m", "RSA-PKCS", "-k", "default"})
+			return rsa.SignPKCS1v15(rand.Reader, s.rsaPriv, crypto.SHA512, input)
+		}
+		return nil, fmt.Errorf("unexpected external keypair manager call: %s", strings.Join(args, " "))
+	})
+}
+
+func (s *extKeypairMgrSuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+func (s *extKeypairMgrSuite) TestFeaturesErrors(c *C) {
+	tests := []struct {
+		features string
+		err      string
+	}{
+		{`{"signing":["RSA-PSS"],"public-keys":["DER"]}`, `external keypair manager "keymgr" does not support RSA-PKCS signing`},
+		{`{"signing":["RSA-PKCS"],"public-keys":["PEM"]}`, `external keypair manager "keymgr" does not support public keys in DER format`},
+		{`{`, `cannot decode external keypair manager "keymgr" features output: .*`},
+	}
+
+	for _, t := range tests {
+		restore := asserts.MockRunExtKeyMgr(func(prev asserts.ExternalKeyMgrRunner, keyMgrPath string, input []byte, args ...string) ([]byte, error) {
+			c.Check(args, DeepEquals, []string{"features"})
+			return []byte(t.features), nil
+		})
+		_, err := asserts.NewExternalKeypairManager("keymgr")
+		c.Check(err, ErrorMatches, t.err)
+		restore()
+	}
+}
+
+func (s *extKeypairMgrSuite) TestGetByName(c *C) {
+	kmgr, err := asserts.NewExternalKeypairManager("keymgr")
+	c.Assert(err, IsNil)
+
+	privk, err := kmgr.GetByName("default")
+	c.Assert(err, IsNil)
+	c.Check(privk.PublicKey().ID(), Equals, assertstest.DevKeyID)
+
+	// the public key is cached
+	_, err = kmgr.GetByName("default")
+	c.Assert(err, IsNil)
+	c.Check(s.calls, DeepEquals, [][]string{
+		{"features"},
+		{"key-names"},
+		{"get-public-key", "-f", "DER", "-k", "default"},
+		{"key-names"},
+	})
+
+	_, err = kmgr.GetByName("missing")
+	c.Check(err, ErrorMatches, `cannot find key named "missing" in external keypair manager`)
+}
+
+func (s *extKeypairMgrSuite) TestGet(c *C) {
+	kmgr, err := asserts.NewExternalKeypairManager("keymgr")
+	c.Assert(err, IsNil)
+
+	privk, err := kmgr.Get(assertstest.DevKeyID)
+	c.Assert(err, IsNil)
+	c.Check(privk.PublicKey().ID(), Equals, assertstest.DevKeyID)
+
+	_, err = kmgr.Get("ffffffffffffffff")
+	c.Check(err, ErrorMatches, `cannot find key "ffffffffffffffff" in external keypair manager`)
+}
+
+func (s *extKeypairMgrSuite) TestListAndExport(c *C) {
+	kmgr, err := asserts.NewExternalKeypairManager("keymgr")
+	c.Assert(err, IsNil)
+
+	keys, err := kmgr.List()
+	c.Assert(err, IsNil)
+	c.Check(keys, DeepEquals, []asserts.ExternalKeyInfo{
+		{Name: "default", ID: assertstest.DevKeyID},
+	})
+
+	encoded, err := kmgr.Export("default")
+	c.Assert(err, IsNil)
+	pubKey, err := asserts.DecodePublicKey(encoded)
+	c.Assert(err, IsNil)
+	c.Check(pubKey.ID(), Equals, assertstest.DevKeyID)
+}
+
+func (s *extKeypairMgrSuite) TestUnsupported(c *C) {
+	kmgr, err := asserts.NewExternalKeypairManager("keymgr")
+	c.Assert(err, IsNil)
+
+	pk, _ := assertstest.GenerateKey(752)
+	c.Check(kmgr.Put(pk), ErrorMatches, `cannot import private key into external keypair manager`)
+	c.Check(kmgr.Generate("", "foo"), ErrorMatches, `cannot generate keys with external keypair manager.*`)
+	c.Check(kmgr.Delete("default"), ErrorMatches, `cannot delete keys with external keypair manager.*`)
+}
+
+func (s *extKeypairMgrSuite) TestUseInSigning(c *C) {
+	store := assertstest.NewStoreStack("trusted", nil)
+
+	kmgr, err := asserts.NewExternalKeypairManager("keymgr")
+	c.Assert(err, IsNil)
+
+	devKey, err := kmgr.GetByName("default")
+	c.Assert(err, IsNil)
+
+	devAcct := assertstest.NewAccount(store, "devel1", map[string]interface{}{
+		"account-id": "dev1-id",
+	}, "")
+	devAccKey := assertstest.NewAccountKey(store, devAcct, nil, devKey.PublicKey(), "")
+
+	signDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		KeypairManager: kmgr,
+	})
+	c.Assert(err, IsNil)
+
+	checkDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   store.Trusted,
+	})
+	c.Assert(err, IsNil)
+	// add store key
+	err = checkDB.Add(store.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	// enable devel key
+	err = checkDB.Add(devAcct)
+	c.Assert(err, IsNil)
+	err = checkDB.Add(devAccKey)
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id":  "dev1-id",
+		"snap-sha3-384": blobSHA3_384,
+		"snap-id":       "snap-id-1",
+		"grade":         "devel",
+		"snap-size":     "1025",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
+	snapBuild, err := signDB.Sign(asserts.SnapBuildType, headers, nil, assertstest.DevKeyID)
+	c.Assert(err, IsNil)
+
+	err = checkDB.Check(snapBuild)
+	c.Check(err, IsNil)
+}
+
+func (s *extKeypairMgrSuite) TestSigningFailure(c *C) {
+	kmgr, err := asserts.NewExternalKeypairManager("keymgr")
+	c.Assert(err, IsNil)
+
+	restore := asserts.MockRunExtKeyMgr(func(prev asserts.ExternalKeyMgrRunner, keyMgrPath string, input []byte, args ...string) ([]byte, error) {
+		if args[0] == "sign" {
+			return nil, fmt.Errorf("boom")
+		}
+		return prev(keyMgrPath, input, args...)
+	})
+	defer restore()
+
+	signDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		KeypairManager: kmgr,
+	})
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id":  "dev1-id",
+		"snap-sha3-384": blobSHA3_384,
+		"snap-id":       "snap-id-1",
+		"grade":         "devel",
+		"snap-size":     "1025",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
+	_, err = signDB.Sign(asserts.SnapBuildType, headers, nil, assertstest.DevKeyID)
+	c.Check(err, ErrorMatches, `cannot sign assertion: cannot sign using external keypair manager: boom`)
+}
@@ -90,3 +90,18 @@ func (fskm *filesystemKeypairManager) Get(keyID string) (PrivateKey, error) {
 	}
 	return privKey, nil
 }
+
+// Delete removes the key pair with the given key id.
+func (fskm *filesystemKeypairManager) Delete(keyID string) error {
+	fskm.mu.Lock()
+	defer fskm.mu.Unlock()
+
+	err := removeEntry(fskm.top, keyID)
+	if os.IsNotExist(err) {
+		return errKeypairNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("cannot remove key pair: %v", err)
+	}
+	return nil
+}
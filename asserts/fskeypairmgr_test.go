@@ -63,3 +63,26 @@ func (fsbss *fsKeypairMgrSuite) TestOpenWorldWritableFail(c *C) {
 	c.Assert(err, ErrorMatches, "assert storage root unexpectedly world-writable: .*")
 	c.Check(bs, IsNil)
 }
+
+func (fsbss *fsKeypairMgrSuite) TestDelete(c *C) {
+	topDir := filepath.Join(c.MkDir(), "asserts-db")
+	keypairMgr, err := asserts.OpenFSKeypairManager(topDir)
+	c.Assert(err, IsNil)
+
+	pk1 := testPrivKey1
+	keyID := pk1.PublicKey().ID()
+	err = keypairMgr.Put(pk1)
+	c.Assert(err, IsNil)
+
+	deleter := keypairMgr.(interface {
+		Delete(keyID string) error
+	})
+	err = deleter.Delete(keyID)
+	c.Assert(err, IsNil)
+
+	_, err = keypairMgr.Get(keyID)
+	c.Check(err, ErrorMatches, "cannot find key pair")
+
+	err = deleter.Delete(keyID)
+	c.Check(err, ErrorMatches, "cannot find key pair")
+}
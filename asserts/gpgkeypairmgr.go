@@ -339,6 +339,21 @@ func (gkm *GPGKeypairManager) Export(name string) ([]byte, error) {
 	return EncodePublicKey(keyInfo.privKey.PublicKey())
 }
 
+// List returns the names and ids of the RSA keys in the GPG keyring.
+func (gkm *GPGKeypairManager) List() (res []ExternalKeyInfo, err error) {
+	collect := func(privk PrivateKey, fpr string, uid string) error {
+		res = append(res, ExternalKeyInfo{
+			Name: uid,
+			ID:   privk.PublicKey().ID(),
+		})
+		return nil
+	}
+	if err := gkm.Walk(collect); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 // Delete removes the named key pair from GnuPG's storage.
 func (gkm *GPGKeypairManager) Delete(name string) error {
 	keyInfo, err := gkm.findByName(name)
@@ -306,6 +306,16 @@ func (gkms *gpgKeypairMgrSuite) TestUseInSigningKeyTooShort(c *C) {
 	c.Check(err, ErrorMatches, `cannot sign assertion: signing needs at least a 4096 bits key, got 2048`)
 }
 
+func (gkms *gpgKeypairMgrSuite) TestList(c *C) {
+	gpgKeypairMgr := gkms.keypairMgr.(*asserts.GPGKeypairManager)
+
+	keys, err := gpgKeypairMgr.List()
+	c.Assert(err, IsNil)
+	c.Check(keys, DeepEquals, []asserts.ExternalKeyInfo{
+		{Name: " (test)", ID: assertstest.DevKeyID},
+	})
+}
+
 func (gkms *gpgKeypairMgrSuite) TestParametersForGenerate(c *C) {
 	gpgKeypairMgr := gkms.keypairMgr.(*asserts.GPGKeypairManager)
 	baseParameters := `
@@ -57,3 +57,15 @@ func (mkm *memoryKeypairManager) Get(keyID string) (PrivateKey, error) {
 	}
 	return privKey, nil
 }
+
+// Delete removes the key pair with the given key id.
+func (mkm *memoryKeypairManager) Delete(keyID string) error {
+	mkm.mu.Lock()
+	defer mkm.mu.Unlock()
+
+	if mkm.pairs[keyID] == nil {
+		return errKeypairNotFound
+	}
+	delete(mkm.pairs, keyID)
+	return nil
+}
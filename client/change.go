@@ -25,6 +25,8 @@ import (
 	"fmt"
 	"net/url"
 	"time"
+
+	"github.com/snapcore/snapd/snap"
 )
 
 // A Change is a modification to the system state.
@@ -162,3 +164,46 @@ func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
 
 	return chgs, err
 }
+
+// A ChangeRecord is what is kept of a change after it is pruned.
+type ChangeRecord struct {
+	ID      string             `json:"id"`
+	Kind    string             `json:"kind"`
+	Summary string             `json:"summary"`
+	Status  string             `json:"status"`
+	User    string             `json:"user,omitempty"`
+	Snaps   []ChangeRecordSnap `json:"snaps,omitempty"`
+	Err     string             `json:"err,omitempty"`
+
+	SpawnTime time.Time `json:"spawn-time"`
+	ReadyTime time.Time `json:"ready-time"`
+}
+
+// A ChangeRecordSnap is a snap affected by a change in the history.
+type ChangeRecordSnap struct {
+	Name        string        `json:"name"`
+	Revision    snap.Revision `json:"revision"`
+	OldRevision snap.Revision `json:"old-revision"`
+}
+
+type ChangeHistoryOptions struct {
+	SnapName string    // if empty, no filtering by name is done
+	Since    time.Time // if zero, no filtering by time is done
+}
+
+// ChangeHistory fetches the records of the changes that were pruned.
+func (client *Client) ChangeHistory(opts *ChangeHistoryOptions) ([]*ChangeRecord, error) {
+	query := url.Values{"select": []string{"history"}}
+	if opts != nil {
+		if opts.SnapName != "" {
+			query.Set("for", opts.SnapName)
+		}
+		if !opts.Since.IsZero() {
+			query.Set("since", opts.Since.Format(time.RFC3339))
+		}
+	}
+
+	var records []*ChangeRecord
+	_, err := client.doSync("GET", "/v2/changes", query, nil, nil, &records)
+	return records, err
+}
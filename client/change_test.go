@@ -23,6 +23,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 	"io/ioutil"
 	"time"
 )
@@ -201,6 +202,45 @@ func (cs *clientSuite) TestClientChangesData(c *check.C) {
 	c.Assert(err, check.Equals, client.ErrNoData)
 }
 
+func (cs *clientSuite) TestClientChangeHistory(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{
+  "id":   "uno",
+  "kind": "refresh-snap",
+  "summary": "...",
+  "status": "Done",
+  "user": "joe",
+  "spawn-time": "2019-05-01T10:00:00Z",
+  "ready-time": "2019-05-01T10:01:00Z",
+  "snaps": [{"name": "foo", "revision": "2", "old-revision": "1"}]
+}]}`
+
+	since := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, t := range []struct {
+		opts  *client.ChangeHistoryOptions
+		query string
+	}{
+		{nil, "select=history"},
+		{&client.ChangeHistoryOptions{SnapName: "foo"}, "for=foo&select=history"},
+		{&client.ChangeHistoryOptions{SnapName: "foo", Since: since}, "for=foo&select=history&since=2019-05-01T00%3A00%3A00Z"},
+	} {
+		records, err := cs.cli.ChangeHistory(t.opts)
+		c.Assert(err, check.IsNil)
+		c.Check(cs.req.Method, check.Equals, "GET")
+		c.Check(cs.req.URL.Path, check.Equals, "/v2/changes")
+		c.Check(cs.req.URL.RawQuery, check.Equals, t.query)
+		c.Check(records, check.DeepEquals, []*client.ChangeRecord{{
+			ID:        "uno",
+			Kind:      "refresh-snap",
+			Summary:   "...",
+			Status:    "Done",
+			User:      "joe",
+			SpawnTime: time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC),
+			ReadyTime: time.Date(2019, 5, 1, 10, 1, 0, 0, time.UTC),
+			Snaps:     []client.ChangeRecordSnap{{Name: "foo", Revision: snap.R(2), OldRevision: snap.R(1)}},
+		}})
+	}
+}
+
 func (cs *clientSuite) TestClientAbort(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": {
   "id":   "uno",
@@ -448,6 +448,8 @@ const (
 
 	ErrorKindSystemRestart = "system-restart"
 	ErrorKindDaemonRestart = "daemon-restart"
+
+	ErrorKindSnapshotPassphraseRequired = "snapshot-passphrase-required"
 )
 
 // IsRetryable returns true if the given error is an error
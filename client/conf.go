@@ -23,7 +23,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // SetConf requests a snap to apply the provided patch to the configuration.
@@ -50,3 +52,72 @@ func (client *Client) Conf(snapName string, keys []string) (configuration map[st
 
 	return configuration, nil
 }
+
+// ConfigOption describes a configuration option declared in the
+// config-schema of a snap.
+type ConfigOption struct {
+	Key         string        `json:"key"`
+	Type        string        `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Minimum     *float64      `json:"minimum,omitempty"`
+	Maximum     *float64      `json:"maximum,omitempty"`
+	Pattern     string        `json:"pattern,omitempty"`
+}
+
+// ConfDescribe asks for the configuration options declared by a snap.
+func (client *Client) ConfDescribe(snapName string) ([]*ConfigOption, error) {
+	query := url.Values{}
+	query.Set("describe", "true")
+
+	var options []*ConfigOption
+	_, err := client.doSync("GET", "/v2/snaps/"+snapName+"/conf", query, nil, nil, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// ConfigKeyChange describes the change of a single configuration option.
+// A nil Old or New value means the option was not set.
+type ConfigKeyChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// ConfigHistoryEntry describes a past change of the configuration of a
+// snap.
+type ConfigHistoryEntry struct {
+	ID       int               `json:"id"`
+	Time     time.Time         `json:"time"`
+	Origin   string            `json:"origin,omitempty"`
+	ChangeID string            `json:"change-id,omitempty"`
+	Changes  []ConfigKeyChange `json:"changes"`
+}
+
+// ConfHistory asks for the recent configuration changes of a snap,
+// oldest first.
+func (client *Client) ConfHistory(snapName string) ([]*ConfigHistoryEntry, error) {
+	query := url.Values{}
+	query.Set("history", "true")
+
+	var history []*ConfigHistoryEntry
+	_, err := client.doSync("GET", "/v2/snaps/"+snapName+"/conf", query, nil, nil, &history)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// RevertConf requests a snap to restore its configuration to how it was
+// right after the given configuration history entry.
+func (client *Client) RevertConf(snapName string, id int) (changeID string, err error) {
+	query := url.Values{}
+	query.Set("revert", strconv.Itoa(id))
+
+	return client.doAsync("PUT", "/v2/snaps/"+snapName+"/conf", query, nil, nil)
+}
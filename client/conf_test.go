@@ -21,8 +21,11 @@ package client_test
 
 import (
 	"encoding/json"
+	"time"
 
 	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
 )
 
 func (cs *clientSuite) TestClientSetConfCallsEndpoint(c *check.C) {
@@ -102,3 +105,70 @@ func (cs *clientSuite) TestClientGetConfMultipleKeys(c *check.C) {
 		"test-key2": "test-value2",
 	})
 }
+
+func (cs *clientSuite) TestClientConfDescribe(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [
+			{"key": "port", "type": "integer", "default": 8080, "minimum": 1024},
+			{"key": "mode", "type": "string", "enum": ["fast", "slow"]}
+		]
+	}`
+	options, err := cs.cli.ConfDescribe("snap-name")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf")
+	c.Check(cs.req.URL.Query().Get("describe"), check.Equals, "true")
+
+	min := float64(1024)
+	c.Check(options, check.DeepEquals, []*client.ConfigOption{
+		{Key: "port", Type: "integer", Default: json.Number("8080"), Minimum: &min},
+		{Key: "mode", Type: "string", Enum: []interface{}{"fast", "slow"}},
+	})
+}
+
+func (cs *clientSuite) TestClientConfHistory(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [
+			{"id": 1, "time": "2019-05-01T10:00:00Z", "origin": "uid 0", "change-id": "42",
+			 "changes": [{"key": "foo", "new": "bar"}]},
+			{"id": 2, "time": "2019-05-01T11:00:00Z",
+			 "changes": [{"key": "foo", "old": "bar", "new": 2}]}
+		]
+	}`
+	history, err := cs.cli.ConfHistory("snap-name")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf")
+	c.Check(cs.req.URL.Query().Get("history"), check.Equals, "true")
+
+	c.Check(history, check.DeepEquals, []*client.ConfigHistoryEntry{{
+		ID:       1,
+		Time:     time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC),
+		Origin:   "uid 0",
+		ChangeID: "42",
+		Changes:  []client.ConfigKeyChange{{Key: "foo", New: "bar"}},
+	}, {
+		ID:      2,
+		Time:    time.Date(2019, 5, 1, 11, 0, 0, 0, time.UTC),
+		Changes: []client.ConfigKeyChange{{Key: "foo", Old: "bar", New: json.Number("2")}},
+	}})
+}
+
+func (cs *clientSuite) TestClientRevertConf(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.RevertConf("snap-name", 3)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "PUT")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf")
+	c.Check(cs.req.URL.Query().Get("revert"), check.Equals, "3")
+}
@@ -36,6 +36,9 @@ type Connection struct {
 	SlotAttrs map[string]interface{} `json:"slot-attrs,omitempty"`
 	// PlugAttrs is the list of attributes of the plug side of the connection.
 	PlugAttrs map[string]interface{} `json:"plug-attrs,omitempty"`
+	// PlugRestrictions are the restrictions of the plug attributes set
+	// by the administrator when connecting.
+	PlugRestrictions map[string]interface{} `json:"plug-restrictions,omitempty"`
 }
 
 // Connections contains information about connections, as well as related plugs
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // Plug represents the potential of a given snap to connect to a slot.
@@ -74,6 +75,22 @@ type InterfaceAction struct {
 	Action string `json:"action"`
 	Plugs  []Plug `json:"plugs,omitempty"`
 	Slots  []Slot `json:"slots,omitempty"`
+	// Restrictions narrow the attributes of the plug when connecting.
+	Restrictions map[string]interface{} `json:"restrictions,omitempty"`
+	// At and Window delay the start of a connect until the given
+	// time or a window of the given schedule, respectively.
+	At     *time.Time `json:"at,omitempty"`
+	Window string     `json:"window,omitempty"`
+}
+
+// ConnectOptions holds the optional parameters of a connect.
+type ConnectOptions struct {
+	// Restrictions narrow the attributes of the plug.
+	Restrictions map[string]interface{}
+	// At and Window delay the start of the connect until the given
+	// time or a window of the given schedule, respectively.
+	At     *time.Time
+	Window string
 }
 
 // InterfaceOptions represents opt-in elements include in responses.
@@ -132,6 +149,29 @@ func (client *Client) Connect(plugSnapName, plugName, slotSnapName, slotName str
 	})
 }
 
+// ConnectWithRestrictions establishes a connection between a plug and
+// a slot with the attributes of the plug narrowed by the given
+// restrictions.
+func (client *Client) ConnectWithRestrictions(plugSnapName, plugName, slotSnapName, slotName string, restrictions map[string]interface{}) (changeID string, err error) {
+	return client.ConnectWithOptions(plugSnapName, plugName, slotSnapName, slotName, &ConnectOptions{Restrictions: restrictions})
+}
+
+// ConnectWithOptions establishes a connection between a plug and a slot
+// according to the given options.
+func (client *Client) ConnectWithOptions(plugSnapName, plugName, slotSnapName, slotName string, opts *ConnectOptions) (changeID string, err error) {
+	action := &InterfaceAction{
+		Action: "connect",
+		Plugs:  []Plug{{Snap: plugSnapName, Name: plugName}},
+		Slots:  []Slot{{Snap: slotSnapName, Name: slotName}},
+	}
+	if opts != nil {
+		action.Restrictions = opts.Restrictions
+		action.At = opts.At
+		action.Window = opts.Window
+	}
+	return client.performInterfaceAction(action)
+}
+
 // Disconnect breaks the connection between a plug and a slot.
 func (client *Client) Disconnect(plugSnapName, plugName, slotSnapName, slotName string) (changeID string, err error) {
 	return client.performInterfaceAction(&InterfaceAction{
@@ -21,6 +21,7 @@ package client_test
 
 import (
 	"encoding/json"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -193,6 +194,43 @@ func (cs *clientSuite) TestClientConnect(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientConnectWithOptions(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	at := time.Date(2019, 10, 1, 22, 0, 0, 0, time.UTC)
+	id, err := cs.cli.ConnectWithOptions("producer", "plug", "consumer", "slot", &client.ConnectOptions{
+		Restrictions: map[string]interface{}{"attr": "value"},
+		At:           &at,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "connect",
+		"plugs": []interface{}{
+			map[string]interface{}{
+				"snap": "producer",
+				"plug": "plug",
+			},
+		},
+		"slots": []interface{}{
+			map[string]interface{}{
+				"snap": "consumer",
+				"slot": "slot",
+			},
+		},
+		"restrictions": map[string]interface{}{"attr": "value"},
+		"at":           "2019-10-01T22:00:00Z",
+	})
+}
+
 func (cs *clientSuite) TestClientDisconnectCallsEndpoint(c *check.C) {
 	cs.cli.Disconnect("producer", "plug", "consumer", "slot")
 	c.Check(cs.req.Method, check.Equals, "POST")
@@ -43,3 +43,19 @@ func (client *Client) Remodel(b []byte) (changeID string, err error) {
 
 	return client.doAsync("POST", "/v2/model", nil, headers, bytes.NewReader(data))
 }
+
+// ReregisterDevice asks to re-register the device with a newly generated
+// device key, replacing the current device key and serial.
+func (client *Client) ReregisterDevice() (changeID string, err error) {
+	data, err := json.Marshal(&debugAction{
+		Action: "reregister-device",
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal re-registration request: %v", err)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	return client.doAsync("POST", "/v2/debug", nil, headers, bytes.NewReader(data))
+}
@@ -53,3 +53,26 @@ func (cs *clientSuite) TestClientRemodel(c *C) {
 	c.Check(jsonBody, HasLen, 1)
 	c.Check(jsonBody["new-model"], Equals, string(remodelJsonData))
 }
+
+func (cs *clientSuite) TestClientReregisterDevice(c *C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": {},
+		"change": "d729"
+	}`
+	id, err := cs.cli.ReregisterDevice()
+	c.Assert(err, IsNil)
+	c.Check(id, Equals, "d729")
+	c.Check(cs.req.Method, Equals, "POST")
+	c.Check(cs.req.URL.Path, Equals, "/v2/debug")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, IsNil)
+	var jsonBody map[string]interface{}
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, IsNil)
+	c.Check(jsonBody, DeepEquals, map[string]interface{}{
+		"action": "reregister-device",
+	})
+}
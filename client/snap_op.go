@@ -27,6 +27,19 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// TransactionType says how the snaps of a multi-snap operation are grouped
+// for the purpose of undoing them when something fails.
+type TransactionType string
+
+const (
+	// TransactionPerSnap undoes only the snap that failed (the default).
+	TransactionPerSnap TransactionType = "per-snap"
+	// TransactionAllSnaps undoes all the snaps when any of them fails.
+	TransactionAllSnaps TransactionType = "all-snaps"
 )
 
 type SnapOptions struct {
@@ -40,7 +53,17 @@ type SnapOptions struct {
 	IgnoreValidation bool   `json:"ignore-validation,omitempty"`
 	Unaliased        bool   `json:"unaliased,omitempty"`
 
+	Transaction TransactionType `json:"transaction,omitempty"`
+
+	// At and Window delay the start of the change until the given
+	// time or a window of the given schedule, respectively.
+	At     *time.Time `json:"at,omitempty"`
+	Window string     `json:"window,omitempty"`
+
 	Users []string `json:"users,omitempty"`
+
+	// Encrypt is what to encrypt a snapshot with, if at all.
+	Encrypt *SnapshotSecret `json:"encrypt,omitempty"`
 }
 
 func writeFieldBool(mw *multipart.Writer, key string, val bool) error {
@@ -81,9 +104,13 @@ type actionData struct {
 }
 
 type multiActionData struct {
-	Action string   `json:"action"`
-	Snaps  []string `json:"snaps,omitempty"`
-	Users  []string `json:"users,omitempty"`
+	Action      string          `json:"action"`
+	Snaps       []string        `json:"snaps,omitempty"`
+	Users       []string        `json:"users,omitempty"`
+	Transaction TransactionType `json:"transaction,omitempty"`
+	At          *time.Time      `json:"at,omitempty"`
+	Window      string          `json:"window,omitempty"`
+	Encrypt     *SnapshotSecret `json:"encrypt,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -111,7 +138,18 @@ func (client *Client) Refresh(name string, options *SnapOptions) (changeID strin
 	return client.doSnapAction("refresh", name, options)
 }
 
+// RefreshMany refreshes the snaps with the given names, or all of them if
+// no names are given. Of the options only Transaction, At and Window are
+// supported.
 func (client *Client) RefreshMany(names []string, options *SnapOptions) (changeID string, err error) {
+	if options != nil && options.Transaction != "" {
+		onlyTransaction := SnapOptions{Transaction: options.Transaction, At: options.At, Window: options.Window}
+		if !reflect.DeepEqual(*options, onlyTransaction) {
+			return "", fmt.Errorf("cannot use options other than transaction for multi-snap refresh")
+		}
+		_, changeID, err = client.doMultiSnapActionFull("refresh", names, options)
+		return changeID, err
+	}
 	return client.doMultiSnapAction("refresh", names, options)
 }
 
@@ -134,8 +172,9 @@ func (client *Client) Switch(name string, options *SnapOptions) (changeID string
 }
 
 // SnapshotMany snapshots many snaps (all, if names empty) for many users (all, if users is empty).
-func (client *Client) SnapshotMany(names []string, users []string) (setID uint64, changeID string, err error) {
-	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, &SnapOptions{Users: users})
+// If secret is not nil, the snapshots are encrypted with it.
+func (client *Client) SnapshotMany(names []string, users []string, secret *SnapshotSecret) (setID uint64, changeID string, err error) {
+	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, &SnapOptions{Users: users, Encrypt: secret})
 	if err != nil {
 		return 0, "", err
 	}
@@ -153,6 +192,8 @@ func (client *Client) SnapshotMany(names []string, users []string) (setID uint64
 
 var ErrDangerousNotApplicable = fmt.Errorf("dangerous option only meaningful when installing from a local file")
 
+var ErrScheduleNotApplicable = fmt.Errorf("cannot schedule installing a snap from a local file")
+
 func (client *Client) doSnapAction(actionName string, snapName string, options *SnapOptions) (changeID string, err error) {
 	if options != nil && options.Dangerous {
 		return "", ErrDangerousNotApplicable
@@ -176,7 +217,10 @@ func (client *Client) doSnapAction(actionName string, snapName string, options *
 
 func (client *Client) doMultiSnapAction(actionName string, snaps []string, options *SnapOptions) (changeID string, err error) {
 	if options != nil {
-		return "", fmt.Errorf("cannot use options for multi-action") // (yet)
+		onlySchedule := SnapOptions{At: options.At, Window: options.Window}
+		if !reflect.DeepEqual(*options, onlySchedule) {
+			return "", fmt.Errorf("cannot use options for multi-action") // (yet)
+		}
 	}
 	_, changeID, err = client.doMultiSnapActionFull(actionName, snaps, options)
 
@@ -190,6 +234,10 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, o
 	}
 	if options != nil {
 		action.Users = options.Users
+		action.Transaction = options.Transaction
+		action.At = options.At
+		action.Window = options.Window
+		action.Encrypt = options.Encrypt
 	}
 	data, err := json.Marshal(&action)
 	if err != nil {
@@ -206,6 +254,9 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, o
 // InstallPath sideloads the snap with the given path under optional provided name,
 // returning the UUID of the background operation upon success.
 func (client *Client) InstallPath(path, name string, options *SnapOptions) (changeID string, err error) {
+	if options != nil && (options.At != nil || options.Window != "") {
+		return "", ErrScheduleNotApplicable
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return "", fmt.Errorf("cannot open: %q", path)
@@ -28,6 +28,7 @@ import (
 	"mime"
 	"mime/multipart"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -72,7 +73,7 @@ func (cs *clientSuite) TestClientMultiOpSnapServerError(c *check.C) {
 		_, err := s.op(cs.cli, nil, nil)
 		c.Check(err, check.ErrorMatches, `.*fail`, check.Commentf(s.action))
 	}
-	_, _, err := cs.cli.SnapshotMany(nil, nil)
+	_, _, err := cs.cli.SnapshotMany(nil, nil, nil)
 	c.Check(err, check.ErrorMatches, `.*fail`)
 }
 
@@ -90,7 +91,7 @@ func (cs *clientSuite) TestClientMultiOpSnapResponseError(c *check.C) {
 		_, err := s.op(cs.cli, nil, nil)
 		c.Check(err, check.ErrorMatches, `.*server error: "potatoes"`, check.Commentf(s.action))
 	}
-	_, _, err := cs.cli.SnapshotMany(nil, nil)
+	_, _, err := cs.cli.SnapshotMany(nil, nil, nil)
 	c.Check(err, check.ErrorMatches, `.*server error: "potatoes"`)
 }
 
@@ -177,6 +178,87 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientRefreshManyTransaction(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RefreshMany([]string{"foo", "bar"}, &client.SnapOptions{Transaction: client.TransactionAllSnaps})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":      "refresh",
+		"snaps":       []interface{}{"foo", "bar"},
+		"transaction": "all-snaps",
+	})
+}
+
+func (cs *clientSuite) TestClientRefreshManyUnsupportedOptions(c *check.C) {
+	_, err := cs.cli.RefreshMany(nil, &client.SnapOptions{Transaction: client.TransactionAllSnaps, Channel: "beta"})
+	c.Check(err, check.ErrorMatches, "cannot use options other than transaction for multi-snap refresh")
+	_, err = cs.cli.RefreshMany(nil, &client.SnapOptions{Channel: "beta"})
+	c.Check(err, check.ErrorMatches, "cannot use options for multi-action")
+}
+
+func (cs *clientSuite) TestClientOpSnapScheduled(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	at := time.Date(2019, 10, 1, 22, 0, 0, 0, time.UTC)
+	id, err := cs.cli.Refresh("foo", &client.SnapOptions{At: &at})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+
+	var jsonBody map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action": "refresh",
+		"at":     "2019-10-01T22:00:00Z",
+	})
+}
+
+func (cs *clientSuite) TestClientMultiOpSnapScheduled(c *check.C) {
+	for _, t := range []struct {
+		op     func([]string, *client.SnapOptions) (string, error)
+		action string
+	}{
+		{cs.cli.InstallMany, "install"},
+		{cs.cli.RefreshMany, "refresh"},
+		{cs.cli.RemoveMany, "remove"},
+	} {
+		cs.rsp = `{
+			"change": "d728",
+			"status-code": 202,
+			"type": "async"
+		}`
+		id, err := t.op([]string{"foo", "bar"}, &client.SnapOptions{Window: "sat,1:00-3:00"})
+		c.Assert(err, check.IsNil)
+		c.Check(id, check.Equals, "d728")
+
+		var jsonBody map[string]interface{}
+		c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+		c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+			"action": t.action,
+			"snaps":  []interface{}{"foo", "bar"},
+			"window": "sat,1:00-3:00",
+		})
+	}
+}
+
+func (cs *clientSuite) TestClientInstallPathScheduled(c *check.C) {
+	_, err := cs.cli.InstallPath("foo.snap", "", &client.SnapOptions{Window: "sat"})
+	c.Check(err, check.Equals, client.ErrScheduleNotApplicable)
+}
+
 func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	// Note body is essentially the same as TestClientMultiOpSnap; keep in sync
 	cs.rsp = `{
@@ -185,7 +267,7 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 		"status-code": 202,
 		"type": "async"
 	}`
-	setID, changeID, err := cs.cli.SnapshotMany([]string{pkgName}, nil)
+	setID, changeID, err := cs.cli.SnapshotMany([]string{pkgName}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
 
@@ -202,6 +284,26 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	c.Check(changeID, check.Equals, "d728")
 }
 
+func (cs *clientSuite) TestClientMultiSnapshotEncrypted(c *check.C) {
+	cs.rsp = `{
+		"result": {"set-id": 42},
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	_, _, err := cs.cli.SnapshotMany([]string{pkgName}, nil, &client.SnapshotSecret{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, check.IsNil)
+	c.Check(jsonBody["action"], check.Equals, "snapshot")
+	c.Check(jsonBody["encrypt"], check.DeepEquals, map[string]interface{}{"passphrase": "sekrit"})
+	c.Check(jsonBody, check.HasLen, 3)
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.rsp = `{
 		"change": "66b3",
@@ -35,14 +35,58 @@ import (
 var (
 	ErrSnapshotSetNotFound   = errors.New("no snapshot set with the given ID")
 	ErrSnapshotSnapsNotFound = errors.New("no snapshot for the requested snaps found in the set with the given ID")
+	// ErrSnapshotPassphraseRequired is returned when operating on a
+	// snapshot set encrypted with a passphrase without giving one.
+	ErrSnapshotPassphraseRequired = errors.New("snapshot set is encrypted with a passphrase, but none was given")
 )
 
+const (
+	// SnapshotSecretPassphrase marks snapshots encrypted with a key
+	// derived from a passphrase given by the user.
+	SnapshotSecretPassphrase = "passphrase"
+	// SnapshotSecretKeyFile marks snapshots encrypted with a key
+	// derived from the contents of the file set in the
+	// snapshots.encryption.key-file system option.
+	SnapshotSecretKeyFile = "key-file"
+)
+
+// A SnapshotSecret is what a snapshot set is encrypted with.
+//
+// When saving, either a passphrase is given or KeyFile is set to use the
+// configured key file. When checking or restoring, only the passphrase is
+// looked at, and only for the sets that were encrypted with one.
+type SnapshotSecret struct {
+	Passphrase string `json:"passphrase,omitempty"`
+	KeyFile    bool   `json:"key-file,omitempty"`
+}
+
+// SnapshotEncryption holds what is needed, besides the secret, to
+// decrypt the archives of an encrypted snapshot.
+type SnapshotEncryption struct {
+	// Cipher the archives are encrypted with (aes-256-gcm)
+	Cipher string `json:"cipher"`
+	// Secret is where the key comes from: a passphrase or a key file
+	Secret string `json:"secret"`
+	// KDF is the key derivation function (scrypt), along with its
+	// parameters: the salt, the CPU/memory cost N, the block size r
+	// and the parallelization p
+	KDF  string `json:"kdf"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	// KeyCheck is used to tell a wrong secret apart from corrupted data
+	KeyCheck string `json:"key-check"`
+}
+
 // A snapshotAction is used to request an operation on a snapshot.
 type snapshotAction struct {
 	SetID  uint64   `json:"set"`
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+
+	Secret *SnapshotSecret `json:"secret,omitempty"`
 }
 
 // A Snapshot is a collection of archives with a simple metadata json file
@@ -61,7 +105,8 @@ type Snapshot struct {
 	Summary  string        `json:"summary"`
 	Version  string        `json:"version"`
 
-	// the snap's configuration at snapshot time
+	// the snap's configuration at snapshot time; for encrypted
+	// snapshots, it is kept encrypted and only available on restore
 	Conf map[string]interface{} `json:"conf,omitempty"`
 
 	// the hash of the archives' data, keyed by archive path
@@ -75,6 +120,14 @@ type Snapshot struct {
 
 	// set if the snapshot was created automatically on snap removal
 	Auto bool `json:"auto,omitempty"`
+	// the snap's hooks that ran successfully right before its data
+	// was collected (i.e. pre-snapshot, if the snap has it)
+	Hooks []string `json:"hooks,omitempty"`
+	// the globs of paths the snap asked to leave out of the snapshot
+	// (e.g. $SNAP_DATA/cache/*)
+	Exclude []string `json:"exclude,omitempty"`
+	// set if the snapshot's archives and configuration are encrypted
+	Encryption *SnapshotEncryption `json:"encryption,omitempty"`
 }
 
 // IsValid checks whether the snapshot is missing information that
@@ -141,26 +194,30 @@ func (client *Client) ForgetSnapshots(setID uint64, snaps []string) (changeID st
 // CheckSnapshots verifies the archive checksums in the given snapshot set.
 //
 // If snaps or users are non-empty, limit to checking only those
-// archives of the snapshot.
-func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+// archives of the snapshot. The secret is needed for sets encrypted
+// with a passphrase.
+func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []string, secret *SnapshotSecret) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
 		SetID:  setID,
 		Action: "check",
 		Snaps:  snaps,
 		Users:  users,
+		Secret: secret,
 	})
 }
 
 // RestoreSnapshots extracts the given snapshot set.
 //
 // If snaps or users are non-empty, limit to checking only those
-// archives of the snapshot.
-func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+// archives of the snapshot. The secret is needed for sets encrypted
+// with a passphrase.
+func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string, secret *SnapshotSecret) (changeID string, err error) {
 	return client.snapshotAction(&snapshotAction{
 		SetID:  setID,
 		Action: "restore",
 		Snaps:  snaps,
 		Users:  users,
+		Secret: secret,
 	})
 }
 
@@ -93,7 +93,7 @@ func (cs *clientSuite) TestClientSnapshotSets(c *check.C) {
 	})
 }
 
-func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, users []string, f func() (string, error)) {
+func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, users []string, secret *client.SnapshotSecret, f func() (string, error)) {
 	cs.rsp = `{
 		"status-code": 202,
 		"type": "async",
@@ -111,6 +111,7 @@ func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, u
 	c.Check(act.Action, check.Equals, action)
 	c.Check(act.Snaps, check.DeepEquals, []string{"asnap", "bsnap"})
 	c.Check(act.Users, check.DeepEquals, users)
+	c.Check(act.Secret, check.DeepEquals, secret)
 
 	c.Check(cs.req.Method, check.Equals, "POST")
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
@@ -118,14 +119,18 @@ func (cs *clientSuite) testClientSnapshotActionFull(c *check.C, action string, u
 }
 
 func (cs *clientSuite) TestClientForgetSnapshot(c *check.C) {
-	cs.testClientSnapshotActionFull(c, "forget", nil, func() (string, error) {
+	cs.testClientSnapshotActionFull(c, "forget", nil, nil, func() (string, error) {
 		return cs.cli.ForgetSnapshots(42, []string{"asnap", "bsnap"})
 	})
 }
 
-func (cs *clientSuite) testClientSnapshotAction(c *check.C, action string, f func(uint64, []string, []string) (string, error)) {
-	cs.testClientSnapshotActionFull(c, action, []string{"auser", "buser"}, func() (string, error) {
-		return f(42, []string{"asnap", "bsnap"}, []string{"auser", "buser"})
+func (cs *clientSuite) testClientSnapshotAction(c *check.C, action string, f func(uint64, []string, []string, *client.SnapshotSecret) (string, error)) {
+	cs.testClientSnapshotActionFull(c, action, []string{"auser", "buser"}, nil, func() (string, error) {
+		return f(42, []string{"asnap", "bsnap"}, []string{"auser", "buser"}, nil)
+	})
+	secret := &client.SnapshotSecret{Passphrase: "sekrit"}
+	cs.testClientSnapshotActionFull(c, action, []string{"auser", "buser"}, secret, func() (string, error) {
+		return f(42, []string{"asnap", "bsnap"}, []string{"auser", "buser"}, secret)
 	})
 }
 
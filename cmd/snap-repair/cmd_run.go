@@ -21,10 +21,12 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 )
@@ -41,7 +43,11 @@ func init() {
 
 }
 
-type cmdRun struct{}
+type cmdRun struct {
+	Local   string   `long:"local" value-name:"<repair-file>" description:"Check the repair from the given file instead of fetching repairs, for testing it (needs --dry-run)"`
+	DryRun  bool     `long:"dry-run" description:"Only verify the local repair and check whether it applies to the device"`
+	Trusted []string `long:"trusted" value-name:"<assertions-file>" description:"Trust the account keys in the given file as well when verifying the local repair"`
+}
 
 var baseURL *url.URL
 
@@ -61,6 +67,17 @@ func init() {
 }
 
 func (c *cmdRun) Execute(args []string) error {
+	// the parser is global and does not reset options it did not
+	// see, make sure they do not leak into a later parsing
+	defer func() { *c = cmdRun{} }()
+
+	if c.Local != "" {
+		return c.runLocal()
+	}
+	if c.DryRun || len(c.Trusted) != 0 {
+		return fmt.Errorf("cannot use --dry-run or --trusted without --local")
+	}
+
 	if err := os.MkdirAll(dirs.SnapRunRepairDir, 0755); err != nil {
 		return err
 	}
@@ -100,3 +117,76 @@ func (c *cmdRun) Execute(args []string) error {
 	}
 	return nil
 }
+
+func readAssertionsFile(fn string) ([]asserts.Assertion, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := asserts.NewDecoderWithTypeMaxBodySize(f, map[*asserts.AssertionType]int{
+		asserts.RepairType: maxRepairScriptSize,
+	})
+	var r []asserts.Assertion
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode assertions from %s: %v", fn, err)
+		}
+		r = append(r, a)
+	}
+	return r, nil
+}
+
+// runLocal verifies and filters the repair in the given file exactly
+// as fetched repairs are.
+//
+// Running the repair script itself is refused: it would run as root
+// against the host, and there is no throwaway root to run it in yet.
+func (c *cmdRun) runLocal() error {
+	if !c.DryRun {
+		return fmt.Errorf("cannot run a local repair without --dry-run: there is no throwaway root to run it in")
+	}
+
+	r, err := readAssertionsFile(c.Local)
+	if err != nil {
+		return err
+	}
+	if len(r) == 0 {
+		return fmt.Errorf("cannot find a repair in %s", c.Local)
+	}
+	repair, ok := r[0].(*asserts.Repair)
+	if !ok {
+		return fmt.Errorf("unexpected first assertion %q in %s", r[0].Type().Name, c.Local)
+	}
+	aux := r[1:]
+
+	var trusted []asserts.Assertion
+	for _, fn := range c.Trusted {
+		as, err := readAssertionsFile(fn)
+		if err != nil {
+			return err
+		}
+		trusted = append(trusted, as...)
+	}
+
+	run := NewRunner()
+	if err := run.LoadDeviceInfo(); err != nil {
+		return err
+	}
+	if err := run.VerifyWithTrusted(repair, aux, trusted); err != nil {
+		return fmt.Errorf("cannot verify repair %s-%d: %v", repair.BrandID(), repair.RepairID(), err)
+	}
+
+	brand, model := run.state.Device.Brand, run.state.Device.Model
+	if !run.Applicable(repair.Headers()) {
+		fmt.Fprintf(Stdout, "repair %s-%d revision %d is not applicable to this device (%s/%s)\n", repair.BrandID(), repair.RepairID(), repair.Revision(), brand, model)
+		return nil
+	}
+	fmt.Fprintf(Stdout, "repair %s-%d revision %d would run on this device (%s/%s)\n", repair.BrandID(), repair.RepairID(), repair.Revision(), brand, model)
+	return nil
+}
@@ -20,8 +20,10 @@
 package main_test
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -31,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
 )
 
 func (r *repairSuite) TestRun(c *C) {
@@ -76,3 +79,97 @@ func (r *repairSuite) TestRunAlreadyLocked(c *C) {
 	err = repair.ParseArgs([]string{"run"})
 	c.Check(err, ErrorMatches, `cannot run, another snap-repair run already executing`)
 }
+
+func (r *repairSuite) writeLocalRepair(c *C, rpr string) string {
+	seqRepairs := r.signSeqRepairs(c, []string{rpr})
+	fn := filepath.Join(c.MkDir(), "repair.assert")
+	err := ioutil.WriteFile(fn, []byte(seqRepairs[0]), 0644)
+	c.Assert(err, IsNil)
+	return fn
+}
+
+func (r *repairSuite) TestRunLocalNeedsDryRun(c *C) {
+	defer release.MockOnClassic(false)()
+
+	r1 := sysdb.InjectTrusted(r.storeSigning.Trusted)
+	defer r1()
+	r2 := repair.MockTrustedRepairRootKeys([]*asserts.AccountKey{r.repairRootAcctKey})
+	defer r2()
+
+	r.freshState(c)
+
+	fn := r.writeLocalRepair(c, makeMockRepair("#!/bin/sh\ntouch $SNAP_REPAIR_RUN_DIR/ran\n"))
+
+	err := repair.ParseArgs([]string{"run", "--local", fn})
+	c.Assert(err, ErrorMatches, `cannot run a local repair without --dry-run: there is no throwaway root to run it in`)
+	c.Check(r.Stdout(), Equals, "")
+
+	// nothing was run or recorded on the device
+	c.Check(filepath.Join(dirs.SnapRepairRunDir, "canonical"), testutil.FileAbsent)
+	c.Check(dirs.SnapRepairStateFile, testutil.FileEquals, freshStateJSON)
+}
+
+func (r *repairSuite) TestRunLocalDryRun(c *C) {
+	defer release.MockOnClassic(false)()
+
+	r1 := sysdb.InjectTrusted(r.storeSigning.Trusted)
+	defer r1()
+	r2 := repair.MockTrustedRepairRootKeys([]*asserts.AccountKey{r.repairRootAcctKey})
+	defer r2()
+
+	r.freshState(c)
+
+	fn := r.writeLocalRepair(c, makeMockRepair("#!/bin/sh\ntouch $SNAP_REPAIR_RUN_DIR/ran\n"))
+
+	err := repair.ParseArgs([]string{"run", "--local", fn, "--dry-run"})
+	c.Assert(err, IsNil)
+	c.Check(r.Stdout(), Equals, "repair canonical-1 revision 0 would run on this device (my-brand/my-model)\n")
+}
+
+func (r *repairSuite) TestRunLocalNotApplicable(c *C) {
+	defer release.MockOnClassic(false)()
+
+	r1 := sysdb.InjectTrusted(r.storeSigning.Trusted)
+	defer r1()
+	r2 := repair.MockTrustedRepairRootKeys([]*asserts.AccountKey{r.repairRootAcctKey})
+	defer r2()
+
+	r.freshState(c)
+
+	rpr := strings.Replace(makeMockRepair("#!/bin/sh\nexit 0\n"), "series:\n  - 16\n", "series:\n  - 16\nmodels:\n  - other-brand/*\n", 1)
+	fn := r.writeLocalRepair(c, rpr)
+
+	err := repair.ParseArgs([]string{"run", "--local", fn, "--dry-run"})
+	c.Assert(err, IsNil)
+	c.Check(r.Stdout(), Equals, "repair canonical-1 revision 0 is not applicable to this device (my-brand/my-model)\n")
+}
+
+func (r *repairSuite) TestRunLocalTrusted(c *C) {
+	defer release.MockOnClassic(false)()
+
+	r1 := sysdb.InjectTrusted(r.storeSigning.Trusted)
+	defer r1()
+	// the repair is not signed with production keys
+	r2 := repair.MockTrustedRepairRootKeys(nil)
+	defer r2()
+
+	r.freshState(c)
+
+	fn := r.writeLocalRepair(c, makeMockRepair("#!/bin/sh\necho done >&$SNAP_REPAIR_STATUS_FD\n"))
+
+	err := repair.ParseArgs([]string{"run", "--local", fn, "--dry-run"})
+	c.Assert(err, ErrorMatches, `cannot verify repair canonical-1: cannot find public key.*`)
+
+	trustedFn := filepath.Join(c.MkDir(), "test-keys.assert")
+	err = ioutil.WriteFile(trustedFn, asserts.Encode(r.repairRootAcctKey), 0644)
+	c.Assert(err, IsNil)
+
+	err = repair.ParseArgs([]string{"run", "--local", fn, "--dry-run", "--trusted", trustedFn})
+	c.Assert(err, IsNil)
+	c.Check(r.Stdout(), Equals, "repair canonical-1 revision 0 would run on this device (my-brand/my-model)\n")
+}
+
+func (r *repairSuite) TestRunDryRunNeedsLocal(c *C) {
+	err := repair.ParseArgs([]string{"run", "--dry-run"})
+	c.Check(err, ErrorMatches, `cannot use --dry-run or --trusted without --local`)
+}
@@ -718,11 +718,25 @@ func (run *Runner) LoadState() error {
 	}
 	// error => initialize from scratch
 	if !os.IsNotExist(err) {
-		logger.Noticef("cannor read repair state: %v", err)
+		logger.Noticef("cannot read repair state: %v", err)
 	}
 	return run.initState()
 }
 
+// LoadDeviceInfo loads only the device information, from the repairs'
+// state if present or otherwise from the seed, without saving anything.
+func (run *Runner) LoadDeviceInfo() error {
+	err := run.readState()
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		logger.Noticef("cannot read repair state: %v", err)
+	}
+	run.state = state{}
+	return run.initDeviceInfo()
+}
+
 // SaveState saves the repairs' state to disk.
 func (run *Runner) SaveState() error {
 	if !run.stateModified {
@@ -969,6 +983,13 @@ var (
 // trusted root keys or by account keys in the stream (passed via aux)
 // directly or indirectly signed by a trusted key.
 func (run *Runner) Verify(repair *asserts.Repair, aux []asserts.Assertion) error {
+	return run.VerifyWithTrusted(repair, aux, nil)
+}
+
+// VerifyWithTrusted verifies the repair as Verify does, additionally
+// trusting the given account keys and accounts, such as test or brand
+// ones when trying out a repair.
+func (run *Runner) VerifyWithTrusted(repair *asserts.Repair, aux []asserts.Assertion, extraTrusted []asserts.Assertion) error {
 	workBS := asserts.NewMemoryBackstore()
 	for _, a := range aux {
 		if a.Type() != asserts.AccountKeyType {
@@ -988,6 +1009,12 @@ func (run *Runner) Verify(repair *asserts.Repair, aux []asserts.Assertion) error
 			trustedBS.Put(asserts.AccountType, t)
 		}
 	}
+	for _, t := range extraTrusted {
+		switch t.Type() {
+		case asserts.AccountKeyType, asserts.AccountType:
+			trustedBS.Put(t.Type(), t)
+		}
+	}
 
 	return verifySignatures(repair, workBS, trustedBS)
 }
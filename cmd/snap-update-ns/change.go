@@ -224,6 +224,31 @@ func (c *Change) ensureSource(as *Assumptions) ([]*Change, error) {
 	return changes, err
 }
 
+// ensureOverlayDirs creates the upper and work directories of overlay mounts.
+//
+// Both directories are in $SNAP_DATA or $SNAP_COMMON and they may be missing
+// the first time the overlay is mounted, for instance right after the snap
+// was installed. Read-only overlays, which only stack lower directories, have
+// neither.
+func (c *Change) ensureOverlayDirs(as *Assumptions) error {
+	if c.Entry.Type != "overlay" {
+		return nil
+	}
+	if _, ok := c.Entry.OptStr("upperdir"); !ok {
+		return nil
+	}
+	for _, opt := range []string{"upperdir", "workdir"} {
+		path, ok := c.Entry.OptStr(opt)
+		if !ok {
+			return fmt.Errorf("cannot mount overlay on %q: missing %s option", c.Entry.Dir, opt)
+		}
+		if err := MkdirAll(path, 0755, 0, 0, as.RestrictionsFor(path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // changePerformImpl is the real implementation of Change.Perform
 func changePerformImpl(c *Change, as *Assumptions) (changes []*Change, err error) {
 	if c.Action == Mount {
@@ -257,6 +282,10 @@ func changePerformImpl(c *Change, as *Assumptions) (changes []*Change, err error
 		if err != nil {
 			return changes, err
 		}
+
+		if err = c.ensureOverlayDirs(as); err != nil {
+			return changes, err
+		}
 	}
 
 	// Perform the underlying mount / unmount / unlink call.
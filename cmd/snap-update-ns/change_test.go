@@ -512,6 +512,68 @@ func (s *changeSuite) TestPerformFilesystemMountWithoutMountPoint(c *C) {
 	})
 }
 
+// Change.Perform wants to mount an overlay and creates the upper and work directories.
+func (s *changeSuite) TestPerformOverlayMount(c *C) {
+	defer s.as.MockUnrestrictedPaths("/")() // Treat test path as unrestricted.
+	s.sys.InsertOsLstatResult(`lstat "/target"`, testutil.FileInfoDir)
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{
+		Name: "overlay", Dir: "/target", Type: "overlay",
+		Options: []string{"lowerdir=/target", "upperdir=/upper", "workdir=/.upper.overlay-work", "x-snapd.origin=layout"}}}
+	synth, err := chg.Perform(s.as)
+	c.Assert(err, IsNil)
+	c.Assert(synth, HasLen, 0)
+	c.Assert(s.sys.RCalls(), testutil.SyscallsEqual, []testutil.CallResultError{
+		{C: `lstat "/target"`, R: testutil.FileInfoDir},
+		{C: `open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY 0`, R: 3},
+		{C: `mkdirat 3 "upper" 0755`},
+		{C: `openat 3 "upper" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY 0`, R: 4},
+		{C: `fchown 4 0 0`},
+		{C: `close 4`},
+		{C: `close 3`},
+		{C: `open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY 0`, R: 3},
+		{C: `mkdirat 3 ".upper.overlay-work" 0755`},
+		{C: `openat 3 ".upper.overlay-work" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY 0`, R: 4},
+		{C: `fchown 4 0 0`},
+		{C: `close 4`},
+		{C: `close 3`},
+		{C: `mount "overlay" "/target" "overlay" 0 "lowerdir=/target,upperdir=/upper,workdir=/.upper.overlay-work"`},
+	})
+}
+
+// Change.Perform wants to mount a read-only overlay without upper and work directories.
+func (s *changeSuite) TestPerformReadOnlyOverlayMount(c *C) {
+	s.sys.InsertOsLstatResult(`lstat "/target"`, testutil.FileInfoDir)
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{
+		Name: "overlay", Dir: "/target", Type: "overlay",
+		Options: []string{"ro", "lowerdir=/one:/two"}}}
+	synth, err := chg.Perform(s.as)
+	c.Assert(err, IsNil)
+	c.Assert(synth, HasLen, 0)
+	c.Assert(s.sys.RCalls(), testutil.SyscallsEqual, []testutil.CallResultError{
+		{C: `lstat "/target"`, R: testutil.FileInfoDir},
+		{C: `mount "overlay" "/target" "overlay" MS_RDONLY "lowerdir=/one:/two"`},
+	})
+}
+
+// Change.Perform wants to mount an overlay but cannot create the upper directory.
+func (s *changeSuite) TestPerformOverlayMountWithErrors(c *C) {
+	defer s.as.MockUnrestrictedPaths("/")() // Treat test path as unrestricted.
+	s.sys.InsertOsLstatResult(`lstat "/target"`, testutil.FileInfoDir)
+	s.sys.InsertFault(`mkdirat 3 "upper" 0755`, errTesting)
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{
+		Name: "overlay", Dir: "/target", Type: "overlay",
+		Options: []string{"lowerdir=/target", "upperdir=/upper", "workdir=/.upper.overlay-work"}}}
+	synth, err := chg.Perform(s.as)
+	c.Assert(err, ErrorMatches, `cannot create directory "/upper": testing`)
+	c.Assert(synth, HasLen, 0)
+	c.Assert(s.sys.RCalls(), testutil.SyscallsEqual, []testutil.CallResultError{
+		{C: `lstat "/target"`, R: testutil.FileInfoDir},
+		{C: `open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY 0`, R: 3},
+		{C: `mkdirat 3 "upper" 0755`, E: errTesting},
+		{C: `close 3`},
+	})
+}
+
 // Change.Perform wants to create a filesystem but the mount point isn't there and cannot be created.
 func (s *changeSuite) TestPerformFilesystemMountWithoutMountPointWithErrors(c *C) {
 	defer s.as.MockUnrestrictedPaths("/")() // Treat test path as unrestricted.
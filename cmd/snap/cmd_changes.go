@@ -20,9 +20,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
@@ -34,6 +37,9 @@ var shortChangesHelp = i18n.G("List system changes")
 var shortTasksHelp = i18n.G("List a change's tasks")
 var longChangesHelp = i18n.G(`
 The changes command displays a summary of system changes performed recently.
+
+With --history it instead displays the record kept of older changes, after
+they were removed from the list of recent changes.
 `)
 var longTasksHelp = i18n.G(`
 The tasks command displays a summary of tasks associated with an individual
@@ -43,6 +49,8 @@ change.
 type cmdChanges struct {
 	clientMixin
 	timeMixin
+	History    bool   `long:"history"`
+	Since      string `long:"since"`
 	Positional struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -55,7 +63,12 @@ type cmdTasks struct {
 
 func init() {
 	addCommand("changes", shortChangesHelp, longChangesHelp,
-		func() flags.Commander { return &cmdChanges{} }, timeDescs, nil)
+		func() flags.Commander { return &cmdChanges{} }, timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"history": i18n.G("Show the history of older changes"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"since": i18n.G("Only show history of changes that were ready at or after the given time (in RFC 3339 format, or YYYY-MM-DD)"),
+		}), nil)
 	addCommand("tasks", shortTasksHelp, longTasksHelp,
 		func() flags.Commander { return &cmdTasks{} },
 		changeIDMixinOptDesc.also(timeDescs),
@@ -96,6 +109,13 @@ func (c *cmdChanges) Execute(args []string) error {
 		return nil
 	}
 
+	if c.Since != "" && !c.History {
+		return errors.New(i18n.G("--since can only be used with --history"))
+	}
+	if c.History {
+		return c.showHistory()
+	}
+
 	opts := client.ChangesOptions{
 		SnapName: c.Positional.Snap,
 		Selector: client.ChangesAll,
@@ -130,6 +150,78 @@ func (c *cmdChanges) Execute(args []string) error {
 	return nil
 }
 
+// parseSince parses either a full RFC 3339 time or a date, which is
+// taken to mean its start in local time.
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(i18n.G("cannot parse time %q: expected RFC 3339 or YYYY-MM-DD"), s)
+	}
+	return t, nil
+}
+
+func fmtRecordSnaps(snaps []client.ChangeRecordSnap) string {
+	if len(snaps) == 0 {
+		return "-"
+	}
+	names := make([]string, len(snaps))
+	for i, sn := range snaps {
+		switch {
+		case sn.Revision.Unset():
+			names[i] = sn.Name
+		case sn.OldRevision.Unset() || sn.OldRevision == sn.Revision:
+			names[i] = fmt.Sprintf("%s:%s", sn.Name, sn.Revision)
+		default:
+			names[i] = fmt.Sprintf("%s:%s->%s", sn.Name, sn.OldRevision, sn.Revision)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *cmdChanges) showHistory() error {
+	opts := client.ChangeHistoryOptions{
+		SnapName: c.Positional.Snap,
+	}
+	if c.Since != "" {
+		since, err := parseSince(c.Since)
+		if err != nil {
+			return err
+		}
+		opts.Since = since
+	}
+
+	records, err := c.client.ChangeHistory(&opts)
+	if err != nil {
+		return err
+	}
+	if err := warnMaintenance(c.client); err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return fmt.Errorf(i18n.G("no changes found in history"))
+	}
+
+	w := tabWriter()
+
+	fmt.Fprintf(w, i18n.G("ID\tStatus\tSpawn\tReady\tUser\tSummary\tSnaps\n"))
+	for _, rec := range records {
+		user := rec.User
+		if user == "" {
+			user = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", rec.ID, rec.Status, c.fmtTime(rec.SpawnTime), c.fmtTime(rec.ReadyTime), user, rec.Summary, fmtRecordSnaps(rec.Snaps))
+	}
+
+	w.Flush()
+	fmt.Fprintln(Stdout)
+
+	return nil
+}
+
 func (c *cmdTasks) Execute([]string) error {
 	chid, err := c.GetChangeID()
 	if err != nil {
@@ -22,7 +22,9 @@ package main_test
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -231,3 +233,86 @@ Doing +2016-04-21T01:02:03Z +2016-04-21T01:02:04Z +some summary \(50.00%\)
 `)
 	c.Check(s.Stderr(), check.Equals, "")
 }
+
+var mockChangeHistoryJSON = `{"type": "sync", "result": [
+  {
+    "id": "1",
+    "kind": "install-snap",
+    "summary": "Install \"foo\" snap",
+    "status": "Done",
+    "user": "joe",
+    "spawn-time": "2019-05-01T10:00:00Z",
+    "ready-time": "2019-05-01T10:01:00Z",
+    "snaps": [{"name": "foo", "revision": "1", "old-revision": "unset"}]
+  },
+  {
+    "id": "2",
+    "kind": "refresh-snap",
+    "summary": "Refresh snaps \"foo\", \"bar\"",
+    "status": "Error",
+    "spawn-time": "2019-05-02T10:00:00Z",
+    "ready-time": "2019-05-02T10:01:00Z",
+    "snaps": [{"name": "foo", "revision": "2", "old-revision": "1"}, {"name": "bar", "revision": "unset", "old-revision": "unset"}]
+  },
+  {
+    "id": "3",
+    "kind": "some-change",
+    "summary": "...",
+    "status": "Done",
+    "spawn-time": "2019-05-03T10:00:00Z",
+    "ready-time": "2019-05-03T10:01:00Z"
+  }
+]}`
+
+func (s *SnapSuite) TestChangesHistory(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes")
+			c.Check(r.URL.Query(), check.DeepEquals, url.Values{
+				"select": []string{"history"},
+				"for":    []string{"foo"},
+				"since":  []string{"2019-05-01T00:00:00Z"},
+			})
+			fmt.Fprintln(w, mockChangeHistoryJSON)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--abs-time", "--history", "--since", "2019-05-01T00:00:00Z", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, ""+
+		"ID   Status  Spawn                 Ready                 User  Summary                     Snaps\n"+
+		"1    Done    2019-05-01T10:00:00Z  2019-05-01T10:01:00Z  joe   Install \"foo\" snap          foo:1\n"+
+		"2    Error   2019-05-02T10:00:00Z  2019-05-02T10:01:00Z  -     Refresh snaps \"foo\", \"bar\"  foo:1->2,bar\n"+
+		"3    Done    2019-05-03T10:00:00Z  2019-05-03T10:01:00Z  -     ...                         -\n"+
+		"\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestChangesHistorySinceDate(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		since, err := time.Parse(time.RFC3339, r.URL.Query().Get("since"))
+		c.Assert(err, check.IsNil)
+		c.Check(since.Equal(time.Date(2019, 5, 1, 0, 0, 0, 0, time.Local)), check.Equals, true)
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--history", "--since", "2019-05-01"})
+	c.Assert(err, check.ErrorMatches, `no changes found in history`)
+}
+
+func (s *SnapSuite) TestChangesHistoryErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--since", "2019-05-01"})
+	c.Assert(err, check.ErrorMatches, `--since can only be used with --history`)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"changes", "--history", "--since", "yesterday"})
+	c.Assert(err, check.ErrorMatches, `cannot parse time "yesterday": expected RFC 3339 or YYYY-MM-DD`)
+}
@@ -20,13 +20,20 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/jsonutil"
 
 	"github.com/jessevdk/go-flags"
 )
 
 type cmdConnect struct {
 	waitMixin
+	scheduleMixin
+	Restrict    []string `long:"restrict"`
 	Positionals struct {
 		PlugSpec connectPlugSpec `required:"yes"`
 		SlotSpec connectSlotSpec
@@ -52,12 +59,29 @@ $ snap connect <snap>:<plug>
 
 Connects the provided plug to the slot in the core snap with a name matching
 the plug name.
+
+$ snap connect --restrict <attribute>=<value> <snap>:<plug> ...
+
+Connects the plug with the given attribute narrowed to the value, which must
+be a subset of what the plug declares. The value is parsed as JSON if
+possible, e.g. --restrict 'read=["$HOME/Music"]'. The read and write
+attributes of personal-files and system-files plugs, and the path of
+serial-port plugs, can be restricted.
+
+$ snap connect --at <time> <snap>:<plug> ...
+$ snap connect --window <schedule> <snap>:<plug> ...
+
+Schedules the connection for the given time or the next window of the given
+schedule instead of connecting right away.
 `)
 
 func init() {
 	addCommand("connect", shortConnectHelp, longConnectHelp, func() flags.Commander {
 		return &cmdConnect{}
-	}, waitDescs, []argDesc{
+	}, waitDescs.also(scheduleDescs).also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"restrict": i18n.G("Narrow a plug attribute for this connection (attribute=value)"),
+	}), []argDesc{
 		// TRANSLATORS: This needs to begin with < and end with >
 		{name: i18n.G("<snap>:<plug>")},
 		// TRANSLATORS: This needs to begin with < and end with >
@@ -77,7 +101,23 @@ func (x *cmdConnect) Execute(args []string) error {
 		x.Positionals.PlugSpec.Snap = ""
 	}
 
-	id, err := x.client.Connect(x.Positionals.PlugSpec.Snap, x.Positionals.PlugSpec.Name, x.Positionals.SlotSpec.Snap, x.Positionals.SlotSpec.Name)
+	opts := &client.ConnectOptions{}
+	if len(x.Restrict) != 0 {
+		restrictions, err := parseRestrictions(x.Restrict)
+		if err != nil {
+			return err
+		}
+		opts.Restrictions = restrictions
+	}
+	at, window, err := x.schedule()
+	if err != nil {
+		return err
+	}
+	opts.At = at
+	opts.Window = window
+	x.scheduled = x.asksForSchedule()
+
+	id, err := x.client.ConnectWithOptions(x.Positionals.PlugSpec.Snap, x.Positionals.PlugSpec.Name, x.Positionals.SlotSpec.Snap, x.Positionals.SlotSpec.Name, opts)
 	if err != nil {
 		return err
 	}
@@ -91,3 +131,21 @@ func (x *cmdConnect) Execute(args []string) error {
 
 	return nil
 }
+
+func parseRestrictions(restricts []string) (map[string]interface{}, error) {
+	restrictions := make(map[string]interface{}, len(restricts))
+	for _, restrict := range restricts {
+		parts := strings.SplitN(restrict, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf(i18n.G("invalid restriction: %q (want attribute=value)"), restrict)
+		}
+		var value interface{}
+		if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
+			// Not valid JSON-- just use the string as-is.
+			restrictions[parts[0]] = parts[1]
+		} else {
+			restrictions[parts[0]] = value
+		}
+	}
+	return restrictions, nil
+}
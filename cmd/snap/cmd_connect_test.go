@@ -53,9 +53,29 @@ $ snap connect <snap>:<plug>
 Connects the provided plug to the slot in the core snap with a name matching
 the plug name.
 
+$ snap connect --restrict <attribute>=<value> <snap>:<plug> ...
+
+Connects the plug with the given attribute narrowed to the value, which must
+be a subset of what the plug declares. The value is parsed as JSON if
+possible, e.g. --restrict 'read=["$HOME/Music"]'. The read and write
+attributes of personal-files and system-files plugs, and the path of
+serial-port plugs, can be restricted.
+
+$ snap connect --at <time> <snap>:<plug> ...
+$ snap connect --window <schedule> <snap>:<plug> ...
+
+Schedules the connection for the given time or the next window of the given
+schedule instead of connecting right away.
+
 [connect command options]
       --no-wait          Do not wait for the operation to finish but just print
                          the change id.
+      --at=              Start the operation at the given time (in RFC 3339
+                         format, or HH:MM for the next such time)
+      --window=          Start the operation in the next window of the given
+                         schedule (same syntax as refresh.timer)
+      --restrict=        Narrow a plug attribute for this connection
+                         (attribute=value)
 `
 	s.testSubCommandHelp(c, "connect", msg)
 }
@@ -93,6 +113,83 @@ func (s *SnapSuite) TestConnectExplicitEverything(c *C) {
 	c.Assert(rest, DeepEquals, []string{})
 }
 
+func (s *SnapSuite) TestConnectWithRestrictions(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "connect",
+				"plugs": []interface{}{
+					map[string]interface{}{
+						"snap": "producer",
+						"plug": "plug",
+					},
+				},
+				"slots": []interface{}{
+					map[string]interface{}{
+						"snap": "",
+						"slot": "",
+					},
+				},
+				"restrictions": map[string]interface{}{
+					"read":  []interface{}{"$HOME/Music"},
+					"write": "$HOME/Music/new",
+				},
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connect", "--restrict", `read=["$HOME/Music"]`, "--restrict", "write=$HOME/Music/new", "producer:plug"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+}
+
+func (s *SnapSuite) TestConnectScheduled(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/interfaces":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "connect",
+				"plugs": []interface{}{
+					map[string]interface{}{
+						"snap": "producer",
+						"plug": "plug",
+					},
+				},
+				"slots": []interface{}{
+					map[string]interface{}{
+						"snap": "consumer",
+						"slot": "slot",
+					},
+				},
+				"window": "sat,1:00-3:00",
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connect", "--window", "sat,1:00-3:00", "producer:plug", "consumer:slot"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Change zzz scheduled, it can be cancelled with \"snap abort zzz\"\n")
+}
+
+func (s *SnapSuite) TestConnectInvalidRestriction(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request %q", r.URL.Path)
+	})
+	_, err := Parser(Client()).ParseArgs([]string{"connect", "--restrict", "read", "producer:plug"})
+	c.Assert(err, ErrorMatches, `invalid restriction: "read" \(want attribute=value\)`)
+}
+
 func (s *SnapSuite) TestConnectExplicitPlugImplicitSlot(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
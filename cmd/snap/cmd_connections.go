@@ -20,6 +20,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -33,6 +34,7 @@ import (
 type cmdConnections struct {
 	clientMixin
 	All         bool `long:"all"`
+	Verbose     bool `long:"verbose"`
 	Positionals struct {
 		Snap installedSnapName
 	} `positional-args:"true"`
@@ -51,6 +53,9 @@ $ snap connections <snap>
 
 Lists connected and unconnected plugs and slots for the specified
 snap.
+
+Pass --verbose to also list the restrictions of plug attributes set
+with snap connect --restrict.
 `)
 
 func init() {
@@ -58,6 +63,8 @@ func init() {
 		return &cmdConnections{}
 	}, map[string]string{
 		"all": i18n.G("Show connected and unconnected plugs and slots"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"verbose": i18n.G("Show the restrictions of connections"),
 	}, []argDesc{{
 		// TRANSLATORS: This needs to be wrapped in <>s.
 		name: "<snap>",
@@ -84,6 +91,7 @@ type connection struct {
 	interfaceDeterminant string
 	manual               bool
 	gadget               bool
+	restrictions         string
 }
 
 func (cn connection) String() string {
@@ -131,6 +139,27 @@ func interfaceDeterminant(conn *client.Connection) string {
 	return fmt.Sprintf("[%v]", value)
 }
 
+// restrictionsString formats the restrictions of a connection as
+// attribute=value pairs, with values in JSON.
+func restrictionsString(restrictions map[string]interface{}) string {
+	if len(restrictions) == 0 {
+		return "-"
+	}
+	attrs := make([]string, 0, len(restrictions))
+	for attr := range restrictions {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	for i, attr := range attrs {
+		value, err := json.Marshal(restrictions[attr])
+		if err != nil {
+			value = []byte(fmt.Sprintf("%v", restrictions[attr]))
+		}
+		attrs[i] = fmt.Sprintf("%s=%s", attr, value)
+	}
+	return strings.Join(attrs, " ")
+}
+
 func (x *cmdConnections) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
@@ -171,11 +200,16 @@ func (x *cmdConnections) Execute(args []string) error {
 			gadget:               conn.Gadget,
 			interfaceName:        conn.Interface,
 			interfaceDeterminant: interfaceDeterminant(&conn),
+			restrictions:         restrictionsString(conn.PlugRestrictions),
 		})
 	}
 
 	w := tabWriter()
-	fmt.Fprintln(w, i18n.G("Interface\tPlug\tSlot\tNotes"))
+	if x.Verbose {
+		fmt.Fprintln(w, i18n.G("Interface\tPlug\tSlot\tNotes\tRestrictions"))
+	} else {
+		fmt.Fprintln(w, i18n.G("Interface\tPlug\tSlot\tNotes"))
+	}
 
 	for _, plug := range connections.Plugs {
 		if len(plug.Connections) == 0 && x.All {
@@ -204,7 +238,15 @@ func (x *cmdConnections) Execute(args []string) error {
 	sort.Sort(byConnectionData(annotatedConns))
 
 	for _, note := range annotatedConns {
-		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", note.interfaceName, note.interfaceDeterminant, note.plug, note.slot, note)
+		if x.Verbose {
+			restrictions := note.restrictions
+			if restrictions == "" {
+				restrictions = "-"
+			}
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", note.interfaceName, note.interfaceDeterminant, note.plug, note.slot, note, restrictions)
+		} else {
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\n", note.interfaceName, note.interfaceDeterminant, note.plug, note.slot, note)
+		}
 	}
 
 	if len(annotatedConns) > 0 {
@@ -463,6 +463,62 @@ func (s *SnapSuite) TestConnectionsFiltering(c *C) {
 	c.Assert(rest, DeepEquals, []string{"--all"})
 }
 
+func (s *SnapSuite) TestConnectionsVerbose(c *C) {
+	result := client.Connections{
+		Established: []client.Connection{
+			{
+				Plug:      client.PlugRef{Snap: "music-player", Name: "music"},
+				Slot:      client.SlotRef{Snap: "core", Name: "personal-files"},
+				Interface: "personal-files",
+				Manual:    true,
+				PlugRestrictions: map[string]interface{}{
+					"write": []interface{}{"$HOME/Music/new"},
+					"read":  []interface{}{"$HOME/Music"},
+				},
+			}, {
+				Plug:      client.PlugRef{Snap: "music-player", Name: "network"},
+				Slot:      client.SlotRef{Snap: "core", Name: "network"},
+				Interface: "network",
+			},
+		},
+		Plugs: []client.Plug{
+			{
+				Snap:      "music-player",
+				Name:      "music",
+				Interface: "personal-files",
+				Connections: []client.SlotRef{
+					{Snap: "core", Name: "personal-files"},
+				},
+			}, {
+				Snap:      "music-player",
+				Name:      "network",
+				Interface: "network",
+				Connections: []client.SlotRef{
+					{Snap: "core", Name: "network"},
+				},
+			},
+		},
+	}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": result,
+		})
+	})
+
+	rest, err := Parser(Client()).ParseArgs([]string{"connections", "--verbose"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"Interface       Plug                  Slot             Notes   Restrictions\n" +
+		"network         music-player:network  :network         -       -\n" +
+		"personal-files  music-player:music    :personal-files  manual  read=[\"$HOME/Music\"] write=[\"$HOME/Music/new\"]\n"
+	c.Check(s.Stdout(), Equals, expectedStdout)
+	c.Check(s.Stderr(), Equals, "")
+}
+
 func (s *SnapSuite) TestConnectionsSorting(c *C) {
 	result := client.Connections{
 		Established: []client.Connection{
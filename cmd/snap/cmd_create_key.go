@@ -67,6 +67,14 @@ func (x *cmdCreateKey) Execute(args []string) error {
 		return fmt.Errorf(i18n.G("key name %q is not valid; only ASCII letters, digits, and hyphens are allowed"), keyName)
 	}
 
+	manager, err := getKeypairManager()
+	if err != nil {
+		return err
+	}
+	if _, ok := manager.(*asserts.ExternalKeypairManager); ok {
+		return fmt.Errorf(i18n.G("cannot create keys with an external keypair manager, create them directly with it"))
+	}
+
 	fmt.Fprint(Stdout, i18n.G("Passphrase: "))
 	passphrase, err := terminal.ReadPassword(0)
 	fmt.Fprint(Stdout, "\n")
@@ -86,6 +94,5 @@ func (x *cmdCreateKey) Execute(args []string) error {
 		return err
 	}
 
-	manager := asserts.NewGPGKeypairManager()
 	return manager.Generate(string(passphrase), keyName)
 }
@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdConnectionPolicy struct {
+	clientMixin
+	Positionals struct {
+		Plug string `required:"yes"`
+		Slot string `required:"yes"`
+	} `positional-args:"true" required:"true"`
+}
+
+func init() {
+	addDebugCommand("connection-policy",
+		i18n.G("Show which policy rule decides a connection"),
+		i18n.G(`
+The connection-policy command shows whether the given plug can be connected
+and auto-connected to the given slot, and which rule of the device-policy,
+of the snap-declarations or of the base-declaration decided it.
+`),
+		func() flags.Commander {
+			return &cmdConnectionPolicy{}
+		}, nil, []argDesc{
+			// TRANSLATORS: This needs to begin with < and end with >
+			{name: i18n.G("<snap>:<plug>")},
+			// TRANSLATORS: This needs to begin with < and end with >
+			{name: i18n.G("<snap>:<slot>")},
+		})
+}
+
+type policyDecision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule"`
+	Error   string `json:"error"`
+}
+
+func printPolicyDecision(w io.Writer, kind string, d *policyDecision) {
+	verdict := i18n.G("allowed")
+	if !d.Allowed {
+		verdict = i18n.G("denied")
+	}
+	if d.Rule == "" {
+		fmt.Fprintf(w, "%s:\t%s (%s)\n", kind, verdict, i18n.G("no rule applies"))
+	} else {
+		fmt.Fprintf(w, "%s:\t%s by %s\n", kind, verdict, d.Rule)
+	}
+	if d.Error != "" {
+		fmt.Fprintf(w, "  reason:\t%s\n", d.Error)
+	}
+}
+
+func (x *cmdConnectionPolicy) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var resp struct {
+		Connection     policyDecision `json:"connection"`
+		AutoConnection policyDecision `json:"auto-connection"`
+	}
+	params := map[string]string{
+		"plug": x.Positionals.Plug,
+		"slot": x.Positionals.Slot,
+	}
+	if err := x.client.DebugGet("connection-policy", &resp, params); err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	printPolicyDecision(w, "connection", &resp.Connection)
+	printPolicyDecision(w, "auto-connection", &resp.AutoConnection)
+	return nil
+}
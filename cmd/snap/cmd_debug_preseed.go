@@ -0,0 +1,66 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/image"
+)
+
+type cmdPreseed struct {
+	Reset bool `long:"reset"`
+
+	Positionals struct {
+		RootDir string `positional-arg-name:"<root-dir>"`
+	} `positional-args:"true" required:"true"`
+}
+
+var imageResetPreseededChroot = image.ResetPreseededChroot
+
+func init() {
+	addDebugCommand("preseed",
+		i18n.G("Preseed a prepared classic image"),
+		i18n.G(`
+The preseed command runs snapd in a chroot of the given prepared classic
+image to seed it as far as possible ahead of its first boot.
+
+With --reset the state and artifacts of a previous preseeding are
+removed instead, so that the image can be preseeded again.
+`),
+		func() flags.Commander {
+			return &cmdPreseed{}
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"reset": i18n.G("Reset the preseeded state of the image"),
+		}, nil)
+}
+
+func (x *cmdPreseed) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if x.Reset {
+		return imageResetPreseededChroot(x.Positionals.RootDir)
+	}
+	return imagePreseed(x.Positionals.RootDir)
+}
@@ -0,0 +1,425 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type cmdDebugState struct {
+	timeMixin
+
+	Changes     bool   `long:"changes"`
+	ChangeID    string `long:"change"`
+	TaskID      string `long:"task"`
+	Snaps       bool   `long:"snaps"`
+	Connections bool   `long:"connections"`
+	Config      bool   `long:"config"`
+	Dot         bool   `long:"dot"`
+
+	Positional struct {
+		StateFilePath string `positional-arg-name:"<state-file>" required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortDebugStateHelp = i18n.G("Inspect a snapd state file")
+var longDebugStateHelp = i18n.G(`
+The state command loads the given snapd state file, along with its journal
+if there is one next to it, without needing a running snapd. By default it
+lists the changes in the state.
+
+With --change it lists the tasks of the given change together with their
+lanes and the tasks they wait for, or with --dot prints the task graph of
+the change in Graphviz dot format.
+`)
+
+func init() {
+	addDebugCommand("state", shortDebugStateHelp, longDebugStateHelp, func() flags.Commander {
+		return &cmdDebugState{}
+	}, timeDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"changes": i18n.G("List all changes"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"change": i18n.G("List the tasks of the given change"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"task": i18n.G("Show the data and log of the given task"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"snaps": i18n.G("List the snaps"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"connections": i18n.G("List the interface connections"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"config": i18n.G("Show the configuration of the snaps"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"dot": i18n.G("Print the task graph of the change given with --change in dot format"),
+	}), []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<state-file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Path to the state file"),
+	}})
+}
+
+// loadState reads the state file at path, replaying its journal if there
+// is one.
+func loadState(path string) (*state.State, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	journal, err := os.Open(path + ".journal")
+	if err == nil {
+		defer journal.Close()
+		return state.ReadJournaledState(nil, f, journal)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return state.ReadState(nil, f)
+}
+
+func (x *cmdDebugState) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	n := 0
+	for _, selected := range []bool{x.Changes, x.ChangeID != "", x.TaskID != "", x.Snaps, x.Connections, x.Config} {
+		if selected {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New(i18n.G("cannot use --changes, --change, --task, --snaps, --connections and --config together"))
+	}
+	if x.Dot && x.ChangeID == "" {
+		return errors.New(i18n.G("--dot can only be used with --change"))
+	}
+
+	st, err := loadState(x.Positional.StateFilePath)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot load state: %v"), err)
+	}
+	st.Lock()
+	defer st.Unlock()
+
+	switch {
+	case x.ChangeID != "" && x.Dot:
+		return x.writeDotOutput(st, x.ChangeID)
+	case x.ChangeID != "":
+		return x.showTasks(st, x.ChangeID)
+	case x.TaskID != "":
+		return x.showTask(st, x.TaskID)
+	case x.Snaps:
+		return x.showSnaps(st)
+	case x.Connections:
+		return x.showConnections(st)
+	case x.Config:
+		return x.showConfig(st)
+	default:
+		return x.showChanges(st)
+	}
+}
+
+func (x *cmdDebugState) fmtReadyTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return x.fmtTime(t)
+}
+
+// byNumericID sorts ids numerically, as the ids of changes and tasks are.
+type byNumericID []string
+
+func (ids byNumericID) Len() int      { return len(ids) }
+func (ids byNumericID) Swap(i, j int) { ids[i], ids[j] = ids[j], ids[i] }
+func (ids byNumericID) Less(i, j int) bool {
+	a, errA := strconv.Atoi(ids[i])
+	b, errB := strconv.Atoi(ids[j])
+	if errA != nil || errB != nil {
+		return ids[i] < ids[j]
+	}
+	return a < b
+}
+
+func sortedTasks(tasks []*state.Task) []*state.Task {
+	byID := make(map[string]*state.Task, len(tasks))
+	ids := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID()] = t
+		ids = append(ids, t.ID())
+	}
+	sort.Sort(byNumericID(ids))
+	sorted := make([]*state.Task, len(ids))
+	for i, id := range ids {
+		sorted[i] = byID[id]
+	}
+	return sorted
+}
+
+func taskIDs(tasks []*state.Task) string {
+	if len(tasks) == 0 {
+		return "-"
+	}
+	ids := make([]string, len(tasks))
+	for i, t := range sortedTasks(tasks) {
+		ids[i] = t.ID()
+	}
+	return strings.Join(ids, ",")
+}
+
+func taskLanes(t *state.Task) string {
+	lanes := t.Lanes()
+	if len(lanes) == 0 {
+		return "0"
+	}
+	strs := make([]string, len(lanes))
+	for i, lane := range lanes {
+		strs[i] = strconv.Itoa(lane)
+	}
+	return strings.Join(strs, ",")
+}
+
+func (x *cmdDebugState) showChanges(st *state.State) error {
+	changes := st.Changes()
+	ids := make([]string, 0, len(changes))
+	byID := make(map[string]*state.Change, len(changes))
+	for _, chg := range changes {
+		ids = append(ids, chg.ID())
+		byID[chg.ID()] = chg
+	}
+	sort.Sort(byNumericID(ids))
+
+	w := tabWriter()
+	fmt.Fprintf(w, i18n.G("ID\tStatus\tSpawn\tReady\tKind\tSummary\n"))
+	for _, id := range ids {
+		chg := byID[id]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", chg.ID(), chg.Status(), x.fmtTime(chg.SpawnTime()), x.fmtReadyTime(chg.ReadyTime()), chg.Kind(), chg.Summary())
+	}
+	w.Flush()
+	return nil
+}
+
+func (x *cmdDebugState) showTasks(st *state.State, changeID string) error {
+	chg := st.Change(changeID)
+	if chg == nil {
+		return fmt.Errorf(i18n.G("no such change: %s"), changeID)
+	}
+
+	w := tabWriter()
+	fmt.Fprintf(w, i18n.G("Lanes\tID\tStatus\tSpawn\tReady\tKind\tWaits\tHalts\tSummary\n"))
+	for _, t := range sortedTasks(chg.Tasks()) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", taskLanes(t), t.ID(), t.Status(), x.fmtTime(t.SpawnTime()), x.fmtReadyTime(t.ReadyTime()), t.Kind(), taskIDs(t.WaitTasks()), taskIDs(t.HaltTasks()), t.Summary())
+	}
+	w.Flush()
+	return nil
+}
+
+func (x *cmdDebugState) showTask(st *state.State, taskID string) error {
+	t := st.Task(taskID)
+	if t == nil {
+		return fmt.Errorf(i18n.G("no such task: %s"), taskID)
+	}
+
+	// the data of a task can only be listed through its serialization
+	raw, err := t.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var serialized struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &serialized); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "id: %s\n", t.ID())
+	fmt.Fprintf(Stdout, "kind: %s\n", t.Kind())
+	fmt.Fprintf(Stdout, "summary: %s\n", t.Summary())
+	fmt.Fprintf(Stdout, "status: %s\n", t.Status())
+	if chg := t.Change(); chg != nil {
+		fmt.Fprintf(Stdout, "change: %s\n", chg.ID())
+	}
+	fmt.Fprintf(Stdout, "lanes: %s\n", taskLanes(t))
+	fmt.Fprintf(Stdout, "waits: %s\n", taskIDs(t.WaitTasks()))
+	fmt.Fprintf(Stdout, "halts: %s\n", taskIDs(t.HaltTasks()))
+	if at := t.AtTime(); !at.IsZero() {
+		fmt.Fprintf(Stdout, "at: %s\n", x.fmtTime(at))
+	}
+
+	if len(serialized.Data) > 0 {
+		keys := make([]string, 0, len(serialized.Data))
+		for k := range serialized.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(Stdout, "data:\n")
+		for _, k := range keys {
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, serialized.Data[k], "    ", "  "); err != nil {
+				return err
+			}
+			fmt.Fprintf(Stdout, "  %s: %s\n", k, buf.String())
+		}
+	}
+
+	if log := t.Log(); len(log) > 0 {
+		fmt.Fprintf(Stdout, "log:\n")
+		for _, line := range log {
+			fmt.Fprintf(Stdout, "  %s\n", strings.Replace(line, "\n", "\n  ", -1))
+		}
+	}
+	return nil
+}
+
+func (x *cmdDebugState) writeDotOutput(st *state.State, changeID string) error {
+	chg := st.Change(changeID)
+	if chg == nil {
+		return fmt.Errorf(i18n.G("no such change: %s"), changeID)
+	}
+
+	fmt.Fprintf(Stdout, "digraph %q {\n", chg.Kind()+" "+chg.ID())
+	for _, t := range sortedTasks(chg.Tasks()) {
+		fmt.Fprintf(Stdout, "\t%q [label=%q];\n", t.ID(), fmt.Sprintf("%s: %s\n%s", t.ID(), t.Kind(), t.Status()))
+	}
+	for _, t := range sortedTasks(chg.Tasks()) {
+		for _, wt := range sortedTasks(t.WaitTasks()) {
+			fmt.Fprintf(Stdout, "\t%q -> %q;\n", wt.ID(), t.ID())
+		}
+	}
+	fmt.Fprintf(Stdout, "}\n")
+	return nil
+}
+
+// debugSnapState holds the parts of the state of a snap that are shown.
+type debugSnapState struct {
+	Type     string           `json:"type"`
+	Sequence []*snap.SideInfo `json:"sequence"`
+	Active   bool             `json:"active,omitempty"`
+	Current  snap.Revision    `json:"current"`
+	Channel  string           `json:"channel,omitempty"`
+}
+
+func (x *cmdDebugState) showSnaps(st *state.State) error {
+	var snaps map[string]*debugSnapState
+	if err := st.Get("snaps", &snaps); err != nil && err != state.ErrNoState {
+		return err
+	}
+	names := make([]string, 0, len(snaps))
+	for name := range snaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabWriter()
+	fmt.Fprintf(w, i18n.G("Name\tType\tRev\tChannel\tActive\tRevisions\n"))
+	for _, name := range names {
+		snapst := snaps[name]
+		revs := make([]string, len(snapst.Sequence))
+		for i, si := range snapst.Sequence {
+			revs[i] = si.Revision.String()
+		}
+		channel := snapst.Channel
+		if channel == "" {
+			channel = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\n", name, snapst.Type, snapst.Current, channel, snapst.Active, strings.Join(revs, ","))
+	}
+	w.Flush()
+	return nil
+}
+
+// debugConnState holds the parts of the state of a connection that are
+// shown.
+type debugConnState struct {
+	Interface string `json:"interface,omitempty"`
+	Auto      bool   `json:"auto,omitempty"`
+	ByGadget  bool   `json:"by-gadget,omitempty"`
+	Undesired bool   `json:"undesired,omitempty"`
+}
+
+func (x *cmdDebugState) showConnections(st *state.State) error {
+	var conns map[string]*debugConnState
+	if err := st.Get("conns", &conns); err != nil && err != state.ErrNoState {
+		return err
+	}
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	w := tabWriter()
+	fmt.Fprintf(w, i18n.G("Interface\tPlug\tSlot\tNotes\n"))
+	for _, id := range ids {
+		conn := conns[id]
+		// connection ids are "<plug snap>:<plug> <slot snap>:<slot>"
+		refs := strings.SplitN(id, " ", 2)
+		if len(refs) != 2 {
+			continue
+		}
+		var notes []string
+		if conn.Auto {
+			notes = append(notes, "auto")
+		} else {
+			notes = append(notes, "manual")
+		}
+		if conn.ByGadget {
+			notes = append(notes, "gadget")
+		}
+		if conn.Undesired {
+			notes = append(notes, "undesired")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", conn.Interface, refs[0], refs[1], strings.Join(notes, ","))
+	}
+	w.Flush()
+	return nil
+}
+
+func (x *cmdDebugState) showConfig(st *state.State) error {
+	var config map[string]map[string]*json.RawMessage
+	if err := st.Get("config", &config); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if len(config) == 0 {
+		return nil
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "%s\n", out)
+	return nil
+}
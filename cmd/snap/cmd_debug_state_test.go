@@ -0,0 +1,219 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type debugStateSuite struct {
+	BaseSnapSuite
+	restoreHuman func()
+}
+
+var _ = Suite(&debugStateSuite{})
+
+func (s *debugStateSuite) SetUpTest(c *C) {
+	s.BaseSnapSuite.SetUpTest(c)
+	s.restoreHuman = main.MockTimeutilHuman(func(time.Time) string { return "today" })
+}
+
+func (s *debugStateSuite) TearDownTest(c *C) {
+	s.restoreHuman()
+	s.BaseSnapSuite.TearDownTest(c)
+}
+
+// writeState writes a state with an install change stuck in the middle
+// of its tasks, and returns the path to it.
+func (s *debugStateSuite) writeState(c *C) string {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install-snap", `Install "foo" snap`)
+	lane := st.NewLane()
+	t1 := st.NewTask("download-snap", `Download snap "foo"`)
+	t1.Set("snap-setup", map[string]interface{}{"channel": "stable"})
+	t1.SetStatus(state.DoneStatus)
+	t2 := st.NewTask("link-snap", `Make snap "foo" available`)
+	t2.WaitFor(t1)
+	t2.Logf("waiting for something")
+	t3 := st.NewTask("run-hook", `Run install hook of "foo" snap`)
+	t3.WaitFor(t2)
+	for _, t := range []*state.Task{t1, t2, t3} {
+		t.JoinLane(lane)
+		chg.AddTask(t)
+	}
+	st.NewChange("refresh-snap", `Refresh "bar" snap`)
+
+	st.Set("snaps", map[string]interface{}{
+		"foo": map[string]interface{}{
+			"type":     "app",
+			"sequence": []interface{}{map[string]interface{}{"name": "foo", "revision": "1"}, map[string]interface{}{"name": "foo", "revision": "2"}},
+			"active":   true,
+			"current":  "2",
+			"channel":  "stable",
+		},
+	})
+	st.Set("conns", map[string]interface{}{
+		"foo:home core:home":     map[string]interface{}{"interface": "home", "auto": true},
+		"foo:camera core:camera": map[string]interface{}{"interface": "camera"},
+	})
+	st.Set("config", map[string]interface{}{
+		"foo": map[string]interface{}{"key": "value"},
+	})
+
+	data, err := json.Marshal(st)
+	c.Assert(err, IsNil)
+	path := filepath.Join(c.MkDir(), "state.json")
+	c.Assert(ioutil.WriteFile(path, data, 0600), IsNil)
+	return path
+}
+
+func (s *debugStateSuite) TestDebugChanges(c *C) {
+	path := s.writeState(c)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", path})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"ID   Status  Spawn  Ready  Kind          Summary\n"+
+		"1    Do      today  -      install-snap  Install \"foo\" snap\n"+
+		"2    Hold    today  -      refresh-snap  Refresh \"bar\" snap\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *debugStateSuite) TestDebugTasks(c *C) {
+	path := s.writeState(c)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--change=1", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Lanes  ID   Status  Spawn  Ready  Kind           Waits  Halts  Summary\n"+
+		"1      1    Done    today  today  download-snap  -      2      Download snap \"foo\"\n"+
+		"1      2    Do      today  -      link-snap      1      3      Make snap \"foo\" available\n"+
+		"1      3    Do      today  -      run-hook       2      -      Run install hook of \"foo\" snap\n")
+}
+
+func (s *debugStateSuite) TestDebugTask(c *C) {
+	path := s.writeState(c)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--task=1", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"id: 1\n"+
+		"kind: download-snap\n"+
+		"summary: Download snap \"foo\"\n"+
+		"status: Done\n"+
+		"change: 1\n"+
+		"lanes: 1\n"+
+		"waits: -\n"+
+		"halts: 2\n"+
+		"data:\n"+
+		"  snap-setup: {\n"+
+		"      \"channel\": \"stable\"\n"+
+		"    }\n")
+
+	s.ResetStdStreams()
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--task=2", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `(?s).*log:\n  .* INFO waiting for something\n$`)
+}
+
+func (s *debugStateSuite) TestDebugDot(c *C) {
+	path := s.writeState(c)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--change=1", "--dot", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"digraph \"install-snap 1\" {\n"+
+		"\t\"1\" [label=\"1: download-snap\\nDone\"];\n"+
+		"\t\"2\" [label=\"2: link-snap\\nDo\"];\n"+
+		"\t\"3\" [label=\"3: run-hook\\nDo\"];\n"+
+		"\t\"1\" -> \"2\";\n"+
+		"\t\"2\" -> \"3\";\n"+
+		"}\n")
+}
+
+func (s *debugStateSuite) TestDebugSnapsConnectionsConfig(c *C) {
+	path := s.writeState(c)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--snaps", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Name  Type  Rev  Channel  Active  Revisions\n"+
+		"foo   app   2    stable   true    1,2\n")
+
+	s.ResetStdStreams()
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--connections", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"Interface  Plug        Slot         Notes\n"+
+		"camera     foo:camera  core:camera  manual\n"+
+		"home       foo:home    core:home    auto\n")
+
+	s.ResetStdStreams()
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--config", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, ""+
+		"{\n"+
+		"  \"foo\": {\n"+
+		"    \"key\": \"value\"\n"+
+		"  }\n"+
+		"}\n")
+}
+
+func (s *debugStateSuite) TestDebugStateJournal(c *C) {
+	path := filepath.Join(c.MkDir(), "state.json")
+	err := ioutil.WriteFile(path, []byte(`{"data":{},"changes":{},"tasks":{},"last-change-id":0,"last-task-id":0,"last-lane-id":0,"journal-seq":1}`), 0600)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(path+".journal", []byte(`{"seq":2,"data":{"config":{"foo":{"key":"journaled"}}},"last-change-id":0,"last-task-id":0,"last-lane-id":0}`+"\n"), 0600)
+	c.Assert(err, IsNil)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--config", path})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `(?s).*"key": "journaled".*`)
+}
+
+func (s *debugStateSuite) TestDebugStateErrors(c *C) {
+	path := s.writeState(c)
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--changes", "--snaps", path}, `cannot use --changes, --change, --task, --snaps, --connections and --config together`},
+		{[]string{"--dot", path}, `--dot can only be used with --change`},
+		{[]string{"--change=9", path}, `no such change: 9`},
+		{[]string{"--task=9", path}, `no such task: 9`},
+		{[]string{filepath.Join(c.MkDir(), "missing")}, `cannot load state: open .*: no such file or directory`},
+	} {
+		_, err := main.Parser(main.Client()).ParseArgs(append([]string{"debug", "state"}, t.args...))
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
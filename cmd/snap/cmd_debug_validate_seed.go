@@ -20,12 +20,19 @@
 package main
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/snap"
 )
 
 type cmdValidateSeed struct {
+	Manifest bool `long:"manifest"`
+
 	Positionals struct {
 		SeedYamlPath string `positional-arg-name:"<seed-yaml-path>"`
 	} `positional-args:"true"`
@@ -37,7 +44,9 @@ func init() {
 		"(internal) validate seed.yaml",
 		func() flags.Commander {
 			return &cmdValidateSeed{}
-		}, nil, nil)
+		}, map[string]string{
+			"manifest": "Check the seed against the manifest.yaml next to seed.yaml",
+		}, nil)
 	cmd.hidden = true
 }
 
@@ -46,8 +55,60 @@ func (x *cmdValidateSeed) Execute(args []string) error {
 		return ErrExtraArgs
 	}
 
-	if _, err := snap.ReadSeedYaml(x.Positionals.SeedYamlPath); err != nil {
+	seed, err := snap.ReadSeedYaml(x.Positionals.SeedYamlPath)
+	if err != nil {
+		return err
+	}
+	if x.Manifest {
+		return validateSeedManifest(filepath.Dir(x.Positionals.SeedYamlPath), seed)
+	}
+	return nil
+}
+
+// validateSeedManifest checks that the manifest in seedDir describes
+// exactly the snaps in the seed and that their licenses are valid.
+func validateSeedManifest(seedDir string, seed *snap.Seed) error {
+	manifest, err := snap.ReadSeedManifest(filepath.Join(seedDir, "manifest.yaml"))
+	if err != nil {
 		return err
 	}
+
+	var problems []string
+	inManifest := make(map[string]*snap.SeedManifestSnap, len(manifest.Snaps))
+	for _, sn := range manifest.Snaps {
+		inManifest[sn.Name] = sn
+	}
+	for _, sn := range seed.Snaps {
+		msn := inManifest[sn.Name]
+		if msn == nil {
+			problems = append(problems, fmt.Sprintf("snap %q is missing from the manifest", sn.Name))
+			continue
+		}
+		delete(inManifest, sn.Name)
+		if msn.File != sn.File {
+			problems = append(problems, fmt.Sprintf("snap %q has file %q in the manifest, expected %q", sn.Name, msn.File, sn.File))
+			continue
+		}
+		sha3_384, _, err := asserts.SnapFileSHA3_384(filepath.Join(seedDir, "snaps", sn.File))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("cannot check snap %q: %v", sn.Name, err))
+			continue
+		}
+		if msn.SHA3_384 != sha3_384 {
+			problems = append(problems, fmt.Sprintf("snap %q does not match its sha3-384 in the manifest", sn.Name))
+		}
+		if msn.LicenseError != "" {
+			problems = append(problems, fmt.Sprintf("snap %q has a license that is not a valid SPDX expression: %s", sn.Name, msn.LicenseError))
+		}
+	}
+	for _, sn := range manifest.Snaps {
+		if inManifest[sn.Name] != nil {
+			problems = append(problems, fmt.Sprintf("snap %q is in the manifest but not in the seed", sn.Name))
+		}
+	}
+
+	if len(problems) != 0 {
+		return fmt.Errorf("cannot validate seed manifest:\n- %s", strings.Join(problems, "\n- "))
+	}
 	return nil
 }
@@ -21,10 +21,13 @@ package main_test
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
 	snap "github.com/snapcore/snapd/cmd/snap"
 )
 
@@ -66,3 +69,86 @@ snaps:
 	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "validate-seed", tmpf})
 	c.Assert(err, ErrorMatches, "cannot read seed yaml: empty element in seed")
 }
+
+func (s *SnapSuite) writeSeedWithManifest(c *C, manifest string) string {
+	seedDir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(seedDir, "snaps"), 0755), IsNil)
+	err := ioutil.WriteFile(filepath.Join(seedDir, "snaps", "core_6673.snap"), []byte("core"), 0644)
+	c.Assert(err, IsNil)
+	sha3_384, _, err := asserts.SnapFileSHA3_384(filepath.Join(seedDir, "snaps", "core_6673.snap"))
+	c.Assert(err, IsNil)
+
+	err = ioutil.WriteFile(filepath.Join(seedDir, "seed.yaml"), []byte(`
+snaps:
+ -
+   name: core
+   channel: stable
+   file: core_6673.snap
+`), 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(seedDir, "manifest.yaml"), []byte(strings.Replace(manifest, "@SHA3@", sha3_384, -1)), 0644)
+	c.Assert(err, IsNil)
+	return filepath.Join(seedDir, "seed.yaml")
+}
+
+func (s *SnapSuite) TestDebugValidateSeedManifestHappy(c *C) {
+	seedYaml := s.writeSeedWithManifest(c, `
+series: "16"
+brand-id: canonical
+model: pc
+snaps:
+ - name: core
+   snap-id: core-id
+   revision: 6673
+   channel: stable
+   file: core_6673.snap
+   sha3-384: @SHA3@
+   publisher: canonical
+   license: GPL-3.0
+`)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "validate-seed", "--manifest", seedYaml})
+	c.Assert(err, IsNil)
+}
+
+func (s *SnapSuite) TestDebugValidateSeedManifestProblems(c *C) {
+	seedYaml := s.writeSeedWithManifest(c, `
+series: "16"
+brand-id: canonical
+model: pc
+snaps:
+ - name: core
+   revision: 6673
+   file: core_6673.snap
+   sha3-384: other
+   license: Other Open Source
+   license-error: "unknown license: Other"
+ - name: extra
+   revision: 1
+   file: extra_1.snap
+   sha3-384: @SHA3@
+`)
+
+	// without --manifest only seed.yaml is checked
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "validate-seed", seedYaml})
+	c.Assert(err, IsNil)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "validate-seed", "--manifest", seedYaml})
+	c.Assert(err, ErrorMatches, `cannot validate seed manifest:
+- snap "core" does not match its sha3-384 in the manifest
+- snap "core" has a license that is not a valid SPDX expression: unknown license: Other
+- snap "extra" is in the manifest but not in the seed`)
+}
+
+func (s *SnapSuite) TestDebugValidateSeedManifestMissingSnap(c *C) {
+	seedYaml := s.writeSeedWithManifest(c, `
+series: "16"
+brand-id: canonical
+model: pc
+snaps: []
+`)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "validate-seed", "--manifest", seedYaml})
+	c.Assert(err, ErrorMatches, `cannot validate seed manifest:
+- snap "core" is missing from the manifest`)
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 )
 
@@ -49,10 +50,18 @@ Nested values may be retrieved via a dotted path:
 
     $ snap get snap-name author.name
     frank
+
+The --describe option lists the configuration options declared by the snap,
+with their type, default value and allowed values.
+
+The --history option lists the recent configuration changes of the snap;
+'snap set --revert=<id>' restores the configuration as it was after one of
+them.
 `)
 
 type cmdGet struct {
 	clientMixin
+	timeMixin
 	Positional struct {
 		Snap installedSnapName `required:"yes"`
 		Keys []string
@@ -61,18 +70,24 @@ type cmdGet struct {
 	Typed    bool `short:"t"`
 	Document bool `short:"d"`
 	List     bool `short:"l"`
+	Describe bool `long:"describe"`
+	History  bool `long:"history"`
 }
 
 func init() {
 	addCommand("get", shortGetHelp, longGetHelp, func() flags.Commander { return &cmdGet{} },
-		map[string]string{
+		timeDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"d": i18n.G("Always return document, even with single key"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"l": i18n.G("Always return list, even with single key"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"t": i18n.G("Strict typing with nulls and quoted strings"),
-		}, []argDesc{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"describe": i18n.G("Describe the configuration options declared by the snap"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"history": i18n.G("Show the recent configuration changes of the snap"),
+		}), []argDesc{
 			{
 				name: "<snap>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -226,6 +241,109 @@ func (x *cmdGet) outputDefault(conf map[string]interface{}, snapName string, con
 
 }
 
+func describeConstraints(opt *client.ConfigOption) string {
+	var constraints []string
+	if len(opt.Enum) > 0 {
+		values := make([]string, len(opt.Enum))
+		for i, v := range opt.Enum {
+			values[i] = fmt.Sprintf("%v", v)
+		}
+		// TRANSLATORS: %s is a comma separated list of values
+		constraints = append(constraints, fmt.Sprintf(i18n.G("one of %s"), strings.Join(values, ", ")))
+	}
+	if opt.Minimum != nil {
+		// TRANSLATORS: %v is a number
+		constraints = append(constraints, fmt.Sprintf(i18n.G("at least %v"), *opt.Minimum))
+	}
+	if opt.Maximum != nil {
+		// TRANSLATORS: %v is a number
+		constraints = append(constraints, fmt.Sprintf(i18n.G("at most %v"), *opt.Maximum))
+	}
+	if opt.Pattern != "" {
+		// TRANSLATORS: %q is a regular expression
+		constraints = append(constraints, fmt.Sprintf(i18n.G("matching %q"), opt.Pattern))
+	}
+	if len(constraints) == 0 {
+		return "-"
+	}
+	return strings.Join(constraints, "; ")
+}
+
+func (x *cmdGet) describe(snapName string) error {
+	options, err := x.client.ConfDescribe(snapName)
+	if err != nil {
+		return err
+	}
+	if len(options) == 0 {
+		return fmt.Errorf(i18n.G("snap %q does not declare any configuration options"), snapName)
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Key\tType\tDefault\tAllowed\tDescription"))
+	for _, opt := range options {
+		def := "-"
+		if opt.Default != nil {
+			def = fmt.Sprintf("%v", opt.Default)
+		}
+		desc := "-"
+		if opt.Description != "" {
+			desc = strings.SplitN(strings.TrimSpace(opt.Description), "\n", 2)[0]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", opt.Key, opt.Type, def, describeConstraints(opt), desc)
+	}
+	return nil
+}
+
+func fmtConfigValue(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(bs)
+}
+
+func (x *cmdGet) history(snapName string) error {
+	history, err := x.client.ConfHistory(snapName)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return fmt.Errorf(i18n.G("snap %q has no configuration history"), snapName)
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("ID\tTime\tOrigin\tChange\tKey\tOld\tNew"))
+	for _, entry := range history {
+		origin := entry.Origin
+		if origin == "" {
+			origin = "-"
+		}
+		changeID := entry.ChangeID
+		if changeID == "" {
+			changeID = "-"
+		}
+		for i, kc := range entry.Changes {
+			if i == 0 {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t", entry.ID, x.fmtTime(entry.Time), origin, changeID)
+			} else {
+				fmt.Fprintf(w, "\t\t\t\t")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", kc.Key, fmtConfigValue(kc.Old), fmtConfigValue(kc.New))
+		}
+	}
+	return nil
+}
+
 func (x *cmdGet) Execute(args []string) error {
 	if len(args) > 0 {
 		// TRANSLATORS: the %s is the list of extra arguments
@@ -243,6 +361,19 @@ func (x *cmdGet) Execute(args []string) error {
 	snapName := string(x.Positional.Snap)
 	confKeys := x.Positional.Keys
 
+	if x.Describe {
+		if x.Document || x.List || x.Typed || x.History || len(confKeys) > 0 {
+			return fmt.Errorf(i18n.G("cannot use --describe with other options or keys"))
+		}
+		return x.describe(snapName)
+	}
+	if x.History {
+		if x.Document || x.List || x.Typed || len(confKeys) > 0 {
+			return fmt.Errorf(i18n.G("cannot use --history with other options or keys"))
+		}
+		return x.history(snapName)
+	}
+
 	conf, err := x.client.Conf(snapName, confKeys)
 	if err != nil {
 		return err
@@ -224,3 +224,72 @@ func (s *SnapSuite) mockGetEmptyConfigServer(c *C) {
 		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {}}`)
 	})
 }
+
+func (s *SnapSuite) TestSnapGetDescribe(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/snaps/snapname/conf")
+		c.Check(r.URL.Query().Get("describe"), Equals, "true")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": [
+{"key": "mode", "type": "string", "description": "How to run\nmore details", "enum": ["fast", "slow"], "default": "fast"},
+{"key": "port", "type": "integer", "minimum": 1024, "maximum": 65535},
+{"key": "server", "type": "object"}
+]}`)
+	})
+
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--describe", "snapname"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, `Key     Type     Default  Allowed                       Description
+mode    string   fast     one of fast, slow             How to run
+port    integer  -        at least 1024; at most 65535  -
+server  object   -        -                             -
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestSnapGetDescribeNoOptions(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--describe", "snapname"})
+	c.Assert(err, ErrorMatches, `snap "snapname" does not declare any configuration options`)
+
+	_, err = snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--describe", "snapname", "foo"})
+	c.Assert(err, ErrorMatches, `cannot use --describe with other options or keys`)
+}
+
+func (s *SnapSuite) TestSnapGetHistory(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/snaps/snapname/conf")
+		c.Check(r.URL.Query().Get("history"), Equals, "true")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": [
+{"id": 1, "time": "2019-05-01T10:00:00Z", "origin": "uid 0", "change-id": "42",
+ "changes": [{"key": "foo", "new": "bar"}, {"key": "num", "new": 1}]},
+{"id": 2, "time": "2019-05-01T11:00:00Z",
+ "changes": [{"key": "foo", "old": "bar", "new": {"a": true}}]}
+]}`)
+	})
+
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--history", "--abs-time", "snapname"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, `ID   Time                  Origin  Change  Key  Old  New
+1    2019-05-01T10:00:00Z  uid 0   42      foo  -    bar
+                                           num  -    1
+2    2019-05-01T11:00:00Z  -       -       foo  bar  {"a":true}
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestSnapGetHistoryEmpty(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--history", "snapname"})
+	c.Assert(err, ErrorMatches, `snap "snapname" has no configuration history`)
+
+	_, err = snapset.Parser(snapset.Client()).ParseArgs([]string{"get", "--history", "-d", "snapname"})
+	c.Assert(err, ErrorMatches, `cannot use --history with other options or keys`)
+}
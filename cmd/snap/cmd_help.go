@@ -206,7 +206,7 @@ var helpCategories = []helpCategory{
 	}, {
 		Label:       i18n.G("Other"),
 		Description: i18n.G("miscellanea"),
-		Commands:    []string{"version", "warnings", "okay", "ack", "known", "create-cohort"},
+		Commands:    []string{"version", "warnings", "okay", "ack", "known", "verify-assertions", "verify", "create-cohort"},
 	}, {
 		Label:       i18n.G("Development"),
 		Description: i18n.G("developer-oriented features"),
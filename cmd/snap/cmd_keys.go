@@ -23,7 +23,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/i18n"
 
 	"github.com/jessevdk/go-flags"
@@ -87,19 +86,20 @@ func (x *cmdKeys) Execute(args []string) error {
 
 	keys := []Key{}
 
-	manager := asserts.NewGPGKeypairManager()
-	collect := func(privk asserts.PrivateKey, fpr string, uid string) error {
-		key := Key{
-			Name:     uid,
-			Sha3_384: privk.PublicKey().ID(),
-		}
-		keys = append(keys, key)
-		return nil
+	manager, err := getKeypairManager()
+	if err != nil {
+		return err
 	}
-	err := manager.Walk(collect)
+	keyInfos, err := manager.List()
 	if err != nil {
 		return err
 	}
+	for _, keyInfo := range keyInfos {
+		keys = append(keys, Key{
+			Name:     keyInfo.Name,
+			Sha3_384: keyInfo.ID,
+		})
+	}
 	if x.JSON {
 		return outputJSON(keys)
 	}
@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -29,7 +30,9 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts/assertstest"
 	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type SnapKeysSuite struct {
@@ -142,3 +145,64 @@ func (s *SnapKeysSuite) TestKeysJSONEmpty(c *C) {
 	c.Check(s.Stdout(), Equals, "[]\n")
 	c.Check(s.Stderr(), Equals, "")
 }
+
+func (s *SnapKeysSuite) mockExtKeyMgr(c *C) *testutil.MockCmd {
+	_, rsaPriv := assertstest.ReadPrivKey(assertstest.DevKey)
+	pubKeyDER, err := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	c.Assert(err, IsNil)
+	pubKeyFn := filepath.Join(s.tempdir, "default.der")
+	err = ioutil.WriteFile(pubKeyFn, pubKeyDER, 0644)
+	c.Assert(err, IsNil)
+
+	keymgr := testutil.MockCommand(c, "keymgr", fmt.Sprintf(`
+case "$1" in
+  features)
+    echo '{"signing":["RSA-PKCS"],"public-keys":["DER"]}'
+    ;;
+  key-names)
+    echo '{"key-names":["default"]}'
+    ;;
+  get-public-key)
+    cat %s
+    ;;
+  *)
+    exit 1
+    ;;
+esac
+`, pubKeyFn))
+	os.Setenv("SNAPD_EXT_KEYMGR", keymgr.Exe())
+	s.AddCleanup(func() { os.Unsetenv("SNAPD_EXT_KEYMGR") })
+	s.AddCleanup(keymgr.Restore)
+	return keymgr
+}
+
+func (s *SnapKeysSuite) TestKeysExternal(c *C) {
+	keymgr := s.mockExtKeyMgr(c)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"keys"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Matches, `Name +SHA3-384
+default +`+assertstest.DevKeyID+`
+`)
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(keymgr.Calls(), DeepEquals, [][]string{
+		{"keymgr", "features"},
+		{"keymgr", "key-names"},
+		{"keymgr", "get-public-key", "-f", "DER", "-k", "default"},
+	})
+}
+
+func (s *SnapKeysSuite) TestCreateKeyExternal(c *C) {
+	s.mockExtKeyMgr(c)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"create-key", "foo"})
+	c.Check(err, ErrorMatches, "cannot create keys with an external keypair manager, create them directly with it")
+}
+
+func (s *SnapKeysSuite) TestDeleteKeyExternal(c *C) {
+	s.mockExtKeyMgr(c)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"delete-key", "default"})
+	c.Check(err, ErrorMatches, "cannot delete keys with external keypair manager, remove them directly with it")
+}
@@ -20,6 +20,7 @@
 package main
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -31,7 +32,9 @@ import (
 
 type cmdPrepareImage struct {
 	Classic      bool   `long:"classic"`
+	Preseed      bool   `long:"preseed"`
 	Architecture string `long:"arch"`
+	Manifest     string `long:"manifest" value-name:"<file>"`
 
 	Positional struct {
 		ModelAssertionFn string
@@ -54,14 +57,22 @@ creating device images.
 For core images it is not invoked directly but usually via
 ubuntu-image.
 
-For preparing classic images it supports a --classic mode`),
+For preparing classic images it supports a --classic mode.
+
+With --preseed the prepared classic image is also seeded as far as
+possible by running snapd in a chroot of it, to speed up its first boot.
+Use 'snap debug preseed --reset' to undo that before preseeding again.`),
 		func() flags.Commander { return &cmdPrepareImage{} },
 		map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"classic": i18n.G("Enable classic mode to prepare a classic model image"),
 			// TRANSLATORS: This should not start with a lowercase letter.
+			"preseed": i18n.G("Preseed the prepared classic image (requires --classic and root)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
 			"arch": i18n.G("Specify an architecture for snaps for --classic when the model does not"),
 			// TRANSLATORS: This should not start with a lowercase letter.
+			"manifest": i18n.G("Write a copy of the manifest of the seeded snaps to the given file"),
+			// TRANSLATORS: This should not start with a lowercase letter.
 			"snap": i18n.G("Include the given snap from the store or a local file and/or specify the channel to track for the given snap"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"extra-snaps": i18n.G("Extra snaps to be installed (DEPRECATED)"),
@@ -82,14 +93,22 @@ For preparing classic images it supports a --classic mode`),
 		})
 }
 
-var imagePrepare = image.Prepare
+var (
+	imagePrepare = image.Prepare
+	imagePreseed = image.Preseed
+)
 
 func (x *cmdPrepareImage) Execute(args []string) error {
+	if x.Preseed && !x.Classic {
+		return fmt.Errorf(i18n.G("cannot preseed a core image, --preseed requires --classic"))
+	}
+
 	opts := &image.Options{
 		Snaps:        x.ExtraSnaps,
 		ModelFile:    x.Positional.ModelAssertionFn,
 		Channel:      x.Channel,
 		Architecture: x.Architecture,
+		ManifestFile: x.Manifest,
 	}
 
 	snaps := make([]string, 0, len(x.Snaps)+len(x.ExtraSnaps))
@@ -119,5 +138,11 @@ func (x *cmdPrepareImage) Execute(args []string) error {
 		opts.GadgetUnpackDir = filepath.Join(x.Positional.Rootdir, "gadget")
 	}
 
-	return imagePrepare(opts)
+	if err := imagePrepare(opts); err != nil {
+		return err
+	}
+	if x.Preseed {
+		return imagePreseed(opts.RootDir)
+	}
+	return nil
 }
@@ -118,3 +118,87 @@ func (s *SnapPrepareImageSuite) TestPrepareImageExtraSnaps(c *C) {
 		SnapChannels:    map[string]string{"bar": "t/edge"},
 	})
 }
+
+func (s *SnapPrepareImageSuite) TestPrepareImageClassicPreseed(c *C) {
+	var opts *image.Options
+	prep := func(o *image.Options) error {
+		opts = o
+		return nil
+	}
+	r := snap.MockImagePrepare(prep)
+	defer r()
+	var preseeded string
+	r = snap.MockImagePreseed(func(dir string) error {
+		c.Check(opts, NotNil)
+		preseeded = dir
+		return nil
+	})
+	defer r()
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"prepare-image", "--classic", "--preseed", "model", "root-dir"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+
+	c.Check(opts, DeepEquals, &image.Options{
+		Classic:   true,
+		ModelFile: "model",
+		Channel:   "stable",
+		RootDir:   "root-dir",
+	})
+	c.Check(preseeded, Equals, "root-dir")
+}
+
+func (s *SnapPrepareImageSuite) TestPrepareImagePreseedRequiresClassic(c *C) {
+	r := snap.MockImagePrepare(func(*image.Options) error {
+		c.Fatal("unexpected call")
+		return nil
+	})
+	defer r()
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"prepare-image", "--preseed", "model", "root-dir"})
+	c.Assert(err, ErrorMatches, "cannot preseed a core image, --preseed requires --classic")
+}
+
+func (s *SnapPrepareImageSuite) TestDebugPreseed(c *C) {
+	var preseeded, reset []string
+	r := snap.MockImagePreseed(func(dir string) error {
+		preseeded = append(preseeded, dir)
+		return nil
+	})
+	defer r()
+	r = snap.MockImageResetPreseededChroot(func(dir string) error {
+		reset = append(reset, dir)
+		return nil
+	})
+	defer r()
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "preseed", "root-dir"})
+	c.Assert(err, IsNil)
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "preseed", "--reset", "root-dir"})
+	c.Assert(err, IsNil)
+
+	c.Check(preseeded, DeepEquals, []string{"root-dir"})
+	c.Check(reset, DeepEquals, []string{"root-dir"})
+}
+
+func (s *SnapPrepareImageSuite) TestPrepareImageManifest(c *C) {
+	var opts *image.Options
+	prep := func(o *image.Options) error {
+		opts = o
+		return nil
+	}
+	r := snap.MockImagePrepare(prep)
+	defer r()
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"prepare-image", "--manifest", "image.manifest", "model", "root-dir"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+
+	c.Check(opts, DeepEquals, &image.Options{
+		ModelFile:       "model",
+		Channel:         "stable",
+		RootDir:         "root-dir/image",
+		GadgetUnpackDir: "root-dir/gadget",
+		ManifestFile:    "image.manifest",
+	})
+}
@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdReregisterDevice struct {
+	waitMixin
+}
+
+func init() {
+	cmd := addDebugCommand("reregister-device",
+		"(internal) re-register the device with a new device key",
+		"(internal) re-register the device with a new device key",
+		func() flags.Commander {
+			return &cmdReregisterDevice{}
+		}, waitDescs, nil)
+	cmd.hidden = true
+}
+
+func (x *cmdReregisterDevice) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	changeID, err := x.client.ReregisterDevice()
+	if err != nil {
+		return err
+	}
+
+	if _, err := x.wait(changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+	fmt.Fprintln(Stdout, i18n.G("Device re-registered with a new device key"))
+	return nil
+}
@@ -41,18 +41,28 @@ snap's configuration hook returns successfully.
 Nested values may be modified via a dotted path:
 
     $ snap set author.name=frank
+
+The --revert option restores the configuration as it was right after the
+given change listed by 'snap get --history'. The snap's configuration
+hook runs as for any other configuration change:
+
+    $ snap set --revert=3 snap-name
 `)
 
 type cmdSet struct {
 	waitMixin
+	Revert     int `long:"revert"`
 	Positional struct {
-		Snap       installedSnapName
-		ConfValues []string `required:"1"`
-	} `positional-args:"yes" required:"yes"`
+		Snap       installedSnapName `required:"yes"`
+		ConfValues []string
+	} `positional-args:"yes"`
 }
 
 func init() {
-	addCommand("set", shortSetHelp, longSetHelp, func() flags.Commander { return &cmdSet{} }, waitDescs, []argDesc{
+	addCommand("set", shortSetHelp, longSetHelp, func() flags.Commander { return &cmdSet{} }, waitDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"revert": i18n.G("Restore the configuration as of the given history entry"),
+	}), []argDesc{
 		{
 			name: "<snap>",
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -67,6 +77,23 @@ func init() {
 }
 
 func (x *cmdSet) Execute(args []string) error {
+	if x.Revert != 0 {
+		if len(x.Positional.ConfValues) > 0 {
+			return fmt.Errorf(i18n.G("cannot use --revert with configuration values"))
+		}
+		if x.Revert < 0 {
+			return fmt.Errorf(i18n.G("invalid configuration history entry %d"), x.Revert)
+		}
+		id, err := x.client.RevertConf(string(x.Positional.Snap), x.Revert)
+		if err != nil {
+			return err
+		}
+		return x.waitConf(id)
+	}
+	if len(x.Positional.ConfValues) == 0 {
+		return fmt.Errorf(i18n.G("the required argument `<conf value> (at least 1 argument)` was not provided"))
+	}
+
 	patchValues := make(map[string]interface{})
 	for _, patchValue := range x.Positional.ConfValues {
 		parts := strings.SplitN(patchValue, "=", 2)
@@ -88,6 +115,10 @@ func (x *cmdSet) Execute(args []string) error {
 		return err
 	}
 
+	return x.waitConf(id)
+}
+
+func (x *cmdSet) waitConf(id string) error {
 	if _, err := x.wait(id); err != nil {
 		if err == noWait {
 			return nil
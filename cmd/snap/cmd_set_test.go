@@ -115,3 +115,33 @@ func (s *SnapSuite) mockSetConfigServer(c *check.C, expectedValue interface{}) {
 		}
 	})
 }
+
+func (s *SnapSuite) TestSnapSetRevert(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps/snapname/conf":
+			c.Check(r.Method, check.Equals, "PUT")
+			c.Check(r.URL.Query().Get("revert"), check.Equals, "3")
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, check.Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"set", "--revert=3", "snapname"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SnapSuite) TestSnapSetRevertErrors(c *check.C) {
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"set", "--revert=3", "snapname", "key=value"})
+	c.Check(err, check.ErrorMatches, "cannot use --revert with configuration values")
+
+	_, err = snapset.Parser(snapset.Client()).ParseArgs([]string{"set", "--revert=-1", "snapname"})
+	c.Check(err, check.ErrorMatches, "invalid configuration history entry -1")
+
+	_, err = snapset.Parser(snapset.Client()).ParseArgs([]string{"set", "snapname"})
+	c.Check(err, check.ErrorMatches, "the required argument `<conf value> \\(at least 1 argument\\)` was not provided")
+}
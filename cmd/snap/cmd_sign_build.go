@@ -83,8 +83,11 @@ func (x *cmdSignBuild) Execute(args []string) error {
 		return err
 	}
 
-	gkm := asserts.NewGPGKeypairManager()
-	privKey, err := gkm.GetByName(string(x.KeyName))
+	keypairMgr, err := getKeypairManager()
+	if err != nil {
+		return err
+	}
+	privKey, err := keypairMgr.GetByName(string(x.KeyName))
 	if err != nil {
 		// TRANSLATORS: %q is the key name, %v the error message
 		return fmt.Errorf(i18n.G("cannot use %q key: %v"), x.KeyName, err)
@@ -104,7 +107,7 @@ func (x *cmdSignBuild) Execute(args []string) error {
 	}
 
 	adb, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
-		KeypairManager: gkm,
+		KeypairManager: keypairMgr,
 	})
 	if err != nil {
 		return fmt.Errorf(i18n.G("cannot open the assertions database: %v"), err)
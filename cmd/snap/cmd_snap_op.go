@@ -86,6 +86,10 @@ have developer access to the snap, either directly or through the
 store's collaboration feature, and to be logged in (see 'snap help login').
 
 Note a later refresh will typically undo a revision override.
+
+By default each snap is refreshed on its own, so when one of them fails the
+others stay refreshed. With --transaction=all-snaps a failure in any of the
+snaps undoes the refresh of all of them.
 `)
 
 var longTryHelp = i18n.G(`
@@ -111,6 +115,7 @@ and the snap can easily be enabled again.
 
 type cmdRemove struct {
 	waitMixin
+	scheduleMixin
 
 	Revision   string `long:"revision"`
 	Positional struct {
@@ -184,7 +189,13 @@ func (x *cmdRemove) removeMany(opts *client.SnapOptions) error {
 }
 
 func (x *cmdRemove) Execute([]string) error {
-	opts := &client.SnapOptions{Revision: x.Revision}
+	at, window, err := x.schedule()
+	if err != nil {
+		return err
+	}
+	x.scheduled = x.asksForSchedule()
+
+	opts := &client.SnapOptions{Revision: x.Revision, At: at, Window: window}
 	if len(x.Positional.Snaps) == 1 {
 		return x.removeOne(opts)
 	}
@@ -192,7 +203,11 @@ func (x *cmdRemove) Execute([]string) error {
 	if x.Revision != "" {
 		return errors.New(i18n.G("a single snap name is needed to specify the revision"))
 	}
-	return x.removeMany(nil)
+	var manyOpts *client.SnapOptions
+	if x.asksForSchedule() {
+		manyOpts = &client.SnapOptions{At: at, Window: window}
+	}
+	return x.removeMany(manyOpts)
 }
 
 type channelMixin struct {
@@ -398,6 +413,7 @@ func (mx modeMixin) setModes(opts *client.SnapOptions) {
 type cmdInstall struct {
 	colorMixin
 	waitMixin
+	scheduleMixin
 
 	channelMixin
 	modeMixin
@@ -524,6 +540,11 @@ func (x *cmdInstall) Execute([]string) error {
 	if err := x.validateMode(); err != nil {
 		return err
 	}
+	at, window, err := x.schedule()
+	if err != nil {
+		return err
+	}
+	x.scheduled = x.asksForSchedule()
 
 	dangerous := x.Dangerous || x.ForceDangerous
 	opts := &client.SnapOptions{
@@ -531,6 +552,8 @@ func (x *cmdInstall) Execute([]string) error {
 		Revision:  x.Revision,
 		Dangerous: dangerous,
 		Unaliased: x.Unaliased,
+		At:        at,
+		Window:    window,
 	}
 	x.setModes(opts)
 
@@ -555,13 +578,18 @@ func (x *cmdInstall) Execute([]string) error {
 	if x.Name != "" {
 		return errors.New(i18n.G("cannot use instance name when installing multiple snaps"))
 	}
-	return x.installMany(names, nil)
+	var manyOpts *client.SnapOptions
+	if x.asksForSchedule() {
+		manyOpts = &client.SnapOptions{At: at, Window: window}
+	}
+	return x.installMany(names, manyOpts)
 }
 
 type cmdRefresh struct {
 	colorMixin
 	timeMixin
 	waitMixin
+	scheduleMixin
 	channelMixin
 	modeMixin
 
@@ -570,7 +598,10 @@ type cmdRefresh struct {
 	List             bool   `long:"list"`
 	Time             bool   `long:"time"`
 	IgnoreValidation bool   `long:"ignore-validation"`
-	Positional       struct {
+
+	Transaction client.TransactionType `long:"transaction" default:"per-snap" choice:"all-snaps" choice:"per-snap"`
+
+	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
 }
@@ -708,20 +739,26 @@ func (x *cmdRefresh) Execute([]string) error {
 	}
 
 	if x.Time {
-		if x.asksForMode() || x.asksForChannel() {
-			return errors.New(i18n.G("--time does not take mode or channel flags"))
+		if x.asksForMode() || x.asksForChannel() || x.asksForSchedule() {
+			return errors.New(i18n.G("--time does not take mode, channel or schedule flags"))
 		}
 		return x.showRefreshTimes()
 	}
 
 	if x.List {
-		if len(x.Positional.Snaps) > 0 || x.asksForMode() || x.asksForChannel() {
+		if len(x.Positional.Snaps) > 0 || x.asksForMode() || x.asksForChannel() || x.asksForSchedule() {
 			return errors.New(i18n.G("--list does not accept additional arguments"))
 		}
 
 		return x.listRefresh()
 	}
 
+	at, window, err := x.schedule()
+	if err != nil {
+		return err
+	}
+	x.scheduled = x.asksForSchedule()
+
 	if len(x.Positional.Snaps) == 0 && os.Getenv("SNAP_REFRESH_FROM_TIMER") == "1" {
 		fmt.Fprintf(Stdout, "Ignoring `snap refresh` from the systemd timer")
 		return nil
@@ -734,6 +771,8 @@ func (x *cmdRefresh) Execute([]string) error {
 			Channel:          x.Channel,
 			IgnoreValidation: x.IgnoreValidation,
 			Revision:         x.Revision,
+			At:               at,
+			Window:           window,
 		}
 		x.setModes(opts)
 		return x.refreshOne(names[0], opts)
@@ -747,7 +786,18 @@ func (x *cmdRefresh) Execute([]string) error {
 		return errors.New(i18n.G("a single snap name must be specified when ignoring validation"))
 	}
 
-	return x.refreshMany(names, nil)
+	var opts *client.SnapOptions
+	if x.Transaction == client.TransactionAllSnaps {
+		opts = &client.SnapOptions{Transaction: x.Transaction}
+	}
+	if x.asksForSchedule() {
+		if opts == nil {
+			opts = &client.SnapOptions{}
+		}
+		opts.At = at
+		opts.Window = window
+	}
+	return x.refreshMany(names, opts)
 }
 
 type cmdTry struct {
@@ -988,12 +1038,12 @@ func (x cmdSwitch) Execute(args []string) error {
 
 func init() {
 	addCommand("remove", shortRemoveHelp, longRemoveHelp, func() flags.Commander { return &cmdRemove{} },
-		waitDescs.also(map[string]string{
+		waitDescs.also(scheduleDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"revision": i18n.G("Remove only the given revision"),
 		}), nil)
 	addCommand("install", shortInstallHelp, longInstallHelp, func() flags.Commander { return &cmdInstall{} },
-		colorDescs.also(waitDescs).also(channelDescs).also(modeDescs).also(map[string]string{
+		colorDescs.also(waitDescs).also(scheduleDescs).also(channelDescs).also(modeDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"revision": i18n.G("Install the given revision of a snap, to which you must have developer access"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -1006,7 +1056,7 @@ func init() {
 			"name": i18n.G("Install the snap file under the given instance name"),
 		}), nil)
 	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() flags.Commander { return &cmdRefresh{} },
-		colorDescs.also(waitDescs).also(channelDescs).also(modeDescs).also(timeDescs).also(map[string]string{
+		colorDescs.also(waitDescs).also(scheduleDescs).also(channelDescs).also(modeDescs).also(timeDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"amend": i18n.G("Allow refresh attempt on snap unknown to the store"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -1017,6 +1067,8 @@ func init() {
 			"time": i18n.G("Show auto refresh information but do not perform a refresh"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"ignore-validation": i18n.G("Ignore validation by other snaps blocking the refresh"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"transaction": i18n.G("Have one transaction per-snap or one for all the specified snaps"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
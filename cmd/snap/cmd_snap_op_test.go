@@ -1301,6 +1301,118 @@ func (s *SnapOpSuite) TestRefreshManyIgnoreValidation(c *check.C) {
 	c.Assert(err, check.ErrorMatches, `a single snap name must be specified when ignoring validation`)
 }
 
+func (s *SnapOpSuite) TestRefreshManyTransaction(c *check.C) {
+	total := 2
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":      "refresh",
+				"snaps":       []interface{}{"one", "two"},
+				"transaction": "all-snaps",
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"snap-names": []}}}`)
+		default:
+			c.Fatalf("expected to get %d requests, now on %d", total, n+1)
+		}
+
+		n++
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--transaction=all-snaps", "one", "two"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+	c.Check(n, check.Equals, total)
+}
+
+func (s *SnapOpSuite) TestRefreshManyTransactionInvalid(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--transaction=some-snaps", "one", "two"})
+	c.Assert(err, check.ErrorMatches, `Invalid value .some-snaps. for option .--transaction.*`)
+}
+
+func (s *SnapOpSuite) TestRemoveScheduledAt(c *check.C) {
+	restore := snap.MockTimeNow(func() time.Time {
+		return time.Date(2019, 10, 1, 23, 0, 0, 0, time.UTC)
+	})
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action": "remove",
+				"at":     "2019-10-02T22:30:00Z",
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+
+		n++
+	})
+
+	// the change is not waited for
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "--at", "22:30", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Change 42 scheduled, it can be cancelled with \"snap abort 42\"\n")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapOpSuite) TestInstallManyScheduledWindow(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action": "install",
+				"snaps":  []interface{}{"one", "two"},
+				"window": "sat,1:00-3:00",
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+
+		n++
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--window", "sat,1:00-3:00", "one", "two"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Change 42 scheduled, it can be cancelled with \"snap abort 42\"\n")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapOpSuite) TestRefreshScheduledInvalid(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"refresh", "--at", "22:30", "--window", "sat", "foo"}, `cannot use --at and --window together`},
+		{[]string{"refresh", "--at", "tonight", "foo"}, `cannot parse time "tonight": expected RFC 3339 or HH:MM`},
+		{[]string{"refresh", "--list", "--window", "sat"}, `--list does not accept additional arguments`},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(t.args)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
+
 func (s *SnapOpSuite) TestRefreshAllModeFlags(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--devmode"})
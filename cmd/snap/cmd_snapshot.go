@@ -20,12 +20,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -46,6 +48,11 @@ var (
 var longSavedHelp = i18n.G(`
 The saved command displays a list of snapshots that have been created
 previously with the 'save' command.
+
+Snapshots that are encrypted are noted as such.
+
+With --verbose, the paths each snap asked to leave out of its snapshots
+are shown as well.
 `)
 var longSaveHelp = i18n.G(`
 The save command creates a snapshot of the current user, system and
@@ -58,6 +65,11 @@ for which users, or a combination of these.
 If a snap is included in a save operation, excluding its system and
 configuration data from the snapshot is not currently possible. This
 restriction may be lifted in the future.
+
+With --encrypt, the snapshot is encrypted with a passphrase that is
+asked for, or with --encrypt=key-file with the contents of the file
+set in the snapshots.encryption.key-file system option. The same
+passphrase or key file is then needed to check or restore it.
 `)
 var longForgetHelp = i18n.G(`
 The forget command deletes a snapshot. This operation can not be
@@ -83,6 +95,8 @@ for which users, or a combination of these.
 If a snap is included in a check-snapshot operation, excluding its
 system and configuration data from the check is not currently
 possible. This restriction may be lifted in the future.
+
+If the snapshot is encrypted with a passphrase, it is asked for.
 `)
 var longRestoreHelp = i18n.G(`
 The restore command replaces the current user, system and
@@ -96,12 +110,15 @@ for which users, or a combination of these.
 If a snap is included in a restore operation, excluding its system and
 configuration data from the restore is not currently possible. This
 restriction may be lifted in the future.
+
+If the snapshot is encrypted with a passphrase, it is asked for.
 `)
 
 type savedCmd struct {
 	clientMixin
 	durationMixin
 	ID         snapshotID `long:"id"`
+	Verbose    bool       `long:"verbose"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -128,7 +145,7 @@ func (x *savedCmd) Execute([]string) error {
 	w := tabWriter()
 	defer w.Flush()
 
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s",
 		// TRANSLATORS: 'Set' as in group or bag of things
 		i18n.G("Set"),
 		"Snap",
@@ -140,12 +157,20 @@ func (x *savedCmd) Execute([]string) error {
 		i18n.G("Size"),
 		// TRANSLATORS: 'Notes' as in 'Comments'
 		i18n.G("Notes"))
+	if x.Verbose {
+		// TRANSLATORS: the paths left out of the snapshot
+		fmt.Fprintf(w, "\t%s", i18n.G("Excluded"))
+	}
+	fmt.Fprintln(w)
 	for _, sg := range list {
 		for _, sh := range sg.Snapshots {
 			notes := []string{}
 			if sh.Auto {
 				notes = append(notes, "auto")
 			}
+			if sh.Encryption != nil {
+				notes = append(notes, "encrypted")
+			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
 			}
@@ -155,16 +180,65 @@ func (x *savedCmd) Execute([]string) error {
 			}
 			size := fmtSize(sh.Size)
 			age := x.fmtDuration(sh.Time)
-			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", sg.ID, sh.Snap, age, sh.Version, sh.Revision, size, note)
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s", sg.ID, sh.Snap, age, sh.Version, sh.Revision, size, note)
+			if x.Verbose {
+				excluded := "-"
+				if len(sh.Exclude) > 0 {
+					excluded = strings.Join(sh.Exclude, ", ")
+				}
+				fmt.Fprintf(w, "\t%s", excluded)
+			}
+			fmt.Fprintln(w)
 		}
 	}
 	return nil
 }
 
+// readSnapshotPassphrase asks for the passphrase of a snapshot, and to
+// confirm it if it is a new one.
+func readSnapshotPassphrase(confirm bool) (string, error) {
+	fmt.Fprint(Stdout, i18n.G("Passphrase: "))
+	passphrase, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return "", err
+	}
+	if len(passphrase) == 0 {
+		return "", errors.New(i18n.G("passphrase cannot be empty"))
+	}
+	if confirm {
+		fmt.Fprint(Stdout, i18n.G("Confirm passphrase: "))
+		confirmPassphrase, err := ReadPassword(0)
+		fmt.Fprint(Stdout, "\n")
+		if err != nil {
+			return "", err
+		}
+		if string(passphrase) != string(confirmPassphrase) {
+			return "", errors.New(i18n.G("passphrases do not match"))
+		}
+	}
+	return string(passphrase), nil
+}
+
+// withSnapshotPassphrase runs the snapshot operation, running it again
+// with the passphrase asked for if the snapshot turns out to need one.
+func withSnapshotPassphrase(op func(secret *client.SnapshotSecret) (string, error)) (string, error) {
+	changeID, err := op(nil)
+	if e, ok := err.(*client.Error); ok && e.Kind == client.ErrorKindSnapshotPassphraseRequired {
+		passphrase, err := readSnapshotPassphrase(false)
+		if err != nil {
+			return "", err
+		}
+		return op(&client.SnapshotSecret{Passphrase: passphrase})
+	}
+	return changeID, err
+}
+
 type saveCmd struct {
 	waitMixin
 	durationMixin
 	Users      string `long:"users"`
+	Encrypt    string `long:"encrypt" optional:"yes" optional-value:"passphrase" choice:"passphrase" choice:"key-file"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -173,7 +247,18 @@ type saveCmd struct {
 func (x *saveCmd) Execute([]string) error {
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	setID, changeID, err := x.client.SnapshotMany(snaps, users)
+	var secret *client.SnapshotSecret
+	switch x.Encrypt {
+	case client.SnapshotSecretPassphrase:
+		passphrase, err := readSnapshotPassphrase(true)
+		if err != nil {
+			return err
+		}
+		secret = &client.SnapshotSecret{Passphrase: passphrase}
+	case client.SnapshotSecretKeyFile:
+		secret = &client.SnapshotSecret{KeyFile: true}
+	}
+	setID, changeID, err := x.client.SnapshotMany(snaps, users, secret)
 	if err != nil {
 		return err
 	}
@@ -243,7 +328,9 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.CheckSnapshots(setID, snaps, users)
+	changeID, err := withSnapshotPassphrase(func(secret *client.SnapshotSecret) (string, error) {
+		return x.client.CheckSnapshots(setID, snaps, users, secret)
+	})
 	if err != nil {
 		return err
 	}
@@ -282,7 +369,9 @@ func (x *restoreCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.RestoreSnapshots(setID, snaps, users)
+	changeID, err := withSnapshotPassphrase(func(secret *client.SnapshotSecret) (string, error) {
+		return x.client.RestoreSnapshots(setID, snaps, users, secret)
+	})
 	if err != nil {
 		return err
 	}
@@ -315,6 +404,8 @@ func init() {
 		durationDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"id": i18n.G("Show only a specific snapshot."),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"verbose": i18n.G("Show the paths left out of each snapshot."),
 		}),
 		nil)
 
@@ -326,6 +417,8 @@ func init() {
 		}, durationDescs.also(waitDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt": i18n.G("Encrypt the snapshot with a passphrase, or with the configured key file"),
 		}), nil)
 
 	addCommand("restore",
@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -41,6 +42,12 @@ var snapshotsTests = []getCmdArgs{{
 }, {
 	args:   "saved",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n1    htop  .*  2        1168      1B  -\n",
+}, {
+	args:   "saved --verbose",
+	stdout: "Set  Snap  Age    Version  Rev   Size    Notes  Excluded\n1    htop  .*  2        1168      1B  -      -\n",
+}, {
+	args:   "saved --id=3 --verbose",
+	stdout: "Set  Snap  Age    Version  Rev   Size    Notes  Excluded\n3    htop  .*  2        1168      1B  auto   \\$SNAP_DATA/cache/\\*, \\$SNAP_COMMON/\\*.tmp\n",
 }, {
 	args:  "forget x",
 	error: "invalid argument for set id: expected a non-negative integer argument",
@@ -93,7 +100,7 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 		case "/v2/snapshots":
 			if r.Method == "GET" {
 				if r.URL.Query().Get("set") == "3" {
-					fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":"2019-03-18T16:15:20.48905909Z","snap":"htop","revision":"1168","snap-id":"Z","auto":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`)
+					fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":"2019-03-18T16:15:20.48905909Z","snap":"htop","revision":"1168","snap-id":"Z","auto":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1,"exclude":["$SNAP_DATA/cache/*","$SNAP_COMMON/*.tmp"]}]}]}`)
 					return
 				}
 				fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":1,"snapshots":[{"set":1,"time":"2019-03-18T16:15:20.48905909Z","snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`)
@@ -107,3 +114,88 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 		}
 	})
 }
+
+func (s *SnapSuite) TestSnapshotSaveEncrypted(c *C) {
+	s.password = "sekrit"
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps":
+			c.Check(r.Method, Equals, "POST")
+			var body map[string]interface{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&body), IsNil)
+			c.Check(body["action"], Equals, "snapshot")
+			c.Check(body["encrypt"], DeepEquals, map[string]interface{}{"passphrase": "sekrit"})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 5}}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		case "/v2/snapshots":
+			c.Check(r.URL.Query().Get("set"), Equals, "5")
+			fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":5,"snapshots":[{"set":5,"time":"2019-03-18T16:15:20.48905909Z","snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1,"encryption":{"cipher":"aes-256-gcm","secret":"passphrase"}}]}]}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, "Passphrase: \nConfirm passphrase: \nSet  Snap  Age    Version  Rev   Size    Notes\n5    htop  .*  2        1168      1B  encrypted\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncryptedWithKeyFile(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps":
+			var body map[string]interface{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&body), IsNil)
+			c.Check(body["encrypt"], DeepEquals, map[string]interface{}{"key-file": true})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 5}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt=key-file", "--no-wait", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "9\n")
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncryptedEmptyPassphrase(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request %q", r.URL.Path)
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Assert(err, ErrorMatches, "passphrase cannot be empty")
+}
+
+func (s *SnapSuite) TestSnapshotRestoreAsksForPassphrase(c *C) {
+	s.password = "sekrit"
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			n++
+			var body map[string]interface{}
+			c.Assert(json.NewDecoder(r.Body).Decode(&body), IsNil)
+			c.Check(body["action"], Equals, "restore")
+			if n == 1 {
+				c.Check(body["secret"], IsNil)
+				w.WriteHeader(400)
+				fmt.Fprintln(w, `{"type":"error", "status-code": 400, "result": {"message": "snapshot set is encrypted with a passphrase, but none was given", "kind": "snapshot-passphrase-required"}}`)
+				return
+			}
+			c.Check(body["secret"], DeepEquals, map[string]interface{}{"passphrase": "sekrit"})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"restore", "1"})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 2)
+	c.Check(s.Stdout(), Equals, "Passphrase: \nRestored snapshot #1.\n")
+}
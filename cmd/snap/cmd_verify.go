@@ -0,0 +1,283 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type verifyMixin struct {
+	Trusted []flags.Filename `long:"trusted"`
+}
+
+var verifyDescs = mixinDescs{
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"trusted": i18n.G("Trust the root assertions in the given file instead of the built-in ones"),
+}
+
+type cmdVerifyAssertions struct {
+	verifyMixin
+	Positional struct {
+		AssertionFiles []flags.Filename `required:"1"`
+	} `positional-args:"true" required:"true"`
+}
+
+type cmdVerify struct {
+	verifyMixin
+	Positional struct {
+		SnapFile       flags.Filename   `required:"1"`
+		AssertionFiles []flags.Filename `required:"1"`
+	} `positional-args:"true" required:"true"`
+}
+
+var shortVerifyAssertionsHelp = i18n.G("Verify assertions without adding them to the system")
+var longVerifyAssertionsHelp = i18n.G(`
+The verify-assertions command checks the signatures and the consistency of
+the assertions in the given files against the trusted root assertions,
+without adding them to the system assertion database.
+
+Assertions the given ones depend on need to be included in the files as well,
+the chain that was verified is listed together with any missing prerequisite.
+`)
+
+var shortVerifyHelp = i18n.G("Verify a snap file against its assertions")
+var longVerifyHelp = i18n.G(`
+The verify command checks the assertions in the given files as
+verify-assertions does and then checks that the snap file is the one
+described by them, without adding anything to the system.
+`)
+
+func init() {
+	addCommand("verify-assertions", shortVerifyAssertionsHelp, longVerifyAssertionsHelp, func() flags.Commander {
+		return &cmdVerifyAssertions{}
+	}, verifyDescs, []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<assertion file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Assertion file"),
+	}})
+	addCommand("verify", shortVerifyHelp, longVerifyHelp, func() flags.Commander {
+		return &cmdVerify{}
+	}, verifyDescs, []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<snap file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Snap file"),
+	}, {
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<assertion file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Assertion file"),
+	}})
+}
+
+func readAssertionFiles(fns []flags.Filename) ([]asserts.Assertion, error) {
+	var res []asserts.Assertion
+	for _, fn := range fns {
+		f, err := os.Open(string(fn))
+		if err != nil {
+			return nil, err
+		}
+		dec := asserts.NewDecoder(f)
+		for {
+			a, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf(i18n.G("cannot read assertions from %q: %v"), fn, err)
+			}
+			res = append(res, a)
+		}
+		f.Close()
+	}
+	return res, nil
+}
+
+func (x *verifyMixin) openDatabase() (*asserts.Database, error) {
+	cfg := &asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+	}
+	if len(x.Trusted) != 0 {
+		trusted, err := readAssertionFiles(x.Trusted)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Trusted = trusted
+	} else {
+		cfg.Trusted = sysdb.Trusted()
+		cfg.OtherPredefined = sysdb.Generic()
+	}
+	db, err := asserts.OpenDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot open the assertions database: %v"), err)
+	}
+	return db, nil
+}
+
+// verifiedAssertion is a line of the verification report.
+type verifiedAssertion struct {
+	ref       *asserts.Ref
+	authority string
+	status    string
+}
+
+// verifyAssertions adds the given assertions to db in prerequisite
+// order, checking them along the way. It returns the chain of
+// assertions involved and the problems found.
+func verifyAssertions(db *asserts.Database, as []asserts.Assertion) (chain []*verifiedAssertion, problems []string) {
+	bs := asserts.NewMemoryBackstore()
+	for _, a := range as {
+		if err := bs.Put(a.Type(), a); err != nil {
+			if revErr, ok := err.(*asserts.RevisionError); ok && revErr.Current >= a.Revision() {
+				// we already got something more recent
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("cannot use %v: %v", a.Ref(), err))
+		}
+	}
+
+	seen := make(map[string]bool)
+	report := func(ref *asserts.Ref, authority, status string) {
+		if seen[ref.Unique()] {
+			return
+		}
+		seen[ref.Unique()] = true
+		chain = append(chain, &verifiedAssertion{ref: ref, authority: authority, status: status})
+	}
+	reportTrusted := func(ref *asserts.Ref) {
+		if a, err := ref.Resolve(db.FindPredefined); err == nil {
+			report(ref, a.AuthorityID(), "trusted")
+		}
+	}
+
+	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
+		a, err := bs.Get(ref.Type, ref.PrimaryKey, ref.Type.MaxSupportedFormat())
+		if asserts.IsNotFound(err) {
+			report(ref, "", "missing")
+			problems = append(problems, fmt.Sprintf("cannot find prerequisite %v", ref))
+		}
+		return a, err
+	}
+	save := func(a asserts.Assertion) error {
+		for _, preref := range a.Prerequisites() {
+			reportTrusted(preref)
+		}
+		reportTrusted(&asserts.Ref{Type: asserts.AccountKeyType, PrimaryKey: []string{a.SignKeyID()}})
+		if err := db.Add(a); err != nil {
+			report(a.Ref(), a.AuthorityID(), "invalid")
+			problems = append(problems, fmt.Sprintf("cannot verify %v: %v", a.Ref(), err))
+			return err
+		}
+		report(a.Ref(), a.AuthorityID(), "verified")
+		return nil
+	}
+
+	f := asserts.NewFetcher(db, retrieve, save)
+	for _, a := range as {
+		reportTrusted(a.Ref())
+		// problems are collected by retrieve and save, keep going
+		// to report as much as possible
+		f.Save(a)
+	}
+	for _, a := range as {
+		// not reached because of problems with its prerequisites
+		report(a.Ref(), a.AuthorityID(), "unverified")
+	}
+	return chain, problems
+}
+
+func showVerifiedChain(chain []*verifiedAssertion) {
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Type\tKey\tAuthority\tStatus"))
+	for _, va := range chain {
+		authority := va.authority
+		if authority == "" {
+			authority = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", va.ref.Type.Name, strings.Join(va.ref.PrimaryKey, "/"), authority, va.status)
+	}
+}
+
+func verificationError(problems []string) error {
+	return fmt.Errorf(i18n.G("verification failed:\n- %s"), strings.Join(problems, "\n- "))
+}
+
+func (x *verifyMixin) verify(assertionFiles []flags.Filename) (*asserts.Database, error) {
+	as, err := readAssertionFiles(assertionFiles)
+	if err != nil {
+		return nil, err
+	}
+	db, err := x.openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	chain, problems := verifyAssertions(db, as)
+	showVerifiedChain(chain)
+	if len(problems) != 0 {
+		return nil, verificationError(problems)
+	}
+	return db, nil
+}
+
+func (x *cmdVerifyAssertions) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	_, err := x.verify(x.Positional.AssertionFiles)
+	return err
+}
+
+func (x *cmdVerify) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	db, err := x.verify(x.Positional.AssertionFiles)
+	if err != nil {
+		return err
+	}
+
+	snapPath := string(x.Positional.SnapFile)
+	si, err := snapasserts.DeriveSideInfo(snapPath, db)
+	if asserts.IsNotFound(err) {
+		return verificationError([]string{fmt.Sprintf("cannot find signatures with metadata for snap %q", snapPath)})
+	}
+	if err != nil {
+		return verificationError([]string{err.Error()})
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("snap %q revision %s (snap-id %s) verified\n"), si.RealName, si.Revision, si.SnapID)
+	return nil
+}
@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+type SnapVerifySuite struct {
+	BaseSnapSuite
+
+	storeSigning *assertstest.StoreStack
+	trustedFile  string
+	snapFile     string
+
+	devAcct  *asserts.Account
+	snapDecl *asserts.SnapDeclaration
+	snapRev  *asserts.SnapRevision
+}
+
+var _ = Suite(&SnapVerifySuite{})
+
+func (s *SnapVerifySuite) SetUpTest(c *C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	dir := c.MkDir()
+	s.storeSigning = assertstest.NewStoreStack("can0nical", nil)
+	s.trustedFile = s.writeAssertions(c, "trusted.assert", s.storeSigning.Trusted...)
+
+	s.snapFile = filepath.Join(dir, "foo_1.snap")
+	err := ioutil.WriteFile(s.snapFile, []byte("not really a snap"), 0644)
+	c.Assert(err, IsNil)
+	digest, size, err := asserts.SnapFileSHA3_384(s.snapFile)
+	c.Assert(err, IsNil)
+
+	s.devAcct = assertstest.NewAccount(s.storeSigning, "devel1", map[string]interface{}{
+		"account-id": "devel1-id",
+	}, "")
+
+	a, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "foo-id",
+		"snap-name":    "foo",
+		"publisher-id": "devel1-id",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.snapDecl = a.(*asserts.SnapDeclaration)
+
+	a, err = s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-id":       "foo-id",
+		"snap-revision": "1",
+		"developer-id":  "devel1-id",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.snapRev = a.(*asserts.SnapRevision)
+}
+
+func (s *SnapVerifySuite) writeAssertions(c *C, name string, as ...asserts.Assertion) string {
+	fn := filepath.Join(c.MkDir(), name)
+	var buf bytes.Buffer
+	enc := asserts.NewEncoder(&buf)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(fn, buf.Bytes(), 0644), IsNil)
+	return fn
+}
+
+func (s *SnapVerifySuite) TestVerifyAssertionsHappy(c *C) {
+	// out of prerequisite order on purpose
+	fn := s.writeAssertions(c, "foo.assert", s.snapRev, s.snapDecl, s.devAcct, s.storeSigning.StoreAccountKey(""))
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-assertions", "--trusted", s.trustedFile, fn})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	rootKeyID := s.storeSigning.TrustedKey.PublicKeyID()
+	c.Check(s.Stdout(), Equals, fmt.Sprintf(`Type              Key                                                               Authority  Status
+account           can0nical                                                         can0nical  trusted
+account-key       %s  can0nical  trusted
+account-key       %s  can0nical  verified
+account           devel1-id                                                         can0nical  verified
+snap-declaration  16/foo-id                                                         can0nical  verified
+snap-revision     %s  can0nical  verified
+`, rootKeyID, s.storeSigning.KeyID, s.snapRev.SnapSHA3_384()))
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapVerifySuite) TestVerifyAssertionsMissingPrerequisite(c *C) {
+	fn := s.writeAssertions(c, "foo.assert", s.snapDecl, s.storeSigning.StoreAccountKey(""))
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-assertions", "--trusted", s.trustedFile, fn})
+	c.Assert(err, ErrorMatches, `verification failed:
+- cannot find prerequisite account \(devel1-id\)`)
+	c.Check(s.Stdout(), Matches, `(?s)Type +Key +Authority +Status
+account +devel1-id +- +missing
+.*snap-declaration +16/foo-id +can0nical +unverified
+`)
+}
+
+func (s *SnapVerifySuite) TestVerifyAssertionsUntrusted(c *C) {
+	fn := s.writeAssertions(c, "foo.assert", s.devAcct, s.storeSigning.StoreAccountKey(""))
+
+	// the built-in trusted assertions do not include the test root key
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-assertions", fn})
+	c.Assert(err, ErrorMatches, `(?s)verification failed:
+- cannot find prerequisite account \(can0nical\).*`)
+}
+
+func (s *SnapVerifySuite) TestVerifyHappy(c *C) {
+	fn := s.writeAssertions(c, "foo.assert", s.snapRev, s.snapDecl, s.devAcct, s.storeSigning.StoreAccountKey(""))
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify", "--trusted", s.trustedFile, s.snapFile, fn})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Matches, `(?s)Type +Key +Authority +Status
+.*snap-revision .* verified
+.*snap "foo" revision 1 \(snap-id foo-id\) verified
+`)
+}
+
+func (s *SnapVerifySuite) TestVerifyWrongSnap(c *C) {
+	fn := s.writeAssertions(c, "foo.assert", s.snapRev, s.snapDecl, s.devAcct, s.storeSigning.StoreAccountKey(""))
+
+	otherSnap := filepath.Join(c.MkDir(), "bar_1.snap")
+	err := ioutil.WriteFile(otherSnap, []byte("another snap"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"verify", "--trusted", s.trustedFile, otherSnap, fn})
+	c.Assert(err, ErrorMatches, `verification failed:
+- cannot find signatures with metadata for snap ".*/bar_1.snap"`)
+}
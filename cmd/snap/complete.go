@@ -28,7 +28,6 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
-	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
@@ -186,12 +185,19 @@ type keyName string
 
 func (s keyName) Complete(match string) []flags.Completion {
 	var res []flags.Completion
-	asserts.NewGPGKeypairManager().Walk(func(_ asserts.PrivateKey, _ string, uid string) error {
-		if strings.HasPrefix(uid, match) {
-			res = append(res, flags.Completion{Item: uid})
-		}
+	keypairMgr, err := getKeypairManager()
+	if err != nil {
 		return nil
-	})
+	}
+	keys, err := keypairMgr.List()
+	if err != nil {
+		return nil
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key.Name, match) {
+			res = append(res, flags.Completion{Item: key.Name})
+		}
+	}
 	return res
 }
 
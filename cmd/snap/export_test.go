@@ -264,4 +264,20 @@ func MockImagePrepare(newImagePrepare func(*image.Options) error) (restore func(
 	}
 }
 
+func MockImagePreseed(newImagePreseed func(string) error) (restore func()) {
+	old := imagePreseed
+	imagePreseed = newImagePreseed
+	return func() {
+		imagePreseed = old
+	}
+}
+
+func MockImageResetPreseededChroot(newReset func(string) error) (restore func()) {
+	old := imageResetPreseededChroot
+	imageResetPreseededChroot = newReset
+	return func() {
+		imageResetPreseededChroot = old
+	}
+}
+
 type ServiceName = serviceName
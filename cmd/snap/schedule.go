@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+type scheduleMixin struct {
+	At     string `long:"at"`
+	Window string `long:"window"`
+}
+
+var scheduleDescs = mixinDescs{
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"at": i18n.G("Start the operation at the given time (in RFC 3339 format, or HH:MM for the next such time)"),
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"window": i18n.G("Start the operation in the next window of the given schedule (same syntax as refresh.timer)"),
+}
+
+func (mx scheduleMixin) asksForSchedule() bool {
+	return mx.At != "" || mx.Window != ""
+}
+
+// schedule returns the time and window asked for on the command line.
+func (mx scheduleMixin) schedule() (*time.Time, string, error) {
+	if mx.At != "" && mx.Window != "" {
+		return nil, "", errors.New(i18n.G("cannot use --at and --window together"))
+	}
+	if mx.At == "" {
+		return nil, mx.Window, nil
+	}
+	at, err := parseAt(mx.At)
+	if err != nil {
+		return nil, "", err
+	}
+	return &at, "", nil
+}
+
+// parseAt parses either a full RFC 3339 time or a time of the day, which
+// is taken to mean the next time the clock shows it.
+func parseAt(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	clock, err := timeutil.ParseClock(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(i18n.G("cannot parse time %q: expected RFC 3339 or HH:MM"), s)
+	}
+	now := timeNow()
+	t := clock.Time(now)
+	if !t.After(now) {
+		t = clock.Time(now.AddDate(0, 0, 1))
+	}
+	return t, nil
+}
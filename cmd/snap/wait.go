@@ -40,6 +40,9 @@ type waitMixin struct {
 	clientMixin
 	NoWait    bool `long:"no-wait"`
 	skipAbort bool
+	// scheduled changes are not waited for, as they might only
+	// start much later
+	scheduled bool
 }
 
 var waitDescs = mixinDescs{
@@ -54,6 +57,10 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 		fmt.Fprintf(Stdout, "%s\n", id)
 		return nil, noWait
 	}
+	if wmx.scheduled {
+		fmt.Fprintf(Stdout, i18n.G("Change %s scheduled, it can be cancelled with \"snap abort %s\"\n"), id, id)
+		return nil, noWait
+	}
 	cli := wmx.client
 	// Intercept sigint
 	c := make(chan os.Signal, 2)
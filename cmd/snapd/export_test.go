@@ -24,7 +24,8 @@ import (
 )
 
 var (
-	Run = run
+	Run        = run
+	RunPreseed = runPreseed
 )
 
 func MockSanityCheck(f func() error) (restore func()) {
@@ -42,3 +43,11 @@ func MockCheckRunningConditionsRetryDelay(d time.Duration) (restore func()) {
 		checkRunningConditionsRetryDelay = oldCheckRunningConditionsRetryDelay
 	}
 }
+
+func MockPreseedCheckInterval(d time.Duration) (restore func()) {
+	oldPreseedCheckInterval := preseedCheckInterval
+	preseedCheckInterval = d
+	return func() {
+		preseedCheckInterval = oldPreseedCheckInterval
+	}
+}
@@ -32,6 +32,9 @@ import (
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sanity"
 	"github.com/snapcore/snapd/systemd"
 )
@@ -55,6 +58,13 @@ func main() {
 
 	ch := make(chan os.Signal, 2)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	if release.PreseedMode {
+		if err := runPreseed(ch); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot preseed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if err := run(ch); err != nil {
 		if err == daemon.ErrRestartSocket {
 			// Note that we don't prepend: "error: " here because
@@ -101,6 +111,67 @@ func runWatchdog(d *daemon.Daemon) (*time.Ticker, error) {
 
 var checkRunningConditionsRetryDelay = 300 * time.Second
 
+var preseedCheckInterval = 1 * time.Second
+
+func seedChangeErr(st *state.State) error {
+	st.Lock()
+	defer st.Unlock()
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "seed" && chg.IsReady() {
+			return chg.Err()
+		}
+	}
+	return nil
+}
+
+// runPreseed runs only the overlord, without the API sockets, until
+// the seeding of the system is done as far as possible without
+// booting the actual device, as signaled by a StopDaemon restart
+// request.
+func runPreseed(ch chan os.Signal) error {
+	ovld, err := overlord.New()
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	ovld.SetRestartHandler(func(t state.RestartType) {
+		switch t {
+		case state.StopDaemon:
+			close(stop)
+		default:
+			logger.Noticef("internal error: restart handler called with unexpected restart type when preseeding: %v", t)
+		}
+	})
+
+	ovld.Loop()
+
+	tic := time.NewTicker(preseedCheckInterval)
+	defer tic.Stop()
+
+	var preseedErr error
+out:
+	for {
+		select {
+		case sig := <-ch:
+			preseedErr = fmt.Errorf("interrupted by %s signal", sig)
+			break out
+		case <-stop:
+			break out
+		case <-tic.C:
+			if err := seedChangeErr(ovld.State()); err != nil {
+				preseedErr = err
+				break out
+			}
+		}
+	}
+
+	if err := ovld.Stop(); err != nil && preseedErr == nil {
+		preseedErr = err
+	}
+	return preseedErr
+}
+
 func run(ch chan os.Signal) error {
 	t0 := time.Now().Truncate(time.Millisecond)
 	httputil.SetUserAgentFromVersion(cmd.Version)
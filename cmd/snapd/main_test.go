@@ -24,6 +24,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -34,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/testutil"
 
 	snapd "github.com/snapcore/snapd/cmd/snapd"
@@ -124,3 +126,30 @@ func (s *snapdSuite) TestSanityFailGoesIntoDegradedMode(c *C) {
 	close(ch)
 	wg.Wait()
 }
+
+func (s *snapdSuite) TestRunPreseedInterrupted(c *C) {
+	restore := release.MockPreseedMode(true)
+	defer restore()
+	restore = snapd.MockPreseedCheckInterval(10 * time.Millisecond)
+	defer restore()
+	restore = apparmor.MockIsHomeUsingNFS(func() (bool, error) { return false, nil })
+	defer restore()
+	restore = seccomp.MockSnapSeccompVersionInfo(func(s seccomp.Compiler) (string, error) {
+		return "abcdef 1.2.3 1234abcd -", nil
+	})
+	defer restore()
+
+	ch := make(chan os.Signal, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- snapd.RunPreseed(ch)
+	}()
+	ch <- syscall.SIGTERM
+
+	select {
+	case err := <-errCh:
+		c.Check(err, ErrorMatches, "interrupted by terminated signal")
+	case <-time.After(5 * time.Second):
+		c.Fatal("preseeding was not interrupted")
+	}
+}
@@ -55,6 +55,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
@@ -901,6 +902,13 @@ type snapInstruction struct {
 	Snaps    []string     `json:"snaps"`
 	Users    []string     `json:"users"`
 
+	Encrypt *client.SnapshotSecret `json:"encrypt"`
+
+	Transaction client.TransactionType `json:"transaction"`
+
+	At     *time.Time `json:"at"`
+	Window string     `json:"window"`
+
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
 }
@@ -984,8 +992,13 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 		return nil, err
 	}
 
+	var flags *snapstate.Flags
+	if inst.Transaction != "" {
+		flags = &snapstate.Flags{Transaction: inst.Transaction}
+	}
+
 	// TODO: use a per-request context
-	updated, tasksets, err := snapstateUpdateMany(context.TODO(), st, inst.Snaps, inst.userID, nil)
+	updated, tasksets, err := snapstateUpdateMany(context.TODO(), st, inst.Snaps, inst.userID, flags)
 	if err != nil {
 		return nil, err
 	}
@@ -1003,8 +1016,13 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 		msg = fmt.Sprintf(i18n.G("Refresh snap %q"), updated[0])
 	default:
 		quoted := strutil.Quoted(updated)
-		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
-		msg = fmt.Sprintf(i18n.G("Refresh snaps %s"), quoted)
+		if inst.Transaction == client.TransactionAllSnaps {
+			// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+			msg = fmt.Sprintf(i18n.G("Refresh snaps %s as one transaction"), quoted)
+		} else {
+			// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+			msg = fmt.Sprintf(i18n.G("Refresh snaps %s"), quoted)
+		}
 	}
 
 	return &snapInstructionResult{
@@ -1026,9 +1044,118 @@ func verifySnapInstructions(inst *snapInstruction) error {
 		}
 	}
 
+	switch inst.Transaction {
+	case "", client.TransactionPerSnap, client.TransactionAllSnaps:
+	default:
+		return fmt.Errorf("invalid value for transaction type: %s", inst.Transaction)
+	}
+	if inst.Transaction != "" && inst.Action != "refresh" {
+		return fmt.Errorf("transaction type is unsupported for %q actions", inst.Action)
+	}
+
+	if sched := changeSchedule(inst.At, inst.Window); sched != nil {
+		switch inst.Action {
+		case "install", "refresh", "remove":
+		default:
+			return fmt.Errorf("scheduling is unsupported for %q actions", inst.Action)
+		}
+		if err := sched.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// changeSchedule returns the schedule asked for with at or window, or nil
+// if the change can start right away.
+func changeSchedule(at *time.Time, window string) *snapstate.ChangeSchedule {
+	if at == nil && window == "" {
+		return nil
+	}
+	sched := &snapstate.ChangeSchedule{Window: window}
+	if at != nil {
+		sched.At = *at
+	}
+	return sched
+}
+
+// scheduledChange returns the operation of the instruction that is run
+// once the schedule allows it, together with the summary of its change.
+func (inst *snapInstruction) scheduledChange(sched *snapstate.ChangeSchedule) (*snapstate.ScheduledChange, string, error) {
+	sc := &snapstate.ScheduledChange{
+		Kind:     inst.Action,
+		Snaps:    inst.Snaps,
+		Channel:  inst.Channel,
+		Revision: inst.Revision,
+		UserID:   inst.userID,
+		Schedule: *sched,
+	}
+
+	var err error
+	var msg string
+	quoted := strutil.Quoted(inst.Snaps)
+	switch inst.Action {
+	case "install":
+		for _, name := range inst.Snaps {
+			if len(name) == 0 {
+				return nil, "", fmt.Errorf(i18n.G("cannot install snap with empty name"))
+			}
+		}
+		sc.Flags, err = inst.installFlags()
+		if len(inst.Snaps) == 1 {
+			msg = fmt.Sprintf(i18n.G("Install %q snap"), inst.Snaps[0])
+		} else {
+			// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+			msg = fmt.Sprintf(i18n.G("Install snaps %s"), quoted)
+		}
+	case "refresh":
+		sc.Flags, err = inst.modeFlags()
+		sc.Flags.IgnoreValidation = inst.IgnoreValidation
+		sc.Flags.Amend = inst.Amend
+		sc.Flags.Transaction = inst.Transaction
+		switch len(inst.Snaps) {
+		case 0:
+			msg = i18n.G("Refresh all snaps")
+		case 1:
+			msg = fmt.Sprintf(i18n.G("Refresh %q snap"), inst.Snaps[0])
+		default:
+			// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+			msg = fmt.Sprintf(i18n.G("Refresh snaps %s"), quoted)
+		}
+	case "remove":
+		if len(inst.Snaps) == 1 {
+			msg = fmt.Sprintf(i18n.G("Remove %q snap"), inst.Snaps[0])
+		} else {
+			// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+			msg = fmt.Sprintf(i18n.G("Remove snaps %s"), quoted)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return sc, msg, nil
+}
+
+// newScheduledChange creates a change that only gets its other tasks,
+// and so only conflicts with other changes, once the schedule allows it.
+func newScheduledChange(st *state.State, kind, summary string, sc *snapstate.ScheduledChange, snapNames []string) *state.Change {
+	summary = fmt.Sprintf("%s (scheduled %s)", summary, &sc.Schedule)
+	return newChange(st, kind, summary, []*state.TaskSet{snapstate.ScheduleChange(st, sc)}, snapNames)
+}
+
+// scheduleSnapChange creates the change of a scheduled snap instruction.
+func scheduleSnapChange(inst *snapInstruction, st *state.State, sched *snapstate.ChangeSchedule) Response {
+	sc, msg, err := inst.scheduledChange(sched)
+	if err != nil {
+		return inst.errToResponse(err)
+	}
+	chg := newScheduledChange(st, inst.Action+"-snap", msg, sc, inst.Snaps)
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
 func snapInstallMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
 	for _, name := range inst.Snaps {
 		if len(name) == 0 {
@@ -1210,7 +1337,7 @@ func snapSwitch(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 }
 
 func snapshotMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users)
+	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.Encrypt)
 	if err != nil {
 		return nil, err
 	}
@@ -1290,6 +1417,10 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("unknown action %s", inst.Action)
 	}
 
+	if sched := changeSchedule(inst.At, inst.Window); sched != nil {
+		return scheduleSnapChange(&inst, state, sched)
+	}
+
 	msg, tsets, err := impl(&inst, state)
 	if err != nil {
 		return inst.errToResponse(err)
@@ -1411,6 +1542,10 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 	default:
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
+	if sched := changeSchedule(inst.At, inst.Window); sched != nil {
+		return scheduleSnapChange(&inst, st, sched)
+	}
+
 	res, err := op(&inst, st)
 	if err != nil {
 		return inst.errToResponse(err)
@@ -1652,7 +1787,15 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	snapName := configstate.RemapSnapFromRequest(vars["name"])
 
-	keys := strutil.CommaSeparatedList(r.URL.Query().Get("keys"))
+	query := r.URL.Query()
+	if query.Get("describe") == "true" {
+		return describeSnapConf(c, snapName)
+	}
+	if query.Get("history") == "true" {
+		return snapConfHistory(c, snapName)
+	}
+
+	keys := strutil.CommaSeparatedList(query.Get("keys"))
 
 	s := c.d.overlord.State()
 	s.Lock()
@@ -1699,30 +1842,141 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(currentConfValues, nil)
 }
 
+func describeSnapConf(c *Command, snapName string) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	options := []*client.ConfigOption{}
+	if snapName == "core" {
+		// system options are not described by a schema
+		return SyncResponse(options, nil)
+	}
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		return InternalError("%v", err)
+	}
+
+	info.ConfigSchema.Walk(func(key string, opt *snap.ConfigOption) {
+		options = append(options, &client.ConfigOption{
+			Key:         key,
+			Type:        opt.Type,
+			Description: opt.Description,
+			Default:     opt.Default,
+			Enum:        opt.Enum,
+			Minimum:     opt.Minimum,
+			Maximum:     opt.Maximum,
+			Pattern:     opt.Pattern,
+		})
+	})
+	return SyncResponse(options, nil)
+}
+
+func snapConfHistory(c *Command, snapName string) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	history, err := config.History(st, snapName)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	entries := make([]*client.ConfigHistoryEntry, 0, len(history))
+	for _, e := range history {
+		entry := &client.ConfigHistoryEntry{
+			ID:       e.ID,
+			Time:     e.Time,
+			Origin:   e.Origin,
+			ChangeID: e.ChangeID,
+			Changes:  make([]client.ConfigKeyChange, len(e.Changes)),
+		}
+		for i, kc := range e.Changes {
+			entry.Changes[i].Key = kc.Key
+			if kc.Old != nil {
+				entry.Changes[i].Old = kc.Old
+			}
+			if kc.New != nil {
+				entry.Changes[i].New = kc.New
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return SyncResponse(entries, nil)
+}
+
+// configOrigin describes who requested a configuration change, for the
+// configuration history.
+func configOrigin(r *http.Request, user *auth.UserState) string {
+	if user != nil {
+		return user.Username
+	}
+	if _, uid, _, err := ucrednetGet(r.RemoteAddr); err == nil {
+		return fmt.Sprintf("uid %d", uid)
+	}
+	return ""
+}
+
 func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	snapName := configstate.RemapSnapFromRequest(vars["name"])
 
+	revert := 0
+	if s := r.URL.Query().Get("revert"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return BadRequest("invalid configuration history entry %q", s)
+		}
+		revert = n
+	}
+
 	var patchValues map[string]interface{}
-	if err := jsonutil.DecodeWithNumber(r.Body, &patchValues); err != nil {
-		return BadRequest("cannot decode request body into patch values: %v", err)
+	if revert == 0 {
+		if err := jsonutil.DecodeWithNumber(r.Body, &patchValues); err != nil {
+			return BadRequest("cannot decode request body into patch values: %v", err)
+		}
 	}
 
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
 
+	if revert != 0 {
+		var err error
+		patchValues, err = config.RevertPatch(st, snapName, revert)
+		if err != nil {
+			if _, ok := err.(*config.NoHistoryEntryError); ok {
+				return BadRequest("%v", err)
+			}
+			return InternalError("%v", err)
+		}
+		if len(patchValues) == 0 {
+			return BadRequest("configuration of snap %q already matches configuration change %d", snapName, revert)
+		}
+	}
+
 	taskset, err := configstate.ConfigureInstalled(st, snapName, patchValues, 0)
 	if err != nil {
 		// TODO: just return snap-not-installed instead ?
 		if _, ok := err.(*snap.NotInstalledError); ok {
 			return SnapNotFound(snapName, err)
 		}
+		if _, ok := err.(*config.ValidationError); ok {
+			return BadRequest("%v", err)
+		}
 		return errToResponse(err, []string{snapName}, InternalError, "%v")
 	}
 
 	summary := fmt.Sprintf("Change configuration of %q snap", snapName)
+	if revert != 0 {
+		summary = fmt.Sprintf("Revert configuration of %q snap to change %d", snapName, revert)
+	}
 	change := newChange(st, "configure-snap", summary, []*state.TaskSet{taskset}, []string{snapName})
+	if origin := configOrigin(r, user); origin != "" {
+		change.Set("config-origin", origin)
+	}
 
 	st.EnsureBefore(0)
 
@@ -1840,6 +2094,18 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 	if len(a.Plugs) == 0 || len(a.Slots) == 0 {
 		return BadRequest("at least one plug and slot is required")
 	}
+	if a.Action != "connect" && len(a.Restrictions) != 0 {
+		return BadRequest("restrictions can only be used when connecting")
+	}
+	sched := changeSchedule(a.At, a.Window)
+	if sched != nil {
+		if a.Action != "connect" {
+			return BadRequest("scheduling can only be used when connecting")
+		}
+		if err := sched.Validate(); err != nil {
+			return BadRequest("%v", err)
+		}
+	}
 
 	var summary string
 	var err error
@@ -1867,7 +2133,25 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 			var ts *state.TaskSet
 			affected = snapNamesFromConns([]*interfaces.ConnRef{connRef})
 			summary = fmt.Sprintf("Connect %s:%s to %s:%s", connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
-			ts, err = ifacestate.Connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			if sched != nil {
+				sc := &snapstate.ScheduledChange{
+					Kind:         "connect",
+					PlugSnap:     connRef.PlugRef.Snap,
+					PlugName:     connRef.PlugRef.Name,
+					SlotSnap:     connRef.SlotRef.Snap,
+					SlotName:     connRef.SlotRef.Name,
+					Restrictions: a.Restrictions,
+					Schedule:     *sched,
+				}
+				change := newScheduledChange(st, a.Action+"-snap", summary, sc, affected)
+				st.EnsureBefore(0)
+				return AsyncResponse(nil, &Meta{Change: change.ID()})
+			}
+			if len(a.Restrictions) != 0 {
+				ts, err = ifacestate.ConnectWithRestrictions(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name, a.Restrictions)
+			} else {
+				ts, err = ifacestate.Connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+			}
 			if _, ok := err.(*ifacestate.ErrAlreadyConnected); ok {
 				change := newChange(st, a.Action+"-snap", summary, nil, affected)
 				change.SetStatus(state.DoneStatus)
@@ -2024,8 +2308,10 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 		filter = func(chg *state.Change) bool { return !chg.Status().Ready() }
 	case "ready":
 		filter = func(chg *state.Change) bool { return chg.Status().Ready() }
+	case "history":
+		return getChangeHistory(r, query)
 	default:
-		return BadRequest("select should be one of: all,in-progress,ready")
+		return BadRequest("select should be one of: all,in-progress,ready,history")
 	}
 
 	if wantedName := query.Get("for"); wantedName != "" {
@@ -2065,6 +2351,33 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(chgInfos, nil)
 }
 
+// getChangeHistory returns the records kept of changes that were pruned.
+// Who requested the changes is only shown to root.
+func getChangeHistory(r *http.Request, query url.Values) Response {
+	filter := &changehistory.Filter{SnapName: query.Get("for")}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return BadRequest("invalid since parameter: %q", since)
+		}
+		filter.Since = t
+	}
+
+	records, err := changehistory.Read(filter)
+	if err != nil {
+		return InternalError("cannot read change history: %v", err)
+	}
+	if records == nil {
+		records = []*changehistory.Record{}
+	}
+	if _, uid, _, err := ucrednetGet(r.RemoteAddr); err != nil || uid != 0 {
+		for _, rec := range records {
+			rec.User = ""
+		}
+	}
+	return SyncResponse(records, nil)
+}
+
 func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
@@ -153,6 +153,8 @@ func collectConnections(ifaceMgr *ifacestate.InterfaceManager, filter collectFil
 			Interface: cstate.Interface,
 			PlugAttrs: mergeAttrs(cstate.StaticPlugAttrs, cstate.DynamicPlugAttrs),
 			SlotAttrs: mergeAttrs(cstate.StaticSlotAttrs, cstate.DynamicSlotAttrs),
+
+			PlugRestrictions: cstate.PlugRestrictions,
 		}
 		if cstate.Undesired {
 			// explicitly disconnected are always manual
@@ -23,12 +23,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/timings"
@@ -84,6 +86,18 @@ func checkConnectivity(st *state.State) Response {
 	return SyncResponse(status, nil)
 }
 
+func reregisterDevice(st *state.State) Response {
+	chg, err := devicestate.Reregister(st)
+	if cce, ok := err.(*snapstate.ChangeConflictError); ok {
+		return SnapChangeConflict(cce)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	ensureStateSoon(st)
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
 type changeTimings struct {
 	DoingTime      time.Duration         `json:"doing-time,omitempty"`
 	UndoingTime    time.Duration         `json:"undoing-time,omitempty"`
@@ -131,6 +145,36 @@ func getChangeTimings(st *state.State, changeID string) Response {
 	return SyncResponse(m, nil)
 }
 
+type policyDecisionJSON struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newPolicyDecisionJSON(rule string, err error) *policyDecisionJSON {
+	d := &policyDecisionJSON{Allowed: err == nil, Rule: rule}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	return d
+}
+
+func getConnectionPolicy(st *state.State, plug, slot string) Response {
+	plugRef := strings.SplitN(plug, ":", 2)
+	slotRef := strings.SplitN(slot, ":", 2)
+	if len(plugRef) != 2 || len(slotRef) != 2 || plugRef[0] == "" || plugRef[1] == "" || slotRef[0] == "" || slotRef[1] == "" {
+		return BadRequest("connection-policy needs a plug and a slot given as <snap>:<name>")
+	}
+	decision, err := ifacestate.ExplainConnectionPolicy(st, plugRef[0], plugRef[1], slotRef[0], slotRef[1])
+	if err != nil {
+		return BadRequest("cannot explain connection policy: %v", err)
+	}
+	return SyncResponse(map[string]interface{}{
+		"connection":      newPolicyDecisionJSON(decision.ConnectRule, decision.ConnectError),
+		"auto-connection": newPolicyDecisionJSON(decision.AutoConnectRule, decision.AutoConnectError),
+	}, nil)
+}
+
 func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 	query := r.URL.Query()
 	aspect := query.Get("aspect")
@@ -153,6 +197,8 @@ func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 	case "change-timings":
 		chgID := query.Get("change-id")
 		return getChangeTimings(st, chgID)
+	case "connection-policy":
+		return getConnectionPolicy(st, query.Get("plug"), query.Get("slot"))
 	default:
 		return BadRequest("unknown debug aspect %q", aspect)
 	}
@@ -185,6 +231,8 @@ func postDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return SyncResponse(devicestate.CanManageRefreshes(st), nil)
 	case "connectivity":
 		return checkConnectivity(st)
+	case "reregister-device":
+		return reregisterDevice(st)
 	default:
 		return BadRequest("unknown debug action: %v", a.Action)
 	}
@@ -25,6 +25,10 @@ import (
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -154,3 +158,98 @@ func (s *postDebugSuite) TestGetDebugBaseDeclaration(c *check.C) {
 	c.Check(rsp.Result.(map[string]interface{})["base-declaration"],
 		testutil.Contains, "type: base-declaration")
 }
+
+func (s *postDebugSuite) TestPostDebugReregisterDevice(c *check.C) {
+	d := s.daemonWithOverlordMock(c)
+
+	st := d.overlord.State()
+	st.Lock()
+	devicestate.SetDevice(st, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc",
+		Serial: "serialserial",
+		KeyID:  "key-id",
+	})
+	st.Unlock()
+
+	buf := bytes.NewBufferString(`{"action": "reregister-device"}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "reregister-device")
+}
+
+func (s *postDebugSuite) TestPostDebugReregisterDeviceNotRegistered(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "reregister-device"}`)
+	req, err := http.NewRequest("POST", "/v2/debug", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "cannot re-register a device that is not registered yet")
+}
+
+func (s *apiSuite) TestGetDebugConnectionPolicy(c *check.C) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    deny-auto-connection: true
+`))
+	defer restore()
+
+	s.daemon(c)
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=connection-policy&plug=consumer:plug&slot=producer:slot", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"connection": &policyDecisionJSON{
+			Allowed: true,
+			Rule:    `slot rule of interface "test" in base-declaration`,
+		},
+		"auto-connection": &policyDecisionJSON{
+			Allowed: false,
+			Rule:    `slot rule of interface "test" in base-declaration`,
+			Error:   `auto-connection denied by slot rule of interface "test"`,
+		},
+	})
+}
+
+func (s *postDebugSuite) TestGetDebugConnectionPolicyBadRequest(c *check.C) {
+	s.daemon(c)
+
+	for _, query := range []string{
+		"plug=consumer:plug",
+		"plug=consumer&slot=producer:slot",
+		"plug=consumer:plug&slot=:slot",
+	} {
+		req, err := http.NewRequest("GET", "/v2/debug?aspect=connection-policy&"+query, nil)
+		c.Assert(err, check.IsNil)
+		rsp := getDebug(debugCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, 400, check.Commentf(query))
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, "connection-policy needs a plug and a slot given as <snap>:<name>")
+	}
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=connection-policy&plug=consumer:plug&slot=producer:slot", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getDebug(debugCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot explain connection policy: snap "consumer" has no plug named "plug"`)
+}
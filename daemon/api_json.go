@@ -20,6 +20,8 @@
 package daemon
 
 import (
+	"time"
+
 	"github.com/snapcore/snapd/interfaces"
 )
 
@@ -61,6 +63,11 @@ type interfaceAction struct {
 	Action string     `json:"action"`
 	Plugs  []plugJSON `json:"plugs,omitempty"`
 	Slots  []slotJSON `json:"slots,omitempty"`
+	// Restrictions narrow the attributes of the plug when connecting.
+	Restrictions map[string]interface{} `json:"restrictions,omitempty"`
+	// At and Window delay the start of a connect.
+	At     *time.Time `json:"at,omitempty"`
+	Window string     `json:"window,omitempty"`
 }
 
 // connectionsJSON aids in marshalling information about a single connection
@@ -73,6 +80,9 @@ type connectionJSON struct {
 	Gadget    bool                   `json:"gadget,omitempty"`
 	SlotAttrs map[string]interface{} `json:"slot-attrs,omitempty"`
 	PlugAttrs map[string]interface{} `json:"plug-attrs,omitempty"`
+	// PlugRestrictions are the administrator restrictions of the
+	// plug attributes.
+	PlugRestrictions map[string]interface{} `json:"plug-restrictions,omitempty"`
 }
 
 // legacyConnectionsJSON aids in marshaling legacy connections into JSON.
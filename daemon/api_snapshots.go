@@ -67,6 +67,8 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+
+	Secret *client.SnapshotSecret `json:"secret,omitempty"`
 }
 
 func (action snapshotAction) String() string {
@@ -110,9 +112,9 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 
 	switch action.Action {
 	case "check":
-		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users)
+		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users, action.Secret)
 	case "restore":
-		affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users)
+		affected, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users, action.Secret)
 	case "forget":
 		if len(action.Users) != 0 {
 			return BadRequest(`snapshot "forget" operation cannot specify users`)
@@ -127,6 +129,8 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		// woo
 	case client.ErrSnapshotSetNotFound, client.ErrSnapshotSnapsNotFound:
 		return NotFound("%v", err)
+	case client.ErrSnapshotPassphraseRequired:
+		return SnapshotPassphraseRequired("%v", err)
 	default:
 		return InternalError("%v", err)
 	}
@@ -64,8 +64,9 @@ func (s *snapshotSuite) TearDownTest(c *check.C) {
 }
 
 func (s *snapshotSuite) TestSnapshotMany(c *check.C) {
-	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string) (uint64, []string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string, secret *client.SnapshotSecret) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
+		c.Check(secret, check.IsNil)
 		t := s.NewTask("fake-snapshot-2", "Snapshot two")
 		return 1, snaps, state.NewTaskSet(t), nil
 	})()
@@ -80,6 +81,22 @@ func (s *snapshotSuite) TestSnapshotMany(c *check.C) {
 	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
 }
 
+func (s *snapshotSuite) TestSnapshotManyEncrypted(c *check.C) {
+	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string, secret *client.SnapshotSecret) (uint64, []string, *state.TaskSet, error) {
+		c.Check(secret, check.DeepEquals, &client.SnapshotSecret{Passphrase: "sekrit"})
+		t := s.NewTask("fake-snapshot-1", "Snapshot one")
+		return 1, snaps, state.NewTaskSet(t), nil
+	})()
+
+	inst := daemon.MustUnmarshalSnapInstruction(c, `{"action": "snapshot", "snaps": ["foo"], "encrypt": {"passphrase": "sekrit"}}`)
+	st := s.o.State()
+	st.Lock()
+	res, err := daemon.SnapshotMany(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(res.Summary, check.Equals, `Snapshot snaps "foo"`)
+}
+
 func (s *snapshotSuite) TestListSnapshots(c *check.C) {
 	snapshots := []client.SnapshotSet{{ID: 1}, {ID: 42}}
 
@@ -216,11 +233,11 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 func (s *snapshotSuite) TestChangeSnapshots404(c *check.C) {
 	var done string
 	expectedError := errors.New("bzzt")
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return nil, nil, expectedError
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return nil, nil, expectedError
 	})()
@@ -248,11 +265,11 @@ func (s *snapshotSuite) TestChangeSnapshots404(c *check.C) {
 func (s *snapshotSuite) TestChangeSnapshots500(c *check.C) {
 	var done string
 	expectedError := errors.New("bzzt")
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return nil, nil, expectedError
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return nil, nil, expectedError
 	})()
@@ -274,13 +291,64 @@ func (s *snapshotSuite) TestChangeSnapshots500(c *check.C) {
 	}
 }
 
+func (s *snapshotSuite) TestChangeSnapshotsPassphraseRequired(c *check.C) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
+		return nil, nil, client.ErrSnapshotPassphraseRequired
+	})()
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
+		return nil, nil, client.ErrSnapshotPassphraseRequired
+	})()
+	for _, action := range []string{"check", "restore"} {
+		comm := check.Commentf("%s", action)
+		body := fmt.Sprintf(`{"set": 42, "action": "%s"}`, action)
+		req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil, comm)
+
+		rsp := daemon.ChangeSnapshots(daemon.SnapshotCmd, req, nil)
+		c.Check(rsp.Type, check.Equals, daemon.ResponseTypeError, comm)
+		c.Check(rsp.Status, check.Equals, 400, comm)
+		c.Check(rsp.ErrorResult().Message, check.Equals, client.ErrSnapshotPassphraseRequired.Error(), comm)
+		c.Check(string(rsp.ErrorResult().Kind), check.Equals, client.ErrorKindSnapshotPassphraseRequired, comm)
+	}
+}
+
+func (s *snapshotSuite) TestChangeSnapshotWithPassphrase(c *check.C) {
+	var secrets []*client.SnapshotSecret
+	defer daemon.MockSnapshotCheck(func(_ *state.State, _ uint64, _, _ []string, secret *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
+		secrets = append(secrets, secret)
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+	defer daemon.MockSnapshotRestore(func(_ *state.State, _ uint64, _, _ []string, secret *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
+		secrets = append(secrets, secret)
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+
+	st := s.o.State()
+	for _, action := range []string{"check", "restore"} {
+		comm := check.Commentf("%s", action)
+		body := fmt.Sprintf(`{"set": 42, "action": "%s", "secret": {"passphrase": "sekrit"}}`, action)
+		req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil, comm)
+
+		rsp := daemon.ChangeSnapshots(daemon.SnapshotCmd, req, nil)
+		c.Check(rsp.Type, check.Equals, daemon.ResponseTypeAsync, comm)
+
+		// the passphrase is not in the change summary
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		c.Check(chg.Summary(), check.Not(check.Matches), ".*sekrit.*", comm)
+		st.Unlock()
+	}
+	c.Check(secrets, check.DeepEquals, []*client.SnapshotSecret{{Passphrase: "sekrit"}, {Passphrase: "sekrit"}})
+}
+
 func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	var done string
-	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotCheck(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
 		done = "check"
 		return []string{"foo"}, state.NewTaskSet(), nil
 	})()
-	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error) {
 		done = "restore"
 		return []string{"foo"}, state.NewTaskSet(), nil
 	})()
@@ -61,6 +61,7 @@ import (
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -3323,6 +3324,244 @@ func (s *apiSuite) TestSetConfBadSnap(c *check.C) {
 		"type": "error"})
 }
 
+var configSchemaYaml = `
+name: config-snap
+version: 1
+hooks:
+    configure:
+config-schema:
+  port:
+    type: integer
+    description: |
+      The port to listen on.
+      Must be unprivileged.
+    default: 8080
+    minimum: 1024
+  server:
+    type: object
+    properties:
+      mode:
+        type: string
+        enum: [fast, slow]
+`
+
+func (s *apiSuite) TestSetConfInvalidForSchema(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, configSchemaYaml)
+
+	text, err := json.Marshal(map[string]interface{}{"port": 80})
+	c.Assert(err, check.IsNil)
+
+	buffer := bytes.NewBuffer(text)
+	req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf", buffer)
+	c.Assert(err, check.IsNil)
+
+	s.vars = map[string]string{"name": "config-snap"}
+
+	rec := httptest.NewRecorder()
+	snapConfCmd.PUT(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"message": `cannot set snap "config-snap" option "port": value must be at least 1024`,
+	})
+}
+
+func (s *apiSuite) TestGetConfDescribe(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, configSchemaYaml)
+
+	s.vars = map[string]string{"name": "config-snap"}
+	req, err := http.NewRequest("GET", "/v2/snaps/config-snap/conf?describe=true", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	snapConfCmd.GET(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, []interface{}{
+		map[string]interface{}{
+			"key":         "port",
+			"type":        "integer",
+			"description": "The port to listen on.\nMust be unprivileged.\n",
+			"default":     8080.,
+			"minimum":     1024.,
+		},
+		map[string]interface{}{
+			"key":  "server",
+			"type": "object",
+		},
+		map[string]interface{}{
+			"key":  "server.mode",
+			"type": "string",
+			"enum": []interface{}{"fast", "slow"},
+		},
+	})
+}
+
+func (s *apiSuite) TestGetConfDescribeNoSchema(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	s.vars = map[string]string{"name": "config-snap"}
+	req, err := http.NewRequest("GET", "/v2/snaps/config-snap/conf?describe=true", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	snapConfCmd.GET(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, []interface{}{})
+}
+
+func (s *apiSuite) TestGetConfHistory(c *check.C) {
+	d := s.daemon(c)
+
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.SetOrigin("uid 0", "42")
+	tr.Set("test-snap", "foo", "bar")
+	tr.Commit()
+	tr = config.NewTransaction(st)
+	tr.Set("test-snap", "foo", 1)
+	tr.Commit()
+	history, err := config.History(st, "test-snap")
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 2)
+
+	s.vars = map[string]string{"name": "test-snap"}
+	req, err := http.NewRequest("GET", "/v2/snaps/test-snap/conf?history=true", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	snapConfCmd.GET(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, []interface{}{
+		map[string]interface{}{
+			"id":        1.,
+			"time":      history[0].Time.Format(time.RFC3339Nano),
+			"origin":    "uid 0",
+			"change-id": "42",
+			"changes": []interface{}{
+				map[string]interface{}{"key": "foo", "new": "bar"},
+			},
+		},
+		map[string]interface{}{
+			"id":   2.,
+			"time": history[1].Time.Format(time.RFC3339Nano),
+			"changes": []interface{}{
+				map[string]interface{}{"key": "foo", "old": "bar", "new": 1.},
+			},
+		},
+	})
+}
+
+func (s *apiSuite) TestSetConfRevert(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	// Mock the hook runner
+	hookRunner := testutil.MockCommand(c, "snap", "")
+	defer hookRunner.Restore()
+
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("config-snap", "foo", "bar")
+	tr.Commit()
+	tr = config.NewTransaction(st)
+	tr.Set("config-snap", "foo", "baz")
+	tr.Set("config-snap", "other", true)
+	tr.Commit()
+	st.Unlock()
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	s.vars = map[string]string{"name": "config-snap"}
+	req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf?revert=1", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	snapConfCmd.PUT(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	id := body["change"].(string)
+
+	st.Lock()
+	chg := st.Change(id)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Revert configuration of "config-snap" snap to change 1`)
+	st.Unlock()
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Err(), check.IsNil)
+
+	var foo string
+	var other interface{}
+	tr = config.NewTransaction(st)
+	c.Check(tr.Get("config-snap", "foo", &foo), check.IsNil)
+	c.Check(foo, check.Equals, "bar")
+	c.Check(tr.Get("config-snap", "other", &other), check.IsNil)
+	c.Check(other, check.IsNil)
+
+	history, err := config.History(st, "config-snap")
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 3)
+	c.Check(history[2].ChangeID, check.Equals, id)
+}
+
+func (s *apiSuite) TestSetConfRevertErrors(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	st := d.overlord.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("config-snap", "foo", "bar")
+	tr.Commit()
+	st.Unlock()
+
+	s.vars = map[string]string{"name": "config-snap"}
+	for _, t := range []struct {
+		revert string
+		err    string
+	}{
+		{"foo", `invalid configuration history entry "foo"`},
+		{"-1", `invalid configuration history entry "-1"`},
+		{"2", `snap "config-snap" has no configuration change 2 in its history`},
+		{"1", `configuration of snap "config-snap" already matches configuration change 1`},
+	} {
+		req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf?revert="+t.revert, nil)
+		c.Assert(err, check.IsNil)
+		rec := httptest.NewRecorder()
+		snapConfCmd.PUT(snapConfCmd, req, nil).ServeHTTP(rec, req)
+		c.Check(rec.Code, check.Equals, 400)
+
+		var body map[string]interface{}
+		err = json.Unmarshal(rec.Body.Bytes(), &body)
+		c.Assert(err, check.IsNil)
+		c.Check(body["result"], check.DeepEquals, map[string]interface{}{"message": t.err})
+	}
+}
+
 func simulateConflict(o *overlord.Overlord, name string) {
 	st := o.State()
 	st.Lock()
@@ -3698,6 +3937,161 @@ func (s *apiSuite) TestPostSnapsOp(c *check.C) {
 	c.Check(apiData["snap-names"], check.DeepEquals, []interface{}{"fake1", "fake2"})
 }
 
+func (s *apiSuite) TestPostSnapsOpRefreshAllSnapsTransaction(c *check.C) {
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
+	snapstateUpdateMany = func(_ context.Context, s *state.State, names []string, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.DeepEquals, []string{"fake1", "fake2"})
+		c.Assert(flags, check.NotNil)
+		c.Check(flags.Transaction, check.Equals, client.TransactionAllSnaps)
+		t := s.NewTask("fake-refresh-2", "Refreshing two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "snaps": ["fake1", "fake2"], "transaction": "all-snaps"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Check(chg.Summary(), check.Equals, `Refresh snaps "fake1", "fake2" as one transaction`)
+}
+
+func (s *apiSuite) TestPostSnapsOpInvalidTransaction(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	for _, tc := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "refresh", "transaction": "some-snaps"}`, `invalid value for transaction type: some-snaps`},
+		{`{"action": "remove", "snaps": ["foo"], "transaction": "all-snaps"}`, `transaction type is unsupported for "remove" actions`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(tc.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+		c.Assert(ok, check.Equals, true)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, tc.err)
+	}
+}
+
+func (s *apiSuite) TestPostSnapScheduled(c *check.C) {
+	snapstateUpdate = func(s *state.State, name, channel string, revision snap.Revision, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		c.Fatalf("unexpected refresh")
+		return nil, nil
+	}
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error {
+		c.Fatalf("unexpected assertions refresh")
+		return nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "refresh", "channel": "beta", "ignore-validation": true, "at": %q}`, at.Format(time.RFC3339)))
+	req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+	c.Assert(err, check.IsNil)
+
+	s.vars = map[string]string{"name": "some-snap"}
+	rsp, ok := postSnap(snapCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Check(chg.Summary(), check.Equals, fmt.Sprintf(`Refresh "some-snap" snap (scheduled at %s)`, at.Format(time.RFC3339)))
+	// the tasks of the refresh are only created once it is due
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "wait-for-schedule")
+	var sc snapstate.ScheduledChange
+	c.Assert(tasks[0].Get("scheduled-change", &sc), check.IsNil)
+	c.Check(sc.Kind, check.Equals, "refresh")
+	c.Check(sc.Snaps, check.DeepEquals, []string{"some-snap"})
+	c.Check(sc.Channel, check.Equals, "beta")
+	c.Check(sc.Flags, check.DeepEquals, snapstate.Flags{IgnoreValidation: true})
+	c.Check(sc.Schedule.At.Equal(at), check.Equals, true)
+}
+
+func (s *apiSuite) TestPostSnapsOpScheduled(c *check.C) {
+	snapstateRemoveMany = func(s *state.State, names []string) ([]string, []*state.TaskSet, error) {
+		c.Fatalf("unexpected remove")
+		return nil, nil, nil
+	}
+
+	d := s.daemonWithOverlordMock(c)
+
+	// a change in progress does not prevent scheduling
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.NewChange("refresh-snap", "...")
+	t := st.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "fake1"}})
+	chg.AddTask(t)
+	st.Unlock()
+
+	buf := bytes.NewBufferString(`{"action": "remove", "snaps": ["fake1", "fake2"], "window": "sat,1:00-3:00"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg = st.Change(rsp.Change)
+	c.Check(chg.Summary(), check.Equals, `Remove snaps "fake1", "fake2" (scheduled in window "sat,1:00-3:00")`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "wait-for-schedule")
+	c.Check(tasks[0].Summary(), check.Equals, `Wait for window "sat,1:00-3:00"`)
+	var sc snapstate.ScheduledChange
+	c.Assert(tasks[0].Get("scheduled-change", &sc), check.IsNil)
+	c.Check(sc.Kind, check.Equals, "remove")
+	c.Check(sc.Snaps, check.DeepEquals, []string{"fake1", "fake2"})
+	c.Check(sc.Schedule.Window, check.Equals, "sat,1:00-3:00")
+}
+
+func (s *apiSuite) TestPostSnapsOpInvalidSchedule(c *check.C) {
+	s.daemonWithOverlordMock(c)
+
+	for _, tc := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "refresh", "window": "10:00-"}`, `cannot schedule change: cannot parse "10:00-": .*`},
+		{`{"action": "install", "snaps": ["foo"], "at": "2019-01-01T00:00:00Z"}`, `cannot schedule change: time 2019-01-01T00:00:00Z is in the past`},
+		{`{"action": "remove", "snaps": ["foo"], "at": "2999-01-01T00:00:00Z", "window": "sat"}`, `cannot schedule change: cannot use both a time and a window`},
+		{`{"action": "snapshot", "window": "sat"}`, `scheduling is unsupported for "snapshot" actions`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(tc.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+		c.Assert(ok, check.Equals, true)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, tc.err)
+	}
+}
+
 func (s *apiSuite) TestRefreshAll(c *check.C) {
 	refreshSnapDecls := false
 	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
@@ -4452,6 +4846,144 @@ func (s *apiSuite) TestConnectPlugFailureNoSuchPlug(c *check.C) {
 	c.Assert(ifaces.Connections, check.HasLen, 0)
 }
 
+func (s *apiSuite) TestConnectRestrictionsNotSupported(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, consumerYaml)
+
+	action := &interfaceAction{
+		Action:       "connect",
+		Plugs:        []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:        []slotJSON{{Snap: "producer", Name: "slot"}},
+		Restrictions: map[string]interface{}{"key": "value"},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"message": `interface "test" does not support restrictions`,
+	})
+
+	repo := d.overlord.InterfaceManager().Repository()
+	ifaces := repo.Interfaces()
+	c.Assert(ifaces.Connections, check.HasLen, 0)
+}
+
+func (s *apiSuite) TestDisconnectWithRestrictions(c *check.C) {
+	s.daemon(c)
+
+	action := &interfaceAction{
+		Action:       "disconnect",
+		Plugs:        []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:        []slotJSON{{Snap: "producer", Name: "slot"}},
+		Restrictions: map[string]interface{}{"key": "value"},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"message": "restrictions can only be used when connecting",
+	})
+}
+
+func (s *apiSuite) TestConnectScheduled(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	action := &interfaceAction{
+		Action: "connect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+		At:     &at,
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(id)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, fmt.Sprintf("Connect consumer:plug to producer:slot (scheduled at %s)", at.Format(time.RFC3339)))
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "wait-for-schedule")
+	var sc snapstate.ScheduledChange
+	c.Assert(tasks[0].Get("scheduled-change", &sc), check.IsNil)
+	c.Check(sc, check.DeepEquals, snapstate.ScheduledChange{
+		Kind:     "connect",
+		PlugSnap: "consumer",
+		PlugName: "plug",
+		SlotSnap: "producer",
+		SlotName: "slot",
+		Schedule: snapstate.ChangeSchedule{At: sc.Schedule.At},
+	})
+	c.Check(sc.Schedule.At.Equal(at), check.Equals, true)
+}
+
+func (s *apiSuite) TestDisconnectScheduled(c *check.C) {
+	s.daemon(c)
+
+	action := &interfaceAction{
+		Action: "disconnect",
+		Plugs:  []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:  []slotJSON{{Snap: "producer", Name: "slot"}},
+		Window: "sat",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/interfaces", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	interfacesCmd.POST(interfacesCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"message": "scheduling can only be used when connecting",
+	})
+}
+
 func (s *apiSuite) TestConnectAlreadyConnected(c *check.C) {
 	d := s.daemon(c)
 
@@ -5202,6 +5734,97 @@ func (s *apiSuite) TestStateChangesForSnapName(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *apiSuite) TestStateChangesHistory(c *check.C) {
+	t0 := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	err := changehistory.Append([]*changehistory.Record{
+		{ID: "1", Kind: "install-snap", Status: "Done", ReadyTime: t0, Snaps: []changehistory.SnapRecord{{Name: "foo", Revision: snap.R(1)}}},
+		{ID: "2", Kind: "remove-snap", Status: "Done", ReadyTime: t0.Add(time.Hour), Snaps: []changehistory.SnapRecord{{Name: "bar"}}},
+		{ID: "3", Kind: "refresh-snap", Status: "Done", ReadyTime: t0.Add(2 * time.Hour), Snaps: []changehistory.SnapRecord{{Name: "foo", Revision: snap.R(2), OldRevision: snap.R(1)}}},
+	})
+	c.Assert(err, check.IsNil)
+
+	// in-state changes are not part of the history
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	setupChanges(st)
+	st.Unlock()
+
+	for _, t := range []struct {
+		query string
+		ids   []string
+	}{
+		{"select=history", []string{"1", "2", "3"}},
+		{"select=history&for=foo", []string{"1", "3"}},
+		{"select=history&since=2019-05-01T11:00:00Z", []string{"2", "3"}},
+		{"select=history&for=foo&since=2019-05-01T11:00:00Z", []string{"3"}},
+		{"select=history&for=baz", nil},
+	} {
+		req, err := http.NewRequest("GET", "/v2/changes?"+t.query, nil)
+		c.Assert(err, check.IsNil)
+		rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+		c.Assert(rsp.Status, check.Equals, 200, check.Commentf(t.query))
+		c.Assert(rsp.Result, check.FitsTypeOf, []*changehistory.Record(nil))
+		var ids []string
+		for _, rec := range rsp.Result.([]*changehistory.Record) {
+			ids = append(ids, rec.ID)
+		}
+		c.Check(ids, check.DeepEquals, t.ids, check.Commentf(t.query))
+	}
+
+	req, err := http.NewRequest("GET", "/v2/changes?select=history&for=foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	res, err := rsp.MarshalJSON()
+	c.Assert(err, check.IsNil)
+	c.Check(string(res), check.Matches, `.*{"id":"3","kind":"refresh-snap","summary":"","status":"Done","spawn-time":"0001-01-01T00:00:00Z","ready-time":"2019-05-01T12:00:00Z","snaps":\[{"name":"foo","revision":"2","old-revision":"1"}\]}.*`)
+}
+
+func (s *apiSuite) TestStateChangesHistoryUserOnlyForRoot(c *check.C) {
+	err := changehistory.Append([]*changehistory.Record{
+		{ID: "1", Kind: "install-snap", Status: "Done", User: "someone@example.com", Snaps: []changehistory.SnapRecord{{Name: "foo", Revision: snap.R(1)}}},
+	})
+	c.Assert(err, check.IsNil)
+	newTestDaemon(c)
+
+	for _, t := range []struct {
+		remoteAddr string
+		user       string
+	}{
+		{"pid=100;uid=0;socket=;", "someone@example.com"},
+		{"pid=100;uid=1000;socket=;", ""},
+		{"", ""},
+	} {
+		req, err := http.NewRequest("GET", "/v2/changes?select=history", nil)
+		c.Assert(err, check.IsNil)
+		req.RemoteAddr = t.remoteAddr
+		rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+		c.Assert(rsp.Status, check.Equals, 200)
+		res := rsp.Result.([]*changehistory.Record)
+		c.Assert(res, check.HasLen, 1)
+		c.Check(res[0].User, check.Equals, t.user, check.Commentf(t.remoteAddr))
+		buf, err := rsp.MarshalJSON()
+		c.Assert(err, check.IsNil)
+		if t.user == "" {
+			c.Check(string(buf), check.Not(check.Matches), ".*someone.*")
+		}
+	}
+}
+
+func (s *apiSuite) TestStateChangesHistoryBadRequest(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/changes?select=history&since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `invalid since parameter: "yesterday"`)
+
+	req, err = http.NewRequest("GET", "/v2/changes?select=bogus", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `select should be one of: all,in-progress,ready,history`)
+}
+
 func (s *apiSuite) TestStateChange(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
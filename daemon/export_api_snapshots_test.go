@@ -31,7 +31,7 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 )
 
-func MockSnapshotSave(newSave func(*state.State, []string, []string) (uint64, []string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotSave(newSave func(*state.State, []string, []string, *client.SnapshotSecret) (uint64, []string, *state.TaskSet, error)) (restore func()) {
 	oldSave := snapshotSave
 	snapshotSave = newSave
 	return func() {
@@ -47,7 +47,7 @@ func MockSnapshotList(newList func(context.Context, uint64, []string) ([]client.
 	}
 }
 
-func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldCheck := snapshotCheck
 	snapshotCheck = newCheck
 	return func() {
@@ -55,7 +55,7 @@ func MockSnapshotCheck(newCheck func(*state.State, uint64, []string, []string) (
 	}
 }
 
-func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotRestore(newRestore func(*state.State, uint64, []string, []string, *client.SnapshotSecret) ([]string, *state.TaskSet, error)) (restore func()) {
 	oldRestore := snapshotRestore
 	snapshotRestore = newRestore
 	return func() {
@@ -188,6 +188,8 @@ const (
 
 	errorKindDaemonRestart = errorKind("daemon-restart")
 	errorKindSystemRestart = errorKind("system-restart")
+
+	errorKindSnapshotPassphraseRequired = errorKind("snapshot-passphrase-required")
 )
 
 type errorValue interface{}
@@ -496,6 +498,21 @@ func InterfacesUnchanged(format string, v ...interface{}) Response {
 	}
 }
 
+// SnapshotPassphraseRequired is an error responder used when an
+// operation on a snapshot set that is encrypted with a passphrase is
+// requested without one.
+func SnapshotPassphraseRequired(format string, v ...interface{}) Response {
+	res := &errorResult{
+		Message: fmt.Sprintf(format, v...),
+		Kind:    errorKindSnapshotPassphraseRequired,
+	}
+	return &resp{
+		Type:   ResponseTypeError,
+		Result: res,
+		Status: 400,
+	}
+}
+
 func errToResponse(err error, snaps []string, fallback func(format string, v ...interface{}) Response, format string, v ...interface{}) Response {
 	var kind errorKind
 	var snapName string
@@ -66,8 +66,10 @@ var (
 	SnapAssertsSpoolDir   string
 	SnapSeqDir            string
 
-	SnapStateFile     string
-	SnapSystemKeyFile string
+	SnapStateFile         string
+	SnapStateJournalFile  string
+	SnapChangeHistoryFile string
+	SnapSystemKeyFile     string
 
 	SnapRepairDir        string
 	SnapRepairStateFile  string
@@ -81,11 +83,12 @@ var (
 	SnapCommandsDB      string
 	SnapAuxStoreInfoDir string
 
-	SnapBinariesDir     string
-	SnapServicesDir     string
-	SnapSystemdConfDir  string
-	SnapDesktopFilesDir string
-	SnapBusPolicyDir    string
+	SnapBinariesDir        string
+	SnapServicesDir        string
+	SnapRuntimeServicesDir string
+	SnapSystemdConfDir     string
+	SnapDesktopFilesDir    string
+	SnapBusPolicyDir       string
 
 	SystemApparmorDir      string
 	SystemApparmorCacheDir string
@@ -239,6 +242,8 @@ func SetRootDir(rootdir string) {
 	SnapSeqDir = filepath.Join(rootdir, snappyDir, "sequence")
 
 	SnapStateFile = filepath.Join(rootdir, snappyDir, "state.json")
+	SnapStateJournalFile = filepath.Join(rootdir, snappyDir, "state.json.journal")
+	SnapChangeHistoryFile = filepath.Join(rootdir, snappyDir, "change-history")
 	SnapSystemKeyFile = filepath.Join(rootdir, snappyDir, "system-key")
 
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
@@ -258,6 +263,7 @@ func SetRootDir(rootdir string) {
 
 	SnapBinariesDir = filepath.Join(SnapMountDir, "bin")
 	SnapServicesDir = filepath.Join(rootdir, "/etc/systemd/system")
+	SnapRuntimeServicesDir = filepath.Join(rootdir, "/run/systemd/system")
 	SnapSystemdConfDir = filepath.Join(rootdir, "/etc/systemd/system.conf.d")
 	SnapBusPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/system.d")
 
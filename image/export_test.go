@@ -54,3 +54,19 @@ func (opts *DownloadOptions) Validate() error {
 }
 
 var ErrRevisionAndCohort = errRevisionAndCohort
+
+func MockGetuid(f func() int) (restore func()) {
+	old := osGetuid
+	osGetuid = f
+	return func() {
+		osGetuid = old
+	}
+}
+
+func MockProcSelfMountInfo(path string) (restore func()) {
+	old := procSelfMountInfo
+	procSelfMountInfo = path
+	return func() {
+		procSelfMountInfo = old
+	}
+}
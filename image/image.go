@@ -38,6 +38,7 @@ import (
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/squashfs"
+	"github.com/snapcore/snapd/spdx"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -55,6 +56,10 @@ type Options struct {
 	ModelFile       string
 	GadgetUnpackDir string
 
+	// ManifestFile is where to write a copy of the seed manifest,
+	// in addition to the one next to seed.yaml.
+	ManifestFile string
+
 	// Architecture to use if none is specified by the model,
 	// useful only for classic mode. If set must match the model otherwise.
 	Architecture string
@@ -524,6 +529,12 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options, local *l
 	var locals []string
 	downloadedSnapsInfoForBootConfig := map[string]*snap.Info{}
 	var seedYaml snap.Seed
+	manifest := &snap.SeedManifest{
+		Series:  model.Series(),
+		BrandID: model.BrandID(),
+		Model:   model.Model(),
+	}
+	var badLicenses []string
 	for _, snapName := range snaps {
 		name := local.Name(snapName)
 		if seen[name] {
@@ -575,12 +586,14 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options, local *l
 			return fmt.Errorf("cannot use classic snap %q in a core system", info.InstanceName())
 		}
 
+		var publisher string
 		// if it comes from the store fetch the snap assertions too
 		if info.SnapID != "" {
 			snapDecl, err := FetchAndCheckSnapAssertions(fn, info, f, db)
 			if err != nil {
 				return err
 			}
+			publisher = snapDecl.PublisherID()
 			var kind string
 			switch typ {
 			case snap.TypeKernel:
@@ -590,7 +603,6 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options, local *l
 			}
 			if kind != "" { // kernel or gadget
 				// TODO: share helpers with devicestate if the policy becomes much more complicated
-				publisher := snapDecl.PublisherID()
 				if publisher != model.BrandID() && publisher != "canonical" {
 					return fmt.Errorf("cannot use %s %q published by %q for model by %q", kind, name, publisher, model.BrandID())
 				}
@@ -630,6 +642,18 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options, local *l
 			// no assertions for this snap were put in the seed
 			Unasserted: info.SnapID == "",
 		})
+
+		manifestSnap, err := makeManifestSnap(fn, info, snapChannel, publisher)
+		if err != nil {
+			return err
+		}
+		if manifestSnap.LicenseError != "" {
+			badLicenses = append(badLicenses, manifestSnap.Name)
+		}
+		manifest.Snaps = append(manifest.Snaps, manifestSnap)
+	}
+	if len(badLicenses) > 0 {
+		fmt.Fprintf(Stderr, "WARNING: %s have a license that is not a valid SPDX expression, see the seed manifest\n", strutil.Quoted(badLicenses))
 	}
 	if len(locals) > 0 {
 		fmt.Fprintf(Stderr, "WARNING: %s were installed from local snaps disconnected from a store and cannot be refreshed subsequently!\n", strutil.Quoted(locals))
@@ -670,6 +694,15 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options, local *l
 		return fmt.Errorf("cannot write seed.yaml: %s", err)
 	}
 
+	if err := manifest.Write(filepath.Join(dirs.SnapSeedDir, "manifest.yaml")); err != nil {
+		return fmt.Errorf("cannot write the seed manifest: %s", err)
+	}
+	if opts.ManifestFile != "" {
+		if err := manifest.Write(opts.ManifestFile); err != nil {
+			return fmt.Errorf("cannot write the seed manifest: %s", err)
+		}
+	}
+
 	if opts.Classic {
 		// warn about ownership if not root:root
 		fi, err := os.Stat(seedFn)
@@ -702,6 +735,35 @@ func setupSeed(tsto *ToolingStore, model *asserts.Model, opts *Options, local *l
 	return nil
 }
 
+func makeManifestSnap(snapPath string, info *snap.Info, channel, publisher string) (*snap.SeedManifestSnap, error) {
+	sha3_384, _, err := asserts.SnapFileSHA3_384(snapPath)
+	if err != nil {
+		return nil, err
+	}
+	manifestSnap := &snap.SeedManifestSnap{
+		Name:      info.InstanceName(),
+		SnapID:    info.SnapID,
+		Revision:  info.Revision,
+		Channel:   channel,
+		File:      filepath.Base(snapPath),
+		SHA3_384:  sha3_384,
+		Publisher: publisher,
+		Base:      info.Base,
+	}
+	if info.License == "" {
+		return manifestSnap, nil
+	}
+	license, err := spdx.NormalizeLicense(info.License)
+	if err != nil {
+		// keep the license as it came
+		manifestSnap.License = info.License
+		manifestSnap.LicenseError = err.Error()
+	} else {
+		manifestSnap.License = license
+	}
+	return manifestSnap, nil
+}
+
 func setBootvars(downloadedSnapsInfoForBootConfig map[string]*snap.Info, model *asserts.Model) error {
 	if len(downloadedSnapsInfoForBootConfig) != 2 {
 		return fmt.Errorf("setBootvars can only be called with exactly one kernel and exactly one core/base boot info: %v", downloadedSnapsInfoForBootConfig)
@@ -711,6 +711,75 @@ func (s *imageSuite) TestSetupSeed(c *C) {
 	c.Check(s.stderr.String(), Equals, "")
 }
 
+func (s *imageSuite) TestSetupSeedManifest(c *C) {
+	restore := image.MockTrusted(s.storeSigning.Trusted)
+	defer restore()
+
+	rootdir := filepath.Join(c.MkDir(), "imageroot")
+	seeddir := filepath.Join(rootdir, "var/lib/snapd/seed")
+	seedsnapsdir := filepath.Join(seeddir, "snaps")
+
+	gadgetUnpackDir := c.MkDir()
+	s.setupSnaps(c, gadgetUnpackDir, map[string]string{
+		"pc":        "canonical",
+		"pc-kernel": "canonical",
+	})
+	s.storeSnapInfo["core"].License = "GPL-3.0"
+	s.storeSnapInfo["pc-kernel"].License = "gpl-2.0 and  mit"
+	s.storeSnapInfo["required-snap1"].License = "Other Open Source"
+
+	manifestFn := filepath.Join(c.MkDir(), "image.manifest")
+	opts := &image.Options{
+		RootDir:         rootdir,
+		GadgetUnpackDir: gadgetUnpackDir,
+		ManifestFile:    manifestFn,
+	}
+	local, err := image.LocalSnaps(s.tsto, opts)
+	c.Assert(err, IsNil)
+
+	err = image.SetupSeed(s.tsto, s.model, opts, local)
+	c.Assert(err, IsNil)
+
+	manifest, err := snap.ReadSeedManifest(filepath.Join(seeddir, "manifest.yaml"))
+	c.Assert(err, IsNil)
+	// and a copy was written where requested
+	manifestCopy, err := snap.ReadSeedManifest(manifestFn)
+	c.Assert(err, IsNil)
+	c.Check(manifestCopy, DeepEquals, manifest)
+
+	c.Check(manifest.Series, Equals, "16")
+	c.Check(manifest.BrandID, Equals, "my-brand")
+	c.Check(manifest.Model, Equals, "my-model")
+	c.Assert(manifest.Snaps, HasLen, 4)
+
+	licenses := map[string]string{
+		"core":      "GPL-3.0",
+		"pc-kernel": "GPL-2.0 AND MIT",
+	}
+	for i, name := range []string{"core", "pc-kernel", "pc"} {
+		info := s.storeSnapInfo[name]
+		fn := filepath.Base(info.MountFile())
+		sha3_384, _, err := asserts.SnapFileSHA3_384(filepath.Join(seedsnapsdir, fn))
+		c.Assert(err, IsNil)
+		c.Check(manifest.Snaps[i], DeepEquals, &snap.SeedManifestSnap{
+			Name:      name,
+			SnapID:    name + "-Id",
+			Revision:  info.Revision,
+			File:      fn,
+			SHA3_384:  sha3_384,
+			Publisher: "canonical",
+			License:   licenses[name],
+		})
+	}
+	c.Check(manifest.Snaps[3].Name, Equals, "required-snap1")
+	c.Check(manifest.Snaps[3].Publisher, Equals, "other")
+	c.Check(manifest.Snaps[3].License, Equals, "Other Open Source")
+	c.Check(manifest.Snaps[3].LicenseError, Equals, "unknown license: Other")
+
+	c.Check(s.stderr.String(), Equals, `WARNING: "required-snap1" have a license that is not a valid SPDX expression, see the seed manifest
+`)
+}
+
 func (s *imageSuite) TestSetupSeedLocalCoreBrandKernel(c *C) {
 	restore := image.MockTrusted(s.storeSigning.Trusted)
 	defer restore()
@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+var (
+	osGetuid          = os.Getuid
+	procSelfMountInfo = osutil.ProcSelfMountInfo
+)
+
+// the filesystems needed by snapd inside the chroot
+var preseedMounts = []struct {
+	fstype string
+	source string
+	target string
+}{
+	{"proc", "proc", "/proc"},
+	{"sysfs", "sysfs", "/sys"},
+	{"", "/dev", "/dev"},
+}
+
+func inChroot(chrootDir, path string) string {
+	return filepath.Join(chrootDir, dirs.StripRootDir(path))
+}
+
+func mountPreseed(chrootDir string) (umount func(), err error) {
+	var mounted []string
+	umount = func() {
+		for i := len(mounted) - 1; i >= 0; i-- {
+			if output, err := exec.Command("umount", mounted[i]).CombinedOutput(); err != nil {
+				fmt.Fprintf(Stderr, "WARNING: cannot unmount %s: %v\n", mounted[i], osutil.OutputErr(output, err))
+			}
+		}
+	}
+	for _, m := range preseedMounts {
+		target := filepath.Join(chrootDir, m.target)
+		var cmd *exec.Cmd
+		if m.fstype == "" {
+			cmd = exec.Command("mount", "--bind", m.source, target)
+		} else {
+			cmd = exec.Command("mount", "-t", m.fstype, m.source, target)
+		}
+		if output, err := cmd.CombinedOutput(); err != nil {
+			umount()
+			return nil, fmt.Errorf("cannot mount %s: %v", target, osutil.OutputErr(output, err))
+		}
+		mounted = append(mounted, target)
+	}
+	return umount, nil
+}
+
+// unmountSnaps unmounts what snapd mounted under the chroot, on the
+// actual device the mount units take care of that.
+func unmountSnaps(chrootDir string) error {
+	entries, err := osutil.LoadMountInfo(procSelfMountInfo)
+	if err != nil {
+		return err
+	}
+	snapMountDir := inChroot(chrootDir, dirs.SnapMountDir) + "/"
+	var mounted []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.MountDir, snapMountDir) {
+			mounted = append(mounted, e.MountDir)
+		}
+	}
+	// deepest first
+	sort.Sort(sort.Reverse(sort.StringSlice(mounted)))
+	for _, dir := range mounted {
+		if output, err := exec.Command("umount", "-d", "-l", dir).CombinedOutput(); err != nil {
+			return fmt.Errorf("cannot unmount %s: %v", dir, osutil.OutputErr(output, err))
+		}
+	}
+	return nil
+}
+
+// Preseed runs snapd in preseed mode in the given chroot of a prepared
+// classic image so that the seeding of the image is performed as far as
+// it is possible without booting the actual device. On first boot snapd
+// resumes the seeding from there.
+func Preseed(chrootDir string) error {
+	if osGetuid() != 0 {
+		return fmt.Errorf("cannot preseed without root privileges")
+	}
+
+	snapdPath := filepath.Join(chrootDir, dirs.CoreLibExecDir, "snapd")
+	if !osutil.FileExists(snapdPath) {
+		return fmt.Errorf("cannot preseed without snapd in the chroot: %s is missing", snapdPath)
+	}
+	if osutil.FileExists(inChroot(chrootDir, dirs.SnapStateFile)) {
+		return fmt.Errorf("the system at %q appears to be preseeded already, reset it first", chrootDir)
+	}
+
+	umount, err := mountPreseed(chrootDir)
+	if err != nil {
+		return err
+	}
+	defer umount()
+
+	cmd := exec.Command("chroot", chrootDir, filepath.Join(dirs.CoreLibExecDir, "snapd"))
+	cmd.Env = append(os.Environ(), "SNAPD_PRESEED=1", "SNAP_REEXEC=0")
+	cmd.Stdout = Stdout
+	cmd.Stderr = Stderr
+	runErr := cmd.Run()
+
+	if err := unmountSnaps(chrootDir); err != nil {
+		return err
+	}
+	if runErr != nil {
+		return fmt.Errorf("cannot preseed %q: %v", chrootDir, runErr)
+	}
+	return nil
+}
+
+// ResetPreseededChroot removes the state and the artifacts of a
+// previous Preseed run from the given chroot, so that it can be
+// preseeded again.
+func ResetPreseededChroot(chrootDir string) error {
+	globs := []string{
+		dirs.SnapStateFile,
+		dirs.SnapStateJournalFile,
+		dirs.SnapSystemKeyFile,
+		filepath.Join(dirs.SnapBlobDir, "*.snap"),
+		filepath.Join(dirs.SnapSeqDir, "*.json"),
+		filepath.Join(dirs.SnapServicesDir, "snap-*.mount"),
+		filepath.Join(dirs.SnapServicesDir, "snap.*"),
+		filepath.Join(dirs.SnapServicesDir, "*.wants", "snap-*.mount"),
+		filepath.Join(dirs.SnapServicesDir, "*.wants", "snap.*"),
+		filepath.Join(dirs.SnapAppArmorDir, "*"),
+		filepath.Join(dirs.SnapSeccompDir, "*"),
+		filepath.Join(dirs.SnapMountPolicyDir, "*"),
+		filepath.Join(dirs.SnapUdevRulesDir, "*-snap.*.rules"),
+		filepath.Join(dirs.SnapBusPolicyDir, "snap.*.conf"),
+		filepath.Join(dirs.SnapKModModulesDir, "snap.*.conf"),
+		filepath.Join(dirs.SnapDesktopFilesDir, "*"),
+		filepath.Join(dirs.SnapDataDir, "*"),
+		filepath.Join(dirs.SnapMountDir, "*"),
+	}
+	for _, glob := range globs {
+		matches, err := filepath.Glob(inChroot(chrootDir, glob))
+		if err != nil {
+			return err
+		}
+		for _, path := range matches {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("cannot reset the preseeded system: %v", err)
+			}
+		}
+	}
+	return nil
+}
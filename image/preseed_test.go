@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type preseedSuite struct {
+	testutil.BaseTest
+
+	chroot string
+	stderr *bytes.Buffer
+}
+
+var _ = Suite(&preseedSuite{})
+
+func (s *preseedSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir("/")
+	s.chroot = c.MkDir()
+	s.AddCleanup(image.MockGetuid(func() int { return 0 }))
+
+	s.stderr = &bytes.Buffer{}
+	image.Stdout = &bytes.Buffer{}
+	image.Stderr = s.stderr
+}
+
+func (s *preseedSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+	image.Stdout = os.Stdout
+	image.Stderr = os.Stderr
+}
+
+func (s *preseedSuite) mockSnapd(c *C) {
+	snapdPath := filepath.Join(s.chroot, "/usr/lib/snapd/snapd")
+	c.Assert(os.MkdirAll(filepath.Dir(snapdPath), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(snapdPath, nil, 0755), IsNil)
+}
+
+func (s *preseedSuite) mockMountInfo(c *C, mountDirs ...string) {
+	var buf bytes.Buffer
+	for i, dir := range mountDirs {
+		fmt.Fprintf(&buf, "%d 1 7:%d / %s ro,nodev,relatime shared:1 - squashfs /dev/loop%d ro\n", 100+i, i, dir, i)
+	}
+	mountInfo := filepath.Join(c.MkDir(), "mountinfo")
+	c.Assert(ioutil.WriteFile(mountInfo, buf.Bytes(), 0644), IsNil)
+	s.AddCleanup(image.MockProcSelfMountInfo(mountInfo))
+}
+
+func (s *preseedSuite) TestPreseedHappy(c *C) {
+	s.mockSnapd(c)
+	snapMount := filepath.Join(s.chroot, dirs.SnapMountDir, "core", "1")
+	s.mockMountInfo(c, "/", snapMount)
+
+	mockMount := testutil.MockCommand(c, "mount", "")
+	defer mockMount.Restore()
+	mockUmount := testutil.MockCommand(c, "umount", "")
+	defer mockUmount.Restore()
+	mockChroot := testutil.MockCommand(c, "chroot", `echo "SNAPD_PRESEED=$SNAPD_PRESEED SNAP_REEXEC=$SNAP_REEXEC"`)
+	defer mockChroot.Restore()
+
+	c.Assert(image.Preseed(s.chroot), IsNil)
+
+	c.Check(mockMount.Calls(), DeepEquals, [][]string{
+		{"mount", "-t", "proc", "proc", filepath.Join(s.chroot, "/proc")},
+		{"mount", "-t", "sysfs", "sysfs", filepath.Join(s.chroot, "/sys")},
+		{"mount", "--bind", "/dev", filepath.Join(s.chroot, "/dev")},
+	})
+	c.Check(mockChroot.Calls(), DeepEquals, [][]string{
+		{"chroot", s.chroot, "/usr/lib/snapd/snapd"},
+	})
+	c.Check(image.Stdout.(*bytes.Buffer).String(), Equals, "SNAPD_PRESEED=1 SNAP_REEXEC=0\n")
+	c.Check(mockUmount.Calls(), DeepEquals, [][]string{
+		{"umount", "-d", "-l", snapMount},
+		{"umount", filepath.Join(s.chroot, "/dev")},
+		{"umount", filepath.Join(s.chroot, "/sys")},
+		{"umount", filepath.Join(s.chroot, "/proc")},
+	})
+}
+
+func (s *preseedSuite) TestPreseedSnapdFails(c *C) {
+	s.mockSnapd(c)
+	s.mockMountInfo(c, "/")
+
+	mockMount := testutil.MockCommand(c, "mount", "")
+	defer mockMount.Restore()
+	mockUmount := testutil.MockCommand(c, "umount", "")
+	defer mockUmount.Restore()
+	mockChroot := testutil.MockCommand(c, "chroot", "exit 1")
+	defer mockChroot.Restore()
+
+	err := image.Preseed(s.chroot)
+	c.Assert(err, ErrorMatches, `cannot preseed ".*": exit status 1`)
+	// the chroot is cleaned up regardless
+	c.Check(mockUmount.Calls(), HasLen, 3)
+}
+
+func (s *preseedSuite) TestPreseedMountFails(c *C) {
+	s.mockSnapd(c)
+
+	mockMount := testutil.MockCommand(c, "mount", `if [ "$2" = sysfs ]; then echo boom; exit 1; fi`)
+	defer mockMount.Restore()
+	mockUmount := testutil.MockCommand(c, "umount", "")
+	defer mockUmount.Restore()
+	mockChroot := testutil.MockCommand(c, "chroot", "")
+	defer mockChroot.Restore()
+
+	err := image.Preseed(s.chroot)
+	c.Assert(err, ErrorMatches, `cannot mount .*/sys: boom`)
+	c.Check(mockUmount.Calls(), DeepEquals, [][]string{
+		{"umount", filepath.Join(s.chroot, "/proc")},
+	})
+	c.Check(mockChroot.Calls(), HasLen, 0)
+}
+
+func (s *preseedSuite) TestPreseedErrors(c *C) {
+	restore := image.MockGetuid(func() int { return 1000 })
+	err := image.Preseed(s.chroot)
+	c.Check(err, ErrorMatches, `cannot preseed without root privileges`)
+	restore()
+
+	err = image.Preseed(s.chroot)
+	c.Check(err, ErrorMatches, `cannot preseed without snapd in the chroot: .*/usr/lib/snapd/snapd is missing`)
+
+	s.mockSnapd(c)
+	stateFile := filepath.Join(s.chroot, "/var/lib/snapd/state.json")
+	c.Assert(os.MkdirAll(filepath.Dir(stateFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(stateFile, nil, 0644), IsNil)
+	err = image.Preseed(s.chroot)
+	c.Check(err, ErrorMatches, `the system at ".*" appears to be preseeded already, reset it first`)
+}
+
+func (s *preseedSuite) TestResetPreseededChroot(c *C) {
+	removed := []string{
+		"/var/lib/snapd/state.json",
+		"/var/lib/snapd/state.json.journal",
+		"/var/lib/snapd/system-key",
+		"/var/lib/snapd/snaps/core_1.snap",
+		"/var/lib/snapd/sequence/core.json",
+		"/etc/systemd/system/snap-core-1.mount",
+		"/etc/systemd/system/snap.foo.svc.service",
+		"/etc/systemd/system/multi-user.target.wants/snap-core-1.mount",
+		"/etc/systemd/system/multi-user.target.wants/snap.foo.svc.service",
+		"/var/lib/snapd/apparmor/profiles/snap.foo.app",
+		"/var/lib/snapd/seccomp/bpf/snap.foo.app.bin",
+		"/var/lib/snapd/mount/snap.foo.fstab",
+		"/etc/udev/rules.d/70-snap.foo.rules",
+		"/etc/dbus-1/system.d/snap.foo.svc.conf",
+		"/var/lib/snapd/desktop/applications/foo_foo.desktop",
+		"/var/snap/foo/common/data",
+		filepath.Join(dirs.SnapMountDir, "core/1/meta/snap.yaml"),
+	}
+	kept := []string{
+		"/var/lib/snapd/seed/seed.yaml",
+		"/var/lib/snapd/seed/snaps/core_1.snap",
+		"/etc/systemd/system/ssh.service",
+		"/etc/systemd/system/multi-user.target.wants/ssh.service",
+		"/etc/udev/rules.d/70-other.rules",
+	}
+	for _, fn := range append(removed, kept...) {
+		path := filepath.Join(s.chroot, fn)
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(path, nil, 0644), IsNil)
+	}
+
+	c.Assert(image.ResetPreseededChroot(s.chroot), IsNil)
+
+	for _, fn := range removed {
+		c.Check(filepath.Join(s.chroot, fn), testutil.FileAbsent)
+	}
+	for _, fn := range kept {
+		c.Check(filepath.Join(s.chroot, fn), testutil.FilePresent)
+	}
+	c.Check(filepath.Join(s.chroot, dirs.SnapMountDir, "core"), testutil.FileAbsent)
+	c.Check(filepath.Join(s.chroot, "/var/snap/foo"), testutil.FileAbsent)
+}
@@ -35,6 +35,7 @@ import (
 	"strings"
 
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/release"
 )
 
 // ValidateNoAppArmorRegexp will check that the given string does not
@@ -73,6 +74,11 @@ func loadProfiles(fnames []string, cacheDir string, flags aaParserFlags) error {
 	if flags&skipReadCache != 0 {
 		args = append(args, "--skip-read-cache")
 	}
+	if release.PreseedMode {
+		// only compile the profiles into the cache when preseeding,
+		// they are loaded from there on boot
+		args = append(args, "--skip-kernel-load")
+	}
 	if !osutil.GetenvBool("SNAPD_DEBUG") {
 		args = append(args, "--quiet")
 	}
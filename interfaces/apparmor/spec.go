@@ -108,6 +108,8 @@ func (spec *Specification) AddUpdateNS(snippet string) {
 //   poke holes in arbitrary read-only locations
 // - mounting/unmounting any part of $SNAP into placeholder directory
 // - mounting/unmounting tmpfs over the original $SNAP/** location
+// - mounting/unmounting overlayfs over $SNAP/** with the upper and work
+//   directories in $SNAP_DATA or $SNAP_COMMON
 // - mounting/unmounting from placeholder back to $SNAP/** (for reconstructing
 //   the data)
 // Importantly, the above mount operations are happening within the per-snap
@@ -173,6 +175,15 @@ func (spec *Specification) AddLayout(si *snap.Info) {
 			fmt.Fprintf(&buf, "  umount %s/,\n", path)
 			// Allow constructing writable mimic to mount point.
 			WritableProfile(&buf, path, 2) // At least / and /some-top-level-directory
+		case l.Type == "overlay":
+			upper, work := l.OverlayDirs()
+			fmt.Fprintf(&buf, "  mount fstype=overlay overlay -> %s/,\n", path)
+			fmt.Fprintf(&buf, "  umount %s/,\n", path)
+			// Allow constructing writable mimic to mount point.
+			WritableProfile(&buf, path, 4) // At least /, /snap/, /snap/$SNAP_NAME and /snap/$SNAP_NAME/$SNAP_REVISION
+			// Allow creating the upper and work directories.
+			WritableProfile(&buf, upper, 4) // At least /, /var/, /var/snap/ and /var/snap/$SNAP_NAME
+			WritableProfile(&buf, work, 4)
 		case l.Symlink != "":
 			// Allow constructing writable mimic to symlink parent directory.
 			fmt.Fprintf(&buf, "  %s rw,\n", path)
@@ -344,7 +355,7 @@ func (spec *Specification) UpdateNS() []string {
 
 func snippetFromLayout(layout *snap.Layout) string {
 	mountPoint := layout.Snap.ExpandSnapVariables(layout.Path)
-	if layout.Bind != "" || layout.Type == "tmpfs" {
+	if layout.Bind != "" || layout.Type == "tmpfs" || layout.Type == "overlay" {
 		return fmt.Sprintf("# Layout path: %s\n%s{,/**} mrwklix,", mountPoint, mountPoint)
 	} else if layout.BindFile != "" {
 		return fmt.Sprintf("# Layout path: %s\n%s mrwklix,", mountPoint, mountPoint)
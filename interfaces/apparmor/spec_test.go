@@ -165,6 +165,76 @@ layout:
     bind-file: $SNAP/foo.conf
 `
 
+const snapWithOverlayLayout = `
+name: vanguard
+version: 0
+apps:
+  vanguard:
+    command: vanguard
+layout:
+  $SNAP/etc:
+    type: overlay
+    upper: $SNAP_DATA/etc
+`
+
+func (s *specSuite) TestApparmorSnippetsFromOverlayLayout(c *C) {
+	snapInfo := snaptest.MockInfo(c, snapWithOverlayLayout, &snap.SideInfo{Revision: snap.R(42)})
+	restore := apparmor.SetSpecScope(s.spec, []string{"snap.vanguard.vanguard"})
+	defer restore()
+
+	s.spec.AddLayout(snapInfo)
+	c.Assert(s.spec.Snippets(), DeepEquals, map[string][]string{
+		"snap.vanguard.vanguard": {
+			"# Layout path: /snap/vanguard/42/etc\n/snap/vanguard/42/etc{,/**} mrwklix,",
+		},
+	})
+	updateNS := s.spec.UpdateNS()
+	c.Assert(updateNS, HasLen, 1)
+	profile := `  # Layout $SNAP/etc: type overlay, upper: $SNAP_DATA/etc
+  mount fstype=overlay overlay -> /snap/vanguard/42/etc/,
+  umount /snap/vanguard/42/etc/,
+  # Writable mimic /snap/vanguard/42
+  # .. permissions for traversing the prefix that is assumed to exist
+  / r,
+  /snap/ r,
+  /snap/vanguard/ r,
+  # .. variant with mimic at /snap/vanguard/42/
+  # Allow reading the mimic directory, it must exist in the first place.
+  /snap/vanguard/42/ r,
+  # Allow setting the read-only directory aside via a bind mount.
+  /tmp/.snap/snap/vanguard/42/ rw,
+  mount options=(rbind, rw) /snap/vanguard/42/ -> /tmp/.snap/snap/vanguard/42/,
+  # Allow mounting tmpfs over the read-only directory.
+  mount fstype=tmpfs options=(rw) tmpfs -> /snap/vanguard/42/,
+  # Allow creating empty files and directories for bind mounting things
+  # to reconstruct the now-writable parent directory.
+  /tmp/.snap/snap/vanguard/42/*/ rw,
+  /snap/vanguard/42/*/ rw,
+  mount options=(rbind, rw) /tmp/.snap/snap/vanguard/42/*/ -> /snap/vanguard/42/*/,
+  /tmp/.snap/snap/vanguard/42/* rw,
+  /snap/vanguard/42/* rw,
+  mount options=(bind, rw) /tmp/.snap/snap/vanguard/42/* -> /snap/vanguard/42/*,
+  # Allow unmounting the auxiliary directory.
+  # TODO: use fstype=tmpfs here for more strictness (LP: #1613403)
+  umount /tmp/.snap/snap/vanguard/42/,
+  # Allow unmounting the destination directory as well as anything
+  # inside.  This lets us perform the undo plan in case the writable
+  # mimic fails.
+  umount /snap/vanguard/42/,
+  umount /snap/vanguard/42/*,
+  umount /snap/vanguard/42/*/,
+  # Writable directory /var/snap/vanguard/42/etc
+  /var/snap/vanguard/42/etc/ rw,
+  /var/snap/vanguard/42/ rw,
+  /var/snap/vanguard/ rw,
+  # Writable directory /var/snap/vanguard/42/.etc.overlay-work
+  /var/snap/vanguard/42/.etc.overlay-work/ rw,
+  /var/snap/vanguard/42/ rw,
+  /var/snap/vanguard/ rw,
+`
+	c.Assert(updateNS[0], Equals, profile)
+}
+
 func (s *specSuite) TestApparmorSnippetsFromLayout(c *C) {
 	snapInfo := snaptest.MockInfo(c, snapWithLayout, &snap.SideInfo{Revision: snap.R(42)})
 	restore := apparmor.SetSpecScope(s.spec, []string{"snap.vanguard.vanguard"})
@@ -0,0 +1,228 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+const audioPlaybackSummary = `allows audio playback via supporting services`
+
+const audioPlaybackBaseDeclarationSlots = `
+  audio-playback:
+    allow-installation:
+      slot-snap-type:
+        - app
+        - core
+    deny-connection:
+      on-classic: false
+`
+
+const audioPlaybackConnectedPlugAppArmor = `
+# Allow communicating with the audio service for playback. Recording is only
+# granted by the audio service to snaps that also have audio-record connected.
+/{run,dev}/shm/pulse-shm-* mrwk,
+
+owner /{,var/}run/pulse/ r,
+owner /{,var/}run/pulse/native rwk,
+owner /run/user/[0-9]*/ r,
+owner /run/user/[0-9]*/pulse/ rw,
+
+/run/udev/data/c116:[0-9]* r,
+/run/udev/data/+sound:card[0-9]* r,
+
+# Allow looking up the address of the audio service on the session bus.
+#include <abstractions/dbus-session-strict>
+dbus (send)
+    bus=session
+    path=/org/pulseaudio/server_lookup1
+    interface=org.freedesktop.DBus.Properties
+    member="Get{,All}"
+    peer=(label=###SLOT_SECURITY_TAGS###),
+`
+
+const audioPlaybackConnectedPlugAppArmorDesktop = `
+# Only on desktop do we need access to /etc/pulse for any PulseAudio client
+# to read available client side configuration settings. On an Ubuntu Core
+# device those things will be stored inside the snap directory.
+/etc/pulse/ r,
+/etc/pulse/** r,
+owner @{HOME}/.pulse-cookie rk,
+owner @{HOME}/.config/pulse/cookie rk,
+owner /{,var/}run/user/*/pulse/ rwk,
+owner /{,var/}run/user/*/pulse/native rwk,
+`
+
+const audioPlaybackConnectedPlugSecComp = `
+shmctl
+`
+
+const audioPlaybackPermanentSlotAppArmor = `
+# When running the audio service in system mode it will switch to the at
+# build time configured user/group on startup.
+capability setuid,
+capability setgid,
+
+capability sys_nice,
+capability sys_resource,
+
+owner @{PROC}/@{pid}/exe r,
+/etc/machine-id r,
+
+# Audio related
+@{PROC}/asound/devices r,
+@{PROC}/asound/card** r,
+
+# Only playback devices, capture devices are granted by audio-record
+/dev/snd/pcmC[0-9]*D[0-9]*p rw,
+/dev/snd/control* rw,
+/dev/snd/timer r,
+
+/sys/**/sound/** r,
+
+# For udev
+network netlink raw,
+/sys/devices/virtual/dmi/id/sys_vendor r,
+/sys/devices/virtual/dmi/id/bios_vendor r,
+# FIXME: use udev queries to make this more specific
+/run/udev/data/** r,
+
+owner /{,var/}run/pulse/ rw,
+owner /{,var/}run/pulse/** rwk,
+
+# Shared memory based communication with clients
+/{run,dev}/shm/pulse-shm-* mrwk,
+
+/usr/share/applications/ r,
+
+owner /run/pulse/native/ rwk,
+owner /run/user/[0-9]*/ r,
+owner /run/user/[0-9]*/pulse/ rw,
+
+# Allow publishing the address of the service on the session bus.
+#include <abstractions/dbus-session-strict>
+dbus (bind)
+    bus=session
+    name=org.PulseAudio1,
+`
+
+const audioPlaybackConnectedSlotAppArmor = `
+# Allow connected clients to look up the address of the audio service.
+#include <abstractions/dbus-session-strict>
+dbus (receive)
+    bus=session
+    path=/org/pulseaudio/server_lookup1
+    interface=org.freedesktop.DBus.Properties
+    member="Get{,All}"
+    peer=(label=###PLUG_SECURITY_TAGS###),
+`
+
+const audioPlaybackPermanentSlotSecComp = `
+# The following are needed for UNIX sockets
+personality
+setpriority
+bind
+listen
+accept
+accept4
+shmctl
+# Needed to set root as group for different state dirs
+# the audio service creates on startup.
+setgroups
+setgroups32
+# libudev
+socket AF_NETLINK - NETLINK_KOBJECT_UEVENT
+`
+
+type audioPlaybackInterface struct{}
+
+func (iface *audioPlaybackInterface) Name() string {
+	return "audio-playback"
+}
+
+func (iface *audioPlaybackInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              audioPlaybackSummary,
+		ImplicitOnClassic:    true,
+		BaseDeclarationSlots: audioPlaybackBaseDeclarationSlots,
+	}
+}
+
+func (iface *audioPlaybackInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	old := "###SLOT_SECURITY_TAGS###"
+	new := slotAppLabelExpr(slot)
+	if release.OnClassic {
+		// Let confined apps access the unconfined audio service on classic
+		new = "unconfined"
+	}
+	spec.AddSnippet(strings.Replace(audioPlaybackConnectedPlugAppArmor, old, new, -1))
+	if release.OnClassic {
+		spec.AddSnippet(audioPlaybackConnectedPlugAppArmorDesktop)
+	}
+	return nil
+}
+
+func (iface *audioPlaybackInterface) AppArmorConnectedSlot(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	old := "###PLUG_SECURITY_TAGS###"
+	new := plugAppLabelExpr(plug)
+	spec.AddSnippet(strings.Replace(audioPlaybackConnectedSlotAppArmor, old, new, -1))
+	return nil
+}
+
+func (iface *audioPlaybackInterface) UDevPermanentSlot(spec *udev.Specification, slot *snap.SlotInfo) error {
+	spec.TagDevice(`KERNEL=="controlC[0-9]*"`)
+	spec.TagDevice(`KERNEL=="pcmC[0-9]*D[0-9]*p"`)
+	spec.TagDevice(`KERNEL=="timer"`)
+	return nil
+}
+
+func (iface *audioPlaybackInterface) AppArmorPermanentSlot(spec *apparmor.Specification, slot *snap.SlotInfo) error {
+	spec.AddSnippet(audioPlaybackPermanentSlotAppArmor)
+	return nil
+}
+
+func (iface *audioPlaybackInterface) SecCompConnectedPlug(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	spec.AddSnippet(audioPlaybackConnectedPlugSecComp)
+	return nil
+}
+
+func (iface *audioPlaybackInterface) SecCompPermanentSlot(spec *seccomp.Specification, slot *snap.SlotInfo) error {
+	spec.AddSnippet(audioPlaybackPermanentSlotSecComp)
+	return nil
+}
+
+func (iface *audioPlaybackInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	return sanitizeSlotReservedForOSOrApp(iface, slot)
+}
+
+func (iface *audioPlaybackInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	return true
+}
+
+func init() {
+	registerIface(&audioPlaybackInterface{})
+}
@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type AudioPlaybackInterfaceSuite struct {
+	iface           interfaces.Interface
+	coreSlotInfo    *snap.SlotInfo
+	coreSlot        *interfaces.ConnectedSlot
+	classicSlotInfo *snap.SlotInfo
+	classicSlot     *interfaces.ConnectedSlot
+	plugInfo        *snap.PlugInfo
+	plug            *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&AudioPlaybackInterfaceSuite{
+	iface: builtin.MustInterface("audio-playback"),
+})
+
+const audioPlaybackMockPlugSnapInfoYaml = `name: consumer
+version: 1.0
+apps:
+ app:
+  command: foo
+  plugs: [audio-playback]
+`
+
+// an audio-playback slot on an audio service snap (as installed on a core/all-snap system)
+const audioPlaybackMockCoreSlotSnapInfoYaml = `name: audio-service
+version: 1.0
+apps:
+ app1:
+  command: foo
+  slots: [audio-playback]
+`
+
+// an audio-playback slot on the core snap (as automatically added on classic)
+const audioPlaybackMockClassicSlotSnapInfoYaml = `name: core
+version: 0
+type: os
+slots:
+ audio-playback:
+  interface: audio-playback
+`
+
+func (s *AudioPlaybackInterfaceSuite) SetUpTest(c *C) {
+	snapInfo := snaptest.MockInfo(c, audioPlaybackMockCoreSlotSnapInfoYaml, nil)
+	s.coreSlotInfo = snapInfo.Slots["audio-playback"]
+	s.coreSlot = interfaces.NewConnectedSlot(s.coreSlotInfo, nil, nil)
+	snapInfo = snaptest.MockInfo(c, audioPlaybackMockClassicSlotSnapInfoYaml, nil)
+	s.classicSlotInfo = snapInfo.Slots["audio-playback"]
+	s.classicSlot = interfaces.NewConnectedSlot(s.classicSlotInfo, nil, nil)
+	snapInfo = snaptest.MockInfo(c, audioPlaybackMockPlugSnapInfoYaml, nil)
+	s.plugInfo = snapInfo.Plugs["audio-playback"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "audio-playback")
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.classicSlotInfo), IsNil)
+	slot := &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-gadget", Type: snap.TypeGadget},
+		Name:      "audio-playback",
+		Interface: "audio-playback",
+	}
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches,
+		"audio-playback slots are reserved for the core and app snaps")
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestAppArmorOnClassic(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.classicSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "owner /run/user/[0-9]*/pulse/ rw,\n")
+	c.Check(snippet, testutil.Contains, "path=/org/pulseaudio/server_lookup1\n")
+	c.Check(snippet, testutil.Contains, "peer=(label=unconfined),\n")
+	c.Check(snippet, testutil.Contains, "/etc/pulse/ r,\n")
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestAppArmorOnCore(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.audio-service.app1", "snap.consumer.app"})
+
+	plugSnippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(plugSnippet, testutil.Contains, `peer=(label="snap.audio-service.app1"),`)
+	c.Check(plugSnippet, Not(testutil.Contains), "/etc/pulse/ r,\n")
+
+	slotSnippet := spec.SnippetForTag("snap.audio-service.app1")
+	c.Check(slotSnippet, testutil.Contains, "/dev/snd/pcmC[0-9]*D[0-9]*p rw,\n")
+	c.Check(slotSnippet, Not(testutil.Contains), "D[0-9]*c rw,\n")
+	c.Check(slotSnippet, testutil.Contains, "name=org.PulseAudio1,\n")
+	c.Check(slotSnippet, testutil.Contains, `peer=(label="snap.consumer.app"),`)
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestSecComp(c *C) {
+	spec := &seccomp.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.audio-service.app1", "snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.audio-service.app1"), testutil.Contains, "listen\n")
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "shmctl\n")
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestUDev(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 4)
+	c.Check(spec.Snippets(), testutil.Contains, `# audio-playback
+KERNEL=="controlC[0-9]*", TAG+="snap_audio-service_app1"`)
+	c.Check(spec.Snippets(), testutil.Contains, `# audio-playback
+KERNEL=="pcmC[0-9]*D[0-9]*p", TAG+="snap_audio-service_app1"`)
+	c.Check(spec.Snippets(), testutil.Contains, `# audio-playback
+KERNEL=="timer", TAG+="snap_audio-service_app1"`)
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, false)
+	c.Check(si.ImplicitOnClassic, Equals, true)
+	c.Check(si.Summary, Equals, "allows audio playback via supporting services")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "audio-playback")
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(s.plugInfo, s.coreSlotInfo), Equals, true)
+}
+
+func (s *AudioPlaybackInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -0,0 +1,128 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+const audioRecordSummary = `allows audio recording via supporting services`
+
+// Recording a user without their knowledge is a privacy concern so
+// audio-record must always be connected manually.
+const audioRecordBaseDeclarationSlots = `
+  audio-record:
+    allow-installation:
+      slot-snap-type:
+        - app
+        - core
+    deny-connection:
+      on-classic: false
+    deny-auto-connection: true
+`
+
+// The audio service socket itself is granted by audio-playback. The service
+// uses the connection state of audio-record to decide whether a client may
+// record, this interface only adds the rules needed to request recording.
+const audioRecordConnectedPlugAppArmor = `
+# Allow controlling record streams of the audio service.
+#include <abstractions/dbus-session-strict>
+dbus (send, receive)
+    bus=session
+    path=/org/pulseaudio/core1/record_stream[0-9]*
+    interface=org.PulseAudio.Core1.Stream
+    peer=(label=###SLOT_SECURITY_TAGS###),
+`
+
+const audioRecordConnectedSlotAppArmor = `
+# Allow connected clients to control record streams.
+#include <abstractions/dbus-session-strict>
+dbus (send, receive)
+    bus=session
+    path=/org/pulseaudio/core1/record_stream[0-9]*
+    interface=org.PulseAudio.Core1.Stream
+    peer=(label=###PLUG_SECURITY_TAGS###),
+`
+
+const audioRecordPermanentSlotAppArmor = `
+# Allow access to the capture devices
+/dev/snd/pcmC[0-9]*D[0-9]*c rw,
+`
+
+type audioRecordInterface struct{}
+
+func (iface *audioRecordInterface) Name() string {
+	return "audio-record"
+}
+
+func (iface *audioRecordInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              audioRecordSummary,
+		ImplicitOnClassic:    true,
+		BaseDeclarationSlots: audioRecordBaseDeclarationSlots,
+	}
+}
+
+func (iface *audioRecordInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	old := "###SLOT_SECURITY_TAGS###"
+	new := slotAppLabelExpr(slot)
+	if release.OnClassic {
+		// Let confined apps access the unconfined audio service on classic
+		new = "unconfined"
+	}
+	spec.AddSnippet(strings.Replace(audioRecordConnectedPlugAppArmor, old, new, -1))
+	return nil
+}
+
+func (iface *audioRecordInterface) AppArmorConnectedSlot(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	old := "###PLUG_SECURITY_TAGS###"
+	new := plugAppLabelExpr(plug)
+	spec.AddSnippet(strings.Replace(audioRecordConnectedSlotAppArmor, old, new, -1))
+	return nil
+}
+
+func (iface *audioRecordInterface) UDevPermanentSlot(spec *udev.Specification, slot *snap.SlotInfo) error {
+	spec.TagDevice(`KERNEL=="pcmC[0-9]*D[0-9]*c"`)
+	return nil
+}
+
+func (iface *audioRecordInterface) AppArmorPermanentSlot(spec *apparmor.Specification, slot *snap.SlotInfo) error {
+	spec.AddSnippet(audioRecordPermanentSlotAppArmor)
+	return nil
+}
+
+func (iface *audioRecordInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	return sanitizeSlotReservedForOSOrApp(iface, slot)
+}
+
+func (iface *audioRecordInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	// allow what declarations allowed
+	return true
+}
+
+func init() {
+	registerIface(&audioRecordInterface{})
+}
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type AudioRecordInterfaceSuite struct {
+	iface           interfaces.Interface
+	coreSlotInfo    *snap.SlotInfo
+	coreSlot        *interfaces.ConnectedSlot
+	classicSlotInfo *snap.SlotInfo
+	classicSlot     *interfaces.ConnectedSlot
+	plugInfo        *snap.PlugInfo
+	plug            *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&AudioRecordInterfaceSuite{
+	iface: builtin.MustInterface("audio-record"),
+})
+
+const audioRecordMockPlugSnapInfoYaml = `name: consumer
+version: 1.0
+apps:
+ app:
+  command: foo
+  plugs: [audio-record]
+`
+
+const audioRecordMockCoreSlotSnapInfoYaml = `name: audio-service
+version: 1.0
+apps:
+ app1:
+  command: foo
+  slots: [audio-record]
+`
+
+const audioRecordMockClassicSlotSnapInfoYaml = `name: core
+version: 0
+type: os
+slots:
+ audio-record:
+  interface: audio-record
+`
+
+func (s *AudioRecordInterfaceSuite) SetUpTest(c *C) {
+	snapInfo := snaptest.MockInfo(c, audioRecordMockCoreSlotSnapInfoYaml, nil)
+	s.coreSlotInfo = snapInfo.Slots["audio-record"]
+	s.coreSlot = interfaces.NewConnectedSlot(s.coreSlotInfo, nil, nil)
+	snapInfo = snaptest.MockInfo(c, audioRecordMockClassicSlotSnapInfoYaml, nil)
+	s.classicSlotInfo = snapInfo.Slots["audio-record"]
+	s.classicSlot = interfaces.NewConnectedSlot(s.classicSlotInfo, nil, nil)
+	snapInfo = snaptest.MockInfo(c, audioRecordMockPlugSnapInfoYaml, nil)
+	s.plugInfo = snapInfo.Plugs["audio-record"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+}
+
+func (s *AudioRecordInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "audio-record")
+}
+
+func (s *AudioRecordInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.classicSlotInfo), IsNil)
+	slot := &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-gadget", Type: snap.TypeGadget},
+		Name:      "audio-record",
+		Interface: "audio-record",
+	}
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches,
+		"audio-record slots are reserved for the core and app snaps")
+}
+
+func (s *AudioRecordInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+}
+
+func (s *AudioRecordInterfaceSuite) TestAppArmorOnClassic(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.classicSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "interface=org.PulseAudio.Core1.Stream\n")
+	c.Check(snippet, testutil.Contains, "peer=(label=unconfined),\n")
+	// The socket of the audio service comes from audio-playback.
+	c.Check(snippet, Not(testutil.Contains), "pulse/native")
+}
+
+func (s *AudioRecordInterfaceSuite) TestAppArmorOnCore(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.audio-service.app1", "snap.consumer.app"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `peer=(label="snap.audio-service.app1"),`)
+	slotSnippet := spec.SnippetForTag("snap.audio-service.app1")
+	c.Check(slotSnippet, testutil.Contains, "/dev/snd/pcmC[0-9]*D[0-9]*c rw,\n")
+	c.Check(slotSnippet, testutil.Contains, `peer=(label="snap.consumer.app"),`)
+}
+
+func (s *AudioRecordInterfaceSuite) TestUDev(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Check(spec.Snippets(), testutil.Contains, `# audio-record
+KERNEL=="pcmC[0-9]*D[0-9]*c", TAG+="snap_audio-service_app1"`)
+}
+
+func (s *AudioRecordInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, false)
+	c.Check(si.ImplicitOnClassic, Equals, true)
+	c.Check(si.Summary, Equals, "allows audio recording via supporting services")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "deny-auto-connection: true")
+}
+
+func (s *AudioRecordInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
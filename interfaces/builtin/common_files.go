@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/utils"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -147,6 +148,51 @@ func (iface *commonFilesInterface) BeforePreparePlug(plug *snap.PlugInfo) error
 	return nil
 }
 
+// pathAllowedBy returns whether the path is one of the given paths or
+// below one of them.
+func pathAllowedBy(path string, allowed []interface{}) bool {
+	for _, a := range allowed {
+		ap, ok := a.(string)
+		if !ok {
+			continue
+		}
+		if path == ap || strings.HasPrefix(path, ap+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// RestrictPlug narrows the "read" and "write" paths of the plug, each
+// restricted path must be one of the paths of the plug or below it.
+func (iface *commonFilesInterface) RestrictPlug(plug *snap.PlugInfo, restrictions map[string]interface{}) (map[string]interface{}, error) {
+	attrs := utils.CopyAttributes(plug.Attrs)
+	for att, value := range restrictions {
+		if att != "read" && att != "write" {
+			return nil, fmt.Errorf("cannot restrict %s attribute %q", iface.name, att)
+		}
+		// a single path is fine too
+		if p, ok := value.(string); ok {
+			value = []interface{}{p}
+		}
+		paths, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot restrict %s: %q must be a list of strings", iface.name, att)
+		}
+		if err := iface.validatePaths(att, paths); err != nil {
+			return nil, fmt.Errorf("cannot restrict %s: %s", iface.name, err)
+		}
+		allowed, _ := plug.Attrs[att].([]interface{})
+		for _, p := range paths {
+			if !pathAllowedBy(p.(string), allowed) {
+				return nil, fmt.Errorf("cannot restrict %s: %q is not allowed by %q of plug %s", iface.name, p, att, plug.Name)
+			}
+		}
+		attrs[att] = paths
+	}
+	return attrs, nil
+}
+
 func (iface *commonFilesInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	var reads, writes []interface{}
 	_ = plug.Attr("read", &reads)
@@ -125,10 +125,34 @@ func (iface *contentInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
 	if !cleanSubPath(target) {
 		return fmt.Errorf("content interface target path is not clean: %q", target)
 	}
+	if merge, ok := plug.Attrs["merge"]; ok {
+		switch merge {
+		case mergePerProvider, mergeOverlay:
+		default:
+			return fmt.Errorf(`content plug "merge" attribute must be %q or %q`, mergePerProvider, mergeOverlay)
+		}
+	}
 
 	return nil
 }
 
+const (
+	// mergePerProvider exposes the content of each connected provider in
+	// a sub-directory of the target, named after the provider snap.
+	mergePerProvider = "per-provider"
+	// mergeOverlay stacks the read-only content of all the connected
+	// providers onto the target with a read-only overlay. Writable
+	// content cannot be merged and is exposed like with mergePerProvider.
+	mergeOverlay = "overlay"
+)
+
+// mergeMode returns the value of the "merge" attribute of the plug.
+func mergeMode(plug *interfaces.ConnectedPlug) string {
+	var merge string
+	_ = plug.Attr("merge", &merge)
+	return merge
+}
+
 // path is an internal helper that extract the "read" and "write" attribute
 // of the slot
 func (iface *contentInterface) path(attrs interfaces.Attrer, name string) []string {
@@ -184,12 +208,24 @@ func resolveSpecialVariable(path string, snapInfo *snap.Info) string {
 }
 
 func sourceTarget(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot, relSrc string) (string, string) {
+	return sourceTargetWithMerge(plug, slot, relSrc, mergeMode(plug))
+}
+
+// sourceTargetWithMerge is like sourceTarget but uses the given merge mode
+// instead of the one of the plug.
+func sourceTargetWithMerge(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot, relSrc, merge string) (string, string) {
 	var target string
 	// The 'target' attribute has already been verified in BeforePreparePlug.
 	_ = plug.Attr("target", &target)
 	source := resolveSpecialVariable(relSrc, slot.Snap())
 	target = resolveSpecialVariable(target, plug.Snap())
 
+	// Give each provider a directory of its own, unless the content is
+	// merged with an overlay.
+	if merge == mergePerProvider {
+		target = filepath.Join(target, slot.Snap().InstanceName())
+	}
+
 	// Check if the "source" section is present.
 	var unused map[string]interface{}
 	if err := slot.Attr("source", &unused); err == nil {
@@ -199,11 +235,20 @@ func sourceTarget(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot
 	return source, target
 }
 
-func mountEntry(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot, relSrc string, extraOptions ...string) osutil.MountEntry {
+// writeSourceTarget is like sourceTarget but for writable content, which
+// cannot be merged with an overlay.
+func writeSourceTarget(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot, relSrc string) (string, string) {
+	merge := mergeMode(plug)
+	if merge == mergeOverlay {
+		merge = mergePerProvider
+	}
+	return sourceTargetWithMerge(plug, slot, relSrc, merge)
+}
+
+func mountEntry(source, target string, extraOptions ...string) osutil.MountEntry {
 	options := make([]string, 0, len(extraOptions)+1)
 	options = append(options, "bind")
 	options = append(options, extraOptions...)
-	source, target := sourceTarget(plug, slot, relSrc)
 	return osutil.MountEntry{
 		Name:    source,
 		Dir:     target,
@@ -225,7 +270,7 @@ func (iface *contentInterface) AppArmorConnectedPlug(spec *apparmor.Specificatio
 		for i, w := range writePaths {
 			fmt.Fprintf(contentSnippet, "%s/** mrwklix,\n",
 				resolveSpecialVariable(w, slot.Snap()))
-			source, target := sourceTarget(plug, slot, w)
+			source, target := writeSourceTarget(plug, slot, w)
 			var buf bytes.Buffer
 			fmt.Fprintf(&buf, "  # Read-write content sharing %s -> %s (w#%d)\n", plug.Ref(), slot.Ref(), i)
 			fmt.Fprintf(&buf, "  mount options=(bind, rw) %s/ -> %s/,\n", source, target)
@@ -257,6 +302,10 @@ func (iface *contentInterface) AppArmorConnectedPlug(spec *apparmor.Specificatio
 			fmt.Fprintf(&buf, "  mount options=(bind) %s/ -> %s/,\n", source, target)
 			fmt.Fprintf(&buf, "  remount options=(bind, ro) %s/,\n", target)
 			fmt.Fprintf(&buf, "  umount %s/,\n", target)
+			if mergeMode(plug) == mergeOverlay {
+				// Content of multiple providers is stacked with an overlay.
+				fmt.Fprintf(&buf, "  mount fstype=overlay options=(ro) overlay -> %s/,\n", target)
+			}
 			// Look at the TODO comment above.
 			apparmor.WritableProfile(&buf, source, 1)
 			apparmor.WritableProfile(&buf, target, 1)
@@ -279,7 +328,7 @@ func (iface *contentInterface) AppArmorConnectedSlot(spec *apparmor.Specificatio
 # tells the slotting app about files to share.
 `)
 		for _, w := range writePaths {
-			_, target := sourceTarget(plug, slot, w)
+			_, target := writeSourceTarget(plug, slot, w)
 			fmt.Fprintf(contentSnippet, "%s/** mrwklix,\n",
 				target)
 		}
@@ -297,14 +346,22 @@ func (iface *contentInterface) AutoConnect(plug *snap.PlugInfo, slot *snap.SlotI
 // Interactions with the mount backend.
 
 func (iface *contentInterface) MountConnectedPlug(spec *mount.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	merge := mergeMode(plug)
 	for _, r := range iface.path(slot, "read") {
-		err := spec.AddMountEntry(mountEntry(plug, slot, r, "ro"))
+		entry := mountEntry(sourceTarget(plug, slot, r))
+		entry.Options = append(entry.Options, "ro")
+		var err error
+		if merge == mergeOverlay {
+			err = spec.AddMergedMountEntry(entry)
+		} else {
+			err = spec.AddMountEntry(entry)
+		}
 		if err != nil {
 			return err
 		}
 	}
 	for _, w := range iface.path(slot, "write") {
-		err := spec.AddMountEntry(mountEntry(plug, slot, w))
+		err := spec.AddMountEntry(mountEntry(writeSourceTarget(plug, slot, w)))
 		if err != nil {
 			return err
 		}
@@ -197,6 +197,29 @@ plugs:
 	c.Assert(interfaces.BeforePreparePlug(s.iface, plug), ErrorMatches, "content interface target path is not clean:.*")
 }
 
+func (s *ContentSuite) TestSanitizePlugMerge(c *C) {
+	const mockSnapYaml = `name: content-slot-snap
+version: 1.0
+plugs:
+ content-plug:
+  interface: content
+  content: mycont
+  target: import
+  merge: %s
+`
+	for _, merge := range []string{"per-provider", "overlay"} {
+		info := snaptest.MockInfo(c, fmt.Sprintf(mockSnapYaml, merge), nil)
+		plug := info.Plugs["content-plug"]
+		c.Check(interfaces.BeforePreparePlug(s.iface, plug), IsNil)
+	}
+	for _, merge := range []string{"union", "true", "[overlay]"} {
+		info := snaptest.MockInfo(c, fmt.Sprintf(mockSnapYaml, merge), nil)
+		plug := info.Plugs["content-plug"]
+		c.Check(interfaces.BeforePreparePlug(s.iface, plug), ErrorMatches,
+			`content plug "merge" attribute must be "per-provider" or "overlay"`)
+	}
+}
+
 func (s *ContentSuite) TestSanitizePlugNilAttrMap(c *C) {
 	const mockSnapYaml = `name: content-slot-snap
 version: 1.0
@@ -993,6 +1016,116 @@ slots:
 	c.Assert(apparmorSpec.SnippetForTag("snap.app.app"), Equals, expected)
 }
 
+func (s *ContentSuite) mockPluginProviders(c *C, merge string) (*interfaces.ConnectedPlug, []*interfaces.ConnectedSlot) {
+	plug := MockPlug(c, `name: app
+version: 0
+plugs:
+ plugins:
+  interface: content
+  content: plugin-for-app
+  target: $SNAP_DATA/plugins
+  merge: `+merge+`
+apps:
+ app:
+  command: foo
+`, &snap.SideInfo{Revision: snap.R(1)}, "plugins")
+	connectedPlug := interfaces.NewConnectedPlug(plug, nil, nil)
+
+	var connectedSlots []*interfaces.ConnectedSlot
+	// The providers are connected in reverse alphabetical order.
+	for _, name := range []string{"plugin-two", "plugin-one"} {
+		slot := MockSlot(c, `name: `+name+`
+version: 0
+slots:
+ plugin-for-app:
+  interface: content
+  source:
+    read: [$SNAP/plugin]
+    write: [$SNAP_DATA/state]
+`, &snap.SideInfo{Revision: snap.R(1)}, "plugin-for-app")
+		connectedSlots = append(connectedSlots, interfaces.NewConnectedSlot(slot, nil, nil))
+	}
+	return connectedPlug, connectedSlots
+}
+
+func (s *ContentSuite) TestModernContentInterfacePluginsPerProvider(c *C) {
+	connectedPlug, connectedSlots := s.mockPluginProviders(c, "per-provider")
+
+	mountSpec := &mount.Specification{}
+	apparmorSpec := &apparmor.Specification{}
+	for _, connectedSlot := range connectedSlots {
+		c.Assert(mountSpec.AddConnectedPlug(s.iface, connectedPlug, connectedSlot), IsNil)
+		c.Assert(apparmorSpec.AddConnectedPlug(s.iface, connectedPlug, connectedSlot), IsNil)
+	}
+
+	// Each provider gets a directory of its own, nothing clashes.
+	c.Assert(mountSpec.MountEntries(), DeepEquals, []osutil.MountEntry{{
+		Name:    "/snap/plugin-two/1/plugin",
+		Dir:     "/var/snap/app/1/plugins/plugin-two/plugin",
+		Options: []string{"bind", "ro"},
+	}, {
+		Name:    "/var/snap/plugin-two/1/state",
+		Dir:     "/var/snap/app/1/plugins/plugin-two/state",
+		Options: []string{"bind"},
+	}, {
+		Name:    "/snap/plugin-one/1/plugin",
+		Dir:     "/var/snap/app/1/plugins/plugin-one/plugin",
+		Options: []string{"bind", "ro"},
+	}, {
+		Name:    "/var/snap/plugin-one/1/state",
+		Dir:     "/var/snap/app/1/plugins/plugin-one/state",
+		Options: []string{"bind"},
+	}})
+
+	updateNS := apparmorSpec.UpdateNS()
+	c.Assert(updateNS, HasLen, 4)
+	c.Check(updateNS[1], testutil.Contains, "  mount options=(bind) /snap/plugin-two/1/plugin/ -> /var/snap/app/1/plugins/plugin-two/plugin/,\n")
+	c.Check(updateNS[3], testutil.Contains, "  mount options=(bind) /snap/plugin-one/1/plugin/ -> /var/snap/app/1/plugins/plugin-one/plugin/,\n")
+}
+
+func (s *ContentSuite) TestModernContentInterfacePluginsOverlay(c *C) {
+	connectedPlug, connectedSlots := s.mockPluginProviders(c, "overlay")
+
+	mountSpec := &mount.Specification{}
+	apparmorSpec := &apparmor.Specification{}
+	// A single provider is bind mounted as usual.
+	c.Assert(mountSpec.AddConnectedPlug(s.iface, connectedPlug, connectedSlots[0]), IsNil)
+	c.Assert(mountSpec.MountEntries(), DeepEquals, []osutil.MountEntry{{
+		Name:    "/var/snap/plugin-two/1/state",
+		Dir:     "/var/snap/app/1/plugins/plugin-two/state",
+		Options: []string{"bind"},
+	}, {
+		Name:    "/snap/plugin-two/1/plugin",
+		Dir:     "/var/snap/app/1/plugins/plugin",
+		Options: []string{"bind", "ro"},
+	}})
+
+	// Read-only content of multiple providers is stacked in a deterministic
+	// order while writable content is exposed per provider.
+	c.Assert(mountSpec.AddConnectedPlug(s.iface, connectedPlug, connectedSlots[1]), IsNil)
+	c.Assert(mountSpec.MountEntries(), DeepEquals, []osutil.MountEntry{{
+		Name:    "/var/snap/plugin-two/1/state",
+		Dir:     "/var/snap/app/1/plugins/plugin-two/state",
+		Options: []string{"bind"},
+	}, {
+		Name:    "/var/snap/plugin-one/1/state",
+		Dir:     "/var/snap/app/1/plugins/plugin-one/state",
+		Options: []string{"bind"},
+	}, {
+		Name:    "overlay",
+		Dir:     "/var/snap/app/1/plugins/plugin",
+		Type:    "overlay",
+		Options: []string{"ro", "lowerdir=/snap/plugin-one/1/plugin:/snap/plugin-two/1/plugin"},
+	}})
+
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, connectedPlug, connectedSlots[0]), IsNil)
+	updateNS := apparmorSpec.UpdateNS()
+	c.Assert(updateNS, HasLen, 2)
+	c.Check(updateNS[0], testutil.Contains, "  mount options=(bind, rw) /var/snap/plugin-two/1/state/ -> /var/snap/app/1/plugins/plugin-two/state/,\n")
+	c.Check(updateNS[1], testutil.Contains, "  mount options=(bind) /snap/plugin-two/1/plugin/ -> /var/snap/app/1/plugins/plugin/,\n")
+	c.Check(updateNS[1], testutil.Contains, "  mount fstype=overlay options=(ro) overlay -> /var/snap/app/1/plugins/plugin/,\n")
+}
+
 func (s *ContentSuite) TestModernContentSameReadAndWriteClash(c *C) {
 	plug := MockPlug(c, `name: consumer
 version: 0
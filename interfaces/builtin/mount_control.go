@@ -0,0 +1,408 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+const mountControlSummary = `allows creating transient and persistent mounts`
+
+const mountControlBaseDeclarationPlugs = `
+  mount-control:
+    allow-installation: false
+    deny-auto-connection: true
+`
+
+const mountControlBaseDeclarationSlots = `
+  mount-control:
+    allow-installation:
+      slot-snap-type:
+        - core
+    deny-auto-connection: true
+`
+
+const mountControlConnectedPlugAppArmor = `
+# Description: Can mount and unmount the filesystems described by the
+# plug. This is restricted because it gives privileged access to
+# arbitrary storage.
+`
+
+// the filesystem types that can be used with mount-control
+var mountControlAllowedTypes = []string{
+	"btrfs",
+	"cifs",
+	"exfat",
+	"ext2",
+	"ext3",
+	"ext4",
+	"iso9660",
+	"nfs",
+	"nfs4",
+	"ntfs",
+	"squashfs",
+	"tmpfs",
+	"udf",
+	"vfat",
+	"xfs",
+}
+
+// the mount options that can be used with mount-control, notably
+// anything that would bind mount or allow devices or setuid is absent;
+// as those are allowed by default, snapctl mount always adds nosuid and
+// nodev as well
+var mountControlAllowedOptions = []string{
+	"async",
+	"atime",
+	"diratime",
+	"dirsync",
+	"lazytime",
+	"noatime",
+	"nodev",
+	"nodiratime",
+	"noexec",
+	"nolazytime",
+	"norelatime",
+	"nostrictatime",
+	"nosuid",
+	"relatime",
+	"ro",
+	"rw",
+	"silent",
+	"strictatime",
+	"sync",
+}
+
+// the directories mount-control mounts can be made below, so that no
+// system directory can be mounted over
+var mountControlAllowedWhere = []string{
+	"/media",
+	"/run/media",
+	"/mnt",
+	"$SNAP_COMMON",
+	"$SNAP_DATA",
+}
+
+// MountControlEntry is one of the mounts allowed by a mount-control plug.
+type MountControlEntry struct {
+	// What is the pattern of the mount sources, a path or, for
+	// network filesystems, a host:/path.
+	What string
+	// Where is the pattern of the mount points, below /media,
+	// /run/media, /mnt, $SNAP_COMMON or $SNAP_DATA.
+	Where      string
+	Types      []string
+	Options    []string
+	Persistent bool
+}
+
+type mountControlInterface struct {
+	commonInterface
+}
+
+// validateMountPattern checks a what or where pattern, only "*" and
+// "**" can be used as wildcards.
+func validateMountPattern(p string) error {
+	if err := apparmor.ValidateNoAppArmorRegexp(strings.Replace(p, "*", "", -1)); err != nil {
+		return err
+	}
+	if strings.ContainsAny(p, ",@") {
+		return fmt.Errorf(`%q cannot contain "," or "@"`, p)
+	}
+	if strings.Contains(p, "***") {
+		return fmt.Errorf(`%q cannot contain "***"`, p)
+	}
+	return nil
+}
+
+func validateMountWhat(what string, types []string) error {
+	if what == "none" || what == "tmpfs" {
+		if len(types) != 1 || types[0] != "tmpfs" {
+			return fmt.Errorf(`"what" can only be %q with type tmpfs`, what)
+		}
+		return nil
+	}
+	path, ok := mountWhatPath(what)
+	if !ok {
+		return fmt.Errorf(`"what" must be an absolute path or a host:/path, not %q`, what)
+	}
+	if filepath.Clean(path) != path {
+		return fmt.Errorf(`"what" must be a clean path, not %q`, what)
+	}
+	return validateMountPattern(what)
+}
+
+// mountWhatPath returns the path of a mount source that is either an
+// absolute path or a host:/path as used by nfs.
+func mountWhatPath(what string) (path string, ok bool) {
+	if strings.HasPrefix(what, "/") {
+		return what, true
+	}
+	idx := strings.Index(what, ":/")
+	if idx <= 0 || strings.Contains(what[:idx], "/") {
+		return "", false
+	}
+	return what[idx+1:], true
+}
+
+func validateMountWhere(where string) error {
+	path := where
+	for _, v := range []string{"$SNAP_COMMON", "$SNAP_DATA"} {
+		if strings.HasPrefix(where, v+"/") {
+			path = strings.TrimPrefix(where, v)
+			break
+		}
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf(`"where" must start with "/", "$SNAP_COMMON/" or "$SNAP_DATA/", not %q`, where)
+	}
+	if filepath.Clean(path) != path || path == "/" {
+		return fmt.Errorf(`"where" must be a clean path below "/", not %q`, where)
+	}
+	if strings.Contains(path, "$") {
+		return fmt.Errorf(`"where" can only start with $SNAP_COMMON or $SNAP_DATA: %q`, where)
+	}
+	allowed := false
+	for _, dir := range mountControlAllowedWhere {
+		if strings.HasPrefix(where, dir+"/") {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf(`"where" must be below %s, not %q`, strings.Join(mountControlAllowedWhere, ", "), where)
+	}
+	return validateMountPattern(where)
+}
+
+func stringList(attrs map[string]interface{}, name string) ([]string, error) {
+	v, ok := attrs[name]
+	if !ok {
+		return nil, nil
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%q must be a list of strings", name)
+	}
+	r := make([]string, 0, len(l))
+	for _, e := range l {
+		s, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a list of strings", name)
+		}
+		r = append(r, s)
+	}
+	return r, nil
+}
+
+func parseMountControlEntry(attrs map[string]interface{}) (*MountControlEntry, error) {
+	e := &MountControlEntry{}
+	for _, key := range []string{"what", "where"} {
+		v, ok := attrs[key].(string)
+		if !ok || v == "" {
+			return nil, fmt.Errorf("%q must be set to a string", key)
+		}
+		if key == "what" {
+			e.What = v
+		} else {
+			e.Where = v
+		}
+	}
+	var err error
+	if e.Types, err = stringList(attrs, "type"); err != nil {
+		return nil, err
+	}
+	if len(e.Types) == 0 {
+		return nil, fmt.Errorf(`"type" must list at least one filesystem type`)
+	}
+	for _, t := range e.Types {
+		if !strutil.ListContains(mountControlAllowedTypes, t) {
+			return nil, fmt.Errorf("filesystem type %q is not supported", t)
+		}
+	}
+	if e.Options, err = stringList(attrs, "options"); err != nil {
+		return nil, err
+	}
+	if len(e.Options) == 0 {
+		return nil, fmt.Errorf(`"options" must list at least one mount option`)
+	}
+	for _, o := range e.Options {
+		if !strutil.ListContains(mountControlAllowedOptions, o) {
+			return nil, fmt.Errorf("mount option %q is not supported", o)
+		}
+	}
+	if v, ok := attrs["persistent"]; ok {
+		if e.Persistent, ok = v.(bool); !ok {
+			return nil, fmt.Errorf(`"persistent" must be a boolean`)
+		}
+	}
+	if err := validateMountWhat(e.What, e.Types); err != nil {
+		return nil, err
+	}
+	if err := validateMountWhere(e.Where); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// MountControlEntries returns the mounts allowed by the given
+// mount-control plug.
+func MountControlEntries(plug *snap.PlugInfo) ([]*MountControlEntry, error) {
+	raw, ok := plug.Attrs["mount"]
+	if !ok {
+		return nil, fmt.Errorf(`needs a "mount" attribute`)
+	}
+	l, ok := raw.([]interface{})
+	if !ok || len(l) == 0 {
+		return nil, fmt.Errorf(`"mount" must be a non-empty list of mount entries`)
+	}
+	entries := make([]*MountControlEntry, 0, len(l))
+	for i, v := range l {
+		attrs, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("mount entry #%d must be a map", i+1)
+		}
+		e, err := parseMountControlEntry(attrs)
+		if err != nil {
+			return nil, fmt.Errorf("mount entry #%d: %v", i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// mountPatternRegexp turns a what or where pattern into a regexp where
+// "*" matches within a path component and "**" across components.
+func mountPatternRegexp(p string) *regexp.Regexp {
+	parts := strings.Split(p, "**")
+	for i, part := range parts {
+		subparts := strings.Split(part, "*")
+		for j := range subparts {
+			subparts[j] = regexp.QuoteMeta(subparts[j])
+		}
+		parts[i] = strings.Join(subparts, "[^/]*")
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// ExpandWhere returns the where pattern with $SNAP_COMMON and $SNAP_DATA
+// expanded for the given snap.
+func (e *MountControlEntry) ExpandWhere(info *snap.Info) string {
+	switch {
+	case strings.HasPrefix(e.Where, "$SNAP_COMMON/"):
+		return dirs.StripRootDir(info.CommonDataDir()) + strings.TrimPrefix(e.Where, "$SNAP_COMMON")
+	case strings.HasPrefix(e.Where, "$SNAP_DATA/"):
+		return dirs.StripRootDir(info.DataDir()) + strings.TrimPrefix(e.Where, "$SNAP_DATA")
+	}
+	return e.Where
+}
+
+// MatchesWhere returns whether the given mount point is allowed by
+// the entry for the given snap.
+func (e *MountControlEntry) MatchesWhere(where string, info *snap.Info) bool {
+	return filepath.Clean(where) == where && mountPatternRegexp(e.ExpandWhere(info)).MatchString(where)
+}
+
+// MatchesWhat returns whether the given mount source is allowed by the
+// entry.
+func (e *MountControlEntry) MatchesWhat(what string) bool {
+	if what != "none" && what != "tmpfs" {
+		path, ok := mountWhatPath(what)
+		if !ok || filepath.Clean(path) != path {
+			return false
+		}
+	}
+	return mountPatternRegexp(e.What).MatchString(what)
+}
+
+// Allows returns whether the entry allows the given mount for the
+// given snap.
+func (e *MountControlEntry) Allows(what, where, fstype string, options []string, persistent bool, info *snap.Info) bool {
+	if persistent && !e.Persistent {
+		return false
+	}
+	if !strutil.ListContains(e.Types, fstype) {
+		return false
+	}
+	for _, o := range options {
+		if !strutil.ListContains(e.Options, o) {
+			return false
+		}
+	}
+	if !e.MatchesWhat(what) {
+		return false
+	}
+	return e.MatchesWhere(where, info)
+}
+
+func (iface *mountControlInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	if _, err := MountControlEntries(plug); err != nil {
+		return fmt.Errorf("cannot add mount-control plug: %v", err)
+	}
+	return nil
+}
+
+func apparmorMountWhere(where string) string {
+	switch {
+	case strings.HasPrefix(where, "$SNAP_COMMON/"):
+		return "/var/snap/@{SNAP_INSTANCE_NAME}/common" + strings.TrimPrefix(where, "$SNAP_COMMON")
+	case strings.HasPrefix(where, "$SNAP_DATA/"):
+		return "/var/snap/@{SNAP_INSTANCE_NAME}/@{SNAP_REVISION}" + strings.TrimPrefix(where, "$SNAP_DATA")
+	}
+	return where
+}
+
+func (iface *mountControlInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	entries, err := MountControlEntries(&snap.PlugInfo{Attrs: plug.StaticAttrs()})
+	if err != nil {
+		return fmt.Errorf("cannot connect plug %s: %v", plug.Name(), err)
+	}
+
+	buf := bytes.NewBufferString(mountControlConnectedPlugAppArmor)
+	for _, e := range entries {
+		where := apparmorMountWhere(e.Where) + "{,/}"
+		fmt.Fprintf(buf, "mount fstype=(%s) options in (%s) \"%s\" -> \"%s\",\n", strings.Join(e.Types, ","), strings.Join(e.Options, ","), e.What, where)
+		fmt.Fprintf(buf, "umount \"%s\",\n", where)
+	}
+	spec.AddSnippet(buf.String())
+	return nil
+}
+
+func init() {
+	registerIface(&mountControlInterface{commonInterface{
+		name:                 "mount-control",
+		summary:              mountControlSummary,
+		implicitOnCore:       true,
+		implicitOnClassic:    true,
+		baseDeclarationPlugs: mountControlBaseDeclarationPlugs,
+		baseDeclarationSlots: mountControlBaseDeclarationSlots,
+		reservedForOS:        true,
+	}})
+}
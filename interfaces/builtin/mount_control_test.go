@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type mountControlInterfaceSuite struct {
+	iface    interfaces.Interface
+	slot     *interfaces.ConnectedSlot
+	slotInfo *snap.SlotInfo
+	plug     *interfaces.ConnectedPlug
+	plugInfo *snap.PlugInfo
+}
+
+var _ = Suite(&mountControlInterfaceSuite{
+	iface: builtin.MustInterface("mount-control"),
+})
+
+const mountControlConsumerYaml = `name: consumer
+version: 1.0
+plugs:
+ mntctl:
+  interface: mount-control
+  mount:
+  - what: /dev/sd*
+    where: $SNAP_COMMON/**
+    type: [ext4, vfat]
+    options: [rw, sync]
+    persistent: true
+  - what: server:/export/*
+    where: /media/nfs
+    type: [nfs]
+    options: [ro]
+apps:
+ app:
+  command: foo
+  plugs: [mntctl]
+`
+
+func (s *mountControlInterfaceSuite) SetUpTest(c *C) {
+	s.slotInfo = &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "core", Type: snap.TypeOS},
+		Name:      "mount-control",
+		Interface: "mount-control",
+	}
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+	plugSnap := snaptest.MockInfo(c, mountControlConsumerYaml, nil)
+	s.plugInfo = plugSnap.Plugs["mntctl"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+}
+
+func (s *mountControlInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "mount-control")
+}
+
+func (s *mountControlInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+	slot := &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-snap"},
+		Name:      "mount-control",
+		Interface: "mount-control",
+	}
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches,
+		"mount-control slots are reserved for the core snap")
+}
+
+func (s *mountControlInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+}
+
+func (s *mountControlInterfaceSuite) TestSanitizePlugAllowedWhere(c *C) {
+	for _, where := range []string{"/media/*", "/run/media/**", "/mnt/usb", "$SNAP_COMMON/**", "$SNAP_DATA/mnt"} {
+		plug := &snap.PlugInfo{
+			Snap:      s.plugInfo.Snap,
+			Name:      "mntctl",
+			Interface: "mount-control",
+			Attrs: map[string]interface{}{
+				"mount": []interface{}{map[string]interface{}{
+					"what":    "/dev/sd*",
+					"where":   where,
+					"type":    []interface{}{"ext4"},
+					"options": []interface{}{"rw"},
+				}},
+			},
+		}
+		c.Check(interfaces.BeforePreparePlug(s.iface, plug), IsNil, Commentf(where))
+	}
+}
+
+func (s *mountControlInterfaceSuite) TestSanitizePlugUnhappy(c *C) {
+	const mockSnapYaml = `name: consumer
+version: 1.0
+plugs:
+ mount-control:
+  $t
+`
+	var testCases = []struct {
+		inp    string
+		errStr string
+	}{
+		{`foo: bar`, `needs a "mount" attribute`},
+		{`mount: []`, `"mount" must be a non-empty list of mount entries`},
+		{`mount: [foo]`, `mount entry #1 must be a map`},
+		{`mount: [{where: /media, type: [ext4], options: [rw]}]`, `mount entry #1: "what" must be set to a string`},
+		{`mount: [{what: /dev/sda, type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be set to a string`},
+		{`mount: [{what: /dev/sda, where: /media, options: [rw]}]`, `mount entry #1: "type" must list at least one filesystem type`},
+		{`mount: [{what: /dev/sda, where: /media, type: ext4, options: [rw]}]`, `mount entry #1: "type" must be a list of strings`},
+		{`mount: [{what: /dev/sda, where: /media, type: [proc], options: [rw]}]`, `mount entry #1: filesystem type "proc" is not supported`},
+		{`mount: [{what: /dev/sda, where: /media, type: [ext4]}]`, `mount entry #1: "options" must list at least one mount option`},
+		{`mount: [{what: /dev/sda, where: /media, type: [ext4], options: [bind]}]`, `mount entry #1: mount option "bind" is not supported`},
+		{`mount: [{what: /dev/sda, where: /media, type: [ext4], options: [suid]}]`, `mount entry #1: mount option "suid" is not supported`},
+		{`mount: [{what: /dev/sda, where: /media, type: [ext4], options: [rw], persistent: yes-please}]`, `mount entry #1: "persistent" must be a boolean`},
+		{`mount: [{what: sda, where: /media, type: [ext4], options: [rw]}]`, `mount entry #1: "what" must be an absolute path or a host:/path, not "sda"`},
+		{`mount: [{what: tmpfs, where: /media, type: [ext4], options: [rw]}]`, `mount entry #1: "what" can only be "tmpfs" with type tmpfs`},
+		{`mount: [{what: /dev/../sda, where: /media, type: [ext4], options: [rw]}]`, `mount entry #1: "what" must be a clean path, not "/dev/../sda"`},
+		{`mount: [{what: "/dev/sd[ab]", where: /media, type: [ext4], options: [rw]}]`, `mount entry #1: "/dev/sd\[ab\]" contains a reserved apparmor char from .*`},
+		{`mount: [{what: /dev/sda, where: media, type: [ext4], options: [rw]}]`, `mount entry #1: "where" must start with "/", "\$SNAP_COMMON/" or "\$SNAP_DATA/", not "media"`},
+		{`mount: [{what: /dev/sda, where: /, type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be a clean path below "/", not "/"`},
+		{`mount: [{what: /dev/sda, where: /media/$SNAP_DATA, type: [ext4], options: [rw]}]`, `mount entry #1: "where" can only start with \$SNAP_COMMON or \$SNAP_DATA: "/media/\$SNAP_DATA"`},
+		{`mount: [{what: /dev/sda, where: "/media/@{HOME}", type: [ext4], options: [rw]}]`, `mount entry #1: "/media/@{HOME}" contains a reserved apparmor char from .*`},
+		{`mount: [{what: /dev/sda, where: /media/a@b, type: [ext4], options: [rw]}]`, `mount entry #1: "/media/a@b" cannot contain "," or "@"`},
+		{`mount: [{what: /dev/sda, where: /media, type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below /media, /run/media, /mnt, \$SNAP_COMMON, \$SNAP_DATA, not "/media"`},
+		{`mount: [{what: /dev/sda, where: /etc, type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below .*, not "/etc"`},
+		{`mount: [{what: /dev/sda, where: "/etc/**", type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below .*, not "/etc/\*\*"`},
+		{`mount: [{what: /dev/sda, where: "/usr/**", type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below .*, not "/usr/\*\*"`},
+		{`mount: [{what: /dev/sda, where: "/snap/**", type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below .*, not "/snap/\*\*"`},
+		{`mount: [{what: /dev/sda, where: /var/lib/snapd, type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below .*, not "/var/lib/snapd"`},
+		{`mount: [{what: /dev/sda, where: "/**", type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below .*, not "/\*\*"`},
+		{`mount: [{what: /dev/sda, where: "/media*/foo", type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be below .*, not "/media\*/foo"`},
+		{`mount: [{what: /dev/sda, where: "/mnt/../etc", type: [ext4], options: [rw]}]`, `mount entry #1: "where" must be a clean path below "/", not "/mnt/../etc"`},
+	}
+
+	for _, t := range testCases {
+		yml := strings.Replace(mockSnapYaml, "$t", t.inp, -1)
+		info := snaptest.MockInfo(c, yml, nil)
+		plug := info.Plugs["mount-control"]
+
+		c.Check(interfaces.BeforePreparePlug(s.iface, plug), ErrorMatches, "cannot add mount-control plug: "+t.errStr, Commentf("unexpected error for %q", t.inp))
+	}
+}
+
+func (s *mountControlInterfaceSuite) TestConnectedPlugAppArmor(c *C) {
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Assert(apparmorSpec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, `
+mount fstype=(ext4,vfat) options in (rw,sync) "/dev/sd*" -> "/var/snap/@{SNAP_INSTANCE_NAME}/common/**{,/}",
+umount "/var/snap/@{SNAP_INSTANCE_NAME}/common/**{,/}",
+mount fstype=(nfs) options in (ro) "server:/export/*" -> "/media/nfs{,/}",
+umount "/media/nfs{,/}",
+`)
+}
+
+func (s *mountControlInterfaceSuite) TestEntriesAllows(c *C) {
+	info := snaptest.MockInfo(c, mountControlConsumerYaml, &snap.SideInfo{Revision: snap.R(7)})
+	entries, err := builtin.MountControlEntries(info.Plugs["mntctl"])
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+
+	persistent := entries[0]
+	c.Check(persistent.ExpandWhere(info), Equals, "/var/snap/consumer/common/**")
+	c.Check(persistent.Allows("/dev/sdb1", "/var/snap/consumer/common/data", "ext4", []string{"rw"}, true, info), Equals, true)
+	c.Check(persistent.Allows("/dev/sdb1", "/var/snap/consumer/common/a/b", "vfat", nil, false, info), Equals, true)
+	// wrong type, option, source or mount point
+	c.Check(persistent.Allows("/dev/sdb1", "/var/snap/consumer/common/data", "xfs", nil, false, info), Equals, false)
+	c.Check(persistent.Allows("/dev/sdb1", "/var/snap/consumer/common/data", "ext4", []string{"noexec"}, false, info), Equals, false)
+	c.Check(persistent.Allows("/dev/mmcblk0", "/var/snap/consumer/common/data", "ext4", nil, false, info), Equals, false)
+	c.Check(persistent.Allows("/dev/sdb1", "/var/snap/other/common/data", "ext4", nil, false, info), Equals, false)
+	c.Check(persistent.Allows("/dev/sdb1", "/var/snap/consumer/common/../../other", "ext4", nil, false, info), Equals, false)
+
+	nfs := entries[1]
+	c.Check(nfs.Allows("server:/export/music", "/media/nfs", "nfs", []string{"ro"}, false, info), Equals, true)
+	// "*" does not cross directories
+	c.Check(nfs.Allows("server:/export/music/rock", "/media/nfs", "nfs", nil, false, info), Equals, false)
+	// not allowed to be persistent
+	c.Check(nfs.Allows("server:/export/music", "/media/nfs", "nfs", nil, true, info), Equals, false)
+	c.Check(nfs.MatchesWhere("/media/nfs", info), Equals, true)
+	c.Check(nfs.MatchesWhere("/media/nfs2", info), Equals, false)
+	c.Check(nfs.MatchesWhat("server:/export/../etc"), Equals, false)
+}
+
+func (s *mountControlInterfaceSuite) TestEntriesAllowsOnlyCleanWhat(c *C) {
+	const yaml = `name: consumer
+version: 0
+plugs:
+ mntctl:
+  interface: mount-control
+  mount:
+  - what: /dev/disk/by-label/**
+    where: /media/**
+    type: [ext4]
+    options: [rw]
+`
+	info := snaptest.MockInfo(c, yaml, nil)
+	entries, err := builtin.MountControlEntries(info.Plugs["mntctl"])
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+
+	e := entries[0]
+	c.Check(e.Allows("/dev/disk/by-label/data", "/media/data", "ext4", nil, false, info), Equals, true)
+	// "**" would otherwise match a way out of the allowed tree
+	c.Check(e.Allows("/dev/disk/by-label/../../../home/user", "/media/data", "ext4", nil, false, info), Equals, false)
+	c.Check(e.Allows("/dev/disk/by-label/data/", "/media/data", "ext4", nil, false, info), Equals, false)
+	c.Check(e.MatchesWhat("/dev/disk/by-label/./data"), Equals, false)
+}
+
+func (s *mountControlInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
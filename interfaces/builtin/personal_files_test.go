@@ -154,6 +154,20 @@ plugs:
 	}
 }
 
+func (s *personalFilesInterfaceSuite) TestRestrictPlug(c *C) {
+	attrs, err := interfaces.RestrictPlug(s.iface, s.plugInfo, map[string]interface{}{
+		"read": []interface{}{"$HOME/.read-dir/one"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(attrs["read"], DeepEquals, []interface{}{"$HOME/.read-dir/one"})
+	c.Check(attrs["write"], DeepEquals, []interface{}{"$HOME/.write-dir", "$HOME/.write-file"})
+
+	_, err = interfaces.RestrictPlug(s.iface, s.plugInfo, map[string]interface{}{
+		"read": []interface{}{"/etc/passwd"},
+	})
+	c.Check(err, ErrorMatches, `cannot restrict personal-files: "/etc/passwd" must start with "\$HOME/"`)
+}
+
 func (s *personalFilesInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
@@ -0,0 +1,130 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type PipewireInterfaceSuite struct {
+	iface           interfaces.Interface
+	coreSlotInfo    *snap.SlotInfo
+	coreSlot        *interfaces.ConnectedSlot
+	classicSlotInfo *snap.SlotInfo
+	classicSlot     *interfaces.ConnectedSlot
+	plugInfo        *snap.PlugInfo
+	plug            *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&PipewireInterfaceSuite{
+	iface: builtin.MustInterface("pipewire"),
+})
+
+const pipewireMockPlugSnapInfoYaml = `name: consumer
+version: 1.0
+apps:
+ app:
+  command: foo
+  plugs: [pipewire]
+`
+
+const pipewireMockCoreSlotSnapInfoYaml = `name: pipewire-server
+version: 1.0
+apps:
+ daemon:
+  command: foo
+  slots: [pipewire]
+`
+
+const pipewireMockClassicSlotSnapInfoYaml = `name: core
+version: 0
+type: os
+slots:
+ pipewire:
+  interface: pipewire
+`
+
+func (s *PipewireInterfaceSuite) SetUpTest(c *C) {
+	snapInfo := snaptest.MockInfo(c, pipewireMockCoreSlotSnapInfoYaml, nil)
+	s.coreSlotInfo = snapInfo.Slots["pipewire"]
+	s.coreSlot = interfaces.NewConnectedSlot(s.coreSlotInfo, nil, nil)
+	snapInfo = snaptest.MockInfo(c, pipewireMockClassicSlotSnapInfoYaml, nil)
+	s.classicSlotInfo = snapInfo.Slots["pipewire"]
+	s.classicSlot = interfaces.NewConnectedSlot(s.classicSlotInfo, nil, nil)
+	snapInfo = snaptest.MockInfo(c, pipewireMockPlugSnapInfoYaml, nil)
+	s.plugInfo = snapInfo.Plugs["pipewire"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+}
+
+func (s *PipewireInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "pipewire")
+}
+
+func (s *PipewireInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.classicSlotInfo), IsNil)
+	slot := &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "some-gadget", Type: snap.TypeGadget},
+		Name:      "pipewire",
+		Interface: "pipewire",
+	}
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches,
+		"pipewire slots are reserved for the core and app snaps")
+}
+
+func (s *PipewireInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+}
+
+func (s *PipewireInterfaceSuite) TestAppArmor(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app", "snap.pipewire-server.daemon"})
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, "owner /run/user/[0-9]*/pipewire-[0-9] rw,\n")
+	c.Check(spec.SnippetForTag("snap.pipewire-server.daemon"), testutil.Contains, "owner /run/user/[0-9]*/pipewire-[0-9] rwk,\n")
+}
+
+func (s *PipewireInterfaceSuite) TestSecComp(c *C) {
+	spec := &seccomp.Specification{}
+	c.Assert(spec.AddPermanentSlot(s.iface, s.coreSlotInfo), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.pipewire-server.daemon"})
+	c.Check(spec.SnippetForTag("snap.pipewire-server.daemon"), testutil.Contains, "listen\n")
+}
+
+func (s *PipewireInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Check(si.ImplicitOnCore, Equals, false)
+	c.Check(si.ImplicitOnClassic, Equals, true)
+	c.Check(si.Summary, Equals, "allows access to the pipewire socket")
+	c.Check(si.BaseDeclarationSlots, testutil.Contains, "deny-auto-connection: true")
+}
+
+func (s *PipewireInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
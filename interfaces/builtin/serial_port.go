@@ -30,6 +30,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/interfaces/utils"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -131,6 +132,40 @@ func (iface *serialPortInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
 	return nil
 }
 
+// RestrictPlug narrows the plug to a single serial device node given
+// by the "path" attribute. Whether the slot provides that node is
+// checked when the connection is set up.
+func (iface *serialPortInterface) RestrictPlug(plug *snap.PlugInfo, restrictions map[string]interface{}) (map[string]interface{}, error) {
+	attrs := utils.CopyAttributes(plug.Attrs)
+	for att, value := range restrictions {
+		if att != "path" {
+			return nil, fmt.Errorf("cannot restrict serial-port attribute %q", att)
+		}
+		path, ok := value.(string)
+		if !ok || !serialDeviceNodePattern.MatchString(path) {
+			return nil, fmt.Errorf("cannot restrict serial-port: \"path\" must be a valid device node")
+		}
+		attrs[att] = path
+	}
+	return attrs, nil
+}
+
+// restrictedPath returns the device node the plug was restricted to,
+// if any, making sure that the slot provides it.
+func (iface *serialPortInterface) restrictedPath(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) (string, error) {
+	var path string
+	if err := plug.Attr("path", &path); err != nil {
+		return "", nil
+	}
+	if !iface.hasUsbAttrs(slot) {
+		var slotPath string
+		if err := slot.Attr("path", &slotPath); err == nil && filepath.Clean(slotPath) != path {
+			return "", fmt.Errorf("cannot connect plug %s: %q is not provided by slot %s", plug.Name(), path, slot.Name())
+		}
+	}
+	return path, nil
+}
+
 func (iface *serialPortInterface) UDevPermanentSlot(spec *udev.Specification, slot *snap.SlotInfo) error {
 	var usbVendor, usbProduct, usbInterfaceNumber int64
 	var path string
@@ -156,6 +191,15 @@ SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}==
 }
 
 func (iface *serialPortInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	restricted, err := iface.restrictedPath(plug, slot)
+	if err != nil {
+		return err
+	}
+	if restricted != "" {
+		spec.AddSnippet(fmt.Sprintf("%s rw,", restricted))
+		return nil
+	}
+
 	if iface.hasUsbAttrs(slot) {
 		// This apparmor rule is an approximation of serialDeviceNodePattern
 		// (AARE is different than regex, so we must approximate).
@@ -177,6 +221,10 @@ func (iface *serialPortInterface) AppArmorConnectedPlug(spec *apparmor.Specifica
 func (iface *serialPortInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	// For connected plugs, we use vendor and product ids if available,
 	// otherwise add the kernel device
+	restricted, err := iface.restrictedPath(plug, slot)
+	if err != nil {
+		return err
+	}
 	hasOnlyPath := !iface.hasUsbAttrs(slot)
 	var usbVendor, usbProduct int64
 	var path string
@@ -193,13 +241,18 @@ func (iface *serialPortInterface) UDevConnectedPlug(spec *udev.Specification, pl
 	if hasOnlyPath {
 		spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="tty", KERNEL=="%s"`, strings.TrimPrefix(path, "/dev/")))
 	} else {
+		// a restricted plug only gets the given node of the device
+		var kernel string
+		if restricted != "" {
+			kernel = fmt.Sprintf(`, KERNEL=="%s"`, strings.TrimPrefix(restricted, "/dev/"))
+		}
 		var usbInterfaceNumber int64
 		if err := slot.Attr("usb-interface-number", &usbInterfaceNumber); err == nil {
 			spec.TagDevice(fmt.Sprintf(`IMPORT{builtin}="usb_id"
-SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x", ENV{ID_USB_INTERFACE_NUM}=="%02x"`, usbVendor, usbProduct, usbInterfaceNumber))
+SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x", ENV{ID_USB_INTERFACE_NUM}=="%02x"%s`, usbVendor, usbProduct, usbInterfaceNumber, kernel))
 		} else {
 			spec.TagDevice(fmt.Sprintf(`IMPORT{builtin}="usb_id"
-SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x"`, usbVendor, usbProduct))
+SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}=="%04x"%s`, usbVendor, usbProduct, kernel))
 		}
 	}
 	return nil
@@ -552,6 +552,59 @@ SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="ffff", ATTRS{idProduct}==
 	checkConnectedPlugSnippet(s.testPlugPort3, s.testUDev2, expectedSnippet10, expectedExtraSnippet10)
 }
 
+func (s *SerialPortInterfaceSuite) TestRestrictPlug(c *C) {
+	attrs, err := interfaces.RestrictPlug(s.iface, s.testPlugPort3Info, map[string]interface{}{
+		"path": "/dev/ttyUSB1",
+	})
+	c.Assert(err, IsNil)
+	c.Check(attrs, DeepEquals, map[string]interface{}{"path": "/dev/ttyUSB1"})
+	// the plug itself is unchanged
+	c.Check(s.testPlugPort3Info.Attrs, HasLen, 0)
+
+	// a device with vendor and product ids is narrowed to the node
+	plug := interfaces.NewConnectedPlug(s.testPlugPort3Info, attrs, nil)
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, plug, s.testUDev1), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.app-accessing-3rd-port"), Equals, `/dev/ttyUSB1 rw,`)
+	udevSpec := &udev.Specification{}
+	c.Assert(udevSpec.AddConnectedPlug(s.iface, plug, s.testUDev3), IsNil)
+	c.Assert(udevSpec.Snippets(), HasLen, 2)
+	c.Check(udevSpec.Snippets()[0], Equals, `# serial-port
+IMPORT{builtin}="usb_id"
+SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="abcd", ATTRS{idProduct}=="1234", ENV{ID_USB_INTERFACE_NUM}=="00", KERNEL=="ttyUSB1", TAG+="snap_client-snap_app-accessing-3rd-port"`)
+
+	// a slot with just a path must provide the node
+	attrs, err = interfaces.RestrictPlug(s.iface, s.testPlugPort3Info, map[string]interface{}{
+		"path": "/dev/ttyUSB927",
+	})
+	c.Assert(err, IsNil)
+	plug = interfaces.NewConnectedPlug(s.testPlugPort3Info, attrs, nil)
+	apparmorSpec = &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, plug, s.testSlot2), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.app-accessing-3rd-port"), Equals, `/dev/ttyUSB927 rw,`)
+	apparmorSpec = &apparmor.Specification{}
+	err = apparmorSpec.AddConnectedPlug(s.iface, plug, s.testSlot1)
+	c.Check(err, ErrorMatches, `cannot connect plug plug-for-port-3: "/dev/ttyUSB927" is not provided by slot test-port-1`)
+	udevSpec = &udev.Specification{}
+	err = udevSpec.AddConnectedPlug(s.iface, plug, s.testSlot1)
+	c.Check(err, ErrorMatches, `cannot connect plug plug-for-port-3: "/dev/ttyUSB927" is not provided by slot test-port-1`)
+}
+
+func (s *SerialPortInterfaceSuite) TestRestrictPlugUnhappy(c *C) {
+	for _, t := range []struct {
+		restrictions map[string]interface{}
+		errStr       string
+	}{
+		{map[string]interface{}{"foo": "bar"}, `cannot restrict serial-port attribute "foo"`},
+		{map[string]interface{}{"path": 1}, `cannot restrict serial-port: "path" must be a valid device node`},
+		{map[string]interface{}{"path": "/dev/sda"}, `cannot restrict serial-port: "path" must be a valid device node`},
+		{map[string]interface{}{"path": "/dev/serial-port-foo"}, `cannot restrict serial-port: "path" must be a valid device node`},
+	} {
+		_, err := interfaces.RestrictPlug(s.iface, s.testPlugPort3Info, t.restrictions)
+		c.Check(err, ErrorMatches, t.errStr)
+	}
+}
+
 func (s *SerialPortInterfaceSuite) TestHotplugDeviceDetected(c *C) {
 	hotplugIface := s.iface.(hotplug.Definer)
 	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "ID_VENDOR_ID": "1234", "ID_MODEL_ID": "5678", "ACTION": "add", "SUBSYSTEM": "tty", "ID_BUS": "usb"})
@@ -177,6 +177,53 @@ apps:
 	c.Assert(err, ErrorMatches, `cannot connect plug system-files: 123 \(int64\) is not a string`)
 }
 
+func (s *systemFilesInterfaceSuite) TestRestrictPlug(c *C) {
+	attrs, err := interfaces.RestrictPlug(s.iface, s.plugInfo, map[string]interface{}{
+		"read":  []interface{}{"/etc/read-dir2/sub"},
+		"write": "/etc/write-file2",
+	})
+	c.Assert(err, IsNil)
+	c.Check(attrs, DeepEquals, map[string]interface{}{
+		"read":  []interface{}{"/etc/read-dir2/sub"},
+		"write": []interface{}{"/etc/write-file2"},
+	})
+	// the plug itself is unchanged
+	c.Check(s.plugInfo.Attrs["read"], DeepEquals, []interface{}{"/etc/read-dir2", "/etc/read-file2"})
+
+	// the restricted attributes are used for the snippets
+	plug := interfaces.NewConnectedPlug(s.plugInfo, attrs, nil)
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, plug, s.slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.other.app"), Equals, `
+# Description: Can access specific system files or directories.
+# This is restricted because it gives file access to arbitrary locations.
+"/etc/read-dir2/sub{,/,/**}" rk,
+"/etc/write-file2{,/,/**}" rwkl,
+`)
+}
+
+func (s *systemFilesInterfaceSuite) TestRestrictPlugUnhappy(c *C) {
+	for _, t := range []struct {
+		restrictions map[string]interface{}
+		errStr       string
+	}{
+		{map[string]interface{}{"foo": "bar"}, `cannot restrict system-files attribute "foo"`},
+		{map[string]interface{}{"read": 1}, `cannot restrict system-files: "read" must be a list of strings`},
+		{map[string]interface{}{"read": []interface{}{"/etc/read-dir2/../x"}}, `cannot restrict system-files: cannot use "/etc/read-dir2/../x": try "/etc/x"`},
+		{map[string]interface{}{"read": []interface{}{"/etc/read-dir2x"}}, `cannot restrict system-files: "/etc/read-dir2x" is not allowed by "read" of plug system-files`},
+		// cannot widen read access to write access
+		{map[string]interface{}{"write": []interface{}{"/etc/read-dir2"}}, `cannot restrict system-files: "/etc/read-dir2" is not allowed by "write" of plug system-files`},
+	} {
+		_, err := interfaces.RestrictPlug(s.iface, s.plugInfo, t.restrictions)
+		c.Check(err, ErrorMatches, t.errStr)
+	}
+}
+
+func (s *systemFilesInterfaceSuite) TestRestrictPlugNotSupported(c *C) {
+	_, err := interfaces.RestrictPlug(builtin.MustInterface("network"), s.plugInfo, map[string]interface{}{"foo": "bar"})
+	c.Check(err, ErrorMatches, `interface "network" does not support restrictions`)
+}
+
 func (s *systemFilesInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
@@ -40,6 +40,16 @@ func BeforePreparePlug(iface Interface, plugInfo *snap.PlugInfo) error {
 	return err
 }
 
+// RestrictPlug returns the static attributes of the plug narrowed by
+// the given administrator restrictions, as checked by the interface.
+func RestrictPlug(iface Interface, plugInfo *snap.PlugInfo, restrictions map[string]interface{}) (map[string]interface{}, error) {
+	restrictor, ok := iface.(PlugRestrictor)
+	if !ok {
+		return nil, fmt.Errorf("interface %q does not support restrictions", iface.Name())
+	}
+	return restrictor.RestrictPlug(plugInfo, restrictions)
+}
+
 // PlugRef is a reference to a plug.
 type PlugRef struct {
 	Snap string `json:"snap"`
@@ -172,6 +182,14 @@ type PlugSanitizer interface {
 	BeforePreparePlug(plug *snap.PlugInfo) error
 }
 
+// PlugRestrictor can be implemented by Interfaces that let an
+// administrator narrow the attributes of a plug when connecting it.
+type PlugRestrictor interface {
+	// RestrictPlug checks that the restrictions are a subset of what
+	// the plug allows and returns the resulting static attributes.
+	RestrictPlug(plug *snap.PlugInfo, restrictions map[string]interface{}) (map[string]interface{}, error)
+}
+
 // SlotSanitizer can be implemented by Interfaces that have reasons to sanitize their slots.
 type SlotSanitizer interface {
 	BeforePrepareSlot(slot *snap.SlotInfo) error
@@ -21,14 +21,20 @@ package kmod
 
 import (
 	"os/exec"
+
+	"github.com/snapcore/snapd/release"
 )
 
 // loadModules loads given list of modules via modprobe.
 // Since different kernels may not have the requested module, we treat any
 // error from modprobe as non-fatal and subsequent module loads are attempted
 // (otherwise failure to load a module means failure to connect the interface
-// and the other security backends)
+// and the other security backends). Nothing is loaded in preseed mode, the
+// modules are loaded on boot as listed in the modules-load.d files.
 func loadModules(modules []string) {
+	if release.PreseedMode {
+		return
+	}
 	for _, mod := range modules {
 		// ignore errors which are logged by loadModule() via syslog
 		_ = exec.Command("modprobe", "--syslog", mod).Run()
@@ -22,6 +22,7 @@ package kmod_test
 import (
 	"github.com/snapcore/snapd/interfaces/ifacetest"
 	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/testutil"
 	. "gopkg.in/check.v1"
 )
@@ -54,3 +55,16 @@ func (s *kmodSuite) TestModprobeCall(c *C) {
 		{"modprobe", "--syslog", "module2"},
 	})
 }
+
+func (s *kmodSuite) TestModprobeNotCalledWhenPreseeding(c *C) {
+	restore := release.MockPreseedMode(true)
+	defer restore()
+
+	cmd := testutil.MockCommand(c, "modprobe", "")
+	defer cmd.Restore()
+
+	kmod.LoadModules([]string{
+		"module1",
+	})
+	c.Assert(cmd.Calls(), HasLen, 0)
+}
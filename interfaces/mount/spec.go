@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"path"
 	"sort"
+	"strings"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
@@ -45,6 +46,7 @@ type Specification struct {
 	general  []osutil.MountEntry
 	user     []osutil.MountEntry
 	overname []osutil.MountEntry
+	merged   map[string][]osutil.MountEntry
 }
 
 // AddMountEntry adds a new mount entry.
@@ -59,6 +61,56 @@ func (spec *Specification) AddUserMountEntry(e osutil.MountEntry) error {
 	return nil
 }
 
+// AddMergedMountEntry adds a new read-only mount entry whose source is merged
+// with the sources of all other merged entries using the same mount point.
+//
+// A mount point with a single source is bind mounted as usual. Multiple
+// sources are stacked with a read-only overlay, ordered by source path so
+// that the result does not depend on the order of the connections.
+func (spec *Specification) AddMergedMountEntry(e osutil.MountEntry) error {
+	// Overlay options are separated by commas and lower directories by
+	// colons, neither can be escaped.
+	if strings.ContainsAny(e.Name, ",:") || strings.ContainsAny(e.Dir, ",:") {
+		return fmt.Errorf("cannot merge %q into %q: paths must not contain ',' or ':'", e.Name, e.Dir)
+	}
+	if spec.merged == nil {
+		spec.merged = make(map[string][]osutil.MountEntry)
+	}
+	spec.merged[e.Dir] = append(spec.merged[e.Dir], e)
+	return nil
+}
+
+// mergedMountEntries returns the mount entries of merged sources, sorted by
+// mount point.
+func (spec *Specification) mergedMountEntries() []osutil.MountEntry {
+	mountPoints := make([]string, 0, len(spec.merged))
+	for dir := range spec.merged {
+		mountPoints = append(mountPoints, dir)
+	}
+	sort.Strings(mountPoints)
+
+	result := make([]osutil.MountEntry, 0, len(mountPoints))
+	for _, dir := range mountPoints {
+		entries := spec.merged[dir]
+		if len(entries) == 1 {
+			result = append(result, entries[0])
+			continue
+		}
+		lowerDirs := make([]string, len(entries))
+		for i, e := range entries {
+			lowerDirs[i] = e.Name
+		}
+		sort.Strings(lowerDirs)
+		result = append(result, osutil.MountEntry{
+			Name:    "overlay",
+			Dir:     dir,
+			Type:    "overlay",
+			Options: []string{"ro", "lowerdir=" + strings.Join(lowerDirs, ":")},
+		})
+	}
+	return result
+}
+
 // AddOvernameMountEntry adds a new overname mount entry.
 func (spec *Specification) AddOvernameMountEntry(e osutil.MountEntry) error {
 	spec.overname = append(spec.overname, e)
@@ -88,6 +140,16 @@ func mountEntryFromLayout(layout *snap.Layout) osutil.MountEntry {
 		entry.Name = "tmpfs"
 	}
 
+	if layout.Type == "overlay" {
+		// The read-only directory of the snap is the lower layer of the
+		// overlay, writes go to the upper directory in $SNAP_DATA or
+		// $SNAP_COMMON.
+		upper, work := layout.OverlayDirs()
+		entry.Type = "overlay"
+		entry.Name = "overlay"
+		entry.Options = []string{"lowerdir=" + mountPoint, "upperdir=" + upper, "workdir=" + work}
+	}
+
 	if layout.Symlink != "" {
 		oldname := layout.Snap.ExpandSnapVariables(layout.Symlink)
 		entry.Options = []string{osutil.XSnapdKindSymlink(), osutil.XSnapdSymlink(oldname)}
@@ -142,13 +204,14 @@ func (spec *Specification) AddLayout(si *snap.Info) {
 
 // MountEntries returns a copy of the added mount entries.
 func (spec *Specification) MountEntries() []osutil.MountEntry {
-	result := make([]osutil.MountEntry, 0, len(spec.overname)+len(spec.layout)+len(spec.general))
+	result := make([]osutil.MountEntry, 0, len(spec.overname)+len(spec.layout)+len(spec.general)+len(spec.merged))
 	// overname is the mappings that were added to support parallel
 	// installation of snaps and must come first, as they establish the base
 	// namespace for any further operations
 	result = append(result, spec.overname...)
 	result = append(result, spec.layout...)
 	result = append(result, spec.general...)
+	result = append(result, spec.mergedMountEntries()...)
 	unclashMountEntries(result)
 	return result
 }
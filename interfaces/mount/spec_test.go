@@ -164,6 +164,33 @@ func (s *specSuite) TestMountEntryFromLayout(c *C) {
 	})
 }
 
+func (s *specSuite) TestMountEntryFromOverlayLayout(c *C) {
+	const overlayYaml = `name: vanguard
+version: 0
+layout:
+  $SNAP/etc:
+    type: overlay
+    upper: $SNAP_DATA/etc
+  $SNAP/usr/share/vanguard:
+    type: overlay
+    upper: $SNAP_COMMON/overlay/share
+`
+	snapInfo := snaptest.MockInfo(c, overlayYaml, &snap.SideInfo{Revision: snap.R(42)})
+	s.spec.AddLayout(snapInfo)
+	c.Assert(s.spec.MountEntries(), DeepEquals, []osutil.MountEntry{
+		{Dir: "/snap/vanguard/42/etc", Name: "overlay", Type: "overlay", Options: []string{
+			"lowerdir=/snap/vanguard/42/etc",
+			"upperdir=/var/snap/vanguard/42/etc",
+			"workdir=/var/snap/vanguard/42/.etc.overlay-work",
+			"x-snapd.origin=layout"}},
+		{Dir: "/snap/vanguard/42/usr/share/vanguard", Name: "overlay", Type: "overlay", Options: []string{
+			"lowerdir=/snap/vanguard/42/usr/share/vanguard",
+			"upperdir=/var/snap/vanguard/common/overlay/share",
+			"workdir=/var/snap/vanguard/common/overlay/.share.overlay-work",
+			"x-snapd.origin=layout"}},
+	})
+}
+
 func (s *specSuite) TestParallelInstanceMountEntryFromLayout(c *C) {
 	snapInfo := snaptest.MockInfo(c, snapWithLayout, &snap.SideInfo{Revision: snap.R(42)})
 	snapInfo.InstanceKey = "instance"
@@ -206,6 +233,29 @@ layout:
 	})
 }
 
+func (s *specSuite) TestMergedMountEntries(c *C) {
+	// A single source is bind mounted as-is.
+	c.Assert(s.spec.AddMergedMountEntry(osutil.MountEntry{Name: "/snap/b/1/data", Dir: "/snap/consumer/1/single", Options: []string{"bind", "ro"}}), IsNil)
+	// Multiple sources are stacked, ordered by source path.
+	c.Assert(s.spec.AddMergedMountEntry(osutil.MountEntry{Name: "/snap/b/1/data", Dir: "/snap/consumer/1/merged", Options: []string{"bind", "ro"}}), IsNil)
+	c.Assert(s.spec.AddMergedMountEntry(osutil.MountEntry{Name: "/snap/c/2/data", Dir: "/snap/consumer/1/merged", Options: []string{"bind", "ro"}}), IsNil)
+	c.Assert(s.spec.AddMergedMountEntry(osutil.MountEntry{Name: "/snap/a/3/data", Dir: "/snap/consumer/1/merged", Options: []string{"bind", "ro"}}), IsNil)
+	c.Assert(s.spec.AddMountEntry(osutil.MountEntry{Name: "/snap/d/1/data", Dir: "/snap/consumer/1/plain", Options: []string{"bind", "ro"}}), IsNil)
+	c.Assert(s.spec.MountEntries(), DeepEquals, []osutil.MountEntry{
+		{Name: "/snap/d/1/data", Dir: "/snap/consumer/1/plain", Options: []string{"bind", "ro"}},
+		{Name: "overlay", Dir: "/snap/consumer/1/merged", Type: "overlay", Options: []string{"ro", "lowerdir=/snap/a/3/data:/snap/b/1/data:/snap/c/2/data"}},
+		{Name: "/snap/b/1/data", Dir: "/snap/consumer/1/single", Options: []string{"bind", "ro"}},
+	})
+}
+
+func (s *specSuite) TestMergedMountEntriesInvalidPaths(c *C) {
+	c.Assert(s.spec.AddMergedMountEntry(osutil.MountEntry{Name: "/snap/a/1/a:b", Dir: "/snap/consumer/1/merged"}), ErrorMatches,
+		`cannot merge "/snap/a/1/a:b" into "/snap/consumer/1/merged": paths must not contain ',' or ':'`)
+	c.Assert(s.spec.AddMergedMountEntry(osutil.MountEntry{Name: "/snap/a/1/data", Dir: "/snap/consumer/1/a,b"}), ErrorMatches,
+		`cannot merge "/snap/a/1/data" into "/snap/consumer/1/a,b": paths must not contain ',' or ':'`)
+	c.Assert(s.spec.MountEntries(), HasLen, 0)
+}
+
 func (s *specSuite) TestParallelInstanceMountEntriesNoInstanceKey(c *C) {
 	snapInfo := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(42)}}
 	s.spec.AddOvername(snapInfo)
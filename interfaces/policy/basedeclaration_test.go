@@ -151,6 +151,7 @@ func (s *baseDeclSuite) TestAutoConnection(c *C) {
 
 	// these simply auto-connect, anything else doesn't
 	autoconnect := map[string]bool{
+		"audio-playback":          true,
 		"browser-support":         true,
 		"desktop":                 true,
 		"desktop-legacy":          true,
@@ -560,6 +561,8 @@ var (
 	slotInstallation = map[string][]string{
 		// other
 		"adb-support":             {"core"},
+		"audio-playback":          {"app", "core"},
+		"audio-record":            {"app", "core"},
 		"autopilot-introspection": {"core"},
 		"avahi-control":           {"app", "core"},
 		"avahi-observe":           {"app", "core"},
@@ -590,6 +593,7 @@ var (
 		"network-status":          {"app"},
 		"ofono":                   {"app", "core"},
 		"online-accounts-service": {"app"},
+		"pipewire":                {"app", "core"},
 		"ppp":         {"core"},
 		"pulseaudio":  {"app", "core"},
 		"serial-port": {"core", "gadget"},
@@ -685,6 +689,7 @@ func (s *baseDeclSuite) TestPlugInstallation(c *C) {
 		"kernel-module-control": true,
 		"kubernetes-support":    true,
 		"lxd-support":           true,
+		"mount-control":         true,
 		"multipass-support":     true,
 		"personal-files":        true,
 		"snapd-control":         true,
@@ -770,9 +775,12 @@ func (s *baseDeclSuite) TestConnectionOnClassic(c *C) {
 	// connecting with these interfaces needs to be allowed on
 	// case-by-case basis when not on classic
 	noconnect := map[string]bool{
+		"audio-playback":  true,
+		"audio-record":    true,
 		"modem-manager":   true,
 		"network-manager": true,
 		"ofono":           true,
+		"pipewire":        true,
 		"pulseaudio":      true,
 		"upower-observe":  true,
 	}
@@ -814,6 +822,7 @@ func (s *baseDeclSuite) TestSanity(c *C) {
 		"kernel-module-control": true,
 		"kubernetes-support":    true,
 		"lxd-support":           true,
+		"mount-control":         true,
 		"multipass-support":     true,
 		"personal-files":        true,
 		"snapd-control":         true,
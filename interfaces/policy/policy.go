@@ -37,15 +37,15 @@ type InstallCandidate struct {
 
 	BaseDeclaration *asserts.BaseDeclaration
 
+	// DevicePolicy holds the brand rules for the device model, they
+	// take precedence over the declarations.
+	DevicePolicy *asserts.DevicePolicy
+
 	Model *asserts.Model
 	Store *asserts.Store
 }
 
-func (ic *InstallCandidate) checkSlotRule(slot *snap.SlotInfo, rule *asserts.SlotRule, snapRule bool) error {
-	context := ""
-	if snapRule {
-		context = fmt.Sprintf(" for %q snap", ic.SnapDeclaration.SnapName())
-	}
+func (ic *InstallCandidate) checkSlotRule(slot *snap.SlotInfo, rule *asserts.SlotRule, context string) error {
 	if checkSlotInstallationConstraints(ic, slot, rule.DenyInstallation) == nil {
 		return fmt.Errorf("installation denied by %q slot rule of interface %q%s", slot.Name, slot.Interface, context)
 	}
@@ -55,11 +55,7 @@ func (ic *InstallCandidate) checkSlotRule(slot *snap.SlotInfo, rule *asserts.Slo
 	return nil
 }
 
-func (ic *InstallCandidate) checkPlugRule(plug *snap.PlugInfo, rule *asserts.PlugRule, snapRule bool) error {
-	context := ""
-	if snapRule {
-		context = fmt.Sprintf(" for %q snap", ic.SnapDeclaration.SnapName())
-	}
+func (ic *InstallCandidate) checkPlugRule(plug *snap.PlugInfo, rule *asserts.PlugRule, context string) error {
 	if checkPlugInstallationConstraints(ic, plug, rule.DenyInstallation) == nil {
 		return fmt.Errorf("installation denied by %q plug rule of interface %q%s", plug.Name, plug.Interface, context)
 	}
@@ -71,26 +67,36 @@ func (ic *InstallCandidate) checkPlugRule(plug *snap.PlugInfo, rule *asserts.Plu
 
 func (ic *InstallCandidate) checkSlot(slot *snap.SlotInfo) error {
 	iface := slot.Interface
+	if devPolicy := ic.DevicePolicy; devPolicy != nil {
+		if rule := devPolicy.SlotRule(ic.Snap.SnapName(), iface); rule != nil {
+			return ic.checkSlotRule(slot, rule, devicePolicyContext(ic.Snap.SnapName()))
+		}
+	}
 	if snapDecl := ic.SnapDeclaration; snapDecl != nil {
 		if rule := snapDecl.SlotRule(iface); rule != nil {
-			return ic.checkSlotRule(slot, rule, true)
+			return ic.checkSlotRule(slot, rule, snapDeclarationContext(snapDecl.SnapName()))
 		}
 	}
 	if rule := ic.BaseDeclaration.SlotRule(iface); rule != nil {
-		return ic.checkSlotRule(slot, rule, false)
+		return ic.checkSlotRule(slot, rule, "")
 	}
 	return nil
 }
 
 func (ic *InstallCandidate) checkPlug(plug *snap.PlugInfo) error {
 	iface := plug.Interface
+	if devPolicy := ic.DevicePolicy; devPolicy != nil {
+		if rule := devPolicy.PlugRule(ic.Snap.SnapName(), iface); rule != nil {
+			return ic.checkPlugRule(plug, rule, devicePolicyContext(ic.Snap.SnapName()))
+		}
+	}
 	if snapDecl := ic.SnapDeclaration; snapDecl != nil {
 		if rule := snapDecl.PlugRule(iface); rule != nil {
-			return ic.checkPlugRule(plug, rule, true)
+			return ic.checkPlugRule(plug, rule, snapDeclarationContext(snapDecl.SnapName()))
 		}
 	}
 	if rule := ic.BaseDeclaration.PlugRule(iface); rule != nil {
-		return ic.checkPlugRule(plug, rule, false)
+		return ic.checkPlugRule(plug, rule, "")
 	}
 	return nil
 }
@@ -128,10 +134,26 @@ type ConnectCandidate struct {
 
 	BaseDeclaration *asserts.BaseDeclaration
 
+	// DevicePolicy holds the brand rules for the device model, they
+	// take precedence over the declarations.
+	DevicePolicy *asserts.DevicePolicy
+
 	Model *asserts.Model
 	Store *asserts.Store
 }
 
+// snapDeclarationContext returns the error context for a rule from
+// the snap-declaration of the given snap.
+func snapDeclarationContext(snapName string) string {
+	return fmt.Sprintf(" for %q snap", snapName)
+}
+
+// devicePolicyContext returns the error context for a rule from the
+// device-policy about the given snap.
+func devicePolicyContext(snapName string) string {
+	return fmt.Sprintf(" for %q snap in device-policy", snapName)
+}
+
 func nestedGet(which string, attrs interfaces.Attrer, path string) (interface{}, error) {
 	val, ok := attrs.Lookup(path)
 	if !ok {
@@ -176,11 +198,7 @@ func (connc *ConnectCandidate) slotPublisherID() string {
 	return "" // never a valid publisher-id
 }
 
-func (connc *ConnectCandidate) checkPlugRule(kind string, rule *asserts.PlugRule, snapRule bool) error {
-	context := ""
-	if snapRule {
-		context = fmt.Sprintf(" for %q snap", connc.PlugSnapDeclaration.SnapName())
-	}
+func (connc *ConnectCandidate) checkPlugRule(kind string, rule *asserts.PlugRule, context string) error {
 	denyConst := rule.DenyConnection
 	allowConst := rule.AllowConnection
 	if kind == "auto-connection" {
@@ -196,11 +214,7 @@ func (connc *ConnectCandidate) checkPlugRule(kind string, rule *asserts.PlugRule
 	return nil
 }
 
-func (connc *ConnectCandidate) checkSlotRule(kind string, rule *asserts.SlotRule, snapRule bool) error {
-	context := ""
-	if snapRule {
-		context = fmt.Sprintf(" for %q snap", connc.SlotSnapDeclaration.SnapName())
-	}
+func (connc *ConnectCandidate) checkSlotRule(kind string, rule *asserts.SlotRule, context string) error {
 	denyConst := rule.DenyConnection
 	allowConst := rule.AllowConnection
 	if kind == "auto-connection" {
@@ -216,43 +230,71 @@ func (connc *ConnectCandidate) checkSlotRule(kind string, rule *asserts.SlotRule
 	return nil
 }
 
-func (connc *ConnectCandidate) check(kind string) error {
+// check checks the connection and returns a description of the rule
+// that decided it, which is empty if no rule applied.
+func (connc *ConnectCandidate) check(kind string) (decidingRule string, err error) {
 	baseDecl := connc.BaseDeclaration
 	if baseDecl == nil {
-		return fmt.Errorf("internal error: improperly initialized ConnectCandidate")
+		return "", fmt.Errorf("internal error: improperly initialized ConnectCandidate")
 	}
 
 	iface := connc.Plug.Interface()
 
 	if connc.Slot.Interface() != iface {
-		return fmt.Errorf("cannot connect mismatched plug interface %q to slot interface %q", iface, connc.Slot.Interface())
+		return "", fmt.Errorf("cannot connect mismatched plug interface %q to slot interface %q", iface, connc.Slot.Interface())
 	}
 
+	plugSnapName := connc.Plug.Snap().SnapName()
+	slotSnapName := connc.Slot.Snap().SnapName()
+	if devPolicy := connc.DevicePolicy; devPolicy != nil {
+		if rule := devPolicy.PlugRule(plugSnapName, iface); rule != nil {
+			return fmt.Sprintf("plug rule of interface %q in device-policy for %q snap", iface, plugSnapName),
+				connc.checkPlugRule(kind, rule, devicePolicyContext(plugSnapName))
+		}
+		if rule := devPolicy.SlotRule(slotSnapName, iface); rule != nil {
+			return fmt.Sprintf("slot rule of interface %q in device-policy for %q snap", iface, slotSnapName),
+				connc.checkSlotRule(kind, rule, devicePolicyContext(slotSnapName))
+		}
+	}
 	if plugDecl := connc.PlugSnapDeclaration; plugDecl != nil {
 		if rule := plugDecl.PlugRule(iface); rule != nil {
-			return connc.checkPlugRule(kind, rule, true)
+			return fmt.Sprintf("plug rule of interface %q in snap-declaration for %q snap", iface, plugDecl.SnapName()),
+				connc.checkPlugRule(kind, rule, snapDeclarationContext(plugDecl.SnapName()))
 		}
 	}
 	if slotDecl := connc.SlotSnapDeclaration; slotDecl != nil {
 		if rule := slotDecl.SlotRule(iface); rule != nil {
-			return connc.checkSlotRule(kind, rule, true)
+			return fmt.Sprintf("slot rule of interface %q in snap-declaration for %q snap", iface, slotDecl.SnapName()),
+				connc.checkSlotRule(kind, rule, snapDeclarationContext(slotDecl.SnapName()))
 		}
 	}
 	if rule := baseDecl.PlugRule(iface); rule != nil {
-		return connc.checkPlugRule(kind, rule, false)
+		return fmt.Sprintf("plug rule of interface %q in base-declaration", iface), connc.checkPlugRule(kind, rule, "")
 	}
 	if rule := baseDecl.SlotRule(iface); rule != nil {
-		return connc.checkSlotRule(kind, rule, false)
+		return fmt.Sprintf("slot rule of interface %q in base-declaration", iface), connc.checkSlotRule(kind, rule, "")
 	}
-	return nil
+	return "", nil
 }
 
 // Check checks whether the connection is allowed.
 func (connc *ConnectCandidate) Check() error {
-	return connc.check("connection")
+	_, err := connc.check("connection")
+	return err
 }
 
 // CheckAutoConnect checks whether the connection is allowed to auto-connect.
 func (connc *ConnectCandidate) CheckAutoConnect() error {
-	return connc.check("auto-connection")
+	_, err := connc.check("auto-connection")
+	return err
+}
+
+// DecidingRule checks whether the connection, or the auto-connection
+// if autoConnect is set, is allowed and returns a description of the
+// rule that decided it. The description is empty if no rule applied.
+func (connc *ConnectCandidate) DecidingRule(autoConnect bool) (string, error) {
+	if autoConnect {
+		return connc.check("auto-connection")
+	}
+	return connc.check("connection")
 }
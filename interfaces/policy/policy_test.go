@@ -977,6 +977,103 @@ func (s *policySuite) TestSnapDeclAllowDenyAutoConnection(c *C) {
 	}
 }
 
+func (s *policySuite) mockDevicePolicy(c *C) *asserts.DevicePolicy {
+	a, err := asserts.Decode([]byte(`type: device-policy
+authority-id: my-brand
+series: 16
+brand-id: my-brand
+model: my-model
+snaps:
+  plug-snap:
+    plugs:
+      snap-plug-deny:
+        allow-connection: true
+      base-plug-allow:
+        deny-connection: true
+      random:
+        allow-installation: false
+  slot-snap:
+    slots:
+      auto-snap-slot-deny:
+        allow-auto-connection: true
+timestamp: 2019-09-30T12:00:00Z
+sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij
+
+AXNpZw==`))
+	c.Assert(err, IsNil)
+	return a.(*asserts.DevicePolicy)
+}
+
+func (s *policySuite) TestDevicePolicyAllowDenyConnection(c *C) {
+	devPolicy := s.mockDevicePolicy(c)
+
+	tests := []struct {
+		iface    string
+		auto     bool
+		rule     string
+		expected string // "" => no error
+	}{
+		// a rule about installation only allows connection by default
+		{"random", false, `plug rule of interface "random" in device-policy for "plug-snap" snap`, ""},
+		{"auto-base-plug-allow", true, `plug rule of interface "auto-base-plug-allow" in base-declaration`, ""},
+		// the device-policy overrides the snap-declaration
+		{"snap-plug-deny", false, `plug rule of interface "snap-plug-deny" in device-policy for "plug-snap" snap`, ""},
+		// and the base-declaration
+		{"base-plug-allow", false, `plug rule of interface "base-plug-allow" in device-policy for "plug-snap" snap`, `connection denied by plug rule of interface "base-plug-allow" for "plug-snap" snap in device-policy`},
+		{"auto-snap-slot-deny", true, `slot rule of interface "auto-snap-slot-deny" in device-policy for "slot-snap" snap`, ""},
+		// other rules are still used
+		{"snap-slot-deny", false, `slot rule of interface "snap-slot-deny" in snap-declaration for "slot-snap" snap`, `connection denied by slot rule of interface "snap-slot-deny" for "slot-snap" snap`},
+		{"base-plug-deny", false, `plug rule of interface "base-plug-deny" in base-declaration`, `connection denied by plug rule of interface "base-plug-deny"`},
+	}
+
+	for _, t := range tests {
+		cand := policy.ConnectCandidate{
+			Plug:                interfaces.NewConnectedPlug(s.plugSnap.Plugs[t.iface], nil, nil),
+			Slot:                interfaces.NewConnectedSlot(s.slotSnap.Slots[t.iface], nil, nil),
+			PlugSnapDeclaration: s.plugDecl,
+			SlotSnapDeclaration: s.slotDecl,
+			BaseDeclaration:     s.baseDecl,
+			DevicePolicy:        devPolicy,
+		}
+
+		rule, err := cand.DecidingRule(t.auto)
+		c.Check(rule, Equals, t.rule, Commentf(t.iface))
+		if t.expected == "" {
+			c.Check(err, IsNil, Commentf(t.iface))
+		} else {
+			c.Check(err, ErrorMatches, t.expected, Commentf(t.iface))
+		}
+	}
+
+	// without the device-policy the snap-declaration decides
+	cand := policy.ConnectCandidate{
+		Plug:                interfaces.NewConnectedPlug(s.plugSnap.Plugs["auto-snap-slot-deny"], nil, nil),
+		Slot:                interfaces.NewConnectedSlot(s.slotSnap.Slots["auto-snap-slot-deny"], nil, nil),
+		PlugSnapDeclaration: s.plugDecl,
+		SlotSnapDeclaration: s.slotDecl,
+		BaseDeclaration:     s.baseDecl,
+	}
+	c.Check(cand.CheckAutoConnect(), ErrorMatches, `auto-connection denied by slot rule of interface "auto-snap-slot-deny" for "slot-snap" snap`)
+}
+
+func (s *policySuite) TestDevicePolicyInstallation(c *C) {
+	installSnap := snaptest.MockInfo(c, `name: plug-snap
+version: 0
+plugs:
+  random:
+`, nil)
+
+	cand := policy.InstallCandidate{
+		Snap:            installSnap,
+		SnapDeclaration: s.plugDecl,
+		BaseDeclaration: s.baseDecl,
+	}
+	c.Check(cand.Check(), IsNil)
+
+	cand.DevicePolicy = s.mockDevicePolicy(c)
+	c.Check(cand.Check(), ErrorMatches, `installation not allowed by "random" plug rule of interface "random" for "plug-snap" snap in device-policy`)
+}
+
 func (s *policySuite) TestSnapTypeCheckConnection(c *C) {
 	gadgetSnap := snaptest.MockInfo(c, `
 name: gadget
@@ -25,6 +25,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -53,6 +54,17 @@ func (s *uDevSuite) TestReloadUDevRulesRunsUDevAdm(c *C) {
 	})
 }
 
+func (s *uDevSuite) TestReloadUDevRulesPreseedMode(c *C) {
+	restore := release.MockPreseedMode(true)
+	defer restore()
+
+	cmd := testutil.MockCommand(c, "udevadm", "")
+	defer cmd.Restore()
+	err := udev.ReloadRules(nil)
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), HasLen, 0)
+}
+
 func (s *uDevSuite) TestReloadUDevRulesReportsErrorsFromReloadRules(c *C) {
 	cmd := testutil.MockCommand(c, "udevadm", `
 if [ "$1" = "control" ]; then
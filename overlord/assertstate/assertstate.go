@@ -356,6 +356,22 @@ func Store(s *state.State, store string) (*asserts.Store, error) {
 	return a.(*asserts.Store), nil
 }
 
+// DevicePolicy returns the device-policy assertion signed by the brand
+// for the given model if it is present in the system assertion
+// database.
+func DevicePolicy(s *state.State, model *asserts.Model) (*asserts.DevicePolicy, error) {
+	db := DB(s)
+	a, err := db.Find(asserts.DevicePolicyType, map[string]string{
+		"series":   model.Series(),
+		"brand-id": model.BrandID(),
+		"model":    model.Model(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.(*asserts.DevicePolicy), nil
+}
+
 // AutoAliases returns the explicit automatic aliases alias=>app mapping for the given installed snap.
 func AutoAliases(s *state.State, info *snap.Info) (map[string]string, error) {
 	if info.SnapID == "" {
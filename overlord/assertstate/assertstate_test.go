@@ -109,10 +109,10 @@ func (s *assertMgrSuite) SetUpTest(c *C) {
 		"account-id":   "my-brand",
 		"verification": "verified",
 	}, "")
-	brandAcctKey := assertstest.NewAccountKey(s.storeSigning, s.brandAcct, nil, brandPrivKey.PublicKey(), "")
+	s.brandAcctKey = assertstest.NewAccountKey(s.storeSigning, s.brandAcct, nil, brandPrivKey.PublicKey(), "")
 	err = s.storeSigning.Add(s.brandAcct)
 	c.Assert(err, IsNil)
-	err = s.storeSigning.Add(brandAcctKey)
+	err = s.storeSigning.Add(s.brandAcctKey)
 	c.Assert(err, IsNil)
 	s.brandSigning = assertstest.NewSigningDB("my-brand", brandPrivKey)
 
@@ -1547,3 +1547,51 @@ func (s *assertMgrSuite) TestStore(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(store.Store(), Equals, "foo")
 }
+
+func (s *assertMgrSuite) TestDevicePolicy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(assertstate.Add(s.state, s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(assertstate.Add(s.state, s.brandAcct), IsNil)
+	c.Assert(assertstate.Add(s.state, s.brandAcctKey), IsNil)
+
+	a, err := s.brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "krnl",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	model := a.(*asserts.Model)
+	c.Assert(assertstate.Add(s.state, model), IsNil)
+
+	_, err = assertstate.DevicePolicy(s.state, model)
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	a, err = s.brandSigning.Sign(asserts.DevicePolicyType, map[string]interface{}{
+		"series":   "16",
+		"brand-id": "my-brand",
+		"model":    "my-model",
+		"snaps": map[string]interface{}{
+			"brand-app": map[string]interface{}{
+				"plugs": map[string]interface{}{
+					"serial-port": map[string]interface{}{
+						"allow-auto-connection": "true",
+					},
+				},
+			},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(assertstate.Add(s.state, a), IsNil)
+
+	devPolicy, err := assertstate.DevicePolicy(s.state, model)
+	c.Assert(err, IsNil)
+	c.Check(devPolicy.SnapNames(), DeepEquals, []string{"brand-app"})
+	c.Check(devPolicy.PlugRule("brand-app", "serial-port"), NotNil)
+}
@@ -20,20 +20,63 @@
 package overlord
 
 import (
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
 type overlordStateBackend struct {
 	path           string
+	journalPath    string
 	ensureBefore   func(d time.Duration)
 	requestRestart func(t state.RestartType)
 }
 
 func (osb *overlordStateBackend) Checkpoint(data []byte) error {
-	return osutil.AtomicWriteFile(osb.path, data, 0600, 0)
+	if err := osutil.AtomicWriteFile(osb.path, data, 0600, 0); err != nil {
+		return err
+	}
+	// the snapshot includes everything that was journaled so far
+	if err := os.Remove(osb.journalPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (osb *overlordStateBackend) AppendJournal(entry []byte) error {
+	f, err := os.OpenFile(osb.journalPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(entry); err != nil {
+		// do not leave a partial entry behind for the retry to append to
+		f.Truncate(fi.Size())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if fi.Size() == 0 {
+		// make sure a newly created journal is there after a power loss
+		dir, err := os.Open(filepath.Dir(osb.journalPath))
+		if err != nil {
+			return err
+		}
+		defer dir.Close()
+		return dir.Sync()
+	}
+	return nil
 }
 
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
@@ -43,3 +86,14 @@ func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 func (osb *overlordStateBackend) RequestRestart(t state.RestartType) {
 	osb.requestRestart(t)
 }
+
+func (osb *overlordStateBackend) ArchiveChanges(changes []*state.Change) {
+	records := make([]*changehistory.Record, len(changes))
+	for i, chg := range changes {
+		records[i] = changehistory.NewRecord(chg)
+	}
+	// losing some history is no reason to keep the changes around
+	if err := changehistory.Append(records); err != nil {
+		logger.Noticef("cannot archive pruned changes: %v", err)
+	}
+}
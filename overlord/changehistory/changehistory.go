@@ -0,0 +1,234 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package changehistory keeps a compact record of the changes that were
+// pruned from the state, so that what happened to the system can still
+// be looked at long after the changes themselves are gone.
+package changehistory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// maxHistorySize is the size the history file can grow to before it is
+// rotated; only one rotated file is kept.
+var maxHistorySize int64 = 512 * 1024
+
+// A Record is what is kept of a change once it is pruned.
+type Record struct {
+	ID        string       `json:"id"`
+	Kind      string       `json:"kind"`
+	Summary   string       `json:"summary"`
+	Status    string       `json:"status"`
+	User      string       `json:"user,omitempty"`
+	SpawnTime time.Time    `json:"spawn-time"`
+	ReadyTime time.Time    `json:"ready-time"`
+	Snaps     []SnapRecord `json:"snaps,omitempty"`
+	Err       string       `json:"err,omitempty"`
+}
+
+// A SnapRecord is a snap affected by a change.
+type SnapRecord struct {
+	Name        string        `json:"name"`
+	Revision    snap.Revision `json:"revision"`
+	OldRevision snap.Revision `json:"old-revision"`
+}
+
+// NewRecord returns the record to keep of the given change. The state
+// must be locked by the caller.
+func NewRecord(chg *state.Change) *Record {
+	st := chg.State()
+	rec := &Record{
+		ID:        chg.ID(),
+		Kind:      chg.Kind(),
+		Summary:   chg.Summary(),
+		Status:    chg.Status().String(),
+		SpawnTime: chg.SpawnTime(),
+		ReadyTime: chg.ReadyTime(),
+	}
+	if err := chg.Err(); err != nil {
+		rec.Err = err.Error()
+	}
+
+	var names []string
+	snaps := make(map[string]*SnapRecord)
+	snapRecord := func(name string) *SnapRecord {
+		if snaps[name] == nil {
+			names = append(names, name)
+			snaps[name] = &SnapRecord{Name: name}
+		}
+		return snaps[name]
+	}
+
+	var userID int
+	for _, t := range chg.Tasks() {
+		if !t.Has("snap-setup") && t.Kind() != "link-snap" {
+			continue
+		}
+		snapsup, err := snapstate.TaskSnapSetup(t)
+		if err != nil {
+			continue
+		}
+		sr := snapRecord(snapsup.InstanceName())
+		if !snapsup.Revision().Unset() {
+			sr.Revision = snapsup.Revision()
+		}
+		var oldCurrent snap.Revision
+		if t.Kind() == "link-snap" && t.Get("old-current", &oldCurrent) == nil {
+			sr.OldRevision = oldCurrent
+		}
+		if userID == 0 {
+			userID = snapsup.UserID
+		}
+	}
+	// changes not driven by snap setups, like snapshots, only know the
+	// names of the snaps they were about
+	var snapNames []string
+	if chg.Get("snap-names", &snapNames) != nil {
+		var apiData struct {
+			SnapNames []string `json:"snap-names"`
+		}
+		chg.Get("api-data", &apiData)
+		snapNames = apiData.SnapNames
+	}
+	for _, name := range snapNames {
+		snapRecord(name)
+	}
+	for _, name := range names {
+		rec.Snaps = append(rec.Snaps, *snaps[name])
+	}
+
+	if userID != 0 {
+		if user, err := auth.User(st, userID); err == nil {
+			rec.User = user.Username
+			if rec.User == "" {
+				rec.User = user.Email
+			}
+		}
+	}
+
+	return rec
+}
+
+func rotatedFile() string {
+	return dirs.SnapChangeHistoryFile + ".1"
+}
+
+// Append adds the given records to the history, rotating it first if it
+// would grow beyond its maximum size.
+func Append(records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapChangeHistoryFile), 0755); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(dirs.SnapChangeHistoryFile); err == nil && fi.Size()+int64(buf.Len()) > maxHistorySize {
+		if err := os.Rename(dirs.SnapChangeHistoryFile, rotatedFile()); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(dirs.SnapChangeHistoryFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// A Filter selects records from the history.
+type Filter struct {
+	// SnapName selects the records of changes that affected the snap.
+	SnapName string
+	// Since selects the records of changes that became ready at or
+	// after the given time.
+	Since time.Time
+}
+
+func (f *Filter) matches(rec *Record) bool {
+	if !f.Since.IsZero() && rec.ReadyTime.Before(f.Since) {
+		return false
+	}
+	if f.SnapName == "" {
+		return true
+	}
+	names := make([]string, len(rec.Snaps))
+	for i, sr := range rec.Snaps {
+		names[i] = sr.Name
+	}
+	return strutil.ListContains(names, f.SnapName)
+}
+
+// Read returns the records in the history that match the filter, oldest
+// first. Records that cannot be decoded are skipped.
+func Read(filter *Filter) ([]*Record, error) {
+	if filter == nil {
+		filter = &Filter{}
+	}
+	var records []*Record
+	for _, fn := range []string{rotatedFile(), dirs.SnapChangeHistoryFile} {
+		f, err := os.Open(fn)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var rec Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				continue
+			}
+			if filter.matches(&rec) {
+				records = append(records, &rec)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,196 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package changehistory_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type historySuite struct {
+	state *state.State
+}
+
+var _ = Suite(&historySuite{})
+
+func (s *historySuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+}
+
+func (s *historySuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *historySuite) TestNewRecordRefresh(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	user, err := auth.NewUser(st, "joe", "joe@example.com", "macaroon", nil)
+	c.Assert(err, IsNil)
+
+	chg := st.NewChange("refresh-snap", `Refresh "foo" snap`)
+	download := st.NewTask("download-snap", "...")
+	download.Set("snap-setup", &snapstate.SnapSetup{
+		UserID:   user.ID,
+		SideInfo: &snap.SideInfo{RealName: "foo", Revision: snap.R(3)},
+	})
+	link := st.NewTask("link-snap", "...")
+	link.Set("snap-setup-task", download.ID())
+	link.Set("old-current", snap.R(2))
+	chg.AddTask(download)
+	chg.AddTask(link)
+	link.Errorf("something broke")
+	link.SetStatus(state.ErrorStatus)
+	download.SetStatus(state.UndoneStatus)
+
+	rec := changehistory.NewRecord(chg)
+	c.Check(rec.ID, Equals, chg.ID())
+	c.Check(rec.Kind, Equals, "refresh-snap")
+	c.Check(rec.Summary, Equals, `Refresh "foo" snap`)
+	c.Check(rec.Status, Equals, "Error")
+	c.Check(rec.User, Equals, "joe")
+	c.Check(rec.SpawnTime.Equal(chg.SpawnTime()), Equals, true)
+	c.Check(rec.ReadyTime.Equal(chg.ReadyTime()), Equals, true)
+	c.Check(rec.Snaps, DeepEquals, []changehistory.SnapRecord{
+		{Name: "foo", Revision: snap.R(3), OldRevision: snap.R(2)},
+	})
+	c.Check(rec.Err, Matches, `(?s)cannot perform the following tasks:.*something broke.*`)
+}
+
+func (s *historySuite) TestNewRecordSnapNames(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("save-snapshot", "...")
+	chg.Set("api-data", map[string]interface{}{"snap-names": []string{"foo", "bar"}})
+	chg.SetStatus(state.DoneStatus)
+
+	rec := changehistory.NewRecord(chg)
+	c.Check(rec.User, Equals, "")
+	c.Check(rec.Err, Equals, "")
+	c.Check(rec.Snaps, DeepEquals, []changehistory.SnapRecord{
+		{Name: "foo"},
+		{Name: "bar"},
+	})
+}
+
+func (s *historySuite) TestAppendRead(c *C) {
+	t0 := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	err := changehistory.Append([]*changehistory.Record{
+		{ID: "1", Kind: "install-snap", Status: "Done", ReadyTime: t0, Snaps: []changehistory.SnapRecord{{Name: "foo", Revision: snap.R(1)}}},
+		{ID: "2", Kind: "remove-snap", Status: "Done", ReadyTime: t0.Add(time.Hour), Snaps: []changehistory.SnapRecord{{Name: "bar"}}},
+	})
+	c.Assert(err, IsNil)
+	err = changehistory.Append([]*changehistory.Record{
+		{ID: "3", Kind: "refresh-snap", Status: "Error", Err: "boom", ReadyTime: t0.Add(2 * time.Hour), Snaps: []changehistory.SnapRecord{{Name: "foo", Revision: snap.R(2), OldRevision: snap.R(1)}}},
+	})
+	c.Assert(err, IsNil)
+
+	st, err := os.Stat(dirs.SnapChangeHistoryFile)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+
+	ids := func(filter *changehistory.Filter) []string {
+		records, err := changehistory.Read(filter)
+		c.Assert(err, IsNil)
+		var ids []string
+		for _, rec := range records {
+			ids = append(ids, rec.ID)
+		}
+		return ids
+	}
+	c.Check(ids(nil), DeepEquals, []string{"1", "2", "3"})
+	c.Check(ids(&changehistory.Filter{SnapName: "foo"}), DeepEquals, []string{"1", "3"})
+	c.Check(ids(&changehistory.Filter{Since: t0.Add(time.Hour)}), DeepEquals, []string{"2", "3"})
+	c.Check(ids(&changehistory.Filter{SnapName: "foo", Since: t0.Add(time.Hour)}), DeepEquals, []string{"3"})
+
+	records, err := changehistory.Read(&changehistory.Filter{SnapName: "foo", Since: t0.Add(time.Hour)})
+	c.Assert(err, IsNil)
+	c.Check(records[0], DeepEquals, &changehistory.Record{
+		ID: "3", Kind: "refresh-snap", Status: "Error", Err: "boom", ReadyTime: t0.Add(2 * time.Hour),
+		Snaps: []changehistory.SnapRecord{{Name: "foo", Revision: snap.R(2), OldRevision: snap.R(1)}},
+	})
+}
+
+func (s *historySuite) TestReadNoHistory(c *C) {
+	records, err := changehistory.Read(nil)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 0)
+}
+
+func (s *historySuite) TestReadSkipsInvalidLines(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapChangeHistoryFile), 0755), IsNil)
+	err := ioutil.WriteFile(dirs.SnapChangeHistoryFile, []byte(`{"id":"1"}
+{"id":"2
+{"id":"3"}
+`), 0600)
+	c.Assert(err, IsNil)
+
+	records, err := changehistory.Read(nil)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 2)
+	c.Check(records[0].ID, Equals, "1")
+	c.Check(records[1].ID, Equals, "3")
+}
+
+func (s *historySuite) TestAppendRotates(c *C) {
+	restore := changehistory.MockMaxHistorySize(200)
+	defer restore()
+
+	for i := 1; i <= 5; i++ {
+		err := changehistory.Append([]*changehistory.Record{{ID: strconv.Itoa(i), Kind: "some-change"}})
+		c.Assert(err, IsNil)
+	}
+
+	// each record is over 100 bytes, so only the last one is in the
+	// current file and the one before it in the rotated one
+	records, err := changehistory.Read(nil)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 2)
+	c.Check(records[0].ID, Equals, "4")
+	c.Check(records[1].ID, Equals, "5")
+}
+
+func (s *historySuite) TestAppendError(c *C) {
+	// the history file cannot be created
+	c.Assert(os.MkdirAll(dirs.SnapChangeHistoryFile, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapChangeHistoryFile+"/x", nil, 0644), IsNil)
+
+	err := changehistory.Append([]*changehistory.Record{{ID: "1"}})
+	c.Check(err, ErrorMatches, `open .*/change-history: is a directory`)
+}
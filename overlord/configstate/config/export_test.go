@@ -21,8 +21,25 @@ package config
 
 import (
 	"encoding/json"
+	"time"
 )
 
 func (t *Transaction) PristineConfig() map[string]map[string]*json.RawMessage {
 	return t.pristine
 }
+
+func MockMaxHistoryEntries(n int) (restore func()) {
+	old := maxHistoryEntries
+	maxHistoryEntries = n
+	return func() {
+		maxHistoryEntries = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
@@ -32,7 +32,18 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
-var validKey = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+var (
+	validKey = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+	// subkeys of the core options listed in coreVerbatimKeyPrefixes
+	validVerbatimKey = regexp.MustCompile("^[a-z0-9_-]+$")
+
+	// coreVerbatimKeyPrefixes lists the options of the core snap whose
+	// subkeys name things outside of snapd, like the sysctl keys of
+	// system.kernel.sysctl, and are kept as they are.
+	coreVerbatimKeyPrefixes = [][]string{
+		{"system", "kernel", "sysctl"},
+	}
+)
 
 func ParseKey(key string) (subkeys []string, err error) {
 	if key == "" {
@@ -47,6 +58,51 @@ func ParseKey(key string) (subkeys []string, err error) {
 	return subkeys, nil
 }
 
+// parseSnapKey works like ParseKey, except that for the options of the
+// core snap listed in coreVerbatimKeyPrefixes the subkeys can be made of
+// lowercase letters, digits, dashes and underscores in any order.
+func parseSnapKey(instanceName, key string) (subkeys []string, err error) {
+	if instanceName != "core" {
+		return ParseKey(key)
+	}
+	if key == "" {
+		return []string{}, nil
+	}
+	subkeys = strings.Split(key, ".")
+	verbatim := verbatimFrom(subkeys)
+	for i, subkey := range subkeys {
+		valid := validKey
+		if i >= verbatim {
+			valid = validVerbatimKey
+		}
+		if !valid.MatchString(subkey) {
+			return nil, fmt.Errorf("invalid option name: %q", subkey)
+		}
+	}
+	return subkeys, nil
+}
+
+// verbatimFrom returns the position from which the subkeys of a core
+// option are taken verbatim, or len(subkeys) if they are not.
+func verbatimFrom(subkeys []string) int {
+	for _, prefix := range coreVerbatimKeyPrefixes {
+		if len(subkeys) <= len(prefix) {
+			continue
+		}
+		matches := true
+		for i := range prefix {
+			if subkeys[i] != prefix[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return len(prefix)
+		}
+	}
+	return len(subkeys)
+}
+
 func PatchConfig(snapName string, subkeys []string, pos int, config interface{}, value *json.RawMessage) (interface{}, error) {
 
 	switch config := config.(type) {
@@ -219,10 +275,15 @@ func DiscardRevisionConfig(st *state.State, snapName string, rev snap.Revision)
 	return nil
 }
 
-// DeleteSnapConfig removed configuration of given snap from the state.
+// DeleteSnapConfig removed configuration of given snap from the state,
+// together with its configuration history.
 func DeleteSnapConfig(st *state.State, snapName string) error {
 	var config map[string]map[string]*json.RawMessage // snap => key => value
 
+	if err := deleteHistory(st, snapName); err != nil {
+		return err
+	}
+
 	err := st.Get("config", &config)
 	if err == state.ErrNoState {
 		return nil
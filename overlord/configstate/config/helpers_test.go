@@ -201,3 +201,37 @@ func (s *configHelpersSuite) TestGetFeatureFlag(c *C) {
 	_, err = config.GetFeatureFlag(tr, features.Layouts)
 	c.Assert(err, ErrorMatches, `layouts can only be set to 'true' or 'false', got "banana"`)
 }
+
+func (s *configHelpersSuite) TestVerbatimSubkeysOfCoreOptions(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+
+	c.Assert(tr.Set("core", "system.kernel.sysctl.vm.dirty_ratio", 10), IsNil)
+	c.Assert(tr.Set("core", "system.kernel.sysctl.net.ipv4.conf.br-lan.forwarding", 1), IsNil)
+
+	var value int
+	c.Assert(tr.Get("core", "system.kernel.sysctl.vm.dirty_ratio", &value), IsNil)
+	c.Check(value, Equals, 10)
+	c.Assert(tr.Get("core", "system.kernel.sysctl.net.ipv4.conf.br-lan.forwarding", &value), IsNil)
+	c.Check(value, Equals, 1)
+
+	// the usual rules apply elsewhere, including to the option itself
+	for _, key := range []string{
+		"system.kernel.dirty_ratio",
+		"system.kernel_sysctl.vm.dirty_ratio",
+		"system.kernel.sysctl.vm.Dirty_ratio",
+		"system.kernel.sysctl.vm..dirty_ratio",
+	} {
+		err := tr.Set("core", key, 1)
+		c.Check(err, ErrorMatches, `invalid option name: ".*"`, Commentf(key))
+	}
+
+	// and to the options of other snaps
+	err := tr.Set("test-snap", "system.kernel.sysctl.vm.dirty_ratio", 10)
+	c.Check(err, ErrorMatches, `invalid option name: "dirty_ratio"`)
+	err = tr.Get("test-snap", "system.kernel.sysctl.vm.dirty_ratio", &value)
+	c.Check(err, ErrorMatches, `invalid option name: "dirty_ratio"`)
+	_, err = config.ParseKey("system.kernel.sysctl.vm.dirty_ratio")
+	c.Check(err, ErrorMatches, `invalid option name: "dirty_ratio"`)
+}
@@ -0,0 +1,304 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// maxHistoryEntries is the number of configuration changes remembered
+// for each snap.
+var maxHistoryEntries = 20
+
+var timeNow = time.Now
+
+// KeyChange records the change of a single configuration option. A nil
+// Old or New value means the option was not set.
+type KeyChange struct {
+	Key string           `json:"key"`
+	Old *json.RawMessage `json:"old,omitempty"`
+	New *json.RawMessage `json:"new,omitempty"`
+}
+
+// HistoryEntry records a committed change of the configuration of a snap.
+type HistoryEntry struct {
+	ID       int          `json:"id"`
+	Time     time.Time    `json:"time"`
+	Origin   string       `json:"origin,omitempty"`
+	ChangeID string       `json:"change-id,omitempty"`
+	Changes  []*KeyChange `json:"changes"`
+}
+
+// NoHistoryEntryError indicates that the requested entry is not in the
+// configuration history of a snap.
+type NoHistoryEntryError struct {
+	SnapName string
+	ID       int
+}
+
+func (e *NoHistoryEntryError) Error() string {
+	return fmt.Sprintf("snap %q has no configuration change %d in its history", e.SnapName, e.ID)
+}
+
+// History returns the recorded configuration changes of the given snap,
+// oldest first.
+//
+// The caller is responsible for locking the state.
+func History(st *state.State, instanceName string) ([]*HistoryEntry, error) {
+	var history map[string][]*HistoryEntry
+	err := st.Get("config-history", &history)
+	if err == state.ErrNoState {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot unmarshal configuration history: %v", err)
+	}
+	return history[instanceName], nil
+}
+
+// addHistoryEntry appends the entry to the history of the snap, assigning
+// it the next ID and dropping the oldest entries beyond the limit.
+func addHistoryEntry(st *state.State, instanceName string, entry *HistoryEntry) error {
+	var history map[string][]*HistoryEntry
+	err := st.Get("config-history", &history)
+	if err == state.ErrNoState {
+		history = make(map[string][]*HistoryEntry)
+	} else if err != nil {
+		return fmt.Errorf("internal error: cannot unmarshal configuration history: %v", err)
+	}
+
+	entries := history[instanceName]
+	entry.ID = 1
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+	entries = append(entries, entry)
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+	history[instanceName] = entries
+	st.Set("config-history", history)
+	return nil
+}
+
+// deleteHistory forgets the configuration history of the given snap.
+func deleteHistory(st *state.State, instanceName string) error {
+	var history map[string][]*HistoryEntry
+	err := st.Get("config-history", &history)
+	if err == state.ErrNoState {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("internal error: cannot unmarshal configuration history: %v", err)
+	}
+	if _, ok := history[instanceName]; ok {
+		delete(history, instanceName)
+		st.Set("config-history", history)
+	}
+	return nil
+}
+
+// RevertPatch returns the configuration patch that restores the
+// configuration of the snap to how it was right after the history entry
+// with the given ID was committed, by undoing the changes recorded after
+// it, newest first. Options that were not set back then are unset.
+//
+// The caller is responsible for locking the state.
+func RevertPatch(st *state.State, instanceName string, id int) (map[string]interface{}, error) {
+	history, err := History(st, instanceName)
+	if err != nil {
+		return nil, err
+	}
+	idx := -1
+	for i, e := range history {
+		if e.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, &NoHistoryEntryError{SnapName: instanceName, ID: id}
+	}
+
+	patch := make(map[string]interface{})
+	for i := len(history) - 1; i > idx; i-- {
+		for _, change := range history[i].Changes {
+			var old interface{}
+			if change.Old != nil {
+				if err := jsonutil.DecodeWithNumber(bytes.NewReader(*change.Old), &old); err != nil {
+					return nil, fmt.Errorf("internal error: cannot unmarshal snap %q option %q: %v", instanceName, change.Key, err)
+				}
+			}
+			undoKeyChange(patch, change.Key, old)
+		}
+	}
+
+	// leave out the options that already have the value to revert to
+	var current map[string]*json.RawMessage
+	raw, err := GetSnapConfig(st, instanceName)
+	if err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*raw), &current); err != nil {
+			return nil, fmt.Errorf("internal error: cannot unmarshal snap %q configuration: %v", instanceName, err)
+		}
+	}
+	for key, value := range patch {
+		if sameRaw(valueOf(instanceName, strings.Split(key, "."), current), jsonRaw(value)) {
+			delete(patch, key)
+		}
+	}
+	return patch, nil
+}
+
+// undoKeyChange sets the option in the patch to the value it had before
+// a change. Changes are undone newest first, so the older value replaces
+// whatever the patch already has for the option and its suboptions. The
+// patch never holds both an option and one of its suboptions.
+func undoKeyChange(patch map[string]interface{}, key string, value interface{}) {
+	for k := range patch {
+		if strings.HasPrefix(k, key+".") {
+			delete(patch, k)
+		}
+	}
+	subkeys := strings.Split(key, ".")
+	for i := 1; i < len(subkeys); i++ {
+		parent := strings.Join(subkeys[:i], ".")
+		if parentValue, ok := patch[parent]; ok {
+			patch[parent] = withSubvalue(parentValue, subkeys[i:], value)
+			return
+		}
+	}
+	patch[key] = value
+}
+
+// withSubvalue returns v with the value at the given subkeys set to
+// value, or removed if value is nil.
+func withSubvalue(v interface{}, subkeys []string, value interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		if value == nil {
+			return v
+		}
+		m = make(map[string]interface{})
+	}
+	if len(subkeys) == 1 {
+		if value == nil {
+			delete(m, subkeys[0])
+		} else {
+			m[subkeys[0]] = value
+		}
+		return m
+	}
+	m[subkeys[0]] = withSubvalue(m[subkeys[0]], subkeys[1:], value)
+	return m
+}
+
+func isNullRaw(raw *json.RawMessage) bool {
+	return raw == nil || string(*raw) == "null"
+}
+
+func sameRaw(a, b *json.RawMessage) bool {
+	if isNullRaw(a) || isNullRaw(b) {
+		return isNullRaw(a) && isNullRaw(b)
+	}
+	return bytes.Equal(*a, *b)
+}
+
+// keyChanges compares the values of the given keys in the old and new
+// configuration of a snap and returns the ones that actually changed.
+func keyChanges(instanceName string, keys []string, oldConfig, newConfig map[string]*json.RawMessage) []*KeyChange {
+	var out []*KeyChange
+	for _, key := range keys {
+		subkeys := strings.Split(key, ".")
+		oldValue := valueOf(instanceName, subkeys, oldConfig)
+		newValue := valueOf(instanceName, subkeys, newConfig)
+		if sameRaw(oldValue, newValue) {
+			continue
+		}
+		out = append(out, &KeyChange{Key: key, Old: oldValue, New: newValue})
+	}
+	return out
+}
+
+func valueOf(instanceName string, subkeys []string, config map[string]*json.RawMessage) *json.RawMessage {
+	var value interface{}
+	if err := getFromConfig(instanceName, subkeys, 0, config, &value); err != nil {
+		return nil
+	}
+	if value == nil {
+		return nil
+	}
+	return jsonRaw(value)
+}
+
+// setKeys returns the keys that were set in the given changes. Unlike
+// changes, it does not dive into values that are maps themselves, as
+// setting those replaces the whole option.
+func setKeys(prefix string, changes map[string]interface{}) []string {
+	var out []string
+	for k, v := range changes {
+		if subChanges, ok := v.(map[string]interface{}); ok {
+			out = append(out, setKeys(prefix+k+".", subChanges)...)
+			continue
+		}
+		out = append(out, prefix+k)
+	}
+	return out
+}
+
+// recordHistory adds a history entry for every snap whose configuration
+// was changed by the transaction.
+func (t *Transaction) recordHistory(oldPristine map[string]map[string]*json.RawMessage) error {
+	snaps := make([]string, 0, len(t.changes))
+	for instanceName := range t.changes {
+		snaps = append(snaps, instanceName)
+	}
+	sort.Strings(snaps)
+
+	now := timeNow()
+	for _, instanceName := range snaps {
+		keys := setKeys("", t.changes[instanceName])
+		sort.Strings(keys)
+
+		keyChanges := keyChanges(instanceName, keys, oldPristine[instanceName], t.pristine[instanceName])
+		if len(keyChanges) == 0 {
+			continue
+		}
+		entry := &HistoryEntry{
+			Time:     now,
+			Origin:   t.origin,
+			ChangeID: t.changeID,
+			Changes:  keyChanges,
+		}
+		if err := addHistoryEntry(t.state, instanceName, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type historySuite struct {
+	state   *state.State
+	restore func()
+	now     time.Time
+}
+
+var _ = Suite(&historySuite{})
+
+func (s *historySuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+	s.now = time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.restore = config.MockTimeNow(func() time.Time { return s.now })
+}
+
+func (s *historySuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+func (s *historySuite) set(c *C, origin, changeID string, values map[string]interface{}) {
+	tr := config.NewTransaction(s.state)
+	tr.SetOrigin(origin, changeID)
+	for k, v := range values {
+		c.Assert(tr.Set("test-snap", k, v), IsNil)
+	}
+	tr.Commit()
+}
+
+func raw(s string) *json.RawMessage {
+	r := json.RawMessage(s)
+	return &r
+}
+
+func (s *historySuite) TestHistoryRecorded(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := config.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+
+	s.set(c, "uid 0", "1", map[string]interface{}{"foo": "bar", "a.b": 1})
+	s.set(c, "configure hook", "2", map[string]interface{}{"foo": "baz", "a.b": 1})
+	// setting the same values again is not recorded
+	s.set(c, "snapctl", "", map[string]interface{}{"foo": "baz"})
+
+	history, err = config.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+
+	c.Check(history[0].ID, Equals, 1)
+	c.Check(history[0].Time.Equal(s.now), Equals, true)
+	c.Check(history[0].Origin, Equals, "uid 0")
+	c.Check(history[0].ChangeID, Equals, "1")
+	c.Check(history[0].Changes, DeepEquals, []*config.KeyChange{
+		{Key: "a.b", New: raw("1")},
+		{Key: "foo", New: raw(`"bar"`)},
+	})
+
+	c.Check(history[1].ID, Equals, 2)
+	c.Check(history[1].Origin, Equals, "configure hook")
+	c.Check(history[1].Changes, DeepEquals, []*config.KeyChange{
+		{Key: "foo", Old: raw(`"bar"`), New: raw(`"baz"`)},
+	})
+}
+
+func (s *historySuite) TestHistoryBounded(c *C) {
+	restore := config.MockMaxHistoryEntries(3)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for i := 1; i <= 5; i++ {
+		s.set(c, "", "", map[string]interface{}{"foo": i})
+	}
+
+	history, err := config.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 3)
+	c.Check(history[0].ID, Equals, 3)
+	c.Check(history[2].ID, Equals, 5)
+}
+
+func (s *historySuite) TestHistoryFailureDoesNotStopCommit(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("config-history", "garbage")
+
+	s.set(c, "", "", map[string]interface{}{"foo": "bar"})
+
+	var foo string
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Get("test-snap", "foo", &foo), IsNil)
+	c.Check(foo, Equals, "bar")
+}
+
+func (s *historySuite) TestRevertPatch(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.set(c, "", "", map[string]interface{}{"foo": "bar", "a.b": 1})
+	s.set(c, "", "", map[string]interface{}{"foo": "baz", "a.c": 2})
+	s.set(c, "", "", map[string]interface{}{"other": true})
+
+	patch, err := config.RevertPatch(s.state, "test-snap", 1)
+	c.Assert(err, IsNil)
+	c.Check(patch, DeepEquals, map[string]interface{}{
+		"foo":   "bar",
+		"a.c":   nil,
+		"other": nil,
+	})
+
+	patch, err = config.RevertPatch(s.state, "test-snap", 3)
+	c.Assert(err, IsNil)
+	c.Check(patch, HasLen, 0)
+
+	_, err = config.RevertPatch(s.state, "test-snap", 4)
+	c.Check(err, ErrorMatches, `snap "test-snap" has no configuration change 4 in its history`)
+	c.Check(err, FitsTypeOf, &config.NoHistoryEntryError{})
+}
+
+func (s *historySuite) TestRevertPatchNested(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.set(c, "", "", map[string]interface{}{"a": map[string]interface{}{"b": 1, "c": 2}})
+	s.set(c, "", "", map[string]interface{}{"a.b": 3})
+	s.set(c, "", "", map[string]interface{}{"a": map[string]interface{}{"d": 4}})
+	s.set(c, "", "", map[string]interface{}{"a.c": 5})
+
+	// setting a map replaces the whole option
+	history, err := config.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 4)
+	c.Check(history[2].Changes, DeepEquals, []*config.KeyChange{
+		{Key: "a", Old: raw(`{"b":3,"c":2}`), New: raw(`{"d":4}`)},
+	})
+
+	// undoing the change of a.c and then the one of a leaves a with
+	// the value it had before, including the change of a.b
+	patch, err := config.RevertPatch(s.state, "test-snap", 2)
+	c.Assert(err, IsNil)
+	c.Check(patch, DeepEquals, map[string]interface{}{
+		"a": map[string]interface{}{"b": json.Number("3"), "c": json.Number("2")},
+	})
+
+	patch, err = config.RevertPatch(s.state, "test-snap", 3)
+	c.Assert(err, IsNil)
+	c.Check(patch, DeepEquals, map[string]interface{}{
+		"a.c": nil,
+	})
+
+	// replaying the patch restores the configuration
+	tr := config.NewTransaction(s.state)
+	patch, err = config.RevertPatch(s.state, "test-snap", 1)
+	c.Assert(err, IsNil)
+	for k, v := range patch {
+		c.Assert(tr.Set("test-snap", k, v), IsNil)
+	}
+	var a map[string]interface{}
+	c.Assert(tr.Get("test-snap", "a", &a), IsNil)
+	c.Check(a, DeepEquals, map[string]interface{}{"b": json.Number("1"), "c": json.Number("2")})
+}
+
+func (s *historySuite) TestDeleteSnapConfigDeletesHistory(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.set(c, "", "", map[string]interface{}{"foo": "bar"})
+	c.Assert(config.DeleteSnapConfig(s.state, "test-snap"), IsNil)
+
+	history, err := config.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+}
@@ -28,6 +28,7 @@ import (
 	"sync"
 
 	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -42,6 +43,9 @@ type Transaction struct {
 	state    *state.State
 	pristine map[string]map[string]*json.RawMessage // snap => key => value
 	changes  map[string]map[string]interface{}
+	schemas  map[string]Schema
+	origin   string
+	changeID string
 }
 
 // NewTransaction creates a new configuration transaction initialized with the given state.
@@ -67,6 +71,33 @@ func (t *Transaction) State() *state.State {
 	return t.state
 }
 
+// SetSchema makes the transaction validate the values set for the given
+// snap against the provided schema. A nil schema disables validation.
+func (t *Transaction) SetSchema(instanceName string, schema Schema) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if schema == nil {
+		delete(t.schemas, instanceName)
+		return
+	}
+	if t.schemas == nil {
+		t.schemas = make(map[string]Schema)
+	}
+	t.schemas[instanceName] = schema
+}
+
+// SetOrigin sets who or what caused the changes made through the
+// transaction, and the ID of the change they are part of, for the
+// configuration history.
+func (t *Transaction) SetOrigin(origin, changeID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.origin = origin
+	t.changeID = changeID
+}
+
 func changes(cfgStr string, cfg map[string]interface{}) []string {
 	var out []string
 	for k := range cfg {
@@ -121,11 +152,17 @@ func (t *Transaction) Set(instanceName, key string, value interface{}) error {
 	}
 	raw := json.RawMessage(data)
 
-	subkeys, err := ParseKey(key)
+	subkeys, err := parseSnapKey(instanceName, key)
 	if err != nil {
 		return err
 	}
 
+	if schema := t.schemas[instanceName]; schema != nil {
+		if err := validateRaw(schema, instanceName, key, data); err != nil {
+			return err
+		}
+	}
+
 	// Check whether it's trying to traverse a non-map from pristine. This
 	// would go unperceived by the configuration patching below.
 	if len(subkeys) > 1 {
@@ -164,7 +201,7 @@ func (t *Transaction) Get(snapName, key string, result interface{}) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	subkeys, err := ParseKey(key)
+	subkeys, err := parseSnapKey(snapName, key)
 	if err != nil {
 		return err
 	}
@@ -247,18 +284,31 @@ func (t *Transaction) Commit() {
 		panic(fmt.Errorf("internal error: cannot unmarshal configuration: %v", err))
 	}
 
-	// Iterate through the write cache and save each item.
+	// Iterate through the write cache and save each item, keeping the
+	// previous configuration around for the history.
+	oldPristine := make(map[string]map[string]*json.RawMessage, len(t.changes))
 	for instanceName, snapChanges := range t.changes {
 		config, ok := t.pristine[instanceName]
 		if !ok {
 			config = make(map[string]*json.RawMessage)
 		}
+		oldConfig := make(map[string]*json.RawMessage, len(config))
+		for k, v := range config {
+			oldConfig[k] = v
+		}
+		oldPristine[instanceName] = oldConfig
 		applyChanges(config, snapChanges)
 		t.pristine[instanceName] = config
 	}
 
 	t.state.Set("config", t.pristine)
 
+	// The history is informational only, failing to record it must not
+	// stop the configuration from being committed.
+	if err := t.recordHistory(oldPristine); err != nil {
+		logger.Noticef("cannot record configuration history: %v", err)
+	}
+
 	// The cache has been flushed, reset it.
 	t.changes = make(map[string]map[string]interface{})
 }
@@ -317,3 +367,51 @@ func (e *NoOptionError) Error() string {
 	}
 	return fmt.Sprintf("snap %q has no %q configuration option", e.SnapName, e.Key)
 }
+
+// Schema is implemented by configuration schemas that can validate the
+// values set for a snap's configuration keys. The empty key refers to the
+// whole configuration document.
+type Schema interface {
+	Validate(key string, value interface{}) error
+}
+
+// ValidationError is returned when a value does not conform to the
+// configuration schema of a snap.
+type ValidationError struct {
+	SnapName string
+	Key      string
+	Err      error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("cannot set snap %q configuration: %v", e.SnapName, e.Err)
+	}
+	return fmt.Sprintf("cannot set snap %q option %q: %v", e.SnapName, e.Key, e.Err)
+}
+
+// ValidateValue checks the value for the given snap configuration key
+// against schema, after converting it to the form it would take once
+// stored in the configuration.
+func ValidateValue(schema Schema, instanceName, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cannot marshal snap %q option %q: %s", instanceName, key, err)
+	}
+	return validateRaw(schema, instanceName, key, data)
+}
+
+func validateRaw(schema Schema, instanceName, key string, data []byte) error {
+	var value interface{}
+	if err := jsonutil.DecodeWithNumber(bytes.NewReader(data), &value); err != nil {
+		return fmt.Errorf("internal error: cannot unmarshal snap %q option %q: %s", instanceName, key, err)
+	}
+	// unsetting an option is always allowed
+	if value == nil {
+		return nil
+	}
+	if err := schema.Validate(key, value); err != nil {
+		return &ValidationError{SnapName: instanceName, Key: key, Err: err}
+	}
+	return nil
+}
@@ -387,3 +387,47 @@ func (s *transactionSuite) TestPristineIsNotTainted(c *C) {
 	c.Assert(json.Unmarshal([]byte(*pristine["test-snap"]["foo"]), &data), IsNil)
 	c.Assert(data, DeepEquals, map[string]interface{}{"a": map[string]interface{}{"a": "a"}})
 }
+
+type portSchema struct{}
+
+func (portSchema) Validate(key string, value interface{}) error {
+	if key != "port" {
+		return fmt.Errorf("option %q is not declared", key)
+	}
+	if _, ok := value.(json.Number); !ok {
+		return fmt.Errorf("value must be a number")
+	}
+	return nil
+}
+
+func (s *transactionSuite) TestSetWithSchema(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.transaction.SetSchema("test-snap", portSchema{})
+
+	c.Assert(s.transaction.Set("test-snap", "port", 8080), IsNil)
+	// unsetting is always allowed
+	c.Assert(s.transaction.Set("test-snap", "port", nil), IsNil)
+
+	err := s.transaction.Set("test-snap", "port", "foo")
+	c.Assert(err, ErrorMatches, `cannot set snap "test-snap" option "port": value must be a number`)
+	verr, ok := err.(*config.ValidationError)
+	c.Assert(ok, Equals, true)
+	c.Check(verr.SnapName, Equals, "test-snap")
+	c.Check(verr.Key, Equals, "port")
+
+	c.Check(s.transaction.Set("test-snap", "other", 1), ErrorMatches, `cannot set snap "test-snap" option "other": option "other" is not declared`)
+
+	// other snaps are not affected
+	c.Check(s.transaction.Set("other-snap", "other", 1), IsNil)
+
+	// and validation can be disabled again
+	s.transaction.SetSchema("test-snap", nil)
+	c.Check(s.transaction.Set("test-snap", "other", 1), IsNil)
+}
+
+func (s *transactionSuite) TestValidateValue(c *C) {
+	c.Check(config.ValidateValue(portSchema{}, "test-snap", "port", 1), IsNil)
+	c.Check(config.ValidateValue(portSchema{}, "test-snap", "port", "1"), ErrorMatches, `cannot set snap "test-snap" option "port": value must be a number`)
+}
@@ -22,6 +22,7 @@ package configcore
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/release"
@@ -48,6 +49,22 @@ func coreCfg(tr config.Conf, key string) (result string, err error) {
 // The actual values are populated by `init()` functions in each module.
 var supportedConfigurations = make(map[string]bool, 32)
 
+// supportedConfigurationPrefixes contains prefixes of handled configuration
+// keys whose last components are dynamic, like system.kernel.sysctl.<key>.
+var supportedConfigurationPrefixes []string
+
+func isSupportedConfiguration(key string) bool {
+	if supportedConfigurations[key] {
+		return true
+	}
+	for _, prefix := range supportedConfigurationPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func validateBoolFlag(tr config.Conf, flag string) error {
 	value, err := coreCfg(tr, flag)
 	if err != nil {
@@ -65,7 +82,7 @@ func validateBoolFlag(tr config.Conf, flag string) error {
 func Run(tr config.Conf) error {
 	// check if the changes
 	for _, k := range tr.Changes() {
-		if !supportedConfigurations[k] {
+		if !isSupportedConfiguration(k) {
 			return fmt.Errorf("cannot set %q: unsupported system option", k)
 		}
 	}
@@ -91,6 +108,21 @@ func Run(tr config.Conf) error {
 	if err := validateAutomaticSnapshotsExpiration(tr); err != nil {
 		return err
 	}
+	if err := validateSnapshotsHookTimeout(tr); err != nil {
+		return err
+	}
+	if err := validateScheduledSnapshots(tr); err != nil {
+		return err
+	}
+	if err := validateSnapshotsEncryptionKeyFile(tr); err != nil {
+		return err
+	}
+	if err := validateJournalSettings(tr); err != nil {
+		return err
+	}
+	if err := validateKernelSettings(tr); err != nil {
+		return err
+	}
 	// FIXME: ensure the user cannot set "core seed.loaded"
 
 	// capture cloud information
@@ -136,6 +168,18 @@ func Run(tr config.Conf) error {
 	if err := handleNetworkConfiguration(tr); err != nil {
 		return err
 	}
+	// journal.{persistent,max-use,max-file-size,max-retention}
+	if err := handleJournalConfiguration(tr); err != nil {
+		return err
+	}
+	// system.kernel.cmdline-append
+	if err := handleKernelCmdlineConfiguration(tr); err != nil {
+		return err
+	}
+	// system.kernel.sysctl.*
+	if err := handleSysctlConfiguration(tr); err != nil {
+		return err
+	}
 
 	return nil
 }
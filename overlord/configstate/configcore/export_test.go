@@ -19,9 +19,49 @@
 
 package configcore
 
+import (
+	"syscall"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
 var (
 	UpdatePiConfig       = updatePiConfig
 	SwitchHandlePowerKey = switchHandlePowerKey
 	SwitchDisableService = switchDisableService
 	UpdateKeyValueStream = updateKeyValueStream
 )
+
+func MockOsutilFindGid(f func(string) (uint64, error)) (restore func()) {
+	old := osutilFindGid
+	osutilFindGid = f
+	return func() {
+		osutilFindGid = old
+	}
+}
+
+func MockSyscallStatfs(f func(string, *syscall.Statfs_t) error) (restore func()) {
+	old := syscallStatfs
+	syscallStatfs = f
+	return func() {
+		syscallStatfs = old
+	}
+}
+
+func MockSysChownPath(f func(string, sys.UserID, sys.GroupID) error) (restore func()) {
+	old := sysChownPath
+	sysChownPath = f
+	return func() {
+		sysChownPath = old
+	}
+}
+
+func MockSnapstateModel(f func(*state.State) (*asserts.Model, error)) (restore func()) {
+	old := snapstateModel
+	snapstateModel = f
+	return func() {
+		snapstateModel = old
+	}
+}
@@ -0,0 +1,378 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// journaldOptions maps the journal.* system options to the journald.conf
+// settings they control.
+var journaldOptions = []struct{ configName, journaldName string }{
+	{"max-use", "SystemMaxUse"},
+	{"max-file-size", "SystemMaxFileSize"},
+	{"max-retention", "MaxRetentionSec"},
+}
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.journal.persistent"] = true
+	for _, opt := range journaldOptions {
+		supportedConfigurations["core.journal."+opt.configName] = true
+	}
+}
+
+const (
+	journaldDropInName = "00-snap-core.conf"
+	// journalMarkerFile is created inside the persistent journal
+	// directory when snapd created it, so that snapd only ever
+	// removes a directory it owns.
+	journalMarkerFile = ".snapd-created"
+)
+
+var (
+	osutilFindGid = osutil.FindGid
+	sysChownPath  = sys.ChownPath
+	syscallStatfs = syscall.Statfs
+)
+
+var (
+	// journald accepts sizes with an optional base 1024 suffix
+	validJournalSize = regexp.MustCompile(`^[0-9]+[KMGTPE]?$`)
+	// and time spans as understood by systemd.time(7), a subset of
+	// the units is supported here
+	validJournalTimeSpan = regexp.MustCompile(`^[0-9]+(s|sec|m|min|h|hr|d|day|days|w|week|weeks|month|months|y|year|years)?$`)
+)
+
+func validateJournalOption(configName, value string) error {
+	switch configName {
+	case "max-retention":
+		if !validJournalTimeSpan.MatchString(value) {
+			return fmt.Errorf("cannot set journal.%s to %q: invalid time span", configName, value)
+		}
+	default:
+		if !validJournalSize.MatchString(value) {
+			return fmt.Errorf("cannot set journal.%s to %q: invalid size", configName, value)
+		}
+	}
+	return nil
+}
+
+func validateJournalSettings(tr config.Conf) error {
+	if err := validateBoolFlag(tr, "journal.persistent"); err != nil {
+		return err
+	}
+	for _, opt := range journaldOptions {
+		value, err := coreCfg(tr, "journal."+opt.configName)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			continue
+		}
+		if err := validateJournalOption(opt.configName, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func journalDir() string {
+	return filepath.Join(dirs.GlobalRootDir, "/var/log/journal")
+}
+
+// readJournaldConfig reads the [Journal] settings of the given
+// journald configuration file into settings, overriding the ones
+// already there. A missing file is not an error.
+func readJournaldConfig(path string, settings map[string]string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	inJournal := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			// empty line or comment
+		case line[0] == '[':
+			inJournal = line == "[Journal]"
+		case inJournal:
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			if value == "" {
+				// an empty value resets the setting to its default
+				delete(settings, key)
+			} else {
+				settings[key] = value
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func formatJournalSize(size uint64) string {
+	const suffixes = "KMGTPE"
+	unit := ""
+	for i := 0; i < len(suffixes) && size != 0 && size%1024 == 0; i++ {
+		size /= 1024
+		unit = suffixes[i : i+1]
+	}
+	return fmt.Sprintf("%d%s", size, unit)
+}
+
+// journaldDefaults returns the values journald uses for the options
+// that are not set: the journal uses at most 10% of its file system,
+// capped to 4G, in files of at most an eighth of that, capped to 128M,
+// and entries are not removed based on their age.
+func journaldDefaults() (map[string]string, error) {
+	dir := journalDir()
+	for !osutil.IsDirectory(dir) && dir != "/" {
+		dir = filepath.Dir(dir)
+	}
+	var st syscall.Statfs_t
+	if err := syscallStatfs(dir, &st); err != nil {
+		return nil, fmt.Errorf("cannot get the size of the journal file system: %v", err)
+	}
+	maxUse := uint64(st.Bsize) * st.Blocks / 10
+	if maxUse > 4<<30 {
+		maxUse = 4 << 30
+	}
+	maxFileSize := maxUse / 8
+	if maxFileSize > 128<<20 {
+		maxFileSize = 128 << 20
+	}
+	return map[string]string{
+		"SystemMaxUse":      formatJournalSize(maxUse),
+		"SystemMaxFileSize": formatJournalSize(maxFileSize),
+		"MaxRetentionSec":   "0",
+	}, nil
+}
+
+// journaldBaseSettings returns the journald settings in effect without
+// the drop-in managed by snapd: the ones from journald.conf and the
+// other drop-ins in /etc, or the journald defaults.
+func journaldBaseSettings() (map[string]string, error) {
+	settings, err := journaldDefaults()
+	if err != nil {
+		return nil, err
+	}
+	confDir := filepath.Join(dirs.GlobalRootDir, "/etc/systemd")
+	paths := []string{filepath.Join(confDir, "journald.conf")}
+	dropIns, err := filepath.Glob(filepath.Join(confDir, "journald.conf.d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range dropIns {
+		if filepath.Base(path) != journaldDropInName {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range paths {
+		if err := readJournaldConfig(path, settings); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// updateJournaldDropIn writes (or removes, when there is nothing to
+// configure) the journald drop-in managed by snapd. It returns whether
+// the on-disk state changed.
+func updateJournaldDropIn(settings map[string]string) (bool, error) {
+	dir := filepath.Join(dirs.GlobalRootDir, "/etc/systemd/journald.conf.d")
+	dirContent := make(map[string]*osutil.FileState, 1)
+
+	lines := make([]string, 0, len(settings))
+	for k, v := range settings {
+		lines = append(lines, fmt.Sprintf("%s=%s\n", k, v))
+	}
+	if len(lines) > 0 {
+		// We order the variables to have predictable output
+		sort.Strings(lines)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return false, err
+		}
+		dirContent[journaldDropInName] = &osutil.FileState{
+			Content: []byte("[Journal]\n" + strings.Join(lines, "")),
+			Mode:    0644,
+		}
+	}
+
+	changed, removed, err := osutil.EnsureDirState(dir, journaldDropInName, dirContent)
+	if err != nil {
+		return false, err
+	}
+	return len(changed) > 0 || len(removed) > 0, nil
+}
+
+// setupPersistentJournal creates or removes the persistent journal
+// directory. It returns whether the on-disk state changed.
+func setupPersistentJournal(persistent bool) (bool, error) {
+	logDir := journalDir()
+	marker := filepath.Join(logDir, journalMarkerFile)
+
+	if !persistent {
+		if !osutil.FileExists(marker) {
+			if osutil.IsDirectory(logDir) {
+				logger.Noticef("not removing persistent journal directory %q not created by snapd", logDir)
+			}
+			return false, nil
+		}
+		if err := os.RemoveAll(logDir); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if osutil.IsDirectory(logDir) {
+		// already persistent (maybe set up by someone else)
+		return false, nil
+	}
+
+	gid, err := osutilFindGid("systemd-journal")
+	if err != nil {
+		return false, fmt.Errorf("cannot find systemd-journal group: %v", err)
+	}
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return false, err
+	}
+	// journald expects the directory to be owned by root:systemd-journal
+	// with the setgid bit set so that new journal files inherit the group
+	if err := sysChownPath(logDir, sys.UserID(0), sys.GroupID(gid)); err != nil {
+		os.RemoveAll(logDir)
+		return false, err
+	}
+	if err := os.Chmod(logDir, 0755|os.ModeSetgid); err != nil {
+		os.RemoveAll(logDir)
+		return false, err
+	}
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		os.RemoveAll(logDir)
+		return false, err
+	}
+	return true, nil
+}
+
+func handleJournalConfiguration(tr config.Conf) error {
+	base, err := journaldBaseSettings()
+	if err != nil {
+		return err
+	}
+
+	// the drop-in only carries the settings that journald would not
+	// use anyway, the others are reported back below
+	values := make(map[string]string, len(journaldOptions))
+	settings := make(map[string]string, len(journaldOptions))
+	for _, opt := range journaldOptions {
+		value, err := coreCfg(tr, "journal."+opt.configName)
+		if err != nil {
+			return err
+		}
+		values[opt.configName] = value
+		if value != "" && value != base[opt.journaldName] {
+			settings[opt.journaldName] = value
+		}
+	}
+
+	persistentOpt, err := coreCfg(tr, "journal.persistent")
+	if err != nil {
+		return err
+	}
+
+	dropInChanged, err := updateJournaldDropIn(settings)
+	if err != nil {
+		return err
+	}
+
+	dirChanged := false
+	if persistentOpt != "" {
+		dirChanged, err = setupPersistentJournal(persistentOpt == "true")
+		if err != nil {
+			return err
+		}
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &sysdLogger{})
+	switch {
+	case dropInChanged || (dirChanged && persistentOpt != "true"):
+		// journald only reads its configuration on startup and keeps
+		// writing to removed files until restarted; journald is
+		// socket activated so a restart does not lose messages
+		if err := sysd.Restart("systemd-journald", 30*time.Second); err != nil {
+			return err
+		}
+	case dirChanged:
+		// ask journald to flush the runtime journal to the new
+		// persistent storage
+		if err := sysd.Kill("systemd-journald", "USR1", ""); err != nil {
+			return err
+		}
+	}
+
+	// report the effective settings through the configuration
+	if persistentOpt == "" {
+		persistent := osutil.IsDirectory(journalDir())
+		if err := tr.Set("core", "journal.persistent", persistent); err != nil {
+			return err
+		}
+	}
+	for _, opt := range journaldOptions {
+		if values[opt.configName] != "" {
+			continue
+		}
+		value := base[opt.journaldName]
+		if err := validateJournalOption(opt.configName, value); err != nil {
+			// not something that could be set back, leave it unset
+			logger.Noticef("not reporting journald setting %s=%s: %v", opt.journaldName, value, err)
+			continue
+		}
+		if err := tr.Set("core", "journal."+opt.configName, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
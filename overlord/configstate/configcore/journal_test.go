@@ -0,0 +1,233 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type journalSuite struct {
+	configcoreSuite
+
+	journalDir    string
+	journalDropIn string
+	chowns        [][]interface{}
+	restores      []func()
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	s.systemctlArgs = nil
+	s.chowns = nil
+
+	s.restores = append(s.restores, release.MockOnClassic(false))
+	s.restores = append(s.restores, configcore.MockOsutilFindGid(func(group string) (uint64, error) {
+		c.Check(group, Equals, "systemd-journal")
+		return 1234, nil
+	}))
+	s.restores = append(s.restores, configcore.MockSysChownPath(func(path string, uid sys.UserID, gid sys.GroupID) error {
+		s.chowns = append(s.chowns, []interface{}{path, uid, gid})
+		return nil
+	}))
+	// a 5G file system
+	s.restores = append(s.restores, configcore.MockSyscallStatfs(func(path string, st *syscall.Statfs_t) error {
+		st.Bsize = 4096
+		st.Blocks = 5 * 1024 * 1024 / 4
+		return nil
+	}))
+
+	s.journalDir = filepath.Join(dirs.GlobalRootDir, "/var/log/journal")
+	s.journalDropIn = filepath.Join(dirs.GlobalRootDir, "/etc/systemd/journald.conf.d/00-snap-core.conf")
+}
+
+func (s *journalSuite) TearDownTest(c *C) {
+	for _, f := range s.restores {
+		f()
+	}
+	s.restores = nil
+	s.configcoreSuite.TearDownTest(c)
+}
+
+func (s *journalSuite) TestConfigurePersistentJournal(c *C) {
+	conf := &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"journal.persistent": true,
+		},
+	}
+	err := configcore.Run(conf)
+	c.Assert(err, IsNil)
+
+	st, err := os.Stat(s.journalDir)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode(), Equals, os.ModeDir|os.ModeSetgid|0755)
+	c.Check(filepath.Join(s.journalDir, ".snapd-created"), testutil.FilePresent)
+	c.Check(s.chowns, DeepEquals, [][]interface{}{
+		{s.journalDir, sys.UserID(0), sys.GroupID(1234)},
+	})
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"kill", "systemd-journald", "-s", "USR1", "--kill-who=all"},
+	})
+	// no size limits means no drop-in
+	c.Check(s.journalDropIn, testutil.FileAbsent)
+
+	// running again changes nothing
+	s.systemctlArgs = nil
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+
+	// now disable it again
+	conf.conf["journal.persistent"] = false
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(osutil.IsDirectory(s.journalDir), Equals, false)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"stop", "systemd-journald"},
+		{"show", "--property=ActiveState", "systemd-journald"},
+		{"start", "systemd-journald"},
+	})
+}
+
+func (s *journalSuite) TestConfigureNonPersistentKeepsForeignJournal(c *C) {
+	c.Assert(os.MkdirAll(s.journalDir, 0755), IsNil)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"journal.persistent": false,
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// the directory was not created by snapd so it is left alone
+	c.Check(osutil.IsDirectory(s.journalDir), Equals, true)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *journalSuite) TestConfigureJournalLimits(c *C) {
+	conf := &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"journal.max-use":       "100M",
+			"journal.max-file-size": "10M",
+			"journal.max-retention": "2weeks",
+		},
+	}
+	err := configcore.Run(conf)
+	c.Assert(err, IsNil)
+
+	c.Check(s.journalDropIn, testutil.FileEquals, "[Journal]\nMaxRetentionSec=2weeks\nSystemMaxFileSize=10M\nSystemMaxUse=100M\n")
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"stop", "systemd-journald"},
+		{"show", "--property=ActiveState", "systemd-journald"},
+		{"start", "systemd-journald"},
+	})
+	// the effective persistence is reported
+	c.Check(conf.conf["journal.persistent"], Equals, false)
+
+	// unsetting the limits removes the drop-in
+	conf.conf = nil
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.journalDropIn, testutil.FileAbsent)
+	// and reports the journald defaults
+	c.Check(conf.conf["journal.max-use"], Equals, "512M")
+	c.Check(conf.conf["journal.max-file-size"], Equals, "64M")
+	c.Check(conf.conf["journal.max-retention"], Equals, "0")
+
+	// which are not written to the drop-in when running again
+	s.systemctlArgs = nil
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.journalDropIn, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *journalSuite) TestConfigureJournalReportsJournaldConfig(c *C) {
+	confDir := filepath.Join(dirs.GlobalRootDir, "/etc/systemd")
+	c.Assert(os.MkdirAll(filepath.Join(confDir, "journald.conf.d"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(confDir, "journald.conf"), []byte(`[Journal]
+#SystemMaxUse=
+SystemMaxUse=1G
+MaxRetentionSec=1week
+`), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(confDir, "journald.conf.d/10-local.conf"), []byte(`[Journal]
+MaxRetentionSec=1month
+SystemMaxFileSize=1.5G
+`), 0644), IsNil)
+
+	conf := &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"journal.max-use": "1G",
+		},
+	}
+	err := configcore.Run(conf)
+	c.Assert(err, IsNil)
+
+	// journald uses the requested value already
+	c.Check(s.journalDropIn, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, HasLen, 0)
+	// the drop-ins override journald.conf
+	c.Check(conf.conf["journal.max-retention"], Equals, "1month")
+	// values that cannot be set back are not reported
+	c.Check(conf.conf["journal.max-file-size"], IsNil)
+
+	// a different value goes to the drop-in
+	conf.conf["journal.max-retention"] = "2weeks"
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.journalDropIn, testutil.FileEquals, "[Journal]\nMaxRetentionSec=2weeks\n")
+}
+
+func (s *journalSuite) TestConfigureJournalInvalid(c *C) {
+	for _, t := range []struct {
+		key, value, err string
+	}{
+		{"journal.persistent", "maybe", `journal.persistent can only be set to 'true' or 'false'`},
+		{"journal.max-use", "lots", `cannot set journal.max-use to "lots": invalid size`},
+		{"journal.max-file-size", "10MB", `cannot set journal.max-file-size to "10MB": invalid size`},
+		{"journal.max-retention", "forever", `cannot set journal.max-retention to "forever": invalid time span`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
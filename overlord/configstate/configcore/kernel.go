@@ -0,0 +1,282 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+const (
+	kernelCmdlineAppendKey = "system.kernel.cmdline-append"
+	kernelSysctlKey        = "system.kernel.sysctl"
+
+	// extraCmdlineBootVar is the bootloader environment variable that
+	// holds the extra kernel command line arguments set through
+	// system.kernel.cmdline-append. The boot configuration of the
+	// gadget is expected to append it to the kernel command line.
+	extraCmdlineBootVar = "snapd_extra_cmdline_args"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core."+kernelCmdlineAppendKey] = true
+	// system.kernel.sysctl.<key> options are dynamic, and named after
+	// the sysctl keys as they are, which the config package allows for
+	// the core snap only
+	supportedConfigurationPrefixes = append(supportedConfigurationPrefixes, "core."+kernelSysctlKey+".")
+}
+
+// defaultAllowedSysctls is the list of sysctl keys that can be set
+// through system.kernel.sysctl.<key> on any device; the model can
+// allow further keys via its allowed-sysctls header.
+var defaultAllowedSysctls = []string{
+	"fs.inotify.max_user_instances",
+	"fs.inotify.max_user_watches",
+	"kernel.printk",
+	"net.core.rmem_max",
+	"net.core.somaxconn",
+	"net.core.wmem_max",
+	"net.ipv4.ip_forward",
+	"net.ipv4.tcp_keepalive_time",
+	"vm.dirty_background_ratio",
+	"vm.dirty_ratio",
+	"vm.swappiness",
+}
+
+var (
+	validCmdlineArg = regexp.MustCompile(`^[a-zA-Z0-9_.-]+(=[a-zA-Z0-9_.,:/@+=-]*)?$`)
+	// arguments that are controlled by snapd or the boot setup and
+	// cannot be overridden
+	forbiddenCmdlineArgs = []string{"init", "root", "rootfstype", "ro", "rw"}
+)
+
+func validateCmdlineAppend(cmdline string) error {
+	for _, arg := range strings.Fields(cmdline) {
+		if !validCmdlineArg.MatchString(arg) {
+			return fmt.Errorf("cannot use kernel command line argument %q: invalid characters", arg)
+		}
+		name := strings.SplitN(arg, "=", 2)[0]
+		if strings.HasPrefix(name, "snap_") || strutil.ListContains(forbiddenCmdlineArgs, name) {
+			return fmt.Errorf("cannot use kernel command line argument %q: reserved argument", arg)
+		}
+	}
+	return nil
+}
+
+// sysctlsFromConfig returns the system.kernel.sysctl.* options as a map
+// of sysctl keys to values.
+func sysctlsFromConfig(tr config.Conf) (map[string]string, error) {
+	var sysctlCfg map[string]interface{}
+	if err := tr.Get("core", kernelSysctlKey, &sysctlCfg); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	sysctls := make(map[string]string)
+	flattenSysctls("", sysctlCfg, sysctls)
+	return sysctls, nil
+}
+
+func flattenSysctls(prefix string, cfg map[string]interface{}, out map[string]string) {
+	for k, v := range cfg {
+		key := prefix + k
+		switch v := v.(type) {
+		case map[string]interface{}:
+			flattenSysctls(key+".", v, out)
+		case nil:
+			// unset
+		default:
+			out[key] = fmt.Sprintf("%v", v)
+		}
+	}
+}
+
+func sysctlAllowed(key string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if strings.HasSuffix(pattern, ".*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			continue
+		}
+		if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// the model is looked up through snapstate, where devicestate hooks it
+var snapstateModel = func(st *state.State) (*asserts.Model, error) {
+	if snapstate.Model == nil {
+		return nil, state.ErrNoState
+	}
+	return snapstate.Model(st)
+}
+
+func allowedSysctls(st *state.State) ([]string, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	allowed := defaultAllowedSysctls
+	model, err := snapstateModel(st)
+	if err == state.ErrNoState {
+		return allowed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(allowed, model.AllowedSysctls()...), nil
+}
+
+func validateSysctlValue(key, value string) error {
+	if value == "" {
+		return fmt.Errorf("cannot set sysctl %q: empty value", key)
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("cannot set sysctl %q: invalid value %q", key, value)
+		}
+	}
+	return nil
+}
+
+func validateKernelSettings(tr config.Conf) error {
+	cmdline, err := coreCfg(tr, kernelCmdlineAppendKey)
+	if err != nil {
+		return err
+	}
+	if err := validateCmdlineAppend(cmdline); err != nil {
+		return err
+	}
+
+	sysctls, err := sysctlsFromConfig(tr)
+	if err != nil {
+		return err
+	}
+	if len(sysctls) == 0 {
+		return nil
+	}
+	allowed, err := allowedSysctls(tr.State())
+	if err != nil {
+		return err
+	}
+	for key, value := range sysctls {
+		if !sysctlAllowed(key, allowed) {
+			return fmt.Errorf("cannot set sysctl %q: not allowed on this device", key)
+		}
+		if err := validateSysctlValue(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handleKernelCmdlineConfiguration(tr config.Conf) error {
+	// the bootloader environment is only touched when the option
+	// actually changes
+	if !strutil.ListContains(tr.Changes(), "core."+kernelCmdlineAppendKey) {
+		return nil
+	}
+
+	cmdline, err := coreCfg(tr, kernelCmdlineAppendKey)
+	if err != nil {
+		return err
+	}
+	cmdline = strings.Join(strings.Fields(cmdline), " ")
+
+	loader, err := bootloader.Find()
+	if err != nil {
+		return fmt.Errorf("cannot set kernel command line: %v", err)
+	}
+	current, err := loader.GetBootVars(extraCmdlineBootVar)
+	if err != nil {
+		return err
+	}
+	if current[extraCmdlineBootVar] == cmdline {
+		return nil
+	}
+	if err := loader.SetBootVars(map[string]string{extraCmdlineBootVar: cmdline}); err != nil {
+		return err
+	}
+
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+	st.Warnf("kernel command line changed to %q, reboot required to take effect", cmdline)
+	return nil
+}
+
+func handleSysctlConfiguration(tr config.Conf) error {
+	sysctls, err := sysctlsFromConfig(tr)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(dirs.GlobalRootDir, "/etc/sysctl.d")
+	name := "10-snapd-sysctl.conf"
+	dirContent := make(map[string]*osutil.FileState, 1)
+
+	if len(sysctls) > 0 {
+		lines := make([]string, 0, len(sysctls))
+		for k, v := range sysctls {
+			lines = append(lines, fmt.Sprintf("%s=%s\n", k, v))
+		}
+		// We order the variables to have predictable output
+		sort.Strings(lines)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		dirContent[name] = &osutil.FileState{
+			Content: []byte(strings.Join(lines, "")),
+			Mode:    0644,
+		}
+	}
+
+	changed, _, err := osutil.EnsureDirState(dir, name, dirContent)
+	if err != nil {
+		return err
+	}
+
+	// load the new config into the kernel; removed keys keep their
+	// current value until the next reboot
+	if len(changed) > 0 {
+		output, err := exec.Command("sysctl", "-p", filepath.Join(dir, name)).CombinedOutput()
+		if err != nil {
+			return osutil.OutputErr(output, err)
+		}
+	}
+
+	return nil
+}
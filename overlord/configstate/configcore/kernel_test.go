@@ -0,0 +1,285 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/boot/boottest"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type kernelSuite struct {
+	configcoreSuite
+
+	bootloader *boottest.MockBootloader
+	mockSysctl *testutil.MockCmd
+	sysctlConf string
+	model      *asserts.Model
+	restores   []func()
+}
+
+var _ = Suite(&kernelSuite{})
+
+func (s *kernelSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	s.restores = append(s.restores, release.MockOnClassic(false))
+
+	s.bootloader = boottest.NewMockBootloader("mock", c.MkDir())
+	bootloader.Force(s.bootloader)
+	s.restores = append(s.restores, func() { bootloader.Force(nil) })
+
+	s.mockSysctl = testutil.MockCommand(c, "sysctl", "")
+	s.restores = append(s.restores, s.mockSysctl.Restore)
+	s.sysctlConf = filepath.Join(dirs.GlobalRootDir, "/etc/sysctl.d/10-snapd-sysctl.conf")
+
+	s.model = nil
+	s.restores = append(s.restores, configcore.MockSnapstateModel(func(*state.State) (*asserts.Model, error) {
+		if s.model == nil {
+			return nil, state.ErrNoState
+		}
+		return s.model, nil
+	}))
+}
+
+func (s *kernelSuite) TearDownTest(c *C) {
+	for _, f := range s.restores {
+		f()
+	}
+	s.restores = nil
+	s.configcoreSuite.TearDownTest(c)
+}
+
+func (s *kernelSuite) TestConfigureCmdlineAppend(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "  quiet  console=ttyS0,115200 ",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snapd_extra_cmdline_args": "quiet console=ttyS0,115200",
+	})
+
+	s.state.Lock()
+	warnings := s.state.AllWarnings()
+	s.state.Unlock()
+	c.Assert(warnings, HasLen, 1)
+	c.Check(warnings[0].String(), Equals, `kernel command line changed to "quiet console=ttyS0,115200", reboot required to take effect`)
+
+	// unsetting clears the variable
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snapd_extra_cmdline_args": "",
+	})
+}
+
+func (s *kernelSuite) TestConfigureCmdlineAppendUnchangedNoWarning(c *C) {
+	s.bootloader.BootVars["snapd_extra_cmdline_args"] = "quiet"
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+		},
+	})
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+}
+
+func (s *kernelSuite) TestConfigureCmdlineAppendInvalid(c *C) {
+	for _, t := range []struct {
+		cmdline, err string
+	}{
+		{`foo="bar baz"`, `cannot use kernel command line argument "foo=\\"bar": invalid characters`},
+		{`foo;reboot`, `cannot use kernel command line argument "foo;reboot": invalid characters`},
+		{`init=/bin/sh`, `cannot use kernel command line argument "init=/bin/sh": reserved argument`},
+		{`quiet snap_core=core_1.snap`, `cannot use kernel command line argument "snap_core=core_1.snap": reserved argument`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"system.kernel.cmdline-append": t.cmdline,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(s.bootloader.BootVars, HasLen, 0)
+}
+
+func (s *kernelSuite) TestConfigureSysctl(c *C) {
+	conf := &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.sysctl": map[string]interface{}{
+				"vm": map[string]interface{}{
+					"swappiness":  10,
+					"dirty_ratio": "20",
+				},
+			},
+		},
+		changes: map[string]interface{}{
+			"system.kernel.sysctl.vm.swappiness": 10,
+		},
+	}
+	err := configcore.Run(conf)
+	c.Assert(err, IsNil)
+
+	c.Check(s.sysctlConf, testutil.FileEquals, "vm.dirty_ratio=20\nvm.swappiness=10\n")
+	c.Check(s.mockSysctl.Calls(), DeepEquals, [][]string{
+		{"sysctl", "-p", s.sysctlConf},
+	})
+	s.mockSysctl.ForgetCalls()
+
+	// unchanged configuration is not applied again
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.mockSysctl.Calls(), HasLen, 0)
+
+	// removing all the settings removes the file
+	conf.conf = nil
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.sysctlConf, testutil.FileAbsent)
+}
+
+func (s *kernelSuite) TestConfigureSysctlNotAllowed(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.sysctl": map[string]interface{}{
+				"kernel": map[string]interface{}{
+					"sysrq": 1,
+				},
+			},
+		},
+	})
+	c.Check(err, ErrorMatches, `cannot set sysctl "kernel.sysrq": not allowed on this device`)
+	c.Check(s.sysctlConf, testutil.FileAbsent)
+	c.Check(s.mockSysctl.Calls(), HasLen, 0)
+}
+
+func (s *kernelSuite) mockModel(c *C, allowedSysctls ...interface{}) {
+	privKey, _ := assertstest.GenerateKey(752)
+	brandSigning := assertstest.NewSigningDB("my-brand", privKey)
+	a, err := brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":          "16",
+		"brand-id":        "my-brand",
+		"model":           "my-model",
+		"architecture":    "amd64",
+		"gadget":          "pc",
+		"kernel":          "pc-kernel",
+		"allowed-sysctls": allowedSysctls,
+		"timestamp":       time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.model = a.(*asserts.Model)
+}
+
+func (s *kernelSuite) TestConfigureSysctlAllowedByModel(c *C) {
+	s.mockModel(c, "kernel.sysrq", "net.ipv6.*")
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.sysctl": map[string]interface{}{
+				"kernel": map[string]interface{}{
+					"sysrq": 1,
+				},
+				"net": map[string]interface{}{
+					"ipv6": map[string]interface{}{
+						"conf": map[string]interface{}{
+							"all": map[string]interface{}{
+								"forwarding": 1,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.sysctlConf, testutil.FileEquals, "kernel.sysrq=1\nnet.ipv6.conf.all.forwarding=1\n")
+}
+
+func (s *kernelSuite) TestConfigureSysctlKeepsDashes(c *C) {
+	s.mockModel(c, "net.ipv4.conf.*")
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.sysctl": map[string]interface{}{
+				"net": map[string]interface{}{
+					"ipv4": map[string]interface{}{
+						"conf": map[string]interface{}{
+							"br-lan": map[string]interface{}{
+								"forwarding": 1,
+							},
+							"eth_0": map[string]interface{}{
+								"forwarding": 0,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.sysctlConf, testutil.FileEquals, "net.ipv4.conf.br-lan.forwarding=1\nnet.ipv4.conf.eth_0.forwarding=0\n")
+}
+
+func (s *kernelSuite) TestConfigureSysctlOptionNames(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Check(tr.Set("core", "system.kernel.sysctl.vm.dirty_ratio", "20"), IsNil)
+	c.Check(tr.Set("core", "system.kernel.sysctl.net.ipv4.conf.br-lan.forwarding", "1"), IsNil)
+}
+
+func (s *kernelSuite) TestConfigureSysctlUnsupportedOptionNameRejected(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.foo": "bar",
+		},
+	})
+	c.Check(err, ErrorMatches, `cannot set "core.system.kernel.foo": unsupported system option`)
+}
@@ -21,14 +21,26 @@ package configcore
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	supportedConfigurations["core.snapshots.hook-timeout"] = true
+	supportedConfigurations["core.snapshots.schedule"] = true
+	supportedConfigurations["core.snapshots.scheduled.retain"] = true
+	supportedConfigurations["core.snapshots.scheduled.retention"] = true
+	supportedConfigurations["core.snapshots.scheduled.include"] = true
+	supportedConfigurations["core.snapshots.scheduled.exclude"] = true
+	supportedConfigurations["core.snapshots.encryption.key-file"] = true
 }
 
 func validateAutomaticSnapshotsExpiration(tr config.Conf) error {
@@ -47,3 +59,85 @@ func validateAutomaticSnapshotsExpiration(tr config.Conf) error {
 	}
 	return nil
 }
+
+func validateSnapshotsHookTimeout(tr config.Conf) error {
+	timeoutStr, err := coreCfg(tr, "snapshots.hook-timeout")
+	if err != nil {
+		return err
+	}
+	if timeoutStr != "" {
+		dur, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("snapshots.hook-timeout cannot be parsed: %v", err)
+		}
+		if dur <= 0 {
+			return fmt.Errorf("snapshots.hook-timeout must be a positive duration")
+		}
+	}
+	return nil
+}
+
+func validateScheduledSnapshots(tr config.Conf) error {
+	scheduleStr, err := coreCfg(tr, "snapshots.schedule")
+	if err != nil {
+		return err
+	}
+	if scheduleStr != "" {
+		if _, err := timeutil.ParseSchedule(scheduleStr); err != nil {
+			return fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+		}
+	}
+
+	retainStr, err := coreCfg(tr, "snapshots.scheduled.retain")
+	if err != nil {
+		return err
+	}
+	if retainStr != "" {
+		if n, err := strconv.ParseUint(retainStr, 10, 8); err != nil || n < 1 || n > 100 {
+			return fmt.Errorf("snapshots.scheduled.retain must be a number between 1 and 100, not %q", retainStr)
+		}
+	}
+
+	retentionStr, err := coreCfg(tr, "snapshots.scheduled.retention")
+	if err != nil {
+		return err
+	}
+	if retentionStr != "" && retentionStr != "no" {
+		dur, err := time.ParseDuration(retentionStr)
+		if err != nil {
+			return fmt.Errorf("snapshots.scheduled.retention cannot be parsed: %v", err)
+		}
+		if dur < time.Hour*24 {
+			return fmt.Errorf("snapshots.scheduled.retention must be a value greater than 24 hours, or \"no\" to disable")
+		}
+	}
+
+	for _, key := range []string{"snapshots.scheduled.include", "snapshots.scheduled.exclude"} {
+		namesStr, err := coreCfg(tr, key)
+		if err != nil {
+			return err
+		}
+		for _, name := range strings.Split(namesStr, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if err := snap.ValidateInstanceName(name); err != nil {
+				return fmt.Errorf("%s has an invalid snap name: %v", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateSnapshotsEncryptionKeyFile(tr config.Conf) error {
+	keyFile, err := coreCfg(tr, "snapshots.encryption.key-file")
+	if err != nil {
+		return err
+	}
+	if keyFile != "" && !filepath.IsAbs(keyFile) {
+		return fmt.Errorf("snapshots.encryption.key-file must be an absolute path, not %q", keyFile)
+	}
+	return nil
+}
@@ -70,3 +70,91 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureSnapshotsHookTimeout(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.hook-timeout": "2m",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsHookTimeoutInvalid(c *C) {
+	for _, t := range []struct {
+		timeout string
+		err     string
+	}{
+		{"invalid", `snapshots.hook-timeout cannot be parsed:.*`},
+		{"0s", `snapshots.hook-timeout must be a positive duration`},
+		{"-1m", `snapshots.hook-timeout must be a positive duration`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"snapshots.hook-timeout": t.timeout,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.schedule":            "mon,02:00",
+			"snapshots.scheduled.retain":    "5",
+			"snapshots.scheduled.retention": "720h",
+			"snapshots.scheduled.include":   "foo, bar_1",
+			"snapshots.scheduled.exclude":   "baz",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsInvalid(c *C) {
+	for _, t := range []struct {
+		key   string
+		value string
+		err   string
+	}{
+		{"snapshots.schedule", "invalid", `snapshots.schedule cannot be parsed: .*`},
+		{"snapshots.scheduled.retain", "0", `snapshots.scheduled.retain must be a number between 1 and 100, not "0"`},
+		{"snapshots.scheduled.retain", "101", `snapshots.scheduled.retain must be a number between 1 and 100, not "101"`},
+		{"snapshots.scheduled.retain", "many", `snapshots.scheduled.retain must be a number between 1 and 100, not "many"`},
+		{"snapshots.scheduled.retention", "invalid", `snapshots.scheduled.retention cannot be parsed:.*`},
+		{"snapshots.scheduled.retention", "1h", `snapshots.scheduled.retention must be a value greater than 24 hours, or "no" to disable`},
+		{"snapshots.scheduled.include", "foo,Bar", `snapshots.scheduled.include has an invalid snap name: invalid snap name: "Bar"`},
+		{"snapshots.scheduled.exclude", "-foo", `snapshots.scheduled.exclude has an invalid snap name: invalid snap name: "-foo"`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.key, t.value))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionKeyFile(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.encryption.key-file": "/root/snapshots.key",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionKeyFileRelative(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.encryption.key-file": "snapshots.key",
+		},
+	})
+	c.Assert(err, ErrorMatches, `snapshots.encryption.key-file must be an absolute path, not "snapshots.key"`)
+}
@@ -24,9 +24,11 @@ package configstate
 import (
 	"fmt"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -88,11 +90,35 @@ func ConfigureInstalled(st *state.State, snapName string, patch map[string]inter
 	if err := canConfigure(st, snapName); err != nil {
 		return nil, err
 	}
+	if err := validatePatch(st, snapName, patch); err != nil {
+		return nil, err
+	}
 
 	taskset := Configure(st, snapName, patch, flags)
 	return taskset, nil
 }
 
+// validatePatch checks the configuration patch against the config-schema
+// of the snap, if it declares one, so that invalid values are refused
+// before any change is created.
+func validatePatch(st *state.State, snapName string, patch map[string]interface{}) error {
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil || len(info.ConfigSchema) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := config.ValidateValue(info.ConfigSchema, snapName, key, patch[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Configure returns a taskset to apply the given configuration patch.
 func Configure(st *state.State, snapName string, patch map[string]interface{}, flags int) *state.TaskSet {
 	summary := fmt.Sprintf(i18n.G("Run configure hook of %q snap"), snapName)
@@ -24,6 +24,7 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
@@ -31,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
 )
 
 type tasksetsSuite struct {
@@ -179,6 +181,41 @@ func (s *tasksetsSuite) TestConfigureNotInstalled(c *C) {
 	c.Check(err, IsNil)
 }
 
+func (s *tasksetsSuite) TestConfigureInstalledValidatesSchema(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+	restore := snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: test-snap
+version: 1
+config-schema:
+  port:
+    type: integer
+    maximum: 65535
+`, si)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+
+	_, err := configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"port": 80}, 0)
+	c.Check(err, IsNil)
+
+	_, err = configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"port": 70000}, 0)
+	c.Check(err, ErrorMatches, `cannot set snap "test-snap" option "port": value must be at most 65535`)
+	c.Check(err, FitsTypeOf, &config.ValidationError{})
+
+	_, err = configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"foo": "bar"}, 0)
+	c.Check(err, ErrorMatches, `cannot set snap "test-snap" option "foo": option "foo" is not declared`)
+}
+
 func (s *tasksetsSuite) TestConfigureDenyBases(c *C) {
 	patch := map[string]interface{}{"foo": "bar"}
 	s.state.Lock()
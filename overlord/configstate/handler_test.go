@@ -28,6 +28,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -108,6 +109,120 @@ func (s *configureHandlerSuite) TestBeforeInitializesTransaction(c *C) {
 	c.Check(value, Equals, "bar")
 }
 
+func (s *configureHandlerSuite) TestTransactionRecordsOrigin(c *C) {
+	s.state.Lock()
+	task := s.state.NewTask("run-hook", "")
+	chg := s.state.NewChange("configure-snap", "")
+	chg.AddTask(task)
+	chg.Set("config-origin", "uid 1000")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	s.state.Unlock()
+
+	context, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+	tr := configstate.ContextTransaction(context)
+	c.Assert(tr.Set("test-snap", "foo", "bar"), IsNil)
+	c.Assert(context.Done(), IsNil)
+
+	history, err := config.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Check(history[0].Origin, Equals, "uid 1000")
+	c.Check(history[0].ChangeID, Equals, chg.ID())
+}
+
+func (s *configureHandlerSuite) TestTransactionOriginHook(c *C) {
+	s.context.Lock()
+	defer s.context.Unlock()
+	tr := configstate.ContextTransaction(s.context)
+	c.Assert(tr.Set("test-snap", "foo", "bar"), IsNil)
+	c.Assert(s.context.Done(), IsNil)
+
+	history, err := config.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Check(history[0].Origin, Equals, "test-hook hook")
+}
+
+func (s *configureHandlerSuite) mockSnapWithSchema(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: test-snap
+version: 1
+config-schema:
+  port:
+    type: integer
+    default: 8080
+  mode:
+    type: string
+    enum: [fast, slow]
+    default: fast
+`, si)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+}
+
+func (s *configureHandlerSuite) TestBeforeAppliesSchemaDefaults(c *C) {
+	s.mockSnapWithSchema(c)
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"mode": "slow",
+	})
+	s.context.Unlock()
+
+	c.Check(s.handler.Before(), IsNil)
+
+	s.context.Lock()
+	tr := configstate.ContextTransaction(s.context)
+	s.context.Unlock()
+
+	var mode string
+	c.Check(tr.Get("test-snap", "mode", &mode), IsNil)
+	c.Check(mode, Equals, "slow")
+	var port int
+	c.Check(tr.Get("test-snap", "port", &port), IsNil)
+	c.Check(port, Equals, 8080)
+}
+
+func (s *configureHandlerSuite) TestBeforeValidatesSchema(c *C) {
+	s.mockSnapWithSchema(c)
+
+	s.context.Lock()
+	s.context.Set("patch", map[string]interface{}{
+		"mode": "medium",
+	})
+	s.context.Unlock()
+
+	c.Check(s.handler.Before(), ErrorMatches, `cannot set snap "test-snap" option "mode": value must be one of "fast", "slow"`)
+}
+
+func (s *configureHandlerSuite) TestHookCanSetUndeclaredOptions(c *C) {
+	s.mockSnapWithSchema(c)
+
+	s.context.Lock()
+	tr := configstate.ContextTransaction(s.context)
+	s.context.Unlock()
+
+	// what snapctl set does from the snap's hooks
+	c.Check(tr.Set("test-snap", "internal.last-run", "yesterday"), IsNil)
+	c.Check(tr.Set("test-snap", "port", 8000), IsNil)
+	c.Check(tr.Set("test-snap", "mode", "medium"), ErrorMatches, `cannot set snap "test-snap" option "mode": value must be one of "fast", "slow"`)
+
+	var lastRun string
+	c.Check(tr.Get("test-snap", "internal.last-run", &lastRun), IsNil)
+	c.Check(lastRun, Equals, "yesterday")
+}
+
 func (s *configureHandlerSuite) TestBeforeInitializesTransactionUseDefaults(c *C) {
 	r := release.MockOnClassic(false)
 	defer r()
@@ -21,6 +21,7 @@ package configstate
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -49,6 +50,15 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 	// It wasn't already cached, so create and cache a new one
 	tr = config.NewTransaction(context.State())
 
+	tr.SetOrigin(transactionOrigin(context))
+
+	// validate the configuration of snaps declaring a schema; snaps can
+	// set options they do not declare from their hooks, to keep internal
+	// state, while snap set is checked strictly by ConfigureInstalled
+	if info, err := snapstate.CurrentInfo(context.State(), context.InstanceName()); err == nil && len(info.ConfigSchema) > 0 {
+		tr.SetSchema(context.InstanceName(), info.ConfigSchema.Lenient())
+	}
+
 	context.OnDone(func() error {
 		tr.Commit()
 		if context.InstanceName() == "core" {
@@ -63,6 +73,26 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 	return tr
 }
 
+// transactionOrigin returns who or what is changing the configuration
+// through the given context, and the ID of the change it is part of.
+func transactionOrigin(context *hookstate.Context) (origin, changeID string) {
+	if task, ok := context.Task(); ok {
+		if chg := task.Change(); chg != nil {
+			changeID = chg.ID()
+			// set by the API for configuration changes requested by users
+			chg.Get("config-origin", &origin)
+		}
+	}
+	if origin == "" {
+		if context.IsEphemeral() {
+			origin = "snapctl"
+		} else {
+			origin = fmt.Sprintf("%s hook", context.HookName())
+		}
+	}
+	return origin, changeID
+}
+
 func newConfigureHandler(context *hookstate.Context) hookstate.Handler {
 	return &configureHandler{context: context}
 }
@@ -113,6 +143,36 @@ func (h *configureHandler) Before() error {
 		}
 	}
 
+	return applySchemaDefaults(st, tr, instanceName)
+}
+
+// applySchemaDefaults sets the defaults declared in the config-schema of
+// the snap for the options that are not set yet.
+func applySchemaDefaults(st *state.State, tr *config.Transaction, instanceName string) error {
+	info, err := snapstate.CurrentInfo(st, instanceName)
+	if err != nil || len(info.ConfigSchema) == 0 {
+		return nil
+	}
+
+	defaults := info.ConfigSchema.Defaults()
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var value interface{}
+		err := tr.Get(instanceName, key, &value)
+		if err == nil {
+			continue
+		}
+		if !config.IsNoOption(err) {
+			return err
+		}
+		if err := tr.Set(instanceName, key, defaults[key]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -528,3 +528,42 @@ func Remodel(st *state.State, new *asserts.Model) ([]*state.TaskSet, error) {
 
 	return tss, nil
 }
+
+// Reregister creates a change that re-registers the device with the
+// store under a freshly generated device key, for example because the
+// current one is suspected to have been compromised. The request for the
+// new serial carries a proof of possession of the current device key;
+// the current key and serial are only replaced once the new serial has
+// been obtained.
+func Reregister(st *state.State) (*state.Change, error) {
+	device, err := Device(st)
+	if err != nil {
+		return nil, err
+	}
+	if device.Serial == "" {
+		return nil, fmt.Errorf("cannot re-register a device that is not registered yet")
+	}
+
+	for _, chg := range st.Changes() {
+		if chg.Status().Ready() {
+			continue
+		}
+		switch chg.Kind() {
+		case "become-operational", "reregister-device", "remodel":
+			return nil, &snapstate.ChangeConflictError{
+				Message:    fmt.Sprintf("cannot re-register the device while change %q is in progress", chg.Kind()),
+				ChangeKind: chg.Kind(),
+			}
+		}
+	}
+
+	genKey := st.NewTask("generate-device-key", i18n.G("Generate new device key"))
+	genKey.Set("rotate-key", true)
+	requestSerial := st.NewTask("request-serial", i18n.G("Request device serial for the new device key"))
+	requestSerial.Set("rotate-key", true)
+	requestSerial.WaitFor(genKey)
+
+	chg := st.NewChange("reregister-device", i18n.G("Re-register device with a new device key"))
+	chg.AddAll(state.NewTaskSet(genKey, requestSerial))
+	return chg, nil
+}
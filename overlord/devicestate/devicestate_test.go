@@ -20,7 +20,9 @@
 package devicestate_test
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -314,6 +316,194 @@ func (s *deviceMgrSuite) TestFullDeviceRegistrationHappy(c *C) {
 	c.Check(device.KeyID, Equals, privKey.PublicKey().ID())
 }
 
+func (s *deviceMgrSuite) TestDeviceReregistrationHappy(c *C) {
+	r1 := devicestate.MockKeyLength(testKeyLength)
+	defer r1()
+
+	var serialReqs []*asserts.SerialRequest
+	bhv := &devicestatetest.DeviceServiceBehavior{
+		PostPreflight: func(c *C, bhv *devicestatetest.DeviceServiceBehavior, w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != bhv.SerialURLPath {
+				return
+			}
+			b, err := ioutil.ReadAll(r.Body)
+			c.Assert(err, IsNil)
+			r.Body = ioutil.NopCloser(bytes.NewReader(b))
+			a, err := asserts.Decode(b)
+			c.Assert(err, IsNil)
+			serialReqs = append(serialReqs, a.(*asserts.SerialRequest))
+		},
+	}
+	mockServer := s.mockServer(c, "REQID-1", bhv)
+	defer mockServer.Close()
+
+	r2 := devicestate.MockBaseStoreURL(mockServer.URL)
+	defer r2()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+
+	devicestate.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+	s.seeding()
+	devicestatetest.MockGadget(c, s.state, "pc", snap.R(2), nil)
+
+	// first registration
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	device, err := devicestate.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(device.Serial, Equals, "9999")
+	oldKeyID := device.KeyID
+	device.SessionMacaroon = "session-macaroon"
+	devicestate.SetDevice(s.state, device)
+
+	chg, err := devicestate.Reregister(s.state)
+	c.Assert(err, IsNil)
+	c.Check(chg.Kind(), Equals, "reregister-device")
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(chg.Err(), IsNil)
+
+	device, err = devicestate.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Brand, Equals, "canonical")
+	c.Check(device.Model, Equals, "pc")
+	c.Check(device.Serial, Equals, "10000")
+	c.Check(device.KeyID, Not(Equals), oldKeyID)
+	c.Check(device.SessionMacaroon, Equals, "")
+
+	a, err := s.db.Find(asserts.SerialType, map[string]string{
+		"brand-id": "canonical",
+		"model":    "pc",
+		"serial":   "10000",
+	})
+	c.Assert(err, IsNil)
+	serial := a.(*asserts.Serial)
+	c.Check(serial.DeviceKey().ID(), Equals, device.KeyID)
+
+	// the new key is in use, the old one is gone
+	_, err = devicestate.KeypairManager(s.mgr).Get(device.KeyID)
+	c.Check(err, IsNil)
+	_, err = devicestate.KeypairManager(s.mgr).Get(oldKeyID)
+	c.Check(err, ErrorMatches, "cannot find key pair")
+
+	// the request for the new serial proved possession of the old key
+	c.Assert(serialReqs, HasLen, 2)
+	c.Check(serialReqs[0].HeaderString("previous-key-proof"), Equals, "")
+	serialReq := serialReqs[1]
+	c.Check(serialReq.SignKeyID(), Equals, device.KeyID)
+	c.Check(serialReq.HeaderString("previous-serial"), Equals, "9999")
+	a, err = asserts.Decode([]byte(serialReq.HeaderString("previous-key-proof")))
+	c.Assert(err, IsNil)
+	proof := a.(*asserts.DeviceSessionRequest)
+	c.Check(proof.SignKeyID(), Equals, oldKeyID)
+	c.Check(proof.Serial(), Equals, "9999")
+	c.Check(proof.Nonce(), Equals, serialReq.RequestID())
+}
+
+func (s *deviceMgrSuite) TestDeviceReregistrationErrorKeepsOldKey(c *C) {
+	r1 := devicestate.MockKeyLength(testKeyLength)
+	defer r1()
+
+	mockServer := s.mockServer(c, devicestatetest.ReqIDBadRequest, nil)
+	defer mockServer.Close()
+
+	r2 := devicestate.MockBaseStoreURL(mockServer.URL)
+	defer r2()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+
+	oldKey, _ := assertstest.GenerateKey(testKeyLength)
+	err := devicestate.KeypairManager(s.mgr).Put(oldKey)
+	c.Assert(err, IsNil)
+	devicestate.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc",
+		Serial: "8989",
+		KeyID:  oldKey.PublicKey().ID(),
+	})
+	s.seeding()
+	devicestatetest.MockGadget(c, s.state, "pc", snap.R(2), nil)
+
+	chg, err := devicestate.Reregister(s.state)
+	c.Assert(err, IsNil)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot deliver device serial request: bad serial-request.*`)
+
+	device, err := devicestate.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "8989")
+	c.Check(device.KeyID, Equals, oldKey.PublicKey().ID())
+
+	var newKeyID string
+	err = chg.Get("new-device-key-id", &newKeyID)
+	c.Assert(err, IsNil)
+	// the unused new key was removed again
+	_, err = devicestate.KeypairManager(s.mgr).Get(newKeyID)
+	c.Check(err, ErrorMatches, "cannot find key pair")
+	_, err = devicestate.KeypairManager(s.mgr).Get(oldKey.PublicKey().ID())
+	c.Check(err, IsNil)
+}
+
+func (s *deviceMgrSuite) TestDeviceReregistrationNotRegistered(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	devicestate.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+
+	_, err := devicestate.Reregister(s.state)
+	c.Check(err, ErrorMatches, "cannot re-register a device that is not registered yet")
+}
+
+func (s *deviceMgrSuite) TestDeviceReregistrationConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	devicestate.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc",
+		Serial: "8989",
+		KeyID:  "key-id",
+	})
+
+	chg := s.state.NewChange("remodel", "...")
+	chg.AddTask(s.state.NewTask("fake-remodel", "..."))
+
+	_, err := devicestate.Reregister(s.state)
+	c.Check(err, ErrorMatches, `cannot re-register the device while change "remodel" is in progress`)
+	c.Check(err, FitsTypeOf, &snapstate.ChangeConflictError{})
+}
+
 func (s *deviceMgrSuite) TestFullDeviceRegistrationHappyWithProxy(c *C) {
 	r1 := devicestate.MockKeyLength(testKeyLength)
 	defer r1()
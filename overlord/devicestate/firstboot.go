@@ -75,14 +75,62 @@ func installSeedSnap(st *state.State, sn *snap.SeedSnap, flags snapstate.Flags,
 	return snapstate.InstallPath(st, &sideInfo, path, "", sn.Channel, flags)
 }
 
-func trivialSeeding(st *state.State, markSeeded *state.Task) []*state.TaskSet {
+func trivialSeeding(st *state.State, markSeeded, markPreseeded *state.Task) []*state.TaskSet {
 	// give the internal core config a chance to run (even if core is
 	// not used at all we put system configuration there)
 	configTs := snapstate.ConfigureSnap(st, "core", 0)
 	markSeeded.WaitAll(configTs)
+	if markPreseeded != nil {
+		configTs.WaitFor(markPreseeded)
+		return []*state.TaskSet{state.NewTaskSet(markPreseeded), configTs, state.NewTaskSet(markSeeded)}
+	}
 	return []*state.TaskSet{configTs, state.NewTaskSet(markSeeded)}
 }
 
+// splitAtHooks splits the tasks of a seeding taskset into the ones that
+// can be run when preseeding and the ones, from the hooks of the snap on,
+// that need the actual device.
+func splitAtHooks(ts *state.TaskSet) (pre, post []*state.Task) {
+	tasks := ts.Tasks()
+	hooks, err := ts.Edge(snapstate.HooksEdge)
+	if err != nil {
+		// configuration
+		return nil, tasks
+	}
+	for i, t := range tasks {
+		if t == hooks {
+			return tasks[:i], tasks[i:]
+		}
+	}
+	return nil, tasks
+}
+
+// chainPreseeded orders the given seeding tasksets for preseeding: the
+// tasks that can be run then are chained one after another, followed by
+// markPreseeded, only then all the tasks that need the actual device are
+// chained in turn. It returns the last set of the latter.
+func chainPreseeded(tsAll []*state.TaskSet, markPreseeded *state.Task) *state.TaskSet {
+	var lastPre *state.TaskSet
+	lastPost := state.NewTaskSet(markPreseeded)
+	for _, ts := range tsAll {
+		pre, post := splitAtHooks(ts)
+		if len(pre) != 0 {
+			preTs := state.NewTaskSet(pre...)
+			if lastPre != nil {
+				preTs.WaitAll(lastPre)
+			}
+			markPreseeded.WaitAll(preTs)
+			lastPre = preTs
+		}
+		if len(post) != 0 {
+			postTs := state.NewTaskSet(post...)
+			postTs.WaitAll(lastPost)
+			lastPost = postTs
+		}
+	}
+	return lastPost
+}
+
 func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.TaskSet, error) {
 	// check that the state is empty
 	var seeded bool
@@ -96,13 +144,25 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 
 	markSeeded := st.NewTask("mark-seeded", i18n.G("Mark system seeded"))
 
+	var markPreseeded *state.Task
+	if release.PreseedMode {
+		markPreseeded = st.NewTask("mark-preseeded", i18n.G("Mark system preseeded"))
+	}
+	// when preseeding the tasksets get ordered by chainPreseeded
+	// at the end instead
+	waitAll := func(ts, prev *state.TaskSet) {
+		if markPreseeded == nil {
+			ts.WaitAll(prev)
+		}
+	}
+
 	// ack all initial assertions
 	var model *asserts.Model
 	timings.Run(tm, "import-assertions", "import assertions from seed", func(nested timings.Measurer) {
 		model, err = importAssertionsFromSeed(st)
 	})
 	if err == errNothingToDo {
-		return trivialSeeding(st, markSeeded), nil
+		return trivialSeeding(st, markSeeded, markPreseeded), nil
 	}
 	if err != nil {
 		return nil, err
@@ -111,7 +171,7 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 	seedYamlFile := filepath.Join(dirs.SnapSeedDir, "seed.yaml")
 	if release.OnClassic && !osutil.FileExists(seedYamlFile) {
 		// on classic it is ok to not seed any snaps
-		return trivialSeeding(st, markSeeded), nil
+		return trivialSeeding(st, markSeeded, markPreseeded), nil
 	}
 
 	seed, err := snap.ReadSeedYaml(seedYamlFile)
@@ -144,7 +204,7 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 			return nil, err
 		}
 		if last >= 0 {
-			ts.WaitAll(tsAll[last])
+			waitAll(ts, tsAll[last])
 		}
 		tsAll = append(tsAll, ts)
 		alreadySeeded[snapName] = true
@@ -177,7 +237,7 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 		}
 		configTs := snapstate.ConfigureSnap(st, kernelName, snapstate.UseConfigDefaults)
 		// wait for the previous configTss
-		configTs.WaitAll(configTss[lastConf])
+		waitAll(configTs, configTss[lastConf])
 		configTss = append(configTss, configTs)
 		last++
 		lastConf++
@@ -200,7 +260,7 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 
 		configTs := snapstate.ConfigureSnap(st, gadgetName, snapstate.UseConfigDefaults)
 		// wait for the previous configTss
-		configTs.WaitAll(configTss[lastConf])
+		waitAll(configTs, configTss[lastConf])
 		configTss = append(configTss, configTs)
 		last++
 		//If we use lastConf again we need to enable this. It is
@@ -212,7 +272,7 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 	// chain together configuring core, kernel, and gadget after
 	// installing them so that defaults are availabble from gadget
 	if len(configTss) > 0 {
-		configTss[0].WaitAll(tsAll[last])
+		waitAll(configTss[0], tsAll[last])
 		tsAll = append(tsAll, configTss...)
 		last += len(configTss)
 	}
@@ -244,7 +304,7 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 	sort.Stable(snap.ByType(infos))
 	for _, info := range infos {
 		ts := infoToTs[info]
-		ts.WaitAll(tsAll[last])
+		waitAll(ts, tsAll[last])
 		tsAll = append(tsAll, ts)
 		last++
 	}
@@ -255,6 +315,10 @@ func populateStateFromSeedImpl(st *state.State, tm timings.Measurer) ([]*state.T
 
 	ts := tsAll[len(tsAll)-1]
 	endTs := state.NewTaskSet()
+	if markPreseeded != nil {
+		ts = chainPreseeded(tsAll, markPreseeded)
+		endTs.AddTask(markPreseeded)
+	}
 	if model.Gadget() != "" {
 		// we have a gadget that could have interface
 		// connection instructions
@@ -1536,6 +1536,117 @@ snaps:
 	c.Check(tSnap.WaitTasks(), testutil.Contains, tOtherBase)
 }
 
+func (s *FirstBootTestSuite) TestPopulateFromSeedPreseedOrdering(c *C) {
+	restore := release.MockPreseedMode(true)
+	defer restore()
+
+	// add a model assertion and its chain
+	assertsChain := s.makeModelAssertionChain(c, "my-model", map[string]interface{}{"base": "core18"})
+	for i, as := range assertsChain {
+		fn := filepath.Join(dirs.SnapSeedDir, "assertions", strconv.Itoa(i))
+		err := ioutil.WriteFile(fn, asserts.Encode(as), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	core18Fname, snapdFname, kernelFname, gadgetFname := s.makeCore18Snaps(c)
+
+	// create a seed.yaml
+	content := []byte(fmt.Sprintf(`
+snaps:
+ - name: snapd
+   file: %s
+ - name: core18
+   file: %s
+ - name: pc-kernel
+   file: %s
+ - name: pc
+   file: %s
+`, snapdFname, core18Fname, kernelFname, gadgetFname))
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapSeedDir, "seed.yaml"), content, 0644)
+	c.Assert(err, IsNil)
+
+	// run the firstboot stuff
+	st := s.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	tsAll, err := devicestate.PopulateStateFromSeedImpl(st, s.perfTimings)
+	c.Assert(err, IsNil)
+
+	endTasks := tsAll[len(tsAll)-1].Tasks()
+	c.Assert(endTasks, HasLen, 3)
+	markPreseeded := endTasks[0]
+	c.Assert(markPreseeded.Kind(), Equals, "mark-preseeded")
+	gadgetConnect := endTasks[1]
+	c.Assert(gadgetConnect.Kind(), Equals, "gadget-connect")
+
+	var prevPre, prevHook *state.Task
+	for _, ts := range tsAll[:4] {
+		first := ts.Tasks()[0]
+		hook, err := ts.Edge(snapstate.HooksEdge)
+		c.Assert(err, IsNil)
+		c.Check(hook.Kind(), Equals, "run-hook")
+		if prevPre == nil {
+			c.Check(first.WaitTasks(), HasLen, 0)
+		} else {
+			// the tasks that can run when preseeding are chained
+			c.Check(first.WaitTasks(), testutil.Contains, prevPre)
+		}
+		// the hooks wait for the system to be preseeded
+		if prevHook == nil {
+			c.Check(hook.WaitTasks(), testutil.Contains, markPreseeded)
+		} else {
+			c.Check(hook.WaitTasks(), Not(testutil.Contains), markPreseeded)
+		}
+		for i, t := range ts.Tasks() {
+			if t == hook {
+				prevPre = ts.Tasks()[i-1]
+				break
+			}
+		}
+		prevHook = hook
+	}
+	c.Check(markPreseeded.WaitTasks(), testutil.Contains, prevPre)
+	c.Check(gadgetConnect.WaitTasks(), Not(testutil.Contains), markPreseeded)
+}
+
+func (s *FirstBootTestSuite) TestMarkPreseededStopsDaemon(c *C) {
+	restore := release.MockPreseedMode(true)
+	defer restore()
+
+	st := s.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("seed", "run the populate from seed changes")
+	t := st.NewTask("mark-preseeded", "...")
+	chg.AddTask(t)
+
+	// run only the task runner, the device manager ensure needs a
+	// bootloader and a model
+	runner := s.overlord.TaskRunner()
+	st.Unlock()
+	runner.Ensure()
+	runner.Wait()
+	st.Lock()
+
+	c.Check(t.Status(), Equals, state.DoingStatus)
+	restarting, rt := st.Restarting()
+	c.Check(restarting, Equals, true)
+	c.Check(rt, Equals, state.StopDaemon)
+	var preseeded bool
+	c.Assert(t.Get("preseeded", &preseeded), IsNil)
+	c.Check(preseeded, Equals, true)
+
+	// on first boot of the preseeded system the task is done
+	restore()
+	state.MockRestarting(st, state.RestartUnset)
+	st.Unlock()
+	runner.Ensure()
+	runner.Wait()
+	st.Lock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+}
+
 func (s *FirstBootTestSuite) TestFirstbootGadgetBaseModelBaseMismatch(c *C) {
 	devAcct := assertstest.NewAccount(s.storeSigning, "developer", map[string]interface{}{
 		"account-id": "developerid",
@@ -43,6 +43,7 @@ import (
 	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/timings"
 )
@@ -60,6 +61,32 @@ func (m *DeviceManager) doMarkSeeded(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+func (m *DeviceManager) doMarkPreseeded(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if !release.PreseedMode {
+		// first boot of a preseeded image, carry on with the rest
+		// of the seeding
+		return nil
+	}
+
+	var preseeded bool
+	err := t.Get("preseeded", &preseeded)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !preseeded {
+		t.Set("preseeded", true)
+		st.Set("preseed-time", time.Now())
+		// stop snapd, the rest of the seeding needs to happen on
+		// the actual device; the task stays in Doing meanwhile
+		st.RequestRestart(state.StopDaemon)
+	}
+	return &state.Retry{Reason: "mark-preseeded will be marked done when snapd is executed in normal mode"}
+}
+
 func isSameAssertsRevision(err error) bool {
 	if e, ok := err.(*asserts.RevisionError); ok {
 		if e.Used == e.Current {
@@ -226,7 +253,22 @@ func (m *DeviceManager) doGenerateDeviceKey(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	if device.KeyID != "" {
+	rotate, err := isKeyRotation(t)
+	if err != nil {
+		return err
+	}
+
+	if rotate {
+		var newKeyID string
+		err := t.Change().Get("new-device-key-id", &newKeyID)
+		if err != nil && err != state.ErrNoState {
+			return err
+		}
+		if newKeyID != "" {
+			// nothing to do
+			return nil
+		}
+	} else if device.KeyID != "" {
 		// nothing to do
 		return nil
 	}
@@ -247,6 +289,14 @@ func (m *DeviceManager) doGenerateDeviceKey(t *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("cannot store device key pair: %v", err)
 	}
 
+	if rotate {
+		// the new key is only made the device key once we
+		// got a serial for it
+		t.Change().Set("new-device-key-id", privKey.PublicKey().ID())
+		t.SetStatus(state.DoneStatus)
+		return nil
+	}
+
 	device.KeyID = privKey.PublicKey().ID()
 	err = SetDevice(st, device)
 	if err != nil {
@@ -256,6 +306,64 @@ func (m *DeviceManager) doGenerateDeviceKey(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+func (m *DeviceManager) undoGenerateDeviceKey(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	rotate, err := isKeyRotation(t)
+	if err != nil {
+		return err
+	}
+	if !rotate {
+		return nil
+	}
+
+	var newKeyID string
+	err = t.Change().Get("new-device-key-id", &newKeyID)
+	if err == state.ErrNoState {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	device, err := Device(st)
+	if err != nil {
+		return err
+	}
+	if device.KeyID == newKeyID {
+		// already switched over to the new key
+		return nil
+	}
+	if err := m.deleteKeypair(newKeyID); err != nil {
+		t.Logf("cannot remove unused new device key: %v", err)
+	}
+	return nil
+}
+
+// isKeyRotation returns whether the task is part of the replacement
+// of the device key and serial of an already registered device.
+func isKeyRotation(t *state.Task) (bool, error) {
+	var rotate bool
+	err := t.Get("rotate-key", &rotate)
+	if err != nil && err != state.ErrNoState {
+		return false, err
+	}
+	return rotate, nil
+}
+
+type keypairDeleter interface {
+	Delete(keyID string) error
+}
+
+func (m *DeviceManager) deleteKeypair(keyID string) error {
+	deleter, ok := m.keypairMgr.(keypairDeleter)
+	if !ok {
+		return fmt.Errorf("internal error: device keypair manager cannot delete keys")
+	}
+	return deleter.Delete(keyID)
+}
+
 type serialSetup struct {
 	SerialRequest string `json:"serial-request"`
 	Serial        string `json:"serial"`
@@ -360,6 +468,22 @@ func prepareSerialRequest(t *state.Task, privKey asserts.PrivateKey, device *aut
 	if cfg.proposedSerial != "" {
 		headers["serial"] = cfg.proposedSerial
 	}
+	if cfg.previousKey != nil {
+		// prove possession of the current device key by signing
+		// with it over the request-id of this request
+		proof, err := asserts.SignWithoutAuthority(asserts.DeviceSessionRequestType, map[string]interface{}{
+			"brand-id":  device.Brand,
+			"model":     device.Model,
+			"serial":    device.Serial,
+			"nonce":     requestID.RequestID,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		}, nil, cfg.previousKey)
+		if err != nil {
+			return "", fmt.Errorf("cannot sign proof of possession of the current device key: %v", err)
+		}
+		headers["previous-serial"] = device.Serial
+		headers["previous-key-proof"] = string(asserts.Encode(proof))
+	}
 
 	serialReq, err := asserts.SignWithoutAuthority(asserts.SerialRequestType, headers, cfg.body, privKey)
 	if err != nil {
@@ -415,7 +539,7 @@ func submitSerialRequest(t *state.Task, serialRequest string, client *http.Clien
 	return serial, nil
 }
 
-func getSerial(t *state.Task, privKey asserts.PrivateKey, device *auth.DeviceState, tm timings.Measurer) (*asserts.Serial, error) {
+func getSerial(t *state.Task, privKey, prevKey asserts.PrivateKey, device *auth.DeviceState, tm timings.Measurer) (*asserts.Serial, error) {
 	var serialSup serialSetup
 	err := t.Get("serial-setup", &serialSup)
 	if err != nil && err != state.ErrNoState {
@@ -443,6 +567,7 @@ func getSerial(t *state.Task, privKey asserts.PrivateKey, device *auth.DeviceSta
 	if err != nil {
 		return nil, err
 	}
+	cfg.previousKey = prevKey
 
 	// NB: until we get at least an Accepted (202) we need to
 	// retry from scratch creating a new request-id because the
@@ -496,6 +621,9 @@ type serialRequestConfig struct {
 	headers          map[string]string
 	proposedSerial   string
 	body             []byte
+	// previousKey is set when replacing the device key of an
+	// already registered device
+	previousKey asserts.PrivateKey
 }
 
 func (cfg *serialRequestConfig) applyHeaders(req *http.Request) {
@@ -600,6 +728,11 @@ func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	rotate, err := isKeyRotation(t)
+	if err != nil {
+		return err
+	}
+
 	privKey, err := m.keyPair()
 	if err == state.ErrNoState {
 		return fmt.Errorf("internal error: cannot find device key pair")
@@ -608,6 +741,24 @@ func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	var prevKey asserts.PrivateKey
+	if rotate {
+		var newKeyID string
+		if err := t.Change().Get("new-device-key-id", &newKeyID); err != nil {
+			return fmt.Errorf("internal error: cannot find new device key id: %v", err)
+		}
+		if device.KeyID == newKeyID {
+			// means we switched to the new key but didn't get to
+			// the end of the task
+			return m.finishKeyRotation(t)
+		}
+		prevKey = privKey
+		privKey, err = m.keypairMgr.Get(newKeyID)
+		if err != nil {
+			return fmt.Errorf("cannot read new device key pair: %v", err)
+		}
+	}
+
 	// make this idempotent, look if we have already a serial assertion
 	// for privKey
 	serials, err := assertstate.DB(st).FindMany(asserts.SerialType, map[string]string{
@@ -621,6 +772,9 @@ func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) error {
 
 	if len(serials) == 1 {
 		// means we saved the assertion but didn't get to the end of the task
+		if rotate {
+			return m.switchDeviceKey(t, device, serials[0].(*asserts.Serial))
+		}
 		return m.finishRegistration(t, device, serials[0].(*asserts.Serial))
 	}
 	if len(serials) > 1 {
@@ -629,7 +783,7 @@ func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) error {
 
 	var serial *asserts.Serial
 	timings.Run(perfTimings, "get-serial", "get device serial", func(tm timings.Measurer) {
-		serial, err = getSerial(t, privKey, device, tm)
+		serial, err = getSerial(t, privKey, prevKey, device, tm)
 	})
 	if err == errPoll {
 		t.Logf("Will poll for device serial assertion in 60 seconds")
@@ -665,9 +819,44 @@ func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) error {
 		return &state.Retry{}
 	}
 
+	if rotate {
+		return m.switchDeviceKey(t, device, serial)
+	}
 	return m.finishRegistration(t, device, serial)
 }
 
+// switchDeviceKey makes the new key and the serial obtained for it the
+// current device identity, in one go.
+func (m *DeviceManager) switchDeviceKey(t *state.Task, device *auth.DeviceState, serial *asserts.Serial) error {
+	t.Set("old-device-key-id", device.KeyID)
+	device.KeyID = serial.DeviceKey().ID()
+	device.Serial = serial.Serial()
+	// the store device session was obtained with the old key,
+	// drop it so that a new one gets requested
+	device.SessionMacaroon = ""
+	if err := SetDevice(t.State(), device); err != nil {
+		return err
+	}
+	return m.finishKeyRotation(t)
+}
+
+// finishKeyRotation removes the old device key once the device switched
+// over to the new one.
+func (m *DeviceManager) finishKeyRotation(t *state.Task) error {
+	var oldKeyID string
+	err := t.Get("old-device-key-id", &oldKeyID)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if oldKeyID != "" {
+		if err := m.deleteKeypair(oldKeyID); err != nil {
+			t.Logf("cannot remove old device key: %v", err)
+		}
+	}
+	t.SetStatus(state.DoneStatus)
+	return nil
+}
+
 var repeatRequestSerial string // for tests
 
 func fetchKeys(st *state.State, keyID string) (errAcctKey error, err error) {
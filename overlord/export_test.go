@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/store"
 )
 
@@ -49,6 +50,16 @@ func MockPruneInterval(prunei, prunew, abortw time.Duration) (restore func()) {
 	}
 }
 
+// NewStateBackend returns the backend used to persist the state for tests.
+func NewStateBackend(path, journalPath string) state.JournalBackend {
+	return &overlordStateBackend{
+		path:           path,
+		journalPath:    journalPath,
+		ensureBefore:   func(time.Duration) {},
+		requestRestart: func(state.RestartType) {},
+	}
+}
+
 // MockEnsureNext sets o.ensureNext for tests.
 func MockEnsureNext(o *Overlord, t time.Time) {
 	o.ensureNext = t
@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	shortMountHelp = i18n.G("Create a mount")
+	longMountHelp  = i18n.G(`
+The mount command mounts the given source onto the given target, using a
+transient mount unit unless --persistent is given. The mount must be allowed
+by a connected mount-control plug of the snap. Mounts are always made with
+the nosuid and nodev options.`)
+)
+
+func init() {
+	addCommand("mount", shortMountHelp, longMountHelp, func() command { return &mountCommand{} })
+}
+
+type mountCommand struct {
+	baseCommand
+	Positional struct {
+		What  string `positional-arg-name:"<what>" required:"yes"`
+		Where string `positional-arg-name:"<where>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+	Type       string `short:"t" long:"type" value-name:"<type>" required:"yes" description:"Filesystem type of the mount"`
+	Options    string `short:"o" long:"options" value-name:"<options>" description:"Comma-separated list of mount options"`
+	Persistent bool   `long:"persistent" description:"Keep the mount across reboots"`
+}
+
+// mountControlEntries returns the mounts allowed by the connected
+// mount-control plugs of the given snap, together with its current
+// info.
+func mountControlEntries(st *state.State, snapName string) (*snap.Info, []*builtin.MountControlEntry, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo := ifacerepo.Get(st)
+	var entries []*builtin.MountControlEntry
+	for _, plug := range repo.Plugs(snapName) {
+		if plug.Interface != "mount-control" {
+			continue
+		}
+		conns, err := repo.Connected(snapName, plug.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(conns) == 0 {
+			continue
+		}
+		plugEntries, err := builtin.MountControlEntries(plug)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, plugEntries...)
+	}
+	return info, entries, nil
+}
+
+func (c *mountCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot mount without a context"))
+	}
+
+	what, where := c.Positional.What, c.Positional.Where
+	var options []string
+	if c.Options != "" {
+		options = strings.Split(c.Options, ",")
+	}
+	// the patterns of the plugs could match these, but they would end
+	// up as extra lines of the mount unit
+	for _, v := range append([]string{what, where, c.Type}, options...) {
+		if strings.IndexFunc(v, unicode.IsControl) >= 0 {
+			return fmt.Errorf(i18n.G("cannot mount %q on %q: %q contains control characters"), what, where, v)
+		}
+	}
+
+	info, entries, err := mountControlEntries(context.State(), context.InstanceName())
+	if err != nil {
+		return err
+	}
+	allowed := false
+	for _, e := range entries {
+		if e.Allows(what, where, c.Type, options, c.Persistent, info) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf(i18n.G("cannot mount %q on %q: no matching mount-control connection"), what, where)
+	}
+
+	// the kernel allows setuid binaries and device nodes unless told
+	// otherwise, which mount-control never lets snaps do
+	unitOptions := append([]string(nil), options...)
+	for _, o := range []string{"nosuid", "nodev"} {
+		if !strutil.ListContains(unitOptions, o) {
+			unitOptions = append(unitOptions, o)
+		}
+	}
+
+	lifetime := systemd.Transient
+	if c.Persistent {
+		lifetime = systemd.Persistent
+	}
+	sysd := systemd.New(dirs.GlobalRootDir, progress.Null)
+	_, err = sysd.AddMountUnitFileWithOptions(&systemd.MountUnitOptions{
+		Lifetime: lifetime,
+		SnapName: info.InstanceName(),
+		Revision: info.Revision.String(),
+		What:     what,
+		Where:    where,
+		Fstype:   c.Type,
+		Options:  unitOptions,
+		Origin:   "mount-control",
+	})
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot mount %q on %q: %v"), what, where, err)
+	}
+	return nil
+}
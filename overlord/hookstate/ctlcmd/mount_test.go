@@ -0,0 +1,286 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/osutil/squashfs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type mountSuite struct {
+	testutil.BaseTest
+	st          *state.State
+	mockContext *hookstate.Context
+	repo        *interfaces.Repository
+
+	sysctlArgs [][]string
+}
+
+var _ = Suite(&mountSuite{})
+
+const mountConsumerYaml = `name: mount-snap
+version: 1.0
+plugs:
+ mntctl:
+  interface: mount-control
+  mount:
+  - what: /dev/sd*
+    where: $SNAP_COMMON/**
+    type: [ext4]
+    options: [rw, sync]
+    persistent: true
+  - what: /dev/sdc*
+    where: $SNAP_COMMON/usb
+    type: [vfat]
+    options: [rw, nodev]
+  - what: tmpfs
+    where: /media/scratch
+    type: [tmpfs]
+    options: [rw]
+apps:
+ app:
+  command: foo
+`
+
+const mountCoreYaml = `name: core
+version: 1.0
+type: os
+slots:
+ mount-control:
+`
+
+func (s *mountSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.sysctlArgs = nil
+	s.AddCleanup(systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		s.sysctlArgs = append(s.sysctlArgs, args)
+		return nil, nil
+	}))
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	info := snaptest.MockSnapCurrent(c, mountConsumerYaml, &snap.SideInfo{Revision: snap.R(3)})
+	snapstate.Set(s.st, info.InstanceName(), &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: info.SnapName(), Revision: info.Revision}},
+		Current:  info.Revision,
+	})
+	coreInfo := snaptest.MockInfo(c, mountCoreYaml, nil)
+
+	s.repo = interfaces.NewRepository()
+	for _, iface := range builtin.Interfaces() {
+		if iface.Name() == "mount-control" {
+			c.Assert(s.repo.AddInterface(iface), IsNil)
+		}
+	}
+	c.Assert(s.repo.AddSnap(info), IsNil)
+	c.Assert(s.repo.AddSnap(coreInfo), IsNil)
+	ifacerepo.Replace(s.st, s.repo)
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "mount-snap", Revision: snap.R(3), Hook: "test-hook"}
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, task.State(), setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+}
+
+func (s *mountSuite) connect(c *C) {
+	connRef := interfaces.NewConnRef(s.repo.Plug("mount-snap", "mntctl"), s.repo.Slot("core", "mount-control"))
+	_, err := s.repo.Connect(connRef, nil, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+}
+
+func (s *mountSuite) TestMountPersistent(c *C) {
+	s.connect(c)
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"mount", "--persistent", "-t", "ext4", "-o", "rw,sync", "/dev/sdb1", "/var/snap/mount-snap/common/data"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	unit := filepath.Join(dirs.SnapServicesDir, "var-snap-mount\\x2dsnap-common-data.mount")
+	c.Check(unit, testutil.FileContains, "Description=Mount unit for mount-snap, revision 3\n")
+	c.Check(unit, testutil.FileContains, "What=/dev/sdb1\nWhere=/var/snap/mount-snap/common/data\nType=ext4\nOptions=rw,sync,nosuid,nodev\nX-SnapdOrigin=mount-control\n")
+	c.Check(s.sysctlArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"--root", dirs.GlobalRootDir, "enable", "var-snap-mount\\x2dsnap-common-data.mount"},
+		{"start", "var-snap-mount\\x2dsnap-common-data.mount"},
+	})
+}
+
+func (s *mountSuite) TestMountTransient(c *C) {
+	s.connect(c)
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "tmpfs", "tmpfs", "/media/scratch"}, 0)
+	c.Assert(err, IsNil)
+
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, "media-scratch.mount"), testutil.FileContains, "What=tmpfs\nWhere=/media/scratch\nType=tmpfs\nOptions=nosuid,nodev\n")
+	c.Check(s.sysctlArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"start", "media-scratch.mount"},
+	})
+}
+
+func (s *mountSuite) TestMountNosuidNodevNotRepeated(c *C) {
+	s.connect(c)
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "vfat", "-o", "rw,nodev", "/dev/sdc1", "/var/snap/mount-snap/common/usb"}, 0)
+	c.Assert(err, IsNil)
+
+	unit := filepath.Join(dirs.SnapRuntimeServicesDir, "var-snap-mount\\x2dsnap-common-usb.mount")
+	c.Check(unit, testutil.FileContains, "Type=vfat\nOptions=rw,nodev,nosuid\n")
+}
+
+func (s *mountSuite) TestMountNotAllowed(c *C) {
+	s.connect(c)
+
+	for _, args := range [][]string{
+		// not allowed to be persistent
+		{"mount", "--persistent", "-t", "tmpfs", "tmpfs", "/media/scratch"},
+		// wrong type
+		{"mount", "-t", "vfat", "/dev/sdb1", "/var/snap/mount-snap/common/data"},
+		// wrong option
+		{"mount", "-t", "ext4", "-o", "noexec", "/dev/sdb1", "/var/snap/mount-snap/common/data"},
+		// wrong source
+		{"mount", "-t", "ext4", "/dev/mmcblk0p1", "/var/snap/mount-snap/common/data"},
+		// wrong target
+		{"mount", "-t", "ext4", "/dev/sdb1", "/var/snap/other-snap/common/data"},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, args, 0)
+		c.Check(err, ErrorMatches, `cannot mount ".*" on ".*": no matching mount-control connection`, Commentf("%v", args))
+	}
+	c.Check(s.sysctlArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestMountControlChars(c *C) {
+	s.connect(c)
+
+	for _, args := range [][]string{
+		{"mount", "-t", "ext4", "/dev/sdb1", "/var/snap/mount-snap/common/x\nWhat=/dev/sda1"},
+		{"mount", "-t", "ext4", "/dev/sdb1\nWhat=/dev/sda1", "/var/snap/mount-snap/common/x"},
+		{"mount", "-t", "ext4", "-o", "rw,sync\n", "/dev/sdb1", "/var/snap/mount-snap/common/x"},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, args, 0)
+		c.Check(err, ErrorMatches, `(?s)cannot mount ".*" on ".*": ".*" contains control characters`, Commentf("%v", args))
+	}
+	c.Check(s.sysctlArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestMountNotConnected(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "tmpfs", "tmpfs", "/media/scratch"}, 0)
+	c.Check(err, ErrorMatches, `cannot mount "tmpfs" on "/media/scratch": no matching mount-control connection`)
+	c.Check(s.sysctlArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestMountNeedsRoot(c *C) {
+	s.connect(c)
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "tmpfs", "/media/scratch"}, 1000)
+	c.Check(err, ErrorMatches, `cannot use "mount" with uid 1000, try with sudo`)
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"umount", "/media/scratch"}, 1000)
+	c.Check(err, ErrorMatches, `cannot use "umount" with uid 1000, try with sudo`)
+}
+
+func (s *mountSuite) TestUmount(c *C) {
+	s.connect(c)
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"mount", "-t", "tmpfs", "tmpfs", "/media/scratch"}, 0)
+	c.Assert(err, IsNil)
+	s.sysctlArgs = nil
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"umount", "/media/scratch"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, "media-scratch.mount"), testutil.FileAbsent)
+	c.Check(s.sysctlArgs, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "disable", "media-scratch.mount"},
+		{"daemon-reload"},
+	})
+}
+
+func (s *mountSuite) TestUmountNotAllowed(c *C) {
+	s.connect(c)
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"umount", "/snap/core/1"}, 0)
+	c.Check(err, ErrorMatches, `cannot unmount "/snap/core/1": no matching mount-control connection`)
+	c.Check(s.sysctlArgs, HasLen, 0)
+}
+
+func (s *mountSuite) TestUmountSnapMountUnitRefused(c *C) {
+	s.connect(c)
+	defer squashfs.MockUseFuse(false)()
+
+	// a mount unit as snapd writes it for a snap, at a place the plug
+	// allows
+	snapFile := filepath.Join(c.MkDir(), "core_1.snap")
+	c.Assert(ioutil.WriteFile(snapFile, nil, 0644), IsNil)
+	sysd := systemd.New(dirs.GlobalRootDir, nil)
+	_, err := sysd.AddMountUnitFile("core", "1", snapFile, "/media/scratch", "squashfs")
+	c.Assert(err, IsNil)
+	s.sysctlArgs = nil
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"umount", "/media/scratch"}, 0)
+	c.Check(err, ErrorMatches, `cannot unmount "/media/scratch": not mounted by snap "mount-snap" through mount-control`)
+	c.Check(s.sysctlArgs, HasLen, 0)
+	c.Check(systemd.MountUnitPath("/media/scratch"), testutil.FilePresent)
+}
+
+func (s *mountSuite) TestUmountOtherSnapMountRefused(c *C) {
+	s.connect(c)
+
+	sysd := systemd.New(dirs.GlobalRootDir, nil)
+	_, err := sysd.AddMountUnitFileWithOptions(&systemd.MountUnitOptions{
+		Lifetime: systemd.Transient,
+		SnapName: "other-snap",
+		Revision: "1",
+		What:     "tmpfs",
+		Where:    "/media/scratch",
+		Fstype:   "tmpfs",
+		Origin:   "mount-control",
+	})
+	c.Assert(err, IsNil)
+	s.sysctlArgs = nil
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"umount", "/media/scratch"}, 0)
+	c.Check(err, ErrorMatches, `cannot unmount "/media/scratch": not mounted by snap "mount-snap" through mount-control`)
+	c.Check(s.sysctlArgs, HasLen, 0)
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, "media-scratch.mount"), testutil.FilePresent)
+}
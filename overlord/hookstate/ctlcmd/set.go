@@ -109,7 +109,9 @@ func (s *setCommand) setConfigSetting(context *hookstate.Context) error {
 			value = parts[1]
 		}
 
-		tr.Set(s.context().InstanceName(), key, value)
+		if err := tr.Set(s.context().InstanceName(), key, value); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -21,9 +21,11 @@ package ctlcmd_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
@@ -99,6 +101,31 @@ func (s *setSuite) TestCommand(c *C) {
 	c.Check(value, Equals, "qux")
 }
 
+func (s *setSuite) TestCommandSetError(c *C) {
+	s.mockContext.Lock()
+	tr := configstate.ContextTransaction(s.mockContext)
+	s.mockContext.Unlock()
+	tr.SetSchema("test-snap", fooSchema{})
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "foo=bar"}, 0)
+	c.Check(err, IsNil)
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set", "foo=1"}, 0)
+	c.Check(err, ErrorMatches, `cannot set snap "test-snap" option "foo": value must be a string`)
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set", "Foo=bar"}, 0)
+	c.Check(err, ErrorMatches, `invalid option name: "Foo"`)
+}
+
+type fooSchema struct{}
+
+func (fooSchema) Validate(key string, value interface{}) error {
+	if _, ok := value.(string); !ok {
+		return fmt.Errorf("value must be a string")
+	}
+	return nil
+}
+
 func (s *getSuite) TestSetRegularUserForbidden(c *C) {
 	state := state.New(nil)
 	state.Lock()
@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	shortUmountHelp = i18n.G("Remove a mount")
+	longUmountHelp  = i18n.G(`
+The umount command unmounts the given target and removes its mount unit. The
+target must be allowed by a connected mount-control plug of the snap, and
+must have been mounted by the snap with the mount command.`)
+)
+
+func init() {
+	addCommand("umount", shortUmountHelp, longUmountHelp, func() command { return &umountCommand{} })
+}
+
+type umountCommand struct {
+	baseCommand
+	Positional struct {
+		Where string `positional-arg-name:"<where>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *umountCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot unmount without a context"))
+	}
+
+	where := c.Positional.Where
+	info, entries, err := mountControlEntries(context.State(), context.InstanceName())
+	if err != nil {
+		return err
+	}
+	allowed := false
+	for _, e := range entries {
+		if e.MatchesWhere(where, info) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf(i18n.G("cannot unmount %q: no matching mount-control connection"), where)
+	}
+
+	mountedDir := filepath.Join(dirs.GlobalRootDir, where)
+	unit, err := systemd.ExistingMountUnit(mountedDir)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot unmount %q: %v"), where, err)
+	}
+	if unit == nil {
+		return nil
+	}
+	// never touch mount units of snaps themselves or of other snaps
+	if unit.Origin != "mount-control" || unit.SnapName != info.InstanceName() {
+		return fmt.Errorf(i18n.G("cannot unmount %q: not mounted by snap %q through mount-control"), where, info.InstanceName())
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, progress.Null)
+	if err := sysd.RemoveMountUnitFile(mountedDir); err != nil {
+		return fmt.Errorf(i18n.G("cannot unmount %q: %v"), where, err)
+	}
+	return nil
+}
@@ -464,8 +464,27 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		policyChecker = policyCheck.check
 	}
 
-	// static attributes of the plug and slot not provided, the ones from snap infos will be used
-	conn, err := m.repo.Connect(connRef, nil, plugDynamicAttrs, nil, slotDynamicAttrs, policyChecker)
+	var restrictions map[string]interface{}
+	if err := task.Get("plug-restrictions", &restrictions); err != nil && err != state.ErrNoState {
+		return err
+	}
+	// static attributes of the plug and slot not provided, the ones
+	// from snap infos will be used unless the plug is restricted
+	var plugStaticAttrs map[string]interface{}
+	if len(restrictions) != 0 {
+		plugStaticAttrs, err = interfaces.RestrictPlug(m.repo.Interface(plug.Interface), plug, restrictions)
+		if err != nil {
+			return err
+		}
+		// the policy applies to the plug as declared, the
+		// restrictions only narrow it
+		check := policyChecker
+		policyChecker = func(connPlug *interfaces.ConnectedPlug, connSlot *interfaces.ConnectedSlot) (bool, error) {
+			return check(interfaces.NewConnectedPlug(plug, nil, connPlug.DynamicAttrs()), connSlot)
+		}
+	}
+
+	conn, err := m.repo.Connect(connRef, plugStaticAttrs, plugDynamicAttrs, nil, slotDynamicAttrs, policyChecker)
 	if err != nil || conn == nil {
 		return err
 	}
@@ -489,6 +508,7 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		Auto:             autoConnect,
 		ByGadget:         byGadget,
 		HotplugKey:       slot.HotplugKey,
+		PlugRestrictions: restrictions,
 	}
 	setConns(st, conns)
 
@@ -460,6 +460,22 @@ type connState struct {
 	// slots.
 	HotplugGone bool            `json:"hotplug-gone,omitempty"`
 	HotplugKey  snap.HotplugKey `json:"hotplug-key,omitempty"`
+	// PlugRestrictions are the administrator restrictions that
+	// narrowed the static attributes of the plug when connecting.
+	PlugRestrictions map[string]interface{} `json:"plug-restrictions,omitempty"`
+}
+
+// devicePolicy returns the device-policy of the brand for the given
+// model, or nil if there is none.
+func devicePolicy(st *state.State, modelAs *asserts.Model) (*asserts.DevicePolicy, error) {
+	devPolicy, err := assertstate.DevicePolicy(st, modelAs)
+	if asserts.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return devPolicy, nil
 }
 
 type autoConnectChecker struct {
@@ -528,6 +544,11 @@ func (c *autoConnectChecker) check(plug *interfaces.ConnectedPlug, slot *interfa
 		}
 	}
 
+	devPolicy, err := devicePolicy(c.st, modelAs)
+	if err != nil {
+		return false, err
+	}
+
 	// check the connection against the declarations' rules
 	ic := policy.ConnectCandidate{
 		Plug:                plug,
@@ -535,6 +556,7 @@ func (c *autoConnectChecker) check(plug *interfaces.ConnectedPlug, slot *interfa
 		Slot:                slot,
 		SlotSnapDeclaration: slotDecl,
 		BaseDeclaration:     c.baseDecl,
+		DevicePolicy:        devPolicy,
 		Model:               modelAs,
 		Store:               storeAs,
 	}
@@ -591,6 +613,11 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		}
 	}
 
+	devPolicy, err := devicePolicy(c.st, modelAs)
+	if err != nil {
+		return false, err
+	}
+
 	// check the connection against the declarations' rules
 	ic := policy.ConnectCandidate{
 		Plug:                plug,
@@ -598,6 +625,7 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		Slot:                slot,
 		SlotSnapDeclaration: slotDecl,
 		BaseDeclaration:     c.baseDecl,
+		DevicePolicy:        devPolicy,
 		Model:               modelAs,
 		Store:               storeAs,
 	}
@@ -194,6 +194,9 @@ type ConnectionState struct {
 	StaticSlotAttrs  map[string]interface{}
 	DynamicSlotAttrs map[string]interface{}
 	HotplugGone      bool
+	// PlugRestrictions are the administrator restrictions of the
+	// static attributes of the plug, if any
+	PlugRestrictions map[string]interface{}
 }
 
 // ConnectionStates return the state of connections tracked by the manager
@@ -217,6 +220,7 @@ func (m *InterfaceManager) ConnectionStates() (connStateByRef map[string]Connect
 			StaticSlotAttrs:  cstate.StaticSlotAttrs,
 			DynamicSlotAttrs: cstate.DynamicSlotAttrs,
 			HotplugGone:      cstate.HotplugGone,
+			PlugRestrictions: cstate.PlugRestrictions,
 		}
 	}
 	return connStateByRef, nil
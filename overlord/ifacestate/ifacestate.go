@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -78,6 +79,9 @@ func findSymmetricAutoconnectTask(st *state.State, plugSnap, slotSnap string, in
 type connectOpts struct {
 	ByGadget    bool
 	AutoConnect bool
+
+	// Restrictions narrow the static attributes of the plug.
+	Restrictions map[string]interface{}
 }
 
 // Connect returns a set of tasks for connecting an interface.
@@ -90,6 +94,18 @@ func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*s
 	return connect(st, plugSnap, plugName, slotSnap, slotName, connectOpts{})
 }
 
+// ConnectWithRestrictions returns a set of tasks for connecting an
+// interface with the static attributes of the plug narrowed by the
+// given administrator restrictions. The interface of the plug checks
+// that the restrictions are a subset of what the plug declares.
+func ConnectWithRestrictions(st *state.State, plugSnap, plugName, slotSnap, slotName string, restrictions map[string]interface{}) (*state.TaskSet, error) {
+	if err := snapstate.CheckChangeConflictMany(st, []string{plugSnap, slotSnap}, ""); err != nil {
+		return nil, err
+	}
+
+	return connect(st, plugSnap, plugName, slotSnap, slotName, connectOpts{Restrictions: restrictions})
+}
+
 func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, flags connectOpts) (*state.TaskSet, error) {
 	// TODO: Store the intent-to-connect in the state so that we automatically
 	// try to reconnect on reboot (reconnection can fail or can connect with
@@ -115,6 +131,9 @@ func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, fla
 	}
 	connRef := interfaces.ConnRef{PlugRef: interfaces.PlugRef{Snap: plugSnap, Name: plugName}, SlotRef: interfaces.SlotRef{Snap: slotSnap, Name: slotName}}
 	if conn, ok := conns[connRef.ID()]; ok && conn.Undesired == false && conn.HotplugGone == false {
+		if len(flags.Restrictions) != 0 {
+			return nil, fmt.Errorf("cannot restrict existing connection %s, disconnect it first", connRef.ID())
+		}
 		return nil, &ErrAlreadyConnected{Connection: connRef}
 	}
 
@@ -138,6 +157,13 @@ func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, fla
 	if err != nil {
 		return nil, err
 	}
+	if len(flags.Restrictions) != 0 {
+		// hooks see the narrowed attributes already
+		plugStatic, err = restrictPlug(st, plugSnap, plugName, flags.Restrictions)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	connectInterface := st.NewTask("connect", fmt.Sprintf(i18n.G("Connect %s:%s to %s:%s"), plugSnap, plugName, slotSnap, slotName))
 	initialContext := make(map[string]interface{})
@@ -199,6 +225,9 @@ func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, fla
 	if flags.ByGadget {
 		connectInterface.Set("by-gadget", true)
 	}
+	if len(flags.Restrictions) != 0 {
+		connectInterface.Set("plug-restrictions", flags.Restrictions)
+	}
 
 	// Expose a copy of all plug and slot attributes coming from yaml to interface hooks. The hooks will be able
 	// to modify them but all attributes will be checked against assertions after the hooks are run.
@@ -256,6 +285,21 @@ func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, fla
 	return tasks, nil
 }
 
+// restrictPlug returns the static attributes of the given plug narrowed
+// by the restrictions, as checked by its interface.
+func restrictPlug(st *state.State, plugSnap, plugName string, restrictions map[string]interface{}) (map[string]interface{}, error) {
+	repo := ifacerepo.Get(st)
+	plug := repo.Plug(plugSnap, plugName)
+	if plug == nil {
+		return nil, fmt.Errorf("snap %q has no %q plug", plugSnap, plugName)
+	}
+	iface := repo.Interface(plug.Interface)
+	if iface == nil {
+		return nil, fmt.Errorf("internal error: unknown interface %q", plug.Interface)
+	}
+	return interfaces.RestrictPlug(iface, plug, restrictions)
+}
+
 func initialConnectAttributes(st *state.State, plugSnapInfo *snap.Info, plugSnap string, plugName string, slotSnapInfo *snap.Info, slotSnap string, slotName string) (plugStatic, slotStatic map[string]interface{}, err error) {
 	var plugSnapst snapstate.SnapState
 
@@ -441,10 +485,16 @@ func CheckInterfaces(st *state.State, snapInfo *snap.Info) error {
 		return fmt.Errorf("cannot find snap declaration for %q: %v", snapInfo.InstanceName(), err)
 	}
 
+	devPolicy, err := devicePolicy(st, modelAs)
+	if err != nil {
+		return err
+	}
+
 	ic := policy.InstallCandidate{
 		Snap:            snapInfo,
 		SnapDeclaration: snapDecl,
 		BaseDeclaration: baseDecl,
+		DevicePolicy:    devPolicy,
 		Model:           modelAs,
 		Store:           storeAs,
 	}
@@ -452,6 +502,92 @@ func CheckInterfaces(st *state.State, snapInfo *snap.Info) error {
 	return ic.Check()
 }
 
+// PolicyDecision describes how the interface policy decides a
+// connection between a plug and a slot.
+type PolicyDecision struct {
+	// ConnectRule describes the rule deciding a manual connection, it
+	// is empty if no rule applied.
+	ConnectRule string
+	// ConnectError is nil if a manual connection is allowed.
+	ConnectError error
+	// AutoConnectRule describes the rule deciding auto-connection, it
+	// is empty if no rule applied.
+	AutoConnectRule string
+	// AutoConnectError is nil if auto-connection is allowed.
+	AutoConnectError error
+}
+
+// ExplainConnectionPolicy returns how the base-declaration, the
+// snap-declarations and the device-policy decide a connection between
+// the given plug and slot.
+func ExplainConnectionPolicy(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*PolicyDecision, error) {
+	repo := ifacerepo.Get(st)
+	plugInfo := repo.Plug(plugSnap, plugName)
+	if plugInfo == nil {
+		return nil, fmt.Errorf("snap %q has no plug named %q", plugSnap, plugName)
+	}
+	slotInfo := repo.Slot(slotSnap, slotName)
+	if slotInfo == nil {
+		return nil, fmt.Errorf("snap %q has no slot named %q", slotSnap, slotName)
+	}
+
+	modelAs, err := devicestate.Model(st)
+	if err != nil {
+		return nil, err
+	}
+
+	var storeAs *asserts.Store
+	if modelAs.Store() != "" {
+		var err error
+		storeAs, err = assertstate.Store(st, modelAs.Store())
+		if err != nil && !asserts.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	baseDecl, err := assertstate.BaseDeclaration(st)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot find base declaration: %v", err)
+	}
+
+	var plugDecl *asserts.SnapDeclaration
+	if plugInfo.Snap.SnapID != "" {
+		plugDecl, err = assertstate.SnapDeclaration(st, plugInfo.Snap.SnapID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", plugSnap, err)
+		}
+	}
+
+	var slotDecl *asserts.SnapDeclaration
+	if slotInfo.Snap.SnapID != "" {
+		slotDecl, err = assertstate.SnapDeclaration(st, slotInfo.Snap.SnapID)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", slotSnap, err)
+		}
+	}
+
+	devPolicy, err := devicePolicy(st, modelAs)
+	if err != nil {
+		return nil, err
+	}
+
+	ic := policy.ConnectCandidate{
+		Plug:                interfaces.NewConnectedPlug(plugInfo, nil, nil),
+		PlugSnapDeclaration: plugDecl,
+		Slot:                interfaces.NewConnectedSlot(slotInfo, nil, nil),
+		SlotSnapDeclaration: slotDecl,
+		BaseDeclaration:     baseDecl,
+		DevicePolicy:        devPolicy,
+		Model:               modelAs,
+		Store:               storeAs,
+	}
+
+	var decision PolicyDecision
+	decision.ConnectRule, decision.ConnectError = ic.DecidingRule(false)
+	decision.AutoConnectRule, decision.AutoConnectError = ic.DecidingRule(true)
+	return &decision, nil
+}
+
 var once sync.Once
 
 func delayedCrossMgrInit() {
@@ -465,9 +601,30 @@ func delayedCrossMgrInit() {
 		// hook into conflict checks mechanisms
 		snapstate.AddAffectedSnapsByKind("connect", connectDisconnectAffectedSnaps)
 		snapstate.AddAffectedSnapsByKind("disconnect", connectDisconnectAffectedSnaps)
+
+		// let connections be scheduled
+		snapstate.AddScheduledChangeKind("connect", scheduledConnect)
 	})
 }
 
+func scheduledConnect(st *state.State, sc *snapstate.ScheduledChange) ([]*state.TaskSet, error) {
+	var ts *state.TaskSet
+	var err error
+	if len(sc.Restrictions) != 0 {
+		ts, err = ConnectWithRestrictions(st, sc.PlugSnap, sc.PlugName, sc.SlotSnap, sc.SlotName, sc.Restrictions)
+	} else {
+		ts, err = Connect(st, sc.PlugSnap, sc.PlugName, sc.SlotSnap, sc.SlotName)
+	}
+	if _, ok := err.(*ErrAlreadyConnected); ok {
+		// nothing to do
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*state.TaskSet{ts}, nil
+}
+
 func MockConnectRetryTimeout(d time.Duration) (restore func()) {
 	old := connectRetryTimeout
 	connectRetryTimeout = d
@@ -155,6 +155,22 @@ func (am *AssertsMock) MockSnapDecl(c *C, name, publisher string, extraHeaders m
 	c.Assert(err, IsNil)
 }
 
+func (am *AssertsMock) MockDevicePolicy(c *C, snaps map[string]interface{}) {
+	headers := map[string]interface{}{
+		"series":    "16",
+		"brand-id":  "my-brand",
+		"model":     "my-model",
+		"snaps":     snaps,
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	devPolicy, err := am.brandSigning.Sign(asserts.DevicePolicyType, headers, nil, "")
+	c.Assert(err, IsNil)
+	am.st.Lock()
+	defer am.st.Unlock()
+	err = assertstate.Add(am.st, devPolicy)
+	c.Assert(err, IsNil)
+}
+
 func (am *AssertsMock) MockStore(c *C, st *state.State, storeID string, extraHeaders map[string]interface{}) {
 	headers := map[string]interface{}{
 		"store":       storeID,
@@ -2165,6 +2181,118 @@ slots:
 	check(conns, repo.Interfaces().Connections)
 }
 
+// The auto-connect task will check the device-policy of the brand
+// before the snap declarations.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsDevicePolicy(c *C) {
+	s.MockModel(c, nil)
+	s.MockDevicePolicy(c, map[string]interface{}{
+		"consumer": map[string]interface{}{
+			"plugs": map[string]interface{}{
+				"test": map[string]interface{}{
+					"allow-auto-connection": "true",
+				},
+			},
+		},
+	})
+
+	// the publishers do not match so the base-declaration alone
+	// would not auto-connect
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-auto-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+`))
+	defer restore()
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.MockSnapDecl(c, "producer", "producer-publisher", nil)
+	s.mockSnap(c, producerYaml)
+
+	mgr := s.manager(c)
+
+	s.MockSnapDecl(c, "consumer", "consumer-publisher", nil)
+	snapInfo := s.mockSnap(c, consumerYaml)
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: snapInfo.SnapName(),
+			SnapID:   snapInfo.SnapID,
+			Revision: snapInfo.Revision,
+		},
+	})
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Status(), Equals, state.DoneStatus)
+
+	var conns map[string]interface{}
+	_ = s.state.Get("conns", &conns)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"auto":        true,
+			"interface":   "test",
+			"plug-static": map[string]interface{}{"attr1": "value1"},
+			"slot-static": map[string]interface{}{"attr2": "value2"},
+		},
+	})
+	c.Check(mgr.Repository().Interfaces().Connections, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestExplainConnectionPolicy(c *C) {
+	s.MockModel(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    deny-auto-connection: true
+`))
+	defer restore()
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.MockSnapDecl(c, "producer", "producer-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.MockSnapDecl(c, "consumer", "consumer-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	decision, err := ifacestate.ExplainConnectionPolicy(s.state, "consumer", "plug", "producer", "slot")
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(decision.ConnectRule, Equals, `slot rule of interface "test" in base-declaration`)
+	c.Check(decision.ConnectError, IsNil)
+	c.Check(decision.AutoConnectRule, Equals, `slot rule of interface "test" in base-declaration`)
+	c.Check(decision.AutoConnectError, ErrorMatches, `auto-connection denied by slot rule of interface "test"`)
+
+	s.MockDevicePolicy(c, map[string]interface{}{
+		"consumer": map[string]interface{}{
+			"plugs": map[string]interface{}{
+				"test": map[string]interface{}{
+					"allow-auto-connection": "true",
+				},
+			},
+		},
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	decision, err = ifacestate.ExplainConnectionPolicy(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	c.Check(decision.AutoConnectRule, Equals, `plug rule of interface "test" in device-policy for "consumer" snap`)
+	c.Check(decision.AutoConnectError, IsNil)
+
+	_, err = ifacestate.ExplainConnectionPolicy(s.state, "consumer", "missing", "producer", "slot")
+	c.Check(err, ErrorMatches, `snap "consumer" has no plug named "missing"`)
+}
+
 // The auto-connect task will check snap declarations providing the
 // model assertion to fulfill device scope constraints: here no store
 // in the model assertion fails an on-store constraint.
@@ -2845,6 +2973,129 @@ func (s *interfaceManagerSuite) TestConnectTracksConnectionsInState(c *C) {
 	})
 }
 
+// restrictingInterface is a test interface that lets the plug attributes
+// be replaced by restrictions of the same type.
+type restrictingInterface struct {
+	ifacetest.TestInterface
+}
+
+func (iface *restrictingInterface) RestrictPlug(plug *snap.PlugInfo, restrictions map[string]interface{}) (map[string]interface{}, error) {
+	attrs := make(map[string]interface{}, len(plug.Attrs))
+	for k, v := range plug.Attrs {
+		attrs[k] = v
+	}
+	for k, v := range restrictions {
+		if _, ok := plug.Attrs[k].(string); !ok {
+			return nil, fmt.Errorf("cannot restrict %q", k)
+		}
+		attrs[k] = v
+	}
+	return attrs, nil
+}
+
+func (s *interfaceManagerSuite) TestConnectWithRestrictions(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(c, &restrictingInterface{ifacetest.TestInterface{InterfaceName: "test"}}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+
+	ts, err := ifacestate.ConnectWithRestrictions(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"attr1": "narrow"})
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 5)
+
+	ts.Tasks()[2].Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "consumer",
+		},
+	})
+
+	// the hooks see the restricted attributes
+	var plugStatic map[string]interface{}
+	c.Assert(ts.Tasks()[2].Get("plug-static", &plugStatic), IsNil)
+	c.Check(plugStatic, DeepEquals, map[string]interface{}{"attr1": "narrow"})
+
+	change := s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":         "test",
+			"plug-static":       map[string]interface{}{"attr1": "narrow"},
+			"slot-static":       map[string]interface{}{"attr2": "value2"},
+			"plug-restrictions": map[string]interface{}{"attr1": "narrow"},
+		},
+	})
+
+	// the repository has the restricted attributes
+	repo := mgr.Repository()
+	conn, err := repo.Connection(&interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(conn.Plug.StaticAttrs(), DeepEquals, map[string]interface{}{"attr1": "narrow"})
+
+	// restrictions are exposed with the connection states
+	states, err := func() (map[string]ifacestate.ConnectionState, error) {
+		s.state.Unlock()
+		defer s.state.Lock()
+		return mgr.ConnectionStates()
+	}()
+	c.Assert(err, IsNil)
+	c.Check(states["consumer:plug producer:slot"].PlugRestrictions, DeepEquals, map[string]interface{}{"attr1": "narrow"})
+
+	// an existing connection cannot be restricted
+	_, err = ifacestate.ConnectWithRestrictions(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"attr1": "narrower"})
+	c.Check(err, ErrorMatches, `cannot restrict existing connection consumer:plug producer:slot, disconnect it first`)
+}
+
+func (s *interfaceManagerSuite) TestConnectWithRestrictionsErrors(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(c, &restrictingInterface{ifacetest.TestInterface{InterfaceName: "test"}}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := ifacestate.ConnectWithRestrictions(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"other": "x"})
+	c.Check(err, ErrorMatches, `cannot restrict "other"`)
+
+}
+
+func (s *interfaceManagerSuite) TestConnectWithRestrictionsNotSupported(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := ifacestate.ConnectWithRestrictions(s.state, "consumer", "plug", "producer", "slot", map[string]interface{}{"attr1": "x"})
+	c.Check(err, ErrorMatches, `interface "test" does not support restrictions`)
+}
+
 func (s *interfaceManagerSuite) TestConnectSetsUpSecurity(c *C) {
 	s.MockModel(c, nil)
 
@@ -3379,6 +3630,43 @@ slots:
 	c.Check(ifacestate.CheckInterfaces(s.state, snapInfo), IsNil)
 }
 
+func (s *interfaceManagerSuite) TestCheckInterfacesDevicePolicy(c *C) {
+	s.MockModel(c, nil)
+
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    deny-installation: true
+`))
+	defer restore()
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+
+	s.MockSnapDecl(c, "producer", "producer-publisher", nil)
+	snapInfo := s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	c.Check(ifacestate.CheckInterfaces(s.state, snapInfo), ErrorMatches, "installation denied.*")
+	s.state.Unlock()
+
+	// the brand allows the installation for its model
+	s.MockDevicePolicy(c, map[string]interface{}{
+		"producer": map[string]interface{}{
+			"slots": map[string]interface{}{
+				"test": map[string]interface{}{
+					"allow-installation": "true",
+				},
+			},
+		},
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(ifacestate.CheckInterfaces(s.state, snapInfo), IsNil)
+}
+
 func (s *interfaceManagerSuite) TestCheckInterfacesDeviceScopeRightStore(c *C) {
 	s.MockModel(c, map[string]interface{}{
 		"store": "my-store",
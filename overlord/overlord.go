@@ -97,6 +97,7 @@ func New() (*Overlord, error) {
 
 	backend := &overlordStateBackend{
 		path:           dirs.SnapStateFile,
+		journalPath:    dirs.SnapStateJournalFile,
 		ensureBefore:   o.ensureBefore,
 		requestRestart: o.requestRestart,
 	}
@@ -145,7 +146,7 @@ func New() (*Overlord, error) {
 	o.addManager(deviceMgr)
 
 	o.addManager(cmdstate.Manager(s, o.runner))
-	o.addManager(snapshotstate.Manager(s, o.runner))
+	o.addManager(snapshotstate.Manager(s, hookMgr, o.runner))
 
 	configstateInit(hookMgr)
 
@@ -210,7 +211,17 @@ func loadState(backend state.Backend) (*state.State, error) {
 	}
 	defer r.Close()
 
-	s, err := state.ReadState(backend, r)
+	var s *state.State
+	journal, err := os.Open(dirs.SnapStateJournalFile)
+	switch {
+	case err == nil:
+		defer journal.Close()
+		s, err = state.ReadJournaledState(backend, r, journal)
+	case os.IsNotExist(err):
+		s, err = state.ReadState(backend, r)
+	default:
+		return nil, fmt.Errorf("cannot read the state journal: %s", err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -312,6 +323,12 @@ func (o *Overlord) Stop() error {
 	o.loopTomb.Kill(nil)
 	err := o.loopTomb.Wait()
 	o.stateEng.Stop()
+
+	// leave no journal behind for whatever runs next to miss
+	st := o.State()
+	st.Lock()
+	st.CompactJournal()
+	st.Unlock()
 	return err
 }
 
@@ -25,6 +25,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -33,8 +34,10 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changehistory"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
@@ -57,6 +60,7 @@ func (ovs *overlordSuite) SetUpTest(c *C) {
 	tmpdir := c.MkDir()
 	dirs.SetRootDir(tmpdir)
 	dirs.SnapStateFile = filepath.Join(tmpdir, "test.json")
+	dirs.SnapStateJournalFile = filepath.Join(tmpdir, "test.json.journal")
 	snapstate.CanAutoRefresh = nil
 	ovs.restoreBackends = ifacestate.MockSecurityBackends(nil)
 }
@@ -576,6 +580,113 @@ func (ovs *overlordSuite) TestCheckpoint(c *C) {
 	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":1`)
 }
 
+func (ovs *overlordSuite) TestNewWithJournaledState(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d,"some":"data","refresh-privacy-key":"0123456789ABCDEF"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0,"journal-seq":1}`, patch.Level, patch.Sublevel))
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+	// the first entry is already in the snapshot, the last one was
+	// only partially written
+	fakeJournal := []byte(`{"seq":1,"data":{"some":"old"},"last-change-id":0,"last-task-id":0,"last-lane-id":0}
+{"seq":2,"data":{"some":"new"},"last-change-id":0,"last-task-id":0,"last-lane-id":0}
+{"seq":3,"data":{"so`)
+	err = ioutil.WriteFile(dirs.SnapStateJournalFile, fakeJournal, 0600)
+	c.Assert(err, IsNil)
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	s := o.State()
+	s.Lock()
+	var some string
+	c.Assert(s.Get("some", &some), IsNil)
+	c.Check(some, Equals, "new")
+
+	// the next checkpoint compacts the journal into the snapshot
+	s.Set("mark", 1)
+	s.Unlock()
+
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"some":"new"`)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"journal-seq":2`)
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+}
+
+func (ovs *overlordSuite) TestStopCompactsJournal(c *C) {
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+	o.Loop()
+
+	// a state large enough to be journaled
+	s := o.State()
+	s.Lock()
+	s.Set("padding", strings.Repeat("x", 512*1024))
+	s.Unlock()
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+	c.Assert(osutil.FileExists(dirs.SnapStateJournalFile), Equals, true)
+	c.Check(dirs.SnapStateFile, Not(testutil.FileContains), `"mark":1`)
+
+	c.Assert(o.Stop(), IsNil)
+
+	// a snapd that knows nothing about the journal finds everything
+	// in the state file
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"mark":1`)
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+}
+
+func (ovs *overlordSuite) TestNewWithCorruptJournal(c *C) {
+	fakeState := []byte(`{"data":{},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`)
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(dirs.SnapStateJournalFile, []byte("{\"seq\":1,\n"), 0600)
+	c.Assert(err, IsNil)
+
+	_, err = overlord.New()
+	c.Assert(err, ErrorMatches, "cannot replay state journal: invalid entry on line 1: .*")
+}
+
+func (ovs *overlordSuite) TestStateBackendAppendJournal(c *C) {
+	oldUmask := syscall.Umask(0)
+	defer syscall.Umask(oldUmask)
+
+	backend := overlord.NewStateBackend(dirs.SnapStateFile, dirs.SnapStateJournalFile)
+
+	c.Assert(backend.Checkpoint([]byte(`{"data":{}}`)), IsNil)
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+
+	c.Assert(backend.AppendJournal([]byte("one\n")), IsNil)
+	c.Assert(backend.AppendJournal([]byte("two\n")), IsNil)
+	c.Check(dirs.SnapStateJournalFile, testutil.FileEquals, "one\ntwo\n")
+	st, err := os.Stat(dirs.SnapStateJournalFile)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode(), Equals, os.FileMode(0600))
+
+	// a checkpoint discards the journal
+	c.Assert(backend.Checkpoint([]byte(`{"data":{"mark":1}}`)), IsNil)
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, `{"data":{"mark":1}}`)
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, false)
+}
+
+func (ovs *overlordSuite) TestStateBackendArchivesPrunedChanges(c *C) {
+	st := state.New(overlord.NewStateBackend(dirs.SnapStateFile, dirs.SnapStateJournalFile))
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install-snap", `Install "foo" snap`)
+	chg.SetStatus(state.DoneStatus)
+	st.NewChange("in-progress", "...").AddTask(st.NewTask("foo", "..."))
+
+	st.Prune(time.Hour, time.Hour, 0)
+	c.Check(st.Changes(), HasLen, 1)
+
+	records, err := changehistory.Read(nil)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Check(records[0].ID, Equals, chg.ID())
+	c.Check(records[0].Kind, Equals, "install-snap")
+	c.Check(records[0].Status, Equals, "Done")
+}
+
 type sampleManager struct {
 	ensureCallback func()
 }
@@ -28,6 +28,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"time"
@@ -44,6 +45,9 @@ const (
 	archiveName  = "archive.tgz"
 	metadataName = "meta.json"
 	metaHashName = "meta.sha3_384"
+	// the configuration of encrypted snapshots is kept in its own
+	// (encrypted) entry instead of in the metadata
+	confName = "conf.json"
 
 	userArchivePrefix = "user/"
 	userArchiveSuffix = ".tgz"
@@ -61,6 +65,16 @@ var (
 // Flags encompasses extra flags for snapshots backend Save.
 type Flags struct {
 	Auto bool
+	// Hooks are the snap's hooks that ran before the save.
+	Hooks []string
+	// Exclude are the globs of paths the snap asked to leave out of
+	// its snapshots, as declared in its meta/snapshots.yaml.
+	Exclude []string
+	// Secret, if set, is what to encrypt the snapshot with, and
+	// SecretSource where it came from (client.SnapshotSecretPassphrase
+	// or client.SnapshotSecretKeyFile).
+	Secret       []byte
+	SecretSource string
 }
 
 // Iter loops over all snapshots in the snapshots directory, applying the given
@@ -163,8 +177,15 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	}
 
 	var auto bool
+	var hooks, exclude []string
+	var secret []byte
+	var secretSource string
 	if flags != nil {
 		auto = flags.Auto
+		hooks = flags.Hooks
+		exclude = flags.Exclude
+		secret = flags.Secret
+		secretSource = flags.SecretSource
 	}
 
 	snapshot := &client.Snapshot{
@@ -179,6 +200,18 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 		Size:     0,
 		Conf:     cfg,
 		Auto:     auto,
+		Hooks:    hooks,
+		Exclude:  exclude,
+	}
+
+	var key []byte
+	if len(secret) > 0 {
+		var err error
+		snapshot.Encryption, key, err = newEncryption(secretSource, secret)
+		if err != nil {
+			return nil, fmt.Errorf("cannot set up snapshot encryption: %v", err)
+		}
+		snapshot.Conf = nil
 	}
 
 	aw, err := osutil.NewAtomicFile(Filename(snapshot), 0600, 0, osutil.NoChown, osutil.NoChown)
@@ -190,7 +223,16 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 
 	w := zip.NewWriter(aw)
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
-	if err := addDirToZip(ctx, snapshot, w, "root", archiveName, si.DataDir()); err != nil {
+	rootExclude, err := tarExcludes(exclude, "$SNAP_DATA", "$SNAP_COMMON", si.Revision.String())
+	if err != nil {
+		return nil, err
+	}
+	userExclude, err := tarExcludes(exclude, "$SNAP_USER_DATA", "$SNAP_USER_COMMON", si.Revision.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addDirToZip(ctx, snapshot, w, "root", archiveName, si.DataDir(), rootExclude, key); err != nil {
 		return nil, err
 	}
 
@@ -200,7 +242,13 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	}
 
 	for _, usr := range users {
-		if err := addDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), si.UserDataDir(usr.HomeDir)); err != nil {
+		if err := addDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), si.UserDataDir(usr.HomeDir), userExclude, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if key != nil && cfg != nil {
+		if err := addConfToZip(snapshot, w, cfg, key); err != nil {
 			return nil, err
 		}
 	}
@@ -238,7 +286,53 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 
 var isTesting = osutil.GetenvBool("SNAPPY_TESTING")
 
-func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username string, entry, dir string) error {
+// tarExcludes maps the exclusions rooted at the given data and common
+// variables to tar patterns relative to the parent of the revision
+// directory, which is where tar runs from.
+func tarExcludes(exclude []string, dataVar, commonVar, revdir string) ([]string, error) {
+	var patterns []string
+	for _, ex := range exclude {
+		variable, glob, err := snap.SplitSnapshotExclude(ex)
+		if err != nil {
+			return nil, err
+		}
+		switch variable {
+		case dataVar:
+			patterns = append(patterns, path.Join(revdir, glob))
+		case commonVar:
+			patterns = append(patterns, path.Join("common", glob))
+		}
+	}
+	return patterns, nil
+}
+
+// addConfToZip adds the snap's configuration to an encrypted snapshot.
+func addConfToZip(snapshot *client.Snapshot, w *zip.Writer, cfg map[string]interface{}, key []byte) error {
+	confWriter, err := w.Create(confName)
+	if err != nil {
+		return err
+	}
+
+	var sz sizer
+	hasher := crypto.SHA3_384.New()
+	ew, err := newEncryptingWriter(io.MultiWriter(confWriter, hasher, &sz), key, confName)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(ew).Encode(cfg); err != nil {
+		return err
+	}
+	if err := ew.Close(); err != nil {
+		return err
+	}
+
+	snapshot.SHA3_384[confName] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += sz.size
+
+	return nil
+}
+
+func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username string, entry, dir string, exclude []string, key []byte) error {
 	parent, revdir := filepath.Split(dir)
 	exists, isDir, err := osutil.DirExists(parent)
 	if err != nil {
@@ -257,6 +351,13 @@ func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer,
 		"--sparse", "--gzip",
 		"--directory", parent,
 	}
+	if len(exclude) > 0 {
+		// exclusions only apply to the names that come after them
+		tarArgs = append(tarArgs, "--anchored")
+		for _, pattern := range exclude {
+			tarArgs = append(tarArgs, "--exclude="+pattern)
+		}
+	}
 
 	noRev, noCommon := true, true
 
@@ -303,6 +404,16 @@ func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer,
 
 	cmd := tarAsUser(username, tarArgs...)
 	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	var ew *encryptingWriter
+	if key != nil {
+		// the hash and size are those of the data as stored, i.e.
+		// encrypted
+		ew, err = newEncryptingWriter(cmd.Stdout, key, entry)
+		if err != nil {
+			return err
+		}
+		cmd.Stdout = ew
+	}
 	matchCounter := &strutil.MatchCounter{N: 1}
 	cmd.Stderr = matchCounter
 	if isTesting {
@@ -316,6 +427,11 @@ func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer,
 		}
 		return fmt.Errorf("tar failed: %v", err)
 	}
+	if ew != nil {
+		if err := ew.Close(); err != nil {
+			return err
+		}
+	}
 
 	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
 	snapshot.Size += sz.size
@@ -20,8 +20,10 @@
 package backend_test
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
@@ -408,11 +410,11 @@ func (s *snapshotSuite) TestAddDirToZipBails(c *check.C) {
 	buf, restore := logger.MockLogger()
 	defer restore()
 	// note as the zip is nil this would panic if it didn't bail
-	c.Check(backend.AddDirToZip(nil, snapshot, nil, "", "an/entry", filepath.Join(s.root, "nonexistent")), check.IsNil)
+	c.Check(backend.AddDirToZip(nil, snapshot, nil, "", "an/entry", filepath.Join(s.root, "nonexistent"), nil, nil), check.IsNil)
 	// no log for the non-existent case
 	c.Check(buf.String(), check.Equals, "")
 	buf.Reset()
-	c.Check(backend.AddDirToZip(nil, snapshot, nil, "", "an/entry", "/etc/passwd"), check.IsNil)
+	c.Check(backend.AddDirToZip(nil, snapshot, nil, "", "an/entry", "/etc/passwd", nil, nil), check.IsNil)
 	c.Check(buf.String(), check.Matches, "(?m).* is not a directory.")
 }
 
@@ -426,7 +428,7 @@ func (s *snapshotSuite) TestAddDirToZipTarFails(c *check.C) {
 
 	var buf bytes.Buffer
 	z := zip.NewWriter(&buf)
-	c.Assert(backend.AddDirToZip(ctx, nil, z, "", "an/entry", d), check.ErrorMatches, ".* context canceled")
+	c.Assert(backend.AddDirToZip(ctx, nil, z, "", "an/entry", d, nil, nil), check.ErrorMatches, ".* context canceled")
 }
 
 func (s *snapshotSuite) TestAddDirToZip(c *check.C) {
@@ -440,7 +442,7 @@ func (s *snapshotSuite) TestAddDirToZip(c *check.C) {
 	snapshot := &client.Snapshot{
 		SHA3_384: map[string]string{},
 	}
-	c.Assert(backend.AddDirToZip(context.Background(), snapshot, z, "", "an/entry", d), check.IsNil)
+	c.Assert(backend.AddDirToZip(context.Background(), snapshot, z, "", "an/entry", d, nil, nil), check.IsNil)
 	z.Close() // write out the central directory
 
 	c.Check(snapshot.SHA3_384, check.HasLen, 1)
@@ -453,12 +455,73 @@ func (s *snapshotSuite) TestAddDirToZip(c *check.C) {
 	c.Check(r.File[0].Name, check.Equals, "an/entry")
 }
 
+func (s *snapshotSuite) TestAddDirToZipExclude(c *check.C) {
+	d := filepath.Join(s.root, "foo")
+	c.Assert(os.MkdirAll(filepath.Join(d, "bar"), 0755), check.IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(d, "cache", "deep"), 0755), check.IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(s.root, "common"), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "bar", "baz"), []byte("hello\n"), 0644), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d, "cache", "deep", "x"), []byte("cached\n"), 0644), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.root, "common", "keep"), []byte("keep\n"), 0644), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.root, "common", "drop.tmp"), []byte("drop\n"), 0644), check.IsNil)
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	snapshot := &client.Snapshot{
+		SHA3_384: map[string]string{},
+	}
+	// running as "root" means tar is run directly, even if we are root
+	c.Assert(backend.AddDirToZip(context.Background(), snapshot, z, "root", "an/entry", d, []string{"foo/cache", "common/*.tmp"}, nil), check.IsNil)
+	z.Close()
+
+	br := bytes.NewReader(buf.Bytes())
+	r, err := zip.NewReader(br, int64(br.Len()))
+	c.Assert(err, check.IsNil)
+	c.Assert(r.File, check.HasLen, 1)
+	rc, err := r.File[0].Open()
+	c.Assert(err, check.IsNil)
+	defer rc.Close()
+	gz, err := gzip.NewReader(rc)
+	c.Assert(err, check.IsNil)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		names = append(names, strings.TrimSuffix(hdr.Name, "/"))
+	}
+	sort.Strings(names)
+	c.Check(names, check.DeepEquals, []string{"common", "common/keep", "foo", "foo/bar", "foo/bar/baz"})
+}
+
+func (s *snapshotSuite) TestTarExcludes(c *check.C) {
+	exclude := []string{"$SNAP_DATA/cache/*", "$SNAP_COMMON/*.tmp", "$SNAP_USER_DATA/.cache", "$SNAP_USER_COMMON/tmp"}
+
+	patterns, err := backend.TarExcludes(exclude, "$SNAP_DATA", "$SNAP_COMMON", "42")
+	c.Assert(err, check.IsNil)
+	c.Check(patterns, check.DeepEquals, []string{"42/cache/*", "common/*.tmp"})
+
+	patterns, err = backend.TarExcludes(exclude, "$SNAP_USER_DATA", "$SNAP_USER_COMMON", "42")
+	c.Assert(err, check.IsNil)
+	c.Check(patterns, check.DeepEquals, []string{"42/.cache", "common/tmp"})
+
+	_, err = backend.TarExcludes([]string{"$SNAP_DATA/../x"}, "$SNAP_DATA", "$SNAP_COMMON", "42")
+	c.Check(err, check.ErrorMatches, `snapshot exclusion "\$SNAP_DATA/../x" must be a clean path below \$SNAP_DATA`)
+}
+
 func (s *snapshotSuite) TestHappyRoundtrip(c *check.C) {
-	s.testHappyRoundtrip(c, "marker", false)
+	s.testHappyRoundtrip(c, "marker", nil)
 }
 
 func (s *snapshotSuite) TestHappyRoundtripAutomaticSnapshot(c *check.C) {
-	s.testHappyRoundtrip(c, "marker", true)
+	s.testHappyRoundtrip(c, "marker", &backend.Flags{Auto: true})
+}
+
+func (s *snapshotSuite) TestHappyRoundtripEncrypted(c *check.C) {
+	s.testHappyRoundtrip(c, "marker", &backend.Flags{Secret: []byte("sekrit"), SecretSource: client.SnapshotSecretPassphrase})
 }
 
 func (s *snapshotSuite) TestHappyRoundtripNoCommon(c *check.C) {
@@ -467,7 +530,7 @@ func (s *snapshotSuite) TestHappyRoundtripNoCommon(c *check.C) {
 			c.Assert(os.RemoveAll(t.dir), check.IsNil)
 		}
 	}
-	s.testHappyRoundtrip(c, "marker", false)
+	s.testHappyRoundtrip(c, "marker", nil)
 }
 
 func (s *snapshotSuite) TestHappyRoundtripNoRev(c *check.C) {
@@ -476,10 +539,68 @@ func (s *snapshotSuite) TestHappyRoundtripNoRev(c *check.C) {
 			c.Assert(os.RemoveAll(t.dir), check.IsNil)
 		}
 	}
-	s.testHappyRoundtrip(c, "../common/marker", false)
+	s.testHappyRoundtrip(c, "../common/marker", nil)
 }
 
-func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string, auto bool) {
+func (s *snapshotSuite) TestSaveRecordsHooks(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42)}, Version: "v1.33"}
+
+	shw, err := backend.Save(context.TODO(), 12, info, nil, nil, &backend.Flags{Hooks: []string{"pre-snapshot"}})
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Hooks, check.DeepEquals, []string{"pre-snapshot"})
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Hooks, check.DeepEquals, []string{"pre-snapshot"})
+}
+
+func (s *snapshotSuite) TestSaveEncrypted(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42)}, Version: "v1.33"}
+	cfg := map[string]interface{}{"token": "very secret"}
+
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, nil, &backend.Flags{Secret: []byte("sekrit"), SecretSource: client.SnapshotSecretKeyFile})
+	c.Assert(err, check.IsNil)
+	c.Assert(shw.Encryption, check.NotNil)
+	c.Check(shw.Encryption.Secret, check.Equals, "key-file")
+	c.Check(shw.Conf, check.IsNil)
+
+	// none of the data is in the clear
+	buf, err := ioutil.ReadFile(backend.Filename(shw))
+	c.Assert(err, check.IsNil)
+	c.Check(bytes.Contains(buf, []byte("very secret")), check.Equals, false)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Encryption, check.DeepEquals, shw.Encryption)
+	c.Check(shr.Conf, check.IsNil)
+
+	c.Check(shr.Check(context.TODO(), nil, []byte("sekrit")), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil, []byte("hunter2")), check.ErrorMatches, `cannot decrypt snapshot of snap "hello-snap": wrong key`)
+	c.Check(shr.Check(context.TODO(), nil, nil), check.ErrorMatches, `cannot decrypt snapshot of snap "hello-snap": no key given`)
+
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, []byte("hunter2"), logger.Debugf)
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot of snap "hello-snap": wrong key`)
+}
+
+func (s *snapshotSuite) TestRestoreEncryptedWrongPassphrase(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42)}, Version: "v1.33"}
+
+	shw, err := backend.Save(context.TODO(), 12, info, nil, nil, &backend.Flags{Secret: []byte("sekrit"), SecretSource: client.SnapshotSecretPassphrase})
+	c.Assert(err, check.IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, []byte("hunter2"), logger.Debugf)
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot of snap "hello-snap": wrong passphrase`)
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, nil, logger.Debugf)
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot of snap "hello-snap": no passphrase given`)
+}
+
+func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string, flags *backend.Flags) {
 	if os.Geteuid() == 0 {
 		c.Skip("this test cannot run as root (runuser will fail)")
 	}
@@ -490,7 +611,20 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string, auto bool)
 	cfg := map[string]interface{}{"some-setting": false}
 	shID := uint64(12)
 
-	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, &backend.Flags{Auto: auto})
+	if flags == nil {
+		flags = &backend.Flags{}
+	}
+	auto := flags.Auto
+	encrypted := flags.Secret != nil
+	// the configuration of encrypted snapshots is only available on restore
+	savedCfg := cfg
+	expectedHashkeys := []string{"archive.tgz", "user/snapuser.tgz"}
+	if encrypted {
+		savedCfg = nil
+		expectedHashkeys = []string{"archive.tgz", "conf.json", "user/snapuser.tgz"}
+	}
+
+	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, flags)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 	c.Check(shw.Snap, check.Equals, info.InstanceName())
@@ -498,10 +632,11 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string, auto bool)
 	c.Check(shw.Version, check.Equals, info.Version)
 	c.Check(shw.Epoch, check.DeepEquals, epoch)
 	c.Check(shw.Revision, check.Equals, info.Revision)
-	c.Check(shw.Conf, check.DeepEquals, cfg)
+	c.Check(shw.Conf, check.DeepEquals, savedCfg)
 	c.Check(shw.Auto, check.Equals, auto)
+	c.Check(shw.Encryption != nil, check.Equals, encrypted)
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
-	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Check(hashkeys(shw), check.DeepEquals, expectedHashkeys)
 
 	shs, err := backend.List(context.TODO(), 0, nil)
 	c.Assert(err, check.IsNil)
@@ -520,12 +655,13 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string, auto bool)
 		c.Check(sh.Version, check.Equals, info.Version, comm)
 		c.Check(sh.Epoch, check.DeepEquals, epoch)
 		c.Check(sh.Revision, check.Equals, info.Revision, comm)
-		c.Check(sh.Conf, check.DeepEquals, cfg, comm)
+		c.Check(sh.Conf, check.DeepEquals, savedCfg, comm)
 		c.Check(sh.SHA3_384, check.DeepEquals, shw.SHA3_384, comm)
 		c.Check(sh.Auto, check.Equals, auto)
+		c.Check(sh.Encryption, check.DeepEquals, shw.Encryption, comm)
 	}
 	c.Check(shr.Name(), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
-	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil, flags.Secret), check.IsNil)
 
 	newroot := c.MkDir()
 	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
@@ -544,10 +680,11 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string, auto bool)
 		c.Check(diff().Run(), check.NotNil, comm)
 
 		// restore leaves things like they were (again and again)
-		rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf)
+		rs, err := shr.Restore(context.TODO(), snap.R(0), nil, flags.Secret, logger.Debugf)
 		c.Assert(err, check.IsNil, comm)
 		rs.Cleanup()
 		c.Check(diff().Run(), check.IsNil, comm)
+		c.Check(shr.Conf, check.DeepEquals, cfg, comm)
 
 		// dirty it -> no longer like it was
 		c.Check(ioutil.WriteFile(filepath.Join(info.DataDir(), marker), []byte("scribble\n"), 0644), check.IsNil, comm)
@@ -598,7 +735,7 @@ func (s *snapshotSuite) TestRestoreRoundtripDifferentRevision(c *check.C) {
 	c.Check(diff().Run(), check.NotNil)
 
 	// restore leaves things like they were, but in the new dir
-	rs, err := shr.Restore(context.TODO(), snap.R("17"), nil, logger.Debugf)
+	rs, err := shr.Restore(context.TODO(), snap.R("17"), nil, nil, logger.Debugf)
 	c.Assert(err, check.IsNil)
 	rs.Cleanup()
 	c.Check(diff().Run(), check.IsNil)
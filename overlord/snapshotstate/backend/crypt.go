@@ -0,0 +1,283 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/snapcore/snapd/client"
+)
+
+// Encrypted snapshots have each of their zip entries encrypted on its
+// own, so that they can still be checked and restored one at a time.
+//
+// The snapshot key is derived from the secret with scrypt, so that
+// guessing weak passphrases is costly in memory as well as time, and each
+// entry starts with a random salt from which
+// (together with the snapshot key) the entry key is derived. The rest of
+// the entry is the data sealed with AES-256-GCM in chunks; the nonce of
+// each chunk is its index, with the last chunk flagged so that truncation
+// is noticed, and the entry name is the additional data so that entries
+// cannot be swapped around.
+
+const (
+	snapshotCipher = "aes-256-gcm"
+	snapshotKDF    = "scrypt"
+
+	keySize       = 32
+	saltSize      = 32
+	entrySaltSize = 16
+	// the size of the chunks the data of an entry is sealed in
+	cryptChunkSize = 64 * 1024
+
+	keyCheckMessage = "snapshot key check"
+)
+
+var (
+	// the scrypt parameters of new snapshots: deriving the key takes
+	// 128*N*r bytes (32MiB) of memory
+	kdfN = 1 << 15
+	kdfR = 8
+	kdfP = 1
+	// the most memory-hungry parameters an existing snapshot may ask
+	// for, as they come from its metadata
+	kdfMaxN = 1 << 20
+
+	errWrongSecret = errors.New("wrong secret")
+)
+
+func keyCheck(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(keyCheckMessage))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func kdf(secret []byte, enc *client.SnapshotEncryption) ([]byte, error) {
+	return scrypt.Key(secret, enc.Salt, enc.N, enc.R, enc.P, keySize)
+}
+
+// newEncryption returns the encryption metadata of a new snapshot, along
+// with the key to encrypt it with.
+func newEncryption(source string, secret []byte) (*client.SnapshotEncryption, []byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	enc := &client.SnapshotEncryption{
+		Cipher: snapshotCipher,
+		Secret: source,
+		KDF:    snapshotKDF,
+		Salt:   salt,
+		N:      kdfN,
+		R:      kdfR,
+		P:      kdfP,
+	}
+	key, err := kdf(secret, enc)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc.KeyCheck = keyCheck(key)
+	return enc, key, nil
+}
+
+// deriveKey derives the key of an existing snapshot from the secret, and
+// checks it is the one the snapshot was encrypted with.
+func deriveKey(secret []byte, enc *client.SnapshotEncryption) ([]byte, error) {
+	if enc.Cipher != snapshotCipher || enc.KDF != snapshotKDF {
+		return nil, fmt.Errorf("unsupported encryption %s with %s", enc.Cipher, enc.KDF)
+	}
+	if enc.N > kdfMaxN || enc.R*enc.P > kdfR*kdfP {
+		return nil, fmt.Errorf("unsupported %s parameters N=%d r=%d p=%d", enc.KDF, enc.N, enc.R, enc.P)
+	}
+	key, err := kdf(secret, enc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive snapshot key: %v", err)
+	}
+	if !hmac.Equal([]byte(keyCheck(key)), []byte(enc.KeyCheck)) {
+		return nil, errWrongSecret
+	}
+	return key, nil
+}
+
+func newEntryAEAD(key, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(aead cipher.AEAD, counter uint64, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce, counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptingWriter encrypts what is written to it into the underlying
+// writer; it needs to be closed to write out the last chunk.
+type encryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	entry   []byte
+	buf     []byte
+	counter uint64
+}
+
+func newEncryptingWriter(w io.Writer, key []byte, entry string) (*encryptingWriter, error) {
+	salt := make([]byte, entrySaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newEntryAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{
+		w:     w,
+		aead:  aead,
+		entry: []byte(entry),
+		buf:   make([]byte, 0, cryptChunkSize),
+	}, nil
+}
+
+func (ew *encryptingWriter) seal(chunk []byte, last bool) error {
+	nonce := chunkNonce(ew.aead, ew.counter, last)
+	ew.counter++
+	_, err := ew.w.Write(ew.aead.Seal(nil, nonce, chunk, ew.entry))
+	return err
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// a full chunk is only sealed once there's more data, as the
+		// last chunk needs to be sealed as such
+		if len(ew.buf) == cryptChunkSize {
+			if err := ew.seal(ew.buf, false); err != nil {
+				return 0, err
+			}
+			ew.buf = ew.buf[:0]
+		}
+		m := copy(ew.buf[len(ew.buf):cryptChunkSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// Close seals the last chunk. It does not close the underlying writer.
+func (ew *encryptingWriter) Close() error {
+	return ew.seal(ew.buf, true)
+}
+
+// decryptingReader decrypts what an encryptingWriter wrote, failing if
+// it has been changed in any way.
+type decryptingReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	entry   []byte
+	chunk   []byte
+	plain   []byte
+	buf     []byte
+	counter uint64
+	done    bool
+	// err is the error decryption failed with, if it did
+	err error
+}
+
+func newDecryptingReader(r io.Reader, key []byte, entry string) (*decryptingReader, error) {
+	salt := make([]byte, entrySaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, fmt.Errorf("cannot decrypt snapshot entry %q: %v", entry, err)
+	}
+	aead, err := newEntryAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		r:     bufio.NewReader(r),
+		aead:  aead,
+		entry: []byte(entry),
+		chunk: make([]byte, cryptChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (dr *decryptingReader) open() error {
+	n, err := io.ReadFull(dr.r, dr.chunk)
+	last := false
+	switch err {
+	case nil:
+		// a full chunk is the last one if nothing follows it
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+
+	nonce := chunkNonce(dr.aead, dr.counter, last)
+	dr.counter++
+	dr.plain, err = dr.aead.Open(dr.plain[:0], nonce, dr.chunk[:n], dr.entry)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt snapshot entry %q: %v", dr.entry, err)
+	}
+	dr.buf = dr.plain
+	dr.done = last
+	return nil
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.buf) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		dr.err = dr.open()
+	}
+	n := copy(p, dr.buf)
+	dr.buf = dr.buf[n:]
+	return n, nil
+}
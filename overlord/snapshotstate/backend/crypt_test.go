@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+)
+
+type cryptSuite struct{}
+
+var _ = check.Suite(&cryptSuite{})
+
+var testKey = bytes.Repeat([]byte{42}, 32)
+
+func encrypt(c *check.C, data []byte, entry string) []byte {
+	var buf bytes.Buffer
+	ew, err := backend.NewEncryptingWriter(&buf, testKey, entry)
+	c.Assert(err, check.IsNil)
+	// write it in odd sizes, to cross chunk boundaries
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err := ew.Write(data[:n])
+		c.Assert(err, check.IsNil)
+		data = data[n:]
+	}
+	c.Assert(ew.Close(), check.IsNil)
+	return buf.Bytes()
+}
+
+func decrypt(key, data []byte, entry string) ([]byte, error) {
+	dr, err := backend.NewDecryptingReader(bytes.NewReader(data), key, entry)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+func (s *cryptSuite) TestRoundtrip(c *check.C) {
+	for _, size := range []int{0, 1, backend.CryptChunkSize - 1, backend.CryptChunkSize, backend.CryptChunkSize + 1, 3 * backend.CryptChunkSize} {
+		comm := check.Commentf("%d", size)
+		data := bytes.Repeat([]byte{'x'}, size)
+		encrypted := encrypt(c, data, "archive.tgz")
+		c.Check(bytes.Contains(encrypted, []byte("xxxx")), check.Equals, false, comm)
+
+		decrypted, err := decrypt(testKey, encrypted, "archive.tgz")
+		c.Assert(err, check.IsNil, comm)
+		c.Check(decrypted, check.DeepEquals, data, comm)
+	}
+}
+
+func (s *cryptSuite) TestEncryptionIsSalted(c *check.C) {
+	data := []byte("some data")
+	c.Check(encrypt(c, data, "archive.tgz"), check.Not(check.DeepEquals), encrypt(c, data, "archive.tgz"))
+}
+
+func (s *cryptSuite) TestDecryptTampered(c *check.C) {
+	data := bytes.Repeat([]byte{'x'}, 2*backend.CryptChunkSize+10)
+	encrypted := encrypt(c, data, "archive.tgz")
+
+	tampered := append([]byte(nil), encrypted...)
+	tampered[len(tampered)/2] ^= 1
+	_, err := decrypt(testKey, tampered, "archive.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "archive.tgz": cipher: message authentication failed`)
+
+	// dropping the last chunk is noticed
+	_, err = decrypt(testKey, encrypted[:len(encrypted)-26], "archive.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "archive.tgz": cipher: message authentication failed`)
+
+	// as is moving entries around
+	_, err = decrypt(testKey, encrypted, "user/snapuser.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "user/snapuser.tgz": cipher: message authentication failed`)
+
+	// or using another key
+	_, err = decrypt(bytes.Repeat([]byte{1}, 32), encrypted, "archive.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "archive.tgz": cipher: message authentication failed`)
+
+	_, err = decrypt(testKey, encrypted[:5], "archive.tgz")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot entry "archive.tgz": unexpected EOF`)
+}
+
+func (s *cryptSuite) TestDeriveKey(c *check.C) {
+	enc, key, err := backend.NewEncryption(client.SnapshotSecretPassphrase, []byte("sekrit"))
+	c.Assert(err, check.IsNil)
+	c.Check(enc.Cipher, check.Equals, "aes-256-gcm")
+	c.Check(enc.KDF, check.Equals, "scrypt")
+	c.Check(enc.Secret, check.Equals, "passphrase")
+	c.Check(enc.Salt, check.HasLen, 32)
+	c.Check(enc.N, check.Equals, 1<<15)
+	c.Check(enc.R, check.Equals, 8)
+	c.Check(enc.P, check.Equals, 1)
+	c.Check(key, check.HasLen, 32)
+
+	derived, err := backend.DeriveKey([]byte("sekrit"), enc)
+	c.Assert(err, check.IsNil)
+	c.Check(derived, check.DeepEquals, key)
+
+	_, err = backend.DeriveKey([]byte("hunter2"), enc)
+	c.Check(err, check.ErrorMatches, "wrong secret")
+
+	// the parameters come from the snapshot, so they are checked
+	n := enc.N
+	enc.N = 1 << 30
+	_, err = backend.DeriveKey([]byte("sekrit"), enc)
+	c.Check(err, check.ErrorMatches, "unsupported scrypt parameters N=1073741824 r=8 p=1")
+	enc.N = n + 1
+	_, err = backend.DeriveKey([]byte("sekrit"), enc)
+	c.Check(err, check.ErrorMatches, "cannot derive snapshot key: scrypt: N must be > 1 and a power of 2")
+	enc.N = n
+
+	enc.Cipher = "rot13"
+	_, err = backend.DeriveKey([]byte("sekrit"), enc)
+	c.Check(err, check.ErrorMatches, "unsupported encryption rot13 with scrypt")
+}
@@ -20,6 +20,7 @@
 package backend
 
 import (
+	"io"
 	"os"
 	"os/user"
 
@@ -28,10 +29,22 @@ import (
 
 var (
 	AddDirToZip     = addDirToZip
+	TarExcludes     = tarExcludes
 	TarAsUser       = tarAsUser
 	PickUserWrapper = pickUserWrapper
+	NewEncryption   = newEncryption
+	DeriveKey       = deriveKey
+	CryptChunkSize  = cryptChunkSize
 )
 
+func NewEncryptingWriter(w io.Writer, key []byte, entry string) (io.WriteCloser, error) {
+	return newEncryptingWriter(w, key, entry)
+}
+
+func NewDecryptingReader(r io.Reader, key []byte, entry string) (io.Reader, error) {
+	return newDecryptingReader(r, key, entry)
+}
+
 func MockIsTesting(newIsTesting bool) func() {
 	oldIsTesting := isTesting
 	isTesting = newIsTesting
@@ -122,7 +122,30 @@ func Open(fn string) (reader *Reader, e error) {
 	return reader, nil
 }
 
-func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) error {
+// key returns the key to decrypt the snapshot with, derived from the
+// given secret; it is nil if the snapshot is not encrypted.
+func (r *Reader) key(secret []byte) ([]byte, error) {
+	if r.Encryption == nil {
+		return nil, nil
+	}
+	what := "passphrase"
+	if r.Encryption.Secret == client.SnapshotSecretKeyFile {
+		what = "key"
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("cannot decrypt snapshot of snap %q: no %s given", r.Snap, what)
+	}
+	key, err := deriveKey(secret, r.Encryption)
+	if err == errWrongSecret {
+		return nil, fmt.Errorf("cannot decrypt snapshot of snap %q: wrong %s", r.Snap, what)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt snapshot of snap %q: %v", r.Snap, err)
+	}
+	return key, nil
+}
+
+func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash, key []byte) error {
 	body, reportedSize, err := zipMember(r.File, entry)
 	if err != nil {
 		return err
@@ -130,13 +153,21 @@ func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) e
 	defer body.Close()
 
 	expectedHash := r.SHA3_384[entry]
-	readSize, err := io.Copy(io.MultiWriter(osutil.ContextWriter(ctx), hasher), body)
-	if err != nil {
+	var sz sizer
+	var data io.Reader = io.TeeReader(body, io.MultiWriter(osutil.ContextWriter(ctx), hasher, &sz))
+	if key != nil {
+		// decrypting is what checks the data is as it was encrypted
+		data, err = newDecryptingReader(data, key, entry)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := io.Copy(ioutil.Discard, data); err != nil {
 		return err
 	}
 
-	if readSize != reportedSize {
-		return fmt.Errorf("snapshot entry %q size (%d) different from actual (%d)", entry, reportedSize, readSize)
+	if sz.size != reportedSize {
+		return fmt.Errorf("snapshot entry %q size (%d) different from actual (%d)", entry, reportedSize, sz.size)
 	}
 
 	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
@@ -146,9 +177,17 @@ func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) e
 }
 
 // Check that the data contained in the snapshot matches its hashsums.
-func (r *Reader) Check(ctx context.Context, usernames []string) error {
+//
+// Encrypted snapshots are decrypted as well, with a key derived from the
+// given secret.
+func (r *Reader) Check(ctx context.Context, usernames []string, secret []byte) error {
 	sort.Strings(usernames)
 
+	key, err := r.key(secret)
+	if err != nil {
+		return err
+	}
+
 	hasher := crypto.SHA3_384.New()
 	for entry := range r.SHA3_384 {
 		if len(usernames) > 0 && isUserArchive(entry) {
@@ -159,7 +198,7 @@ func (r *Reader) Check(ctx context.Context, usernames []string) error {
 			}
 		}
 
-		if err := r.checkOne(ctx, entry, hasher); err != nil {
+		if err := r.checkOne(ctx, entry, hasher, key); err != nil {
 			return err
 		}
 		hasher.Reset()
@@ -168,6 +207,46 @@ func (r *Reader) Check(ctx context.Context, usernames []string) error {
 	return nil
 }
 
+// readConf reads the configuration of an encrypted snapshot into Conf.
+func (r *Reader) readConf(key []byte) error {
+	body, expectedSize, err := zipMember(r.File, confName)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var sz sizer
+	hasher := crypto.SHA3_384.New()
+	var data io.Reader = io.TeeReader(body, io.MultiWriter(hasher, &sz))
+	if key != nil {
+		data, err = newDecryptingReader(data, key, confName)
+		if err != nil {
+			return err
+		}
+	}
+	var conf map[string]interface{}
+	if err := jsonutil.DecodeWithNumber(data, &conf); err != nil {
+		return fmt.Errorf("cannot read snapshot configuration: %v", err)
+	}
+	// the rest needs to be read for it to be checked
+	if _, err := io.Copy(ioutil.Discard, data); err != nil {
+		return err
+	}
+
+	if sz.size != expectedSize {
+		return fmt.Errorf("snapshot %q entry %q expected size (%d) does not match actual (%d)",
+			r.Name(), confName, expectedSize, sz.size)
+	}
+	expectedHash := r.SHA3_384[confName]
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
+		return fmt.Errorf("snapshot %q entry %q expected hash (%.7s…) does not match actual (%.7s…)",
+			r.Name(), confName, expectedHash, actualHash)
+	}
+
+	r.Conf = conf
+	return nil
+}
+
 // Logf is the type implemented by logging functions.
 type Logf func(format string, args ...interface{})
 
@@ -176,7 +255,10 @@ type Logf func(format string, args ...interface{})
 // If successful this will replace the existing data (for the given revision,
 // or the one in the snapshot) with that contained in the snapshot. It keeps
 // track of the old data in the task so it can be undone (or cleaned up).
-func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames []string, logf Logf) (rs *RestoreState, e error) {
+//
+// Encrypted snapshots are decrypted with a key derived from the given
+// secret; their configuration is only available in Conf after this.
+func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames []string, secret []byte, logf Logf) (rs *RestoreState, e error) {
 	rs = &RestoreState{}
 	defer func() {
 		if e != nil {
@@ -186,6 +268,11 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		}
 	}()
 
+	key, err := r.key(secret)
+	if err != nil {
+		return rs, err
+	}
+
 	sort.Strings(usernames)
 	isRoot := sys.Geteuid() == 0
 	si := snap.MinimalPlaceInfo(r.Snap, r.Revision)
@@ -202,6 +289,13 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 			return rs, err
 		}
 
+		if entry == confName {
+			if err := r.readConf(key); err != nil {
+				return rs, err
+			}
+			continue
+		}
+
 		var dest string
 		isUser := isUserArchive(entry)
 		username := "root"
@@ -297,7 +391,15 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		expectedHash := r.SHA3_384[entry]
 
-		tr := io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		var tr io.Reader = io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		var dr *decryptingReader
+		if key != nil {
+			dr, err = newDecryptingReader(tr, key, entry)
+			if err != nil {
+				return rs, err
+			}
+			tr = dr
+		}
 
 		// resist the temptation of using archive/tar unless it's proven
 		// that calling out to tar has issues -- there are a lot of
@@ -317,12 +419,23 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		}
 
 		if err = osutil.RunWithContext(ctx, cmd); err != nil {
+			if dr != nil && dr.err != nil {
+				// tar complaining about its input is not as helpful
+				return rs, dr.err
+			}
 			matches, count := matchCounter.Matches()
 			if count > 0 {
 				return rs, fmt.Errorf("cannot unpack archive: %s (and %d more)", matches[0], count-1)
 			}
 			return rs, fmt.Errorf("tar failed: %v", err)
 		}
+		if dr != nil {
+			// tar need not read all of it, but it all needs to be
+			// decrypted for it to be authenticated
+			if _, err := io.Copy(ioutil.Discard, dr); err != nil {
+				return rs, err
+			}
+		}
 
 		if sz.size != expectedSize {
 			return rs, fmt.Errorf("snapshot %q entry %q expected size (%d) does not match actual (%d)",
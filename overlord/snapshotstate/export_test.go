@@ -43,10 +43,18 @@ var (
 	DoCheck                    = doCheck
 	DoForget                   = doForget
 	SaveExpiration             = saveExpiration
+	SaveScheduled              = saveScheduled
 	ExpiredSnapshotSets        = expiredSnapshotSets
 	RemoveSnapshotState        = removeSnapshotState
+	SetPassphrase              = setPassphrase
+	CleanupPassphrase          = cleanupPassphrase
 )
 
+func CachedPassphrase(task *state.Task) string {
+	passphrase, _ := task.State().Cached(snapshotPassphraseKey{task.ID()}).(string)
+	return passphrase
+}
+
 func (summaries snapshotSnapSummaries) AsMaps() []map[string]string {
 	out := make([]map[string]string, len(summaries))
 	for i, summary := range summaries {
@@ -84,6 +92,14 @@ func MockSnapstateCurrentInfo(f func(*state.State, string) (*snap.Info, error))
 	}
 }
 
+func MockSnapReadSnapshotYaml(f func(*snap.Info) (*snap.SnapshotOptions, error)) (restore func()) {
+	old := snapReadSnapshotYaml
+	snapReadSnapshotYaml = f
+	return func() {
+		snapReadSnapshotYaml = old
+	}
+}
+
 func MockSnapstateCheckChangeConflictMany(f func(*state.State, []string, string) error) (restore func()) {
 	old := snapstateCheckChangeConflictMany
 	snapstateCheckChangeConflictMany = f
@@ -108,7 +124,7 @@ func MockBackendOpen(f func(string) (*backend.Reader, error)) (restore func()) {
 	}
 }
 
-func MockBackendRestore(f func(*backend.Reader, context.Context, snap.Revision, []string, backend.Logf) (*backend.RestoreState, error)) (restore func()) {
+func MockBackendRestore(f func(*backend.Reader, context.Context, snap.Revision, []string, []byte, backend.Logf) (*backend.RestoreState, error)) (restore func()) {
 	old := backendRestore
 	backendRestore = f
 	return func() {
@@ -116,7 +132,7 @@ func MockBackendRestore(f func(*backend.Reader, context.Context, snap.Revision,
 	}
 }
 
-func MockBackendCheck(f func(*backend.Reader, context.Context, []string) error) (restore func()) {
+func MockBackendCheck(f func(*backend.Reader, context.Context, []string, []byte) error) (restore func()) {
 	old := backendCheck
 	backendCheck = f
 	return func() {
@@ -160,3 +176,11 @@ func MockConfigSetSnapConfig(f func(*state.State, string, *json.RawMessage) erro
 func (mgr *SnapshotManager) SetLastForgetExpiredSnapshotTime(t time.Time) {
 	mgr.lastForgetExpiredSnapshotTime = t
 }
+
+func (mgr *SnapshotManager) NextScheduledSnapshot() time.Time {
+	return mgr.nextScheduledSnapshot
+}
+
+func (mgr *SnapshotManager) SetNextScheduledSnapshot(t time.Time) {
+	mgr.nextScheduledSnapshot = t
+}
@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"regexp"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func setupHooks(hookMgr *hookstate.HookManager) {
+	handlerGenerator := func(context *hookstate.Context) hookstate.Handler {
+		return &snapshotHookHandler{context: context}
+	}
+
+	hookMgr.Register(regexp.MustCompile("^pre-snapshot$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^post-snapshot$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^post-restore$"), handlerGenerator)
+}
+
+// snapshotHookHandler records the outcome of the snapshot hooks in
+// the data of their change, so that it is known which of them failed
+// even when they ran after the data was saved or restored.
+type snapshotHookHandler struct {
+	context *hookstate.Context
+}
+
+func (h *snapshotHookHandler) Before() error {
+	return nil
+}
+
+func (h *snapshotHookHandler) Done() error {
+	return h.trace(nil)
+}
+
+func (h *snapshotHookHandler) Error(err error) error {
+	return h.trace(err)
+}
+
+type snapshotHookOutcome struct {
+	Snap  string `json:"snap"`
+	Hook  string `json:"hook"`
+	Error string `json:"error,omitempty"`
+}
+
+func (h *snapshotHookHandler) trace(hookErr error) error {
+	task, ok := h.context.Task()
+	if !ok {
+		return nil
+	}
+
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	chg := task.Change()
+	if chg == nil {
+		return nil
+	}
+	var data map[string]interface{}
+	err := chg.Get("api-data", &data)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if len(data) == 0 {
+		data = make(map[string]interface{})
+	}
+
+	outcome := &snapshotHookOutcome{
+		Snap: h.context.InstanceName(),
+		Hook: h.context.HookName(),
+	}
+	if hookErr != nil {
+		outcome.Error = hookErr.Error()
+	}
+	hooks, _ := data["snapshot-hooks"].([]interface{})
+	data["snapshot-hooks"] = append(hooks, outcome)
+
+	chg.Set("api-data", data)
+	return nil
+}
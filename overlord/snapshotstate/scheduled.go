@@ -0,0 +1,351 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+const (
+	// scheduled snapshots are taken at least this often, whatever the
+	// schedule says
+	maxScheduledSnapshotPostponement = 31 * 24 * time.Hour
+
+	// number of scheduled snapshot sets kept, if not set by the user
+	defaultScheduledSnapshotRetain = 3
+)
+
+// snapshotSchedule returns the parsed snapshots.schedule along with its
+// string form; an empty schedule means no scheduled snapshots.
+func snapshotSchedule(st *state.State) ([]*timeutil.Schedule, string, error) {
+	var scheduleStr string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "snapshots.schedule", &scheduleStr)
+	if err != nil && !config.IsNoOption(err) {
+		return nil, "", err
+	}
+	if scheduleStr == "" {
+		return nil, "", nil
+	}
+	schedule, err := timeutil.ParseSchedule(scheduleStr)
+	if err != nil {
+		logger.Noticef("snapshots.schedule cannot be parsed: %v", err)
+		return nil, "", nil
+	}
+	return schedule, scheduleStr, nil
+}
+
+// scheduledSnapshotRetention returns how many scheduled snapshot sets to
+// keep, and for how long; a zero duration means they are kept for as
+// long as they are within the count.
+func scheduledSnapshotRetention(st *state.State) (retain int, retention time.Duration, err error) {
+	var retainStr, retentionStr string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.scheduled.retain", &retainStr); err != nil && !config.IsNoOption(err) {
+		return 0, 0, err
+	}
+	if err := tr.Get("core", "snapshots.scheduled.retention", &retentionStr); err != nil && !config.IsNoOption(err) {
+		return 0, 0, err
+	}
+
+	retain = defaultScheduledSnapshotRetain
+	if retainStr != "" {
+		n, err := strconv.Atoi(retainStr)
+		if err == nil && n > 0 {
+			retain = n
+		} else {
+			logger.Noticef("snapshots.scheduled.retain cannot be parsed: %q", retainStr)
+		}
+	}
+	if retentionStr != "" && retentionStr != "no" {
+		dur, err := time.ParseDuration(retentionStr)
+		if err == nil {
+			retention = dur
+		} else {
+			logger.Noticef("snapshots.scheduled.retention cannot be parsed: %v", err)
+		}
+	}
+	return retain, retention, nil
+}
+
+func snapNamesOption(tr *config.Transaction, key string) ([]string, error) {
+	var namesStr string
+	if err := tr.Get("core", key, &namesStr); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(namesStr, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// scheduledSnapshotSnapNames returns the snaps to include in a scheduled
+// snapshot: all the active ones, or only the ones opted in with
+// snapshots.scheduled.include if set, minus the ones opted out with
+// snapshots.scheduled.exclude.
+func scheduledSnapshotSnapNames(st *state.State) ([]string, error) {
+	tr := config.NewTransaction(st)
+	include, err := snapNamesOption(tr, "snapshots.scheduled.include")
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := snapNamesOption(tr, "snapshots.scheduled.exclude")
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := allActiveSnapNames(st)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range all {
+		if len(include) > 0 && !strutil.ListContains(include, name) {
+			continue
+		}
+		if strutil.ListContains(exclude, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// scheduledSnapshotSetsToPrune returns the scheduled snapshot sets that
+// fall outside of the retention policy, either because there are newer
+// ones enough or because they are too old.
+// The state needs to be locked by the caller.
+func scheduledSnapshotSetsToPrune(st *state.State, now time.Time) (map[uint64]bool, error) {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil {
+		if err != state.ErrNoState {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	retain, retention, err := scheduledSnapshotRetention(st)
+	if err != nil {
+		return nil, err
+	}
+
+	var setIDs []uint64
+	for setID, snapshotSet := range snapshots {
+		if snapshotSet.Scheduled != nil {
+			setIDs = append(setIDs, setID)
+		}
+	}
+	// newest first
+	sort.Slice(setIDs, func(i, j int) bool { return setIDs[i] > setIDs[j] })
+
+	prune := make(map[uint64]bool)
+	for i, setID := range setIDs {
+		tooOld := retention > 0 && snapshots[setID].Scheduled.Add(retention).Before(now)
+		if i >= retain || tooOld {
+			prune[setID] = true
+		}
+	}
+	return prune, nil
+}
+
+// checkScheduledSnapshotChanges warns about the scheduled snapshot
+// changes that failed, and reports whether one is still in progress.
+// The state needs to be locked by the caller.
+func checkScheduledSnapshotChanges(st *state.State) (inFlight bool) {
+	for _, chg := range st.Changes() {
+		if chg.Kind() != "scheduled-snapshot" {
+			continue
+		}
+		if !chg.Status().Ready() {
+			inFlight = true
+			continue
+		}
+		if chg.Status() != state.ErrorStatus {
+			continue
+		}
+		var warned bool
+		if err := chg.Get("warned", &warned); err == nil && warned {
+			continue
+		}
+		st.Warnf("cannot take scheduled snapshot (change %s): %v", chg.ID(), chg.Err())
+		chg.Set("warned", true)
+	}
+	return inFlight
+}
+
+// ensureScheduledSnapshots takes a snapshot set of the snaps when the
+// snapshots.schedule says so, and prunes the scheduled sets that fall
+// outside of the retention policy.
+func (mgr *SnapshotManager) ensureScheduledSnapshots() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	if checkScheduledSnapshotChanges(st) {
+		// wait for it to be done before pruning or taking another one
+		return nil
+	}
+
+	if err := mgr.pruneScheduledSnapshots(); err != nil {
+		return err
+	}
+
+	schedule, scheduleStr, err := snapshotSchedule(st)
+	if err != nil {
+		return err
+	}
+	if len(schedule) == 0 {
+		mgr.nextScheduledSnapshot = time.Time{}
+		return nil
+	}
+	if scheduleStr != mgr.lastSnapshotSchedule {
+		logger.Debugf("Snapshot schedule changed.")
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = scheduleStr
+	}
+
+	now := time.Now()
+	if mgr.nextScheduledSnapshot.IsZero() {
+		var last time.Time
+		if err := st.Get("last-scheduled-snapshot", &last); err != nil && err != state.ErrNoState {
+			return err
+		}
+		if last.IsZero() {
+			// anchor the schedule, so the first scheduled snapshot
+			// is taken on the next window and not right away
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		mgr.nextScheduledSnapshot = now.Add(timeutil.Next(schedule, last, maxScheduledSnapshotPostponement))
+		logger.Debugf("Next scheduled snapshot at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if mgr.nextScheduledSnapshot.After(now) {
+		return nil
+	}
+
+	return mgr.launchScheduledSnapshot(now)
+}
+
+// launchScheduledSnapshot creates the change taking a scheduled snapshot
+// set. If any of the snaps has a conflicting change in progress, like a
+// refresh, it does nothing so that it is tried again on the next Ensure.
+// The state needs to be locked by the caller.
+func (mgr *SnapshotManager) launchScheduledSnapshot(now time.Time) error {
+	st := mgr.state
+
+	names, err := scheduledSnapshotSnapNames(st)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		logger.Debugf("No snaps to include in the scheduled snapshot.")
+		st.Set("last-scheduled-snapshot", now)
+		mgr.nextScheduledSnapshot = time.Time{}
+		return nil
+	}
+
+	setID, saved, ts, err := save(st, names, nil, nil, true)
+	if err != nil {
+		if _, ok := err.(*snapstate.ChangeConflictError); ok {
+			logger.Debugf("Postponing scheduled snapshot: %v", err)
+			return nil
+		}
+		st.Warnf("cannot take scheduled snapshot: %v", err)
+		st.Set("last-scheduled-snapshot", now)
+		mgr.nextScheduledSnapshot = time.Time{}
+		return nil
+	}
+
+	chg := st.NewChange("scheduled-snapshot", fmt.Sprintf("Save scheduled snapshot set #%d", setID))
+	chg.AddAll(ts)
+	chg.Set("snap-names", saved)
+	chg.Set("api-data", map[string]interface{}{"snap-names": saved})
+
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
+	return nil
+}
+
+// pruneScheduledSnapshots forgets the scheduled snapshot sets that fall
+// outside of the retention policy. Sets that are being checked or
+// restored are left for a later Ensure.
+// The state needs to be locked by the caller.
+func (mgr *SnapshotManager) pruneScheduledSnapshots() error {
+	st := mgr.state
+
+	sets, err := scheduledSnapshotSetsToPrune(st, time.Now())
+	if err != nil {
+		return fmt.Errorf("internal error: cannot determine scheduled snapshots to prune: %v", err)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	conflicted := make(map[uint64]bool)
+	err = backendIter(context.TODO(), func(r *backend.Reader) error {
+		if !sets[r.SetID] || conflicted[r.SetID] {
+			return nil
+		}
+		// pruning needs to conflict with check and restore
+		if err := checkSnapshotTaskConflict(st, r.SetID, "check-snapshot", "restore-snapshot"); err != nil {
+			// try again on a later Ensure
+			conflicted[r.SetID] = true
+			return nil
+		}
+		// as with expired snapshots, the set leaves the state first so
+		// that a file that cannot be removed is not retried forever
+		if err := removeSnapshotState(st, r.SetID); err != nil {
+			return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", r.SetID, err)
+		}
+		if err := osRemove(r.Name()); err != nil {
+			return fmt.Errorf("cannot remove snapshot file %q: %v", r.Name(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot prune scheduled snapshots: %v", err)
+	}
+
+	// sets with no files left on disk leave the state as well
+	var gone []uint64
+	for setID := range sets {
+		if !conflicted[setID] {
+			gone = append(gone, setID)
+		}
+	}
+	return removeSnapshotState(st, gone...)
+}
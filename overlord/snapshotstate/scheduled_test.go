@@ -0,0 +1,419 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type scheduledSuite struct {
+	st      *state.State
+	mgr     *snapshotstate.SnapshotManager
+	restore []func()
+}
+
+var _ = check.Suite(&scheduledSuite{})
+
+func (s *scheduledSuite) SetUpTest(c *check.C) {
+	s.restore = []func(){
+		snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+			return map[string]*snapstate.SnapState{
+				"a-snap": {Active: true},
+				"b-snap": {Active: true},
+				"c-snap": {Active: true},
+				"d-snap": {},
+			}, nil
+		}),
+		snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, name string) (*snap.Info, error) {
+			return &snap.Info{SideInfo: snap.SideInfo{RealName: name}}, nil
+		}),
+		snapshotstate.MockSnapstateCheckChangeConflictMany(func(*state.State, []string, string) error {
+			return nil
+		}),
+	}
+
+	s.st = state.New(nil)
+	runner := state.NewTaskRunner(s.st)
+	hookMgr, err := hookstate.Manager(s.st, runner)
+	c.Assert(err, check.IsNil)
+	s.mgr = snapshotstate.Manager(s.st, hookMgr, runner)
+	// expired snapshots are not the concern of these tests
+	s.mgr.SetLastForgetExpiredSnapshotTime(time.Now())
+}
+
+func (s *scheduledSuite) TearDownTest(c *check.C) {
+	for i := len(s.restore) - 1; i >= 0; i-- {
+		s.restore[i]()
+	}
+}
+
+func (s *scheduledSuite) setConfig(c *check.C, conf map[string]string) {
+	s.st.Lock()
+	defer s.st.Unlock()
+	tr := config.NewTransaction(s.st)
+	for key, value := range conf {
+		c.Assert(tr.Set("core", key, value), check.IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *scheduledSuite) ensure(c *check.C) {
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.st.Lock()
+}
+
+func (s *scheduledSuite) TestNoSchedule(c *check.C) {
+	s.ensure(c)
+	defer s.st.Unlock()
+
+	c.Check(s.st.Changes(), check.HasLen, 0)
+	var last time.Time
+	c.Check(s.st.Get("last-scheduled-snapshot", &last), check.Equals, state.ErrNoState)
+	c.Check(s.mgr.NextScheduledSnapshot().IsZero(), check.Equals, true)
+}
+
+func (s *scheduledSuite) TestFirstEnsureAnchorsSchedule(c *check.C) {
+	s.setConfig(c, map[string]string{"snapshots.schedule": "00:00-24:00"})
+
+	before := time.Now()
+	s.ensure(c)
+	defer s.st.Unlock()
+
+	// no snapshot right away, the first one happens on the next window
+	c.Check(s.st.Changes(), check.HasLen, 0)
+	var last time.Time
+	c.Assert(s.st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Before(before), check.Equals, false)
+	c.Check(s.mgr.NextScheduledSnapshot().After(before), check.Equals, true)
+}
+
+func (s *scheduledSuite) TestScheduleChangeResetsNext(c *check.C) {
+	s.setConfig(c, map[string]string{"snapshots.schedule": "00:00-24:00"})
+	s.ensure(c)
+	s.st.Unlock()
+
+	next := time.Now().Add(time.Hour)
+	s.mgr.SetNextScheduledSnapshot(next)
+	s.ensure(c)
+	s.st.Unlock()
+	c.Check(s.mgr.NextScheduledSnapshot().Equal(next), check.Equals, true)
+
+	s.setConfig(c, map[string]string{"snapshots.schedule": "mon,10:00"})
+	s.ensure(c)
+	s.st.Unlock()
+	c.Check(s.mgr.NextScheduledSnapshot().Equal(next), check.Equals, false)
+}
+
+func (s *scheduledSuite) TestLaunchScheduledSnapshot(c *check.C) {
+	s.setConfig(c, map[string]string{
+		"snapshots.schedule":          "00:00-24:00",
+		"snapshots.scheduled.include": "a-snap,b-snap,d-snap",
+		"snapshots.scheduled.exclude": "b-snap",
+	})
+	s.st.Lock()
+	s.st.Set("last-scheduled-snapshot", time.Now().Add(-60*24*time.Hour))
+	s.st.Unlock()
+
+	before := time.Now()
+	s.ensure(c)
+	defer s.st.Unlock()
+
+	changes := s.st.Changes()
+	c.Assert(changes, check.HasLen, 1)
+	chg := changes[0]
+	c.Check(chg.Kind(), check.Equals, "scheduled-snapshot")
+	c.Check(chg.Summary(), check.Equals, "Save scheduled snapshot set #1")
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"a-snap"})
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "save-snapshot")
+	var snapshot map[string]interface{}
+	c.Assert(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["snap"], check.Equals, "a-snap")
+	c.Check(snapshot["scheduled"], check.Equals, true)
+
+	var last time.Time
+	c.Assert(s.st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Before(before), check.Equals, false)
+
+	// nothing else happens while the change is in progress
+	s.st.Unlock()
+	s.ensure(c)
+	c.Check(s.st.Changes(), check.HasLen, 1)
+}
+
+func (s *scheduledSuite) TestLaunchScheduledSnapshotPostponedOnConflict(c *check.C) {
+	s.restore = append(s.restore, snapshotstate.MockSnapstateCheckChangeConflictMany(func(*state.State, []string, string) error {
+		return &snapstate.ChangeConflictError{Snap: "a-snap", ChangeKind: "refresh-snap"}
+	}))
+	s.setConfig(c, map[string]string{"snapshots.schedule": "00:00-24:00"})
+	lastTime := time.Now().Add(-60 * 24 * time.Hour)
+	s.st.Lock()
+	s.st.Set("last-scheduled-snapshot", lastTime)
+	s.st.Unlock()
+
+	s.ensure(c)
+	defer s.st.Unlock()
+
+	c.Check(s.st.Changes(), check.HasLen, 0)
+	c.Check(s.st.AllWarnings(), check.HasLen, 0)
+	// it is tried again on the next Ensure
+	var last time.Time
+	c.Assert(s.st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Equal(lastTime), check.Equals, true)
+	c.Check(s.mgr.NextScheduledSnapshot().After(time.Now()), check.Equals, false)
+}
+
+func (s *scheduledSuite) TestLaunchScheduledSnapshotWarnsOnError(c *check.C) {
+	s.restore = append(s.restore, snapshotstate.MockSnapstateCheckChangeConflictMany(func(*state.State, []string, string) error {
+		return errors.New("bzzt")
+	}))
+	s.setConfig(c, map[string]string{"snapshots.schedule": "00:00-24:00"})
+	s.st.Lock()
+	s.st.Set("last-scheduled-snapshot", time.Now().Add(-60*24*time.Hour))
+	s.st.Unlock()
+
+	s.ensure(c)
+	defer s.st.Unlock()
+
+	c.Check(s.st.Changes(), check.HasLen, 0)
+	warnings := s.st.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Equals, "cannot take scheduled snapshot: bzzt")
+
+	// not retried until the next window
+	s.st.Unlock()
+	s.ensure(c)
+	c.Check(s.st.AllWarnings(), check.HasLen, 1)
+	c.Check(s.mgr.NextScheduledSnapshot().After(time.Now()), check.Equals, true)
+}
+
+func (s *scheduledSuite) TestWarnsOnFailedScheduledSnapshot(c *check.C) {
+	s.st.Lock()
+	chg := s.st.NewChange("scheduled-snapshot", "...")
+	t := s.st.NewTask("save-snapshot", "...")
+	t.Errorf("boom")
+	t.SetStatus(state.ErrorStatus)
+	chg.AddTask(t)
+	s.st.Unlock()
+
+	s.ensure(c)
+	warnings := s.st.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Matches, `(?s)cannot take scheduled snapshot \(change 1\): cannot perform the following tasks:.*boom.*`)
+	s.st.Unlock()
+
+	// warned only once
+	s.st.Lock()
+	s.st.OkayWarnings(time.Now())
+	s.st.Unlock()
+	s.ensure(c)
+	defer s.st.Unlock()
+	warnings, _ = s.st.PendingWarnings()
+	c.Check(warnings, check.HasLen, 0)
+}
+
+func (s *scheduledSuite) mockSnapshotFiles(c *check.C, setIDs ...uint64) (removed *[]string) {
+	dir := c.MkDir()
+	var files []*os.File
+	for _, setID := range setIDs {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("%d_a-snap_1_1.zip", setID)))
+		c.Assert(err, check.IsNil)
+		files = append(files, f)
+	}
+	s.restore = append(s.restore, snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		for i, setID := range setIDs {
+			if err := f(&backend.Reader{
+				Snapshot: client.Snapshot{SetID: setID, Snap: "a-snap"},
+				File:     files[i],
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	removed = new([]string)
+	s.restore = append(s.restore, snapshotstate.MockOsRemove(func(name string) error {
+		*removed = append(*removed, filepath.Base(name))
+		return nil
+	}))
+	s.restore = append(s.restore, func() {
+		for _, f := range files {
+			f.Close()
+		}
+	})
+	return removed
+}
+
+func (s *scheduledSuite) snapshotSetIDs(c *check.C) []int {
+	var snapshots map[uint64]interface{}
+	c.Assert(s.st.Get("snapshots", &snapshots), check.IsNil)
+	var setIDs []int
+	for setID := range snapshots {
+		setIDs = append(setIDs, int(setID))
+	}
+	sort.Ints(setIDs)
+	return setIDs
+}
+
+func (s *scheduledSuite) TestPruneByCount(c *check.C) {
+	removed := s.mockSnapshotFiles(c, 1, 2, 3, 4, 5)
+	s.setConfig(c, map[string]string{"snapshots.scheduled.retain": "2"})
+
+	now := time.Now()
+	s.st.Lock()
+	for _, setID := range []uint64{1, 2, 4, 5} {
+		c.Assert(snapshotstate.SaveScheduled(s.st, setID, now), check.IsNil)
+	}
+	// not a scheduled set
+	c.Assert(snapshotstate.SaveExpiration(s.st, 3, now.Add(time.Hour)), check.IsNil)
+	s.st.Unlock()
+
+	s.ensure(c)
+	defer s.st.Unlock()
+
+	c.Check(*removed, check.DeepEquals, []string{"1_a-snap_1_1.zip", "2_a-snap_1_1.zip"})
+	c.Check(s.snapshotSetIDs(c), check.DeepEquals, []int{3, 4, 5})
+}
+
+func (s *scheduledSuite) TestPruneByAge(c *check.C) {
+	removed := s.mockSnapshotFiles(c, 1, 2)
+	s.setConfig(c, map[string]string{"snapshots.scheduled.retention": "48h"})
+
+	now := time.Now()
+	s.st.Lock()
+	c.Assert(snapshotstate.SaveScheduled(s.st, 1, now.Add(-72*time.Hour)), check.IsNil)
+	c.Assert(snapshotstate.SaveScheduled(s.st, 2, now.Add(-24*time.Hour)), check.IsNil)
+	// set 3 is gone from disk already
+	c.Assert(snapshotstate.SaveScheduled(s.st, 3, now.Add(-96*time.Hour)), check.IsNil)
+	s.st.Unlock()
+
+	s.ensure(c)
+	defer s.st.Unlock()
+
+	c.Check(*removed, check.DeepEquals, []string{"1_a-snap_1_1.zip"})
+	c.Check(s.snapshotSetIDs(c), check.DeepEquals, []int{2})
+}
+
+func (s *scheduledSuite) TestPruneConflict(c *check.C) {
+	removed := s.mockSnapshotFiles(c, 1, 2)
+	s.setConfig(c, map[string]string{"snapshots.scheduled.retain": "1"})
+
+	now := time.Now()
+	s.st.Lock()
+	c.Assert(snapshotstate.SaveScheduled(s.st, 1, now), check.IsNil)
+	c.Assert(snapshotstate.SaveScheduled(s.st, 2, now), check.IsNil)
+	chg := s.st.NewChange("restore-snapshot", "...")
+	t := s.st.NewTask("restore-snapshot", "...")
+	t.Set("snapshot-setup", map[string]interface{}{"set-id": 1})
+	chg.AddTask(t)
+	s.st.Unlock()
+
+	s.ensure(c)
+	c.Check(*removed, check.HasLen, 0)
+	c.Check(s.snapshotSetIDs(c), check.DeepEquals, []int{1, 2})
+
+	// pruned once the restore is done
+	t.SetStatus(state.DoneStatus)
+	s.st.Unlock()
+	s.ensure(c)
+	defer s.st.Unlock()
+	c.Check(*removed, check.DeepEquals, []string{"1_a-snap_1_1.zip"})
+	c.Check(s.snapshotSetIDs(c), check.DeepEquals, []int{2})
+}
+
+func (s *scheduledSuite) TestNoPruneWhileScheduledSnapshotInFlight(c *check.C) {
+	removed := s.mockSnapshotFiles(c, 1, 2)
+	s.setConfig(c, map[string]string{"snapshots.scheduled.retain": "1"})
+
+	s.st.Lock()
+	c.Assert(snapshotstate.SaveScheduled(s.st, 1, time.Now()), check.IsNil)
+	c.Assert(snapshotstate.SaveScheduled(s.st, 2, time.Now()), check.IsNil)
+	chg := s.st.NewChange("scheduled-snapshot", "...")
+	chg.AddTask(s.st.NewTask("save-snapshot", "..."))
+	s.st.Unlock()
+
+	s.ensure(c)
+	defer s.st.Unlock()
+	c.Check(*removed, check.HasLen, 0)
+	c.Check(s.snapshotSetIDs(c), check.DeepEquals, []int{1, 2})
+}
+
+func (s *scheduledSuite) TestExpiredSnapshotSetsSkipsScheduled(c *check.C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	c.Assert(snapshotstate.SaveScheduled(s.st, 1, time.Now().Add(-time.Hour)), check.IsNil)
+	c.Assert(snapshotstate.SaveExpiration(s.st, 2, time.Now().Add(-time.Hour)), check.IsNil)
+
+	expired, err := snapshotstate.ExpiredSnapshotSets(s.st, time.Now())
+	c.Assert(err, check.IsNil)
+	c.Check(expired, check.DeepEquals, map[uint64]bool{2: true})
+}
+
+func (s *scheduledSuite) TestDoSaveRecordsScheduledSet(c *check.C) {
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+	defer snapshotstate.MockBackendSave(func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *backend.Flags) (*client.Snapshot, error) {
+		return nil, nil
+	})()
+
+	s.st.Lock()
+	task := s.st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id":    42,
+		"snap":      "a-snap",
+		"scheduled": true,
+	})
+	s.st.Unlock()
+
+	before := time.Now()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	var snapshots map[uint64]struct {
+		Scheduled *time.Time `json:"scheduled"`
+	}
+	c.Assert(s.st.Get("snapshots", &snapshots), check.IsNil)
+	c.Assert(snapshots[42].Scheduled, check.NotNil)
+	c.Check(snapshots[42].Scheduled.Before(before), check.Equals, false)
+}
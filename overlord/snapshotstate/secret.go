@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Passphrases are never written to the state: they are kept in its
+// cache, keyed by the task that needs them, until the change of the
+// task is ready. They are lost if snapd is restarted meanwhile.
+type snapshotPassphraseKey struct {
+	taskID string
+}
+
+func snapshotKeyFile(st *state.State) (string, error) {
+	var keyFile string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.encryption.key-file", &keyFile); err != nil && !config.IsNoOption(err) {
+		return "", err
+	}
+	return keyFile, nil
+}
+
+// readerSecretSource returns where the secret of the opened snapshot
+// comes from, if it is encrypted.
+func readerSecretSource(r *backend.Reader) string {
+	if r.Encryption == nil {
+		return ""
+	}
+	return r.Encryption.Secret
+}
+
+// saveSecretSource checks the secret a snapshot is to be saved with, and
+// returns where it comes from.
+// The state needs to be locked by the caller.
+func saveSecretSource(st *state.State, secret *client.SnapshotSecret) (string, error) {
+	if secret == nil {
+		return "", nil
+	}
+	if secret.KeyFile {
+		if secret.Passphrase != "" {
+			return "", fmt.Errorf("cannot encrypt snapshot with both a passphrase and the key file")
+		}
+		keyFile, err := snapshotKeyFile(st)
+		if err != nil {
+			return "", err
+		}
+		if keyFile == "" {
+			return "", fmt.Errorf("cannot encrypt snapshot with the key file: snapshots.encryption.key-file is not set")
+		}
+		return client.SnapshotSecretKeyFile, nil
+	}
+	if secret.Passphrase == "" {
+		return "", fmt.Errorf("cannot encrypt snapshot: no passphrase given")
+	}
+	return client.SnapshotSecretPassphrase, nil
+}
+
+// setPassphrase keeps the passphrase for the task to use.
+// The state needs to be locked by the caller.
+func setPassphrase(task *state.Task, passphrase string) {
+	task.State().Cache(snapshotPassphraseKey{task.ID()}, passphrase)
+}
+
+// forgetPassphrase drops the passphrase kept for the task, if any.
+// The state needs to be locked by the caller.
+func forgetPassphrase(task *state.Task) {
+	task.State().Cache(snapshotPassphraseKey{task.ID()}, nil)
+}
+
+// cleanupPassphrase is the cleanup of the tasks that might have been
+// given a passphrase: it is no longer needed once their change is ready.
+func cleanupPassphrase(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+	forgetPassphrase(task)
+	return nil
+}
+
+// snapshotSecret returns the secret to encrypt or decrypt the snapshot
+// of the given task with, according to where it comes from.
+// The state needs to be locked by the caller.
+func snapshotSecret(task *state.Task, setID uint64, source string) ([]byte, error) {
+	st := task.State()
+	switch source {
+	case "":
+		return nil, nil
+	case client.SnapshotSecretPassphrase:
+		passphrase, _ := st.Cached(snapshotPassphraseKey{task.ID()}).(string)
+		if passphrase == "" {
+			// snapd was restarted after the task was created
+			return nil, fmt.Errorf("passphrase of snapshot set #%d was lost when snapd restarted, try again", setID)
+		}
+		return []byte(passphrase), nil
+	case client.SnapshotSecretKeyFile:
+		keyFile, err := snapshotKeyFile(st)
+		if err != nil {
+			return nil, err
+		}
+		if keyFile == "" {
+			return nil, fmt.Errorf("cannot read snapshot key file: snapshots.encryption.key-file is not set")
+		}
+		secret, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read snapshot key file: %v", err)
+		}
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("cannot use snapshot key file %q: it is empty", keyFile)
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("internal error: unknown snapshot secret source %q", source)
+	}
+}
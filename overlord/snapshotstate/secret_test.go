@@ -0,0 +1,311 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func setKeyFile(st *state.State, keyFile string) {
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.encryption.key-file", keyFile)
+	tr.Commit()
+}
+
+func (snapshotSuite) TestSaveEncryptedWithPassphrase(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, taskset, err := snapshotstate.Save(st, []string{"a-snap", "b-snap"}, nil, &client.SnapshotSecret{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	for _, task := range tasks {
+		var snapshot map[string]interface{}
+		c.Check(task.Get("snapshot-setup", &snapshot), check.IsNil)
+		c.Check(snapshot["encrypt"], check.Equals, "passphrase")
+		c.Check(snapshotstate.CachedPassphrase(task), check.Equals, "sekrit")
+	}
+
+	// the passphrase is nowhere in the state
+	buf, err := json.Marshal(st)
+	c.Assert(err, check.IsNil)
+	c.Check(string(buf), check.Not(check.Matches), ".*sekrit.*")
+}
+
+func (snapshotSuite) TestSaveEncryptedWithKeyFile(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setKeyFile(st, "/etc/snapshots.key")
+
+	_, _, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, nil, &client.SnapshotSecret{KeyFile: true})
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var snapshot map[string]interface{}
+	c.Check(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["encrypt"], check.Equals, "key-file")
+	c.Check(snapshotstate.CachedPassphrase(tasks[0]), check.Equals, "")
+}
+
+func (snapshotSuite) TestSaveEncryptedErrors(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, _, err := snapshotstate.Save(st, []string{"a-snap"}, nil, &client.SnapshotSecret{})
+	c.Check(err, check.ErrorMatches, "cannot encrypt snapshot: no passphrase given")
+
+	_, _, _, err = snapshotstate.Save(st, []string{"a-snap"}, nil, &client.SnapshotSecret{KeyFile: true})
+	c.Check(err, check.ErrorMatches, "cannot encrypt snapshot with the key file: snapshots.encryption.key-file is not set")
+
+	_, _, _, err = snapshotstate.Save(st, []string{"a-snap"}, nil, &client.SnapshotSecret{KeyFile: true, Passphrase: "sekrit"})
+	c.Check(err, check.ErrorMatches, "cannot encrypt snapshot with both a passphrase and the key file")
+
+	// no set was used up
+	var lastSetID uint64
+	c.Check(st.Get("last-snapshot-set-id", &lastSetID), check.Equals, state.ErrNoState)
+}
+
+func mockEncryptedIter(c *check.C, secret string) func() {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	return snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		defer shotfile.Close()
+		for _, name := range []string{"a-snap", "b-snap"} {
+			snapshot := client.Snapshot{SetID: 42, Snap: name}
+			if name == "a-snap" {
+				snapshot.Encryption = &client.SnapshotEncryption{Secret: secret}
+			}
+			c.Assert(f(&backend.Reader{Snapshot: snapshot, File: shotfile}), check.IsNil)
+		}
+		return nil
+	})
+}
+
+func (snapshotSuite) TestCheckEncryptedNeedsPassphrase(c *check.C) {
+	defer mockEncryptedIter(c, client.SnapshotSecretPassphrase)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err := snapshotstate.Check(st, 42, nil, nil, nil)
+	c.Check(err, check.Equals, client.ErrSnapshotPassphraseRequired)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, &client.SnapshotSecret{})
+	c.Check(err, check.Equals, client.ErrSnapshotPassphraseRequired)
+
+	// only the snaps that are encrypted with it need it
+	_, _, err = snapshotstate.Check(st, 42, []string{"b-snap"}, nil, nil)
+	c.Check(err, check.IsNil)
+}
+
+func (snapshotSuite) TestCheckEncryptedWithPassphrase(c *check.C) {
+	defer mockEncryptedIter(c, client.SnapshotSecretPassphrase)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, taskset, err := snapshotstate.Check(st, 42, nil, nil, &client.SnapshotSecret{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(snapshotstate.CachedPassphrase(tasks[0]), check.Equals, "sekrit")
+	c.Check(snapshotstate.CachedPassphrase(tasks[1]), check.Equals, "")
+}
+
+func (snapshotSuite) TestCheckEncryptedWithKeyFileNeedsNoPassphrase(c *check.C) {
+	defer mockEncryptedIter(c, client.SnapshotSecretKeyFile)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, taskset, err := snapshotstate.Check(st, 42, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(taskset.Tasks(), check.HasLen, 2)
+}
+
+func (snapshotSuite) TestDoSaveEncrypted(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, snapname string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: snapname, Revision: snap.R(1)}}, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		return nil, nil
+	})()
+	var calls int
+	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]interface{}, _ []string, flags *backend.Flags) (*client.Snapshot, error) {
+		calls++
+		c.Check(string(flags.Secret), check.Equals, "sekrit")
+		c.Check(flags.SecretSource, check.Equals, "passphrase")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id":  42,
+		"snap":    "a-snap",
+		"encrypt": "passphrase",
+	})
+	snapshotstate.SetPassphrase(task, "sekrit")
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(calls, check.Equals, 1)
+
+	// the passphrase is kept for as long as the task might run again
+	st.Lock()
+	c.Check(snapshotstate.CachedPassphrase(task), check.Equals, "sekrit")
+	st.Unlock()
+	err = snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(calls, check.Equals, 2)
+
+	// and dropped once the change is ready
+	c.Assert(snapshotstate.CleanupPassphrase(task, &tomb.Tomb{}), check.IsNil)
+	st.Lock()
+	c.Check(snapshotstate.CachedPassphrase(task), check.Equals, "")
+	st.Unlock()
+}
+
+func (snapshotSuite) TestDoSaveEncryptedAfterRestart(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, snapname string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: snapname, Revision: snap.R(1)}}, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		return nil, nil
+	})()
+	defer snapshotstate.MockBackendSave(func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *backend.Flags) (*client.Snapshot, error) {
+		c.Fatal("unexpected call to backend.Save")
+		return nil, nil
+	})()
+
+	// a task of a change that was started before snapd restarted
+	// does not have its passphrase anymore
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id":  42,
+		"snap":    "a-snap",
+		"encrypt": "passphrase",
+	})
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, `passphrase of snapshot set #42 was lost when snapd restarted, try again`)
+}
+
+func (rs *readerSuite) TestDoRestoreEncrypted(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{Encryption: &client.SnapshotEncryption{Secret: "passphrase"}},
+		}, nil
+	})()
+	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, _ snap.Revision, _ []string, secret []byte, _ backend.Logf) (*backend.RestoreState, error) {
+		rs.calls = append(rs.calls, "restore")
+		c.Check(string(secret), check.Equals, "sekrit")
+		return &backend.RestoreState{}, nil
+	})()
+
+	st := rs.task.State()
+	st.Lock()
+	snapshotstate.SetPassphrase(rs.task, "sekrit")
+	st.Unlock()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "restore", "set config"})
+
+	// the passphrase is dropped on cleanup
+	c.Assert(snapshotstate.CleanupRestore(rs.task, &tomb.Tomb{}), check.IsNil)
+	st.Lock()
+	c.Check(snapshotstate.CachedPassphrase(rs.task), check.Equals, "")
+	st.Unlock()
+}
+
+func (rs *readerSuite) TestDoRestoreEncryptedAfterRestart(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Encryption: &client.SnapshotEncryption{Secret: "passphrase"}},
+		}, nil
+	})()
+	defer snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, []byte, backend.Logf) (*backend.RestoreState, error) {
+		rs.calls = append(rs.calls, "restore")
+		return &backend.RestoreState{}, nil
+	})()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, `passphrase of snapshot set #\d+ was lost when snapd restarted, try again`)
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open"})
+}
+
+func (rs *readerSuite) TestDoCheckEncryptedWithKeyFile(c *check.C) {
+	keyFile := filepath.Join(c.MkDir(), "snapshots.key")
+	c.Assert(ioutil.WriteFile(keyFile, []byte("very secret key"), 0600), check.IsNil)
+
+	st := rs.task.State()
+	st.Lock()
+	setKeyFile(st, keyFile)
+	st.Unlock()
+
+	defer snapshotstate.MockBackendOpen(func(string) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{Encryption: &client.SnapshotEncryption{Secret: "key-file"}},
+		}, nil
+	})()
+	defer snapshotstate.MockBackendCheck(func(_ *backend.Reader, _ context.Context, _ []string, secret []byte) error {
+		rs.calls = append(rs.calls, "check")
+		c.Check(string(secret), check.Equals, "very secret key")
+		return nil
+	})()
+
+	err := snapshotstate.DoCheck(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"open", "check"})
+
+	// without the key file it fails clearly
+	c.Assert(os.Remove(keyFile), check.IsNil)
+	err = snapshotstate.DoCheck(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, `cannot read snapshot key file: open .*/snapshots.key: no such file or directory`)
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -40,6 +41,7 @@ import (
 var (
 	osRemove             = os.Remove
 	snapstateCurrentInfo = snapstate.CurrentInfo
+	snapReadSnapshotYaml = snap.ReadSnapshotYaml
 	configGetSnapConfig  = config.GetSnapConfig
 	configSetSnapConfig  = config.SetSnapConfig
 	backendOpen          = backend.Open
@@ -57,15 +59,21 @@ type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+
+	lastSnapshotSchedule  string
+	nextScheduledSnapshot time.Time
 }
 
 // Manager returns a new SnapshotManager
-func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
+func Manager(st *state.State, hookMgr *hookstate.HookManager, runner *state.TaskRunner) *SnapshotManager {
 	delayedCrossMgrInit()
+	setupHooks(hookMgr)
 
 	runner.AddHandler("save-snapshot", doSave, doForget)
+	runner.AddCleanup("save-snapshot", cleanupPassphrase)
 	runner.AddHandler("forget-snapshot", doForget, nil)
 	runner.AddHandler("check-snapshot", doCheck, nil)
+	runner.AddCleanup("check-snapshot", cleanupPassphrase)
 	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
 	runner.AddCleanup("restore-snapshot", cleanupRestore)
 
@@ -79,6 +87,9 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 
 // Ensure is part of the overlord.StateManager interface.
 func (mgr *SnapshotManager) Ensure() error {
+	if err := mgr.ensureScheduledSnapshots(); err != nil {
+		return err
+	}
 	// process expired snapshots once a day.
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
 		return mgr.forgetExpiredSnapshots()
@@ -154,6 +165,13 @@ type snapshotSetup struct {
 	Filename string        `json:"filename,omitempty"`
 	Current  snap.Revision `json:"current"`
 	Auto     bool          `json:"auto,omitempty"`
+	Hooks    []string      `json:"hooks,omitempty"`
+	Exclude  []string      `json:"exclude,omitempty"`
+	// set for the snapshots taken on the snapshots.schedule
+	Scheduled bool `json:"scheduled,omitempty"`
+	// where the secret to encrypt the snapshot with comes from, if it
+	// is to be encrypted (the secret itself is never kept here)
+	Encrypt string `json:"encrypt,omitempty"`
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -168,29 +186,44 @@ func filename(setID uint64, si *snap.Info) string {
 
 // prepareSave does all the steps of doSave that require the state lock;
 // it has no real significance beyond making the lock handling simpler
-func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg map[string]interface{}, err error) {
+func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg map[string]interface{}, secret []byte, err error) {
 	st := task.State()
 	st.Lock()
 	defer st.Unlock()
 
 	if err := task.Get("snapshot-setup", &snapshot); err != nil {
-		return nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
+		return nil, nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
 	}
 	cur, err = snapstateCurrentInfo(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
-	// updating snapshot-setup with the filename, for use in undo
+	secret, err = snapshotSecret(task, snapshot.SetID, snapshot.Encrypt)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	// updating snapshot-setup with the filename, for use in undo, and
+	// with the hooks that ran and the paths the snap asked to leave
+	// out, for the snapshot metadata
 	snapshot.Filename = filename(snapshot.SetID, cur)
+	snapshot.Hooks, err = hooksRunBefore(task)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	opts, err := snapReadSnapshotYaml(cur)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	snapshot.Exclude = opts.Exclude
 	task.Set("snapshot-setup", &snapshot)
 
 	rawCfg, err := configGetSnapConfig(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	if rawCfg != nil {
 		if err := json.Unmarshal(*rawCfg, &cfg); err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 	}
 
@@ -198,22 +231,51 @@ func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg
 	if snapshot.Auto {
 		expiration, err := AutomaticSnapshotExpiration(st)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 		if err := saveExpiration(st, snapshot.SetID, time.Now().Add(expiration)); err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
+		}
+	}
+	if snapshot.Scheduled {
+		if err := saveScheduled(st, snapshot.SetID, time.Now()); err != nil {
+			return nil, nil, nil, nil, err
 		}
 	}
 
-	return snapshot, cur, cfg, nil
+	return snapshot, cur, cfg, secret, nil
+}
+
+// hooksRunBefore returns the snap hooks that ran successfully right
+// before the given task.
+func hooksRunBefore(task *state.Task) ([]string, error) {
+	var hooks []string
+	for _, t := range task.WaitTasks() {
+		if t.Kind() != "run-hook" || t.Status() != state.DoneStatus {
+			continue
+		}
+		var hooksup hookstate.HookSetup
+		if err := t.Get("hook-setup", &hooksup); err != nil {
+			return nil, taskGetErrMsg(t, err, "hook")
+		}
+		hooks = append(hooks, hooksup.Hook)
+	}
+	return hooks, nil
 }
 
 func doSave(task *state.Task, tomb *tomb.Tomb) error {
-	snapshot, cur, cfg, err := prepareSave(task)
+	snapshot, cur, cfg, secret, err := prepareSave(task)
 	if err != nil {
 		return err
 	}
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, &backend.Flags{Auto: snapshot.Auto})
+	flags := &backend.Flags{
+		Auto:         snapshot.Auto,
+		Hooks:        snapshot.Hooks,
+		Exclude:      snapshot.Exclude,
+		Secret:       secret,
+		SecretSource: snapshot.Encrypt,
+	}
+	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, flags)
 	if err != nil {
 		st := task.State()
 		st.Lock()
@@ -225,38 +287,43 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 
 // prepareRestore does the steps of doRestore that require the state lock
 // before the backend Restore call.
-func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[string]interface{}, reader *backend.Reader, err error) {
+func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[string]interface{}, reader *backend.Reader, secret []byte, err error) {
 	st := task.State()
 
 	st.Lock()
 	defer st.Unlock()
 
 	if err := task.Get("snapshot-setup", &snapshot); err != nil {
-		return nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
+		return nil, nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
 	}
 
 	rawCfg, err := configGetSnapConfig(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("internal error: cannot obtain current snap config for snapshot restore: %v", err)
+		return nil, nil, nil, nil, fmt.Errorf("internal error: cannot obtain current snap config for snapshot restore: %v", err)
 	}
 
 	if rawCfg != nil {
 		if err := json.Unmarshal(*rawCfg, &oldCfg); err != nil {
-			return nil, nil, nil, fmt.Errorf("internal error: cannot decode current snap config: %v", err)
+			return nil, nil, nil, nil, fmt.Errorf("internal error: cannot decode current snap config: %v", err)
 		}
 	}
 
 	reader, err = backendOpen(snapshot.Filename)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("cannot open snapshot: %v", err)
+		return nil, nil, nil, nil, fmt.Errorf("cannot open snapshot: %v", err)
+	}
+	secret, err = snapshotSecret(task, snapshot.SetID, readerSecretSource(reader))
+	if err != nil {
+		reader.Close()
+		return nil, nil, nil, nil, err
 	}
 	// note given the Open succeeded, caller needs to close it when done
 
-	return snapshot, oldCfg, reader, nil
+	return snapshot, oldCfg, reader, secret, nil
 }
 
 func doRestore(task *state.Task, tomb *tomb.Tomb) error {
-	snapshot, oldCfg, reader, err := prepareRestore(task)
+	snapshot, oldCfg, reader, secret, err := prepareRestore(task)
 	if err != nil {
 		return err
 	}
@@ -269,7 +336,7 @@ func doRestore(task *state.Task, tomb *tomb.Tomb) error {
 		task.Logf(format, args...)
 	}
 
-	restoreState, err := backendRestore(reader, tomb.Context(nil), snapshot.Current, snapshot.Users, logf)
+	restoreState, err := backendRestore(reader, tomb.Context(nil), snapshot.Current, snapshot.Users, secret, logf)
 	if err != nil {
 		return err
 	}
@@ -328,6 +395,7 @@ func cleanupRestore(task *state.Task, _ *tomb.Tomb) error {
 
 	st := task.State()
 	st.Lock()
+	forgetPassphrase(task)
 	status := task.Status()
 	err := task.Get("restore-state", &restoreState)
 	st.Unlock()
@@ -367,7 +435,14 @@ func doCheck(task *state.Task, tomb *tomb.Tomb) error {
 	}
 	defer reader.Close()
 
-	return backendCheck(reader, tomb.Context(nil), snapshot.Users)
+	st.Lock()
+	secret, err := snapshotSecret(task, snapshot.SetID, readerSecretSource(reader))
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return backendCheck(reader, tomb.Context(nil), snapshot.Users, secret)
 }
 
 func doForget(task *state.Task, _ *tomb.Tomb) error {
@@ -33,6 +33,7 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
@@ -44,14 +45,18 @@ func (snapshotSuite) TestManager(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 	runner := state.NewTaskRunner(st)
-	mgr := snapshotstate.Manager(st, runner)
+	hookMgr, err := hookstate.Manager(st, runner)
+	c.Assert(err, check.IsNil)
+	mgr := snapshotstate.Manager(st, hookMgr, runner)
 	c.Assert(mgr, check.NotNil)
 	kinds := runner.KnownTaskKinds()
 	sort.Strings(kinds)
 	c.Check(kinds, check.DeepEquals, []string{
 		"check-snapshot",
+		"configure-snapd",
 		"forget-snapshot",
 		"restore-snapshot",
+		"run-hook",
 		"save-snapshot",
 	})
 }
@@ -89,7 +94,9 @@ func (snapshotSuite) TestEnsureForgetsSnapshots(c *check.C) {
 
 	st := state.New(nil)
 	runner := state.NewTaskRunner(st)
-	mgr := snapshotstate.Manager(st, runner)
+	hookMgr, err := hookstate.Manager(st, runner)
+	c.Assert(err, check.IsNil)
+	mgr := snapshotstate.Manager(st, hookMgr, runner)
 	c.Assert(mgr, check.NotNil)
 
 	st.Lock()
@@ -134,7 +141,9 @@ func (snapshotSuite) TestEnsureForgetsSnapshotsRunsRegularly(c *check.C) {
 
 	st := state.New(nil)
 	runner := state.NewTaskRunner(st)
-	mgr := snapshotstate.Manager(st, runner)
+	hookMgr, err := hookstate.Manager(st, runner)
+	c.Assert(err, check.IsNil)
+	mgr := snapshotstate.Manager(st, hookMgr, runner)
 	c.Assert(mgr, check.NotNil)
 
 	storeExpiredSnapshot := func() {
@@ -177,7 +186,9 @@ func (snapshotSuite) testEnsureForgetSnapshotsConflict(c *check.C, snapshotTaskK
 
 	st := state.New(nil)
 	runner := state.NewTaskRunner(st)
-	mgr := snapshotstate.Manager(st, runner)
+	hookMgr, err := hookstate.Manager(st, runner)
+	c.Assert(err, check.IsNil)
+	mgr := snapshotstate.Manager(st, hookMgr, runner)
 	c.Assert(mgr, check.NotNil)
 
 	st.Lock()
@@ -266,6 +277,7 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 		c.Check(cfg, check.DeepEquals, map[string]interface{}{"hello": "there"})
 		c.Check(usernames, check.DeepEquals, []string{"a-user", "b-user"})
 		c.Check(flags.Auto, check.Equals, false)
+		c.Check(flags.Hooks, check.HasLen, 0)
 		return nil, nil
 	})()
 
@@ -282,6 +294,120 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (snapshotSuite) TestDoSaveRecordsHooks(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snapInfo, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+	var hooks []string
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.Flags) (*client.Snapshot, error) {
+		hooks = flags.Hooks
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	pre := st.NewTask("run-hook", "...")
+	pre.Set("hook-setup", map[string]interface{}{"snap": "a-snap", "hook": "pre-snapshot"})
+	pre.SetStatus(state.DoneStatus)
+	task.WaitFor(pre)
+	// tasks of other kinds are not hooks
+	other := st.NewTask("foo", "...")
+	other.SetStatus(state.DoneStatus)
+	task.WaitFor(other)
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(hooks, check.DeepEquals, []string{"pre-snapshot"})
+
+	st.Lock()
+	defer st.Unlock()
+	var snapshot map[string]interface{}
+	c.Assert(task.Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["hooks"], check.DeepEquals, []interface{}{"pre-snapshot"})
+}
+
+func (snapshotSuite) TestDoSaveHonorsExclusions(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snapInfo, nil
+	})()
+	defer snapshotstate.MockSnapReadSnapshotYaml(func(info *snap.Info) (*snap.SnapshotOptions, error) {
+		c.Check(info, check.Equals, &snapInfo)
+		return &snap.SnapshotOptions{Exclude: []string{"$SNAP_DATA/cache/*"}}, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+	var exclude []string
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.Flags) (*client.Snapshot, error) {
+		exclude = flags.Exclude
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(exclude, check.DeepEquals, []string{"$SNAP_DATA/cache/*"})
+
+	st.Lock()
+	defer st.Unlock()
+	var snapshot map[string]interface{}
+	c.Assert(task.Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["exclude"], check.DeepEquals, []interface{}{"$SNAP_DATA/cache/*"})
+}
+
+func (snapshotSuite) TestDoSaveFailsWithBadSnapshotYaml(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: "a-snap", Revision: snap.R(-1)}}, nil
+	})()
+	defer snapshotstate.MockSnapReadSnapshotYaml(func(*snap.Info) (*snap.SnapshotOptions, error) {
+		return nil, errors.New("bzzt")
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.Flags) (*client.Snapshot, error) {
+		c.Fatal("should not be reached")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "bzzt")
+}
+
 func (snapshotSuite) TestDoSaveFailsWithNoSnap(c *check.C) {
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
 		return nil, errors.New("bzzt")
@@ -501,11 +627,11 @@ func (rs *readerSuite) SetUpTest(c *check.C) {
 			rs.calls = append(rs.calls, "open")
 			return &backend.Reader{}, nil
 		}),
-		snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, backend.Logf) (*backend.RestoreState, error) {
+		snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, []byte, backend.Logf) (*backend.RestoreState, error) {
 			rs.calls = append(rs.calls, "restore")
 			return &backend.RestoreState{}, nil
 		}),
-		snapshotstate.MockBackendCheck(func(*backend.Reader, context.Context, []string) error {
+		snapshotstate.MockBackendCheck(func(*backend.Reader, context.Context, []string, []byte) error {
 			rs.calls = append(rs.calls, "check")
 			return nil
 		}),
@@ -538,7 +664,7 @@ func (rs *readerSuite) TestDoRestore(c *check.C) {
 			Snapshot: client.Snapshot{Conf: map[string]interface{}{"hello": "there"}},
 		}, nil
 	})()
-	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, _ snap.Revision, users []string, _ backend.Logf) (*backend.RestoreState, error) {
+	defer snapshotstate.MockBackendRestore(func(_ *backend.Reader, _ context.Context, _ snap.Revision, users []string, _ []byte, _ backend.Logf) (*backend.RestoreState, error) {
 		rs.calls = append(rs.calls, "restore")
 		c.Check(users, check.DeepEquals, []string{"a-user", "b-user"})
 		return &backend.RestoreState{}, nil
@@ -621,7 +747,7 @@ func (rs *readerSuite) TestDoRestoreFailsUnserialisableSnapshotConfigError(c *ch
 }
 
 func (rs *readerSuite) TestDoRestoreFailsOnRestoreError(c *check.C) {
-	defer snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, backend.Logf) (*backend.RestoreState, error) {
+	defer snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, []byte, backend.Logf) (*backend.RestoreState, error) {
 		rs.calls = append(rs.calls, "restore")
 		return nil, errors.New("bzzt")
 	})()
@@ -682,7 +808,7 @@ func (rs *readerSuite) TestDoCheck(c *check.C) {
 			Snapshot: client.Snapshot{Conf: map[string]interface{}{"hello": "there"}},
 		}, nil
 	})()
-	defer snapshotstate.MockBackendCheck(func(_ *backend.Reader, _ context.Context, users []string) error {
+	defer snapshotstate.MockBackendCheck(func(_ *backend.Reader, _ context.Context, users []string, _ []byte) error {
 		rs.calls = append(rs.calls, "check")
 		c.Check(users, check.DeepEquals, []string{"a-user", "b-user"})
 		return nil
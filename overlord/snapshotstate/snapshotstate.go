@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -47,6 +48,10 @@ var (
 
 type snapshotState struct {
 	ExpiryTime time.Time `json:"expiry-time"`
+	// Scheduled is when the set was taken, for the sets taken on the
+	// snapshots.schedule; these are pruned according to the scheduled
+	// retention policy instead of expiring.
+	Scheduled *time.Time `json:"scheduled,omitempty"`
 }
 
 func newSnapshotSetID(st *state.State) (uint64, error) {
@@ -100,9 +105,97 @@ func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
 	return defaultAutomaticSnapshotExpiration, nil
 }
 
+// hookTimeout returns the timeout for snapshot hooks set by the user, or
+// zero if the hooks should use the default timeout.
+func hookTimeout(st *state.State) (time.Duration, error) {
+	var timeoutStr string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "snapshots.hook-timeout", &timeoutStr)
+	if err != nil && !config.IsNoOption(err) {
+		return 0, err
+	}
+	if err == nil {
+		dur, err := time.ParseDuration(timeoutStr)
+		if err == nil {
+			return dur, nil
+		}
+		logger.Noticef("snapshots.hook-timeout cannot be parsed: %v", err)
+	}
+	return 0, nil
+}
+
+func snapshotHookSetup(snapName, hook string, timeout time.Duration) *hookstate.HookSetup {
+	return &hookstate.HookSetup{
+		Snap:     snapName,
+		Hook:     hook,
+		Optional: true,
+		Timeout:  timeout,
+	}
+}
+
+// addSaveHooks surrounds the given save task with the snapshot hooks of
+// the snap, if it has any. The pre-snapshot hook runs first, and if it
+// fails the snapshot is not taken. The post-snapshot hook runs after the
+// save, and also as undo of the pre-snapshot hook if the save fails.
+// Undoing the post-snapshot hook runs the pre-snapshot hook again, so
+// that undoing the whole change leaves the snap as post-snapshot left it.
+func addSaveHooks(st *state.State, ts *state.TaskSet, name string, save *state.Task) error {
+	info, err := snapstateCurrentInfo(st, name)
+	if err != nil {
+		// saving will fail with a better error
+		return nil
+	}
+	hasPre := info.Hooks["pre-snapshot"] != nil
+	hasPost := info.Hooks["post-snapshot"] != nil
+	if !hasPre && !hasPost {
+		return nil
+	}
+	timeout, err := hookTimeout(st)
+	if err != nil {
+		return err
+	}
+
+	var preSetup, postSetup *hookstate.HookSetup
+	if hasPre {
+		preSetup = snapshotHookSetup(name, "pre-snapshot", timeout)
+	}
+	if hasPost {
+		postSetup = snapshotHookSetup(name, "post-snapshot", timeout)
+	}
+
+	if hasPre {
+		summary := fmt.Sprintf("Run pre-snapshot hook of snap %q", name)
+		pre := hookstate.HookTaskWithUndo(st, summary, preSetup, postSetup, nil)
+		save.WaitFor(pre)
+		ts.AddTask(pre)
+	}
+	if hasPost {
+		summary := fmt.Sprintf("Run post-snapshot hook of snap %q", name)
+		post := hookstate.HookTaskWithUndo(st, summary, postSetup, preSetup, nil)
+		post.WaitFor(save)
+		ts.AddTask(post)
+	}
+	return nil
+}
+
 // saveExpiration saves expiration date of the given snapshot set, in the state.
 // The state needs to be locked by the caller.
 func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
+	return setSnapshotState(st, setID, &snapshotState{
+		ExpiryTime: expiryTime,
+	})
+}
+
+// saveScheduled records the given snapshot set as taken on the snapshots
+// schedule at the given time, in the state.
+// The state needs to be locked by the caller.
+func saveScheduled(st *state.State, setID uint64, t time.Time) error {
+	return setSnapshotState(st, setID, &snapshotState{
+		Scheduled: &t,
+	})
+}
+
+func setSnapshotState(st *state.State, setID uint64, snapshotSet *snapshotState) error {
 	var snapshots map[uint64]*json.RawMessage
 	err := st.Get("snapshots", &snapshots)
 	if err != nil && err != state.ErrNoState {
@@ -111,9 +204,7 @@ func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
 	if snapshots == nil {
 		snapshots = make(map[uint64]*json.RawMessage)
 	}
-	data, err := json.Marshal(&snapshotState{
-		ExpiryTime: expiryTime,
-	})
+	data, err := json.Marshal(snapshotSet)
 	if err != nil {
 		return err
 	}
@@ -156,6 +247,11 @@ func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool
 
 	expired := make(map[uint64]bool)
 	for setID, snapshotSet := range snapshots {
+		// scheduled sets have no expiry time, they are pruned by
+		// pruneScheduledSnapshots instead
+		if snapshotSet.Scheduled != nil {
+			continue
+		}
 		if snapshotSet.ExpiryTime.Before(cutoffTime) {
 			expired[setID] = true
 		}
@@ -181,6 +277,9 @@ type snapshotSnapSummary struct {
 	snapID   string
 	filename string
 	epoch    snap.Epoch
+	// where the secret the snapshot is encrypted with comes from,
+	// if it is encrypted
+	secret string
 }
 
 // snapSummariesInSnapshotSet goes looking for the requested snaps in the
@@ -192,12 +291,16 @@ func snapSummariesInSnapshotSet(setID uint64, requested []string) (summaries sna
 		if r.SetID == setID {
 			found = true
 			if len(requested) == 0 || strutil.SortedListContains(requested, r.Snap) {
-				summaries = append(summaries, &snapshotSnapSummary{
+				summary := &snapshotSnapSummary{
 					filename: r.Name(),
 					snap:     r.Snap,
 					snapID:   r.SnapID,
 					epoch:    r.Epoch,
-				})
+				}
+				if r.Encryption != nil {
+					summary.secret = r.Encryption.Secret
+				}
+				summaries = append(summaries, summary)
 			}
 		}
 
@@ -216,6 +319,20 @@ func snapSummariesInSnapshotSet(setID uint64, requested []string) (summaries sna
 	return summaries, nil
 }
 
+// passphrase returns the passphrase given for the snapshots, failing if
+// any of them is encrypted with one and none was given.
+func (summaries snapshotSnapSummaries) passphrase(secret *client.SnapshotSecret) (string, error) {
+	if secret != nil && secret.Passphrase != "" {
+		return secret.Passphrase, nil
+	}
+	for _, summary := range summaries {
+		if summary.secret == client.SnapshotSecretPassphrase {
+			return "", client.ErrSnapshotPassphraseRequired
+		}
+	}
+	return "", nil
+}
+
 func taskGetErrMsg(task *state.Task, err error, what string) error {
 	if err == state.ErrNoState {
 		return fmt.Errorf("internal error: task %s (%s) is missing %s information", task.ID(), task.Kind(), what)
@@ -250,9 +367,19 @@ func checkSnapshotTaskConflict(st *state.State, setID uint64, conflictingKinds .
 // Note that the state must be locked by the caller.
 var List = backend.List
 
-// Save creates a taskset for taking snapshots of snaps' data.
+// Save creates a taskset for taking snapshots of snaps' data; if secret
+// is not nil, the snapshots are encrypted with it.
 // Note that the state must be locked by the caller.
-func Save(st *state.State, instanceNames []string, users []string) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+func Save(st *state.State, instanceNames []string, users []string, secret *client.SnapshotSecret) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	return save(st, instanceNames, users, secret, false)
+}
+
+func save(st *state.State, instanceNames []string, users []string, secret *client.SnapshotSecret, scheduled bool) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	encrypt, err := saveSecretSource(st, secret)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
 	if len(instanceNames) == 0 {
 		instanceNames, err = allActiveSnapNames(st)
 		if err != nil {
@@ -276,11 +403,19 @@ func Save(st *state.State, instanceNames []string, users []string) (setID uint64
 		desc := fmt.Sprintf("Save data of snap %q in snapshot set #%d", name, setID)
 		task := st.NewTask("save-snapshot", desc)
 		snapshot := snapshotSetup{
-			SetID: setID,
-			Snap:  name,
-			Users: users,
+			SetID:     setID,
+			Snap:      name,
+			Users:     users,
+			Scheduled: scheduled,
+			Encrypt:   encrypt,
 		}
 		task.Set("snapshot-setup", &snapshot)
+		if encrypt == client.SnapshotSecretPassphrase {
+			setPassphrase(task, secret.Passphrase)
+		}
+		if err := addSaveHooks(st, ts, name, task); err != nil {
+			return 0, nil, nil, err
+		}
 		// Here, note that a snapshot set behaves as a unit: it either
 		// succeeds, or fails, as a whole; we don't use lanes, to have
 		// some snaps' snapshot succeed and not others in a single set.
@@ -311,6 +446,8 @@ func AutomaticSnapshot(st *state.State, snapName string) (ts *state.TaskSet, err
 		return nil, err
 	}
 
+	// no snapshot hooks here: the snap is being removed and its
+	// services are already stopped
 	ts = state.NewTaskSet()
 	desc := fmt.Sprintf("Save data of snap %q in automatic snapshot set #%d", snapName, setID)
 	task := st.NewTask("save-snapshot", desc)
@@ -325,13 +462,18 @@ func AutomaticSnapshot(st *state.State, snapName string) (ts *state.TaskSet, err
 	return ts, nil
 }
 
-// Restore creates a taskset for restoring a snapshot's data.
+// Restore creates a taskset for restoring a snapshot's data; the secret
+// is needed if the snapshots are encrypted with a passphrase.
 // Note that the state must be locked by the caller.
-func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+func Restore(st *state.State, setID uint64, snapNames []string, users []string, secret *client.SnapshotSecret) (snapsFound []string, ts *state.TaskSet, err error) {
 	summaries, err := snapSummariesInSnapshotSet(setID, snapNames)
 	if err != nil {
 		return nil, nil, err
 	}
+	passphrase, err := summaries.passphrase(secret)
+	if err != nil {
+		return nil, nil, err
+	}
 	all, err := snapstateAll(st)
 	if err != nil {
 		return nil, nil, err
@@ -352,6 +494,7 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 
 	for _, summary := range summaries {
 		var current snap.Revision
+		var hasPostRestore bool
 		if snapst, ok := all[summary.snap]; ok {
 			info, err := snapst.CurrentInfo()
 			if err != nil {
@@ -367,6 +510,7 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 				return nil, nil, fmt.Errorf(tpl, summary.snap, info.SnapID, summary.snapID)
 			}
 			current = snapst.Current
+			hasPostRestore = info.Hooks["post-restore"] != nil
 		}
 
 		desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d", summary.snap, setID)
@@ -379,16 +523,33 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 			Current:  current,
 		}
 		task.Set("snapshot-setup", &snapshot)
+		if summary.secret == client.SnapshotSecretPassphrase {
+			setPassphrase(task, passphrase)
+		}
 		// see the note about snapshots not using lanes, above.
 		ts.AddTask(task)
+
+		if hasPostRestore {
+			// the hook can migrate the restored data; if it fails,
+			// the restore is undone
+			timeout, err := hookTimeout(st)
+			if err != nil {
+				return nil, nil, err
+			}
+			hookSummary := fmt.Sprintf("Run post-restore hook of snap %q", summary.snap)
+			post := hookstate.HookTask(st, hookSummary, snapshotHookSetup(summary.snap, "post-restore", timeout), nil)
+			post.WaitFor(task)
+			ts.AddTask(post)
+		}
 	}
 
 	return snapsFound, ts, nil
 }
 
-// Check creates a taskset for checking a snapshot's data.
+// Check creates a taskset for checking a snapshot's data; the secret is
+// needed if the snapshots are encrypted with a passphrase.
 // Note that the state must be locked by the caller.
-func Check(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+func Check(st *state.State, setID uint64, snapNames []string, users []string, secret *client.SnapshotSecret) (snapsFound []string, ts *state.TaskSet, err error) {
 	// check needs to conflict with forget of itself
 	if err := checkSnapshotTaskConflict(st, setID, "forget-snapshot"); err != nil {
 		return nil, nil, err
@@ -398,6 +559,10 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 	if err != nil {
 		return nil, nil, err
 	}
+	passphrase, err := summaries.passphrase(secret)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	ts = state.NewTaskSet()
 
@@ -411,6 +576,9 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 			Filename: summary.filename,
 		}
 		task.Set("snapshot-setup", &snapshot)
+		if summary.secret == client.SnapshotSecretPassphrase {
+			setPassphrase(task, passphrase)
+		}
 		ts.AddTask(task)
 	}
 
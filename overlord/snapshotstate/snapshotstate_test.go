@@ -33,12 +33,14 @@ import (
 	"time"
 
 	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -292,7 +294,7 @@ func (snapshotSuite) TestSaveChecksSnapnamesError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -317,7 +319,10 @@ func (snapshotSuite) createConflictingChange(c *check.C) (st *state.State, resto
 	stmgr, err := snapstate.Manager(st, o.TaskRunner())
 	c.Assert(err, check.IsNil)
 	o.AddManager(stmgr)
-	shmgr := snapshotstate.Manager(st, o.TaskRunner())
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
 	o.AddManager(shmgr)
 
 	st.Lock()
@@ -354,7 +359,7 @@ func (s snapshotSuite) TestSaveChecksSnapstateConflict(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil)
+	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil, nil)
 	c.Assert(err, check.NotNil)
 	c.Check(err, check.FitsTypeOf, &snapstate.ChangeConflictError{})
 }
@@ -374,7 +379,10 @@ func (snapshotSuite) TestSaveConflictsWithSnapstate(c *check.C) {
 	stmgr, err := snapstate.Manager(st, o.TaskRunner())
 	c.Assert(err, check.IsNil)
 	o.AddManager(stmgr)
-	shmgr := snapshotstate.Manager(st, o.TaskRunner())
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
 	o.AddManager(shmgr)
 
 	st.Lock()
@@ -390,7 +398,7 @@ func (snapshotSuite) TestSaveConflictsWithSnapstate(c *check.C) {
 	})
 
 	chg := st.NewChange("snapshot-save", "...")
-	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil)
+	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	chg.AddAll(saveTasks)
 
@@ -406,7 +414,7 @@ func (snapshotSuite) TestSaveChecksSnapstateConflictError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -417,7 +425,7 @@ func (snapshotSuite) TestSaveChecksSetIDError(c *check.C) {
 
 	st.Set("last-snapshot-set-id", "3/4")
 
-	_, _, _, err := snapshotstate.Save(st, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, ".* could not unmarshal .*")
 }
 
@@ -426,7 +434,7 @@ func (snapshotSuite) TestSaveNoSnapsInState(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.HasLen, 0)
@@ -448,7 +456,7 @@ func (snapshotSuite) TestSaveSomeSnaps(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap", "c-snap"})
@@ -472,7 +480,7 @@ func (snapshotSuite) TestSaveOneSnap(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"})
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap"})
@@ -490,6 +498,83 @@ func (snapshotSuite) TestSaveOneSnap(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestSaveWithHooks(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, name string) (*snap.Info, error) {
+		info := &snap.Info{SideInfo: snap.SideInfo{RealName: name}}
+		info.Hooks = map[string]*snap.HookInfo{
+			"pre-snapshot":  {Snap: info, Name: "pre-snapshot"},
+			"post-snapshot": {Snap: info, Name: "post-snapshot"},
+		}
+		return info, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "snapshots.hook-timeout", "2m"), check.IsNil)
+	tr.Commit()
+
+	_, _, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 3)
+	pre, post, save := tasks[0], tasks[1], tasks[2]
+	c.Check(save.Kind(), check.Equals, "save-snapshot")
+	c.Check(pre.Kind(), check.Equals, "run-hook")
+	c.Check(pre.Summary(), check.Equals, `Run pre-snapshot hook of snap "a-snap"`)
+	c.Check(post.Kind(), check.Equals, "run-hook")
+	c.Check(post.Summary(), check.Equals, `Run post-snapshot hook of snap "a-snap"`)
+	c.Check(save.WaitTasks(), check.DeepEquals, []*state.Task{pre})
+	c.Check(post.WaitTasks(), check.DeepEquals, []*state.Task{save})
+
+	preSetup := &hookstate.HookSetup{Snap: "a-snap", Hook: "pre-snapshot", Optional: true, Timeout: 2 * time.Minute}
+	postSetup := &hookstate.HookSetup{Snap: "a-snap", Hook: "post-snapshot", Optional: true, Timeout: 2 * time.Minute}
+	for _, t := range []struct {
+		task      *state.Task
+		setup     *hookstate.HookSetup
+		undoSetup *hookstate.HookSetup
+	}{
+		// a failed save resumes the snap
+		{pre, preSetup, postSetup},
+		// and undoing a done snapshot goes through both hooks again
+		{post, postSetup, preSetup},
+	} {
+		var setup, undoSetup hookstate.HookSetup
+		c.Assert(t.task.Get("hook-setup", &setup), check.IsNil)
+		c.Check(&setup, check.DeepEquals, t.setup)
+		c.Assert(t.task.Get("undo-hook-setup", &undoSetup), check.IsNil)
+		c.Check(&undoSetup, check.DeepEquals, t.undoSetup)
+	}
+}
+
+func (snapshotSuite) TestSaveWithPreSnapshotHookOnly(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(_ *state.State, name string) (*snap.Info, error) {
+		info := &snap.Info{SideInfo: snap.SideInfo{RealName: name}}
+		info.Hooks = map[string]*snap.HookInfo{
+			"pre-snapshot": {Snap: info, Name: "pre-snapshot"},
+		}
+		return info, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	pre, save := tasks[0], tasks[1]
+	c.Check(save.WaitTasks(), check.DeepEquals, []*state.Task{pre})
+
+	var setup hookstate.HookSetup
+	c.Assert(pre.Get("hook-setup", &setup), check.IsNil)
+	c.Check(setup, check.DeepEquals, hookstate.HookSetup{Snap: "a-snap", Hook: "pre-snapshot", Optional: true})
+	c.Check(pre.Has("undo-hook-setup"), check.Equals, false)
+}
+
 func (snapshotSuite) TestSaveIntegration(c *check.C) {
 	if os.Geteuid() == 0 {
 		c.Skip("this test cannot run as root (runuser will fail)")
@@ -515,7 +600,10 @@ func (snapshotSuite) TestSaveIntegration(c *check.C) {
 	stmgr, err := snapstate.Manager(st, o.TaskRunner())
 	c.Assert(err, check.IsNil)
 	o.AddManager(stmgr)
-	shmgr := snapshotstate.Manager(st, o.TaskRunner())
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
 	o.AddManager(shmgr)
 	o.AddManager(o.TaskRunner())
 
@@ -545,7 +633,7 @@ func (snapshotSuite) TestSaveIntegration(c *check.C) {
 		}
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"})
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -562,7 +650,7 @@ func (snapshotSuite) TestSaveIntegration(c *check.C) {
 
 	tf := time.Now()
 	c.Assert(backend.Iter(context.TODO(), func(r *backend.Reader) error {
-		c.Check(r.Check(context.TODO(), nil), check.IsNil)
+		c.Check(r.Check(context.TODO(), nil, nil), check.IsNil)
 
 		// check the unknowables, and zero them out
 		c.Check(r.Snapshot.Time.After(t0), check.Equals, true)
@@ -625,7 +713,10 @@ exec /bin/tar "$@"
 	stmgr, err := snapstate.Manager(st, o.TaskRunner())
 	c.Assert(err, check.IsNil)
 	o.AddManager(stmgr)
-	shmgr := snapshotstate.Manager(st, o.TaskRunner())
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
 	o.AddManager(shmgr)
 	o.AddManager(o.TaskRunner())
 
@@ -651,7 +742,7 @@ exec /bin/tar "$@"
 		c.Assert(os.Mkdir(filepath.Join(homedir, "snap", name, "common", "common-"+name), mode), check.IsNil)
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"})
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -696,7 +787,7 @@ func (snapshotSuite) TestRestoreChecksIterError(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, "bzzt")
 }
 
@@ -704,7 +795,7 @@ func (s snapshotSuite) TestRestoreChecksSnapstateConflicts(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.NotNil)
 	c.Check(err, check.FitsTypeOf, &snapstate.ChangeConflictError{})
 
@@ -745,7 +836,10 @@ func (snapshotSuite) TestRestoreConflictsWithSnapstate(c *check.C) {
 	stmgr, err := snapstate.Manager(st, o.TaskRunner())
 	c.Assert(err, check.IsNil)
 	o.AddManager(stmgr)
-	shmgr := snapshotstate.Manager(st, o.TaskRunner())
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
 	o.AddManager(shmgr)
 
 	st.Lock()
@@ -761,7 +855,7 @@ func (snapshotSuite) TestRestoreConflictsWithSnapstate(c *check.C) {
 	})
 
 	chg := st.NewChange("snapshot-restore", "...")
-	_, restoreTasks, err := snapshotstate.Restore(st, 42, nil, nil)
+	_, restoreTasks, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	chg.AddAll(restoreTasks)
 
@@ -793,7 +887,7 @@ func (snapshotSuite) TestRestoreChecksForgetConflicts(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change \"1\" is in progress`)
 }
 
@@ -828,7 +922,7 @@ func (snapshotSuite) TestRestoreChecksChangesToSnapID(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot restore snapshot for "a-snap": current snap \(ID 1234567…\) does not match snapshot \(ID 0987654…\)`)
 }
 
@@ -869,7 +963,7 @@ func (snapshotSuite) TestRestoreChecksChangesToEpoch(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot restore snapshot for "a-snap": current snap \(epoch 17\) cannot read snapshot data \(epoch 42\)`)
 }
 
@@ -910,7 +1004,7 @@ func (snapshotSuite) TestRestoreWorksWithCompatibleEpoch(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil)
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -927,6 +1021,134 @@ func (snapshotSuite) TestRestoreWorksWithCompatibleEpoch(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestRestoreWithPostRestoreHook(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+
+	sideInfo := &snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}
+	fakeSnapstateAll := func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {
+				Active:   true,
+				Sequence: []*snap.SideInfo{sideInfo},
+				Current:  sideInfo.Revision,
+			},
+		}, nil
+	}
+	defer snapshotstate.MockSnapstateAll(fakeSnapstateAll)()
+	snaptest.MockSnap(c, "{name: a-snap, version: v1, hooks: {post-restore: {}}}", sideInfo)
+
+	fakeIter := func(_ context.Context, f func(*backend.Reader) error) error {
+		c.Assert(f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Snap: "a-snap"},
+			File:     shotfile,
+		}), check.IsNil)
+		return nil
+	}
+	defer snapshotstate.MockBackendIter(fakeIter)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, taskset, err := snapshotstate.Restore(st, 42, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	restore, hook := tasks[0], tasks[1]
+	c.Check(restore.Kind(), check.Equals, "restore-snapshot")
+	c.Check(hook.Kind(), check.Equals, "run-hook")
+	c.Check(hook.Summary(), check.Equals, `Run post-restore hook of snap "a-snap"`)
+	c.Check(hook.WaitTasks(), check.DeepEquals, []*state.Task{restore})
+
+	var setup hookstate.HookSetup
+	c.Assert(hook.Get("hook-setup", &setup), check.IsNil)
+	c.Check(setup, check.DeepEquals, hookstate.HookSetup{Snap: "a-snap", Hook: "post-restore", Optional: true})
+}
+
+func (snapshotSuite) TestRestoreFailingPostRestoreHook(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		c.Assert(f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Snap: "a-snap"},
+			File:     shotfile,
+		}), check.IsNil)
+		return nil
+	})()
+	defer snapshotstate.MockBackendOpen(func(string) (*backend.Reader, error) {
+		return &backend.Reader{Snapshot: client.Snapshot{SetID: 42, Snap: "a-snap"}}, nil
+	})()
+	defer snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, []byte, backend.Logf) (*backend.RestoreState, error) {
+		return &backend.RestoreState{}, nil
+	})()
+	var reverted bool
+	defer snapshotstate.MockBackendRevert(func(*backend.RestoreState) {
+		reverted = true
+	})()
+	defer snapshotstate.MockBackendCleanup(func(*backend.RestoreState) {})()
+	defer hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		c.Check(ctx.HookName(), check.Equals, "post-restore")
+		return []byte("not today"), errors.New("exit status 1")
+	})()
+
+	o := overlord.Mock()
+	st := o.State()
+
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
+	o.AddManager(shmgr)
+	o.AddManager(o.TaskRunner())
+
+	st.Lock()
+	defer st.Unlock()
+
+	sideInfo := &snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}
+	snapstate.Set(st, "a-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{sideInfo},
+		Current:  sideInfo.Revision,
+		SnapType: "app",
+	})
+	snaptest.MockSnap(c, "{name: a-snap, version: v1, hooks: {post-restore: {}}}", sideInfo)
+
+	_, taskset, err := snapshotstate.Restore(st, 42, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	change := st.NewChange("restore-snapshot", "...")
+	change.AddAll(taskset)
+	change.Set("api-data", map[string]interface{}{"snap-names": []string{"a-snap"}})
+
+	st.Unlock()
+	c.Assert(o.Settle(5*time.Second), check.IsNil)
+	st.Lock()
+
+	c.Check(change.Err(), check.ErrorMatches, `(?s).*run hook "post-restore": not today.*`)
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Status(), check.Equals, state.UndoneStatus)
+	c.Check(tasks[1].Status(), check.Equals, state.ErrorStatus)
+	c.Check(reverted, check.Equals, true)
+
+	// the failure is recorded along the rest of the change's data
+	var data map[string]interface{}
+	c.Assert(change.Get("api-data", &data), check.IsNil)
+	c.Check(data, check.DeepEquals, map[string]interface{}{
+		"snap-names": []interface{}{"a-snap"},
+		"snapshot-hooks": []interface{}{
+			map[string]interface{}{
+				"snap":  "a-snap",
+				"hook":  "post-restore",
+				"error": "not today",
+			},
+		},
+	})
+}
+
 func (snapshotSuite) TestRestore(c *check.C) {
 	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
 	c.Assert(err, check.IsNil)
@@ -946,7 +1168,7 @@ func (snapshotSuite) TestRestore(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Restore(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
@@ -992,7 +1214,10 @@ func (snapshotSuite) TestRestoreIntegration(c *check.C) {
 	stmgr, err := snapstate.Manager(st, o.TaskRunner())
 	c.Assert(err, check.IsNil)
 	o.AddManager(stmgr)
-	shmgr := snapshotstate.Manager(st, o.TaskRunner())
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
 	o.AddManager(shmgr)
 	o.AddManager(o.TaskRunner())
 
@@ -1023,7 +1248,7 @@ func (snapshotSuite) TestRestoreIntegration(c *check.C) {
 	// remove b-user's home
 	c.Assert(os.RemoveAll(homedirB), check.IsNil)
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user", "b-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user", "b-user"}, nil)
 	c.Assert(err, check.IsNil)
 	sort.Strings(found)
 	c.Check(found, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -1071,7 +1296,10 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 	stmgr, err := snapstate.Manager(st, o.TaskRunner())
 	c.Assert(err, check.IsNil)
 	o.AddManager(stmgr)
-	shmgr := snapshotstate.Manager(st, o.TaskRunner())
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	shmgr := snapshotstate.Manager(st, hookMgr, o.TaskRunner())
 	o.AddManager(shmgr)
 	o.AddManager(o.TaskRunner())
 
@@ -1101,7 +1329,7 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 	c.Assert(os.MkdirAll(filepath.Join(homedir, "snap"), 0755), check.IsNil)
 	c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", "too-snap"), 0), check.IsNil)
 
-	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user"})
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	sort.Strings(found)
 	c.Check(found, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -1153,7 +1381,7 @@ func (snapshotSuite) TestCheckChecksIterError(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, _, err := snapshotstate.Check(st, 42, nil, nil)
+	_, _, err := snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, "bzzt")
 }
 
@@ -1161,7 +1389,7 @@ func (s snapshotSuite) TestCheckDoesNotTriggerSnapstateConflict(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, err := snapshotstate.Check(st, 42, nil, nil)
+	_, _, err := snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 }
 
@@ -1189,7 +1417,7 @@ func (snapshotSuite) TestCheckChecksForgetConflicts(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, _, err = snapshotstate.Check(st, 42, nil, nil)
+	_, _, err = snapshotstate.Check(st, 42, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change \"1\" is in progress`)
 }
 
@@ -1212,7 +1440,7 @@ func (snapshotSuite) TestCheck(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	found, taskset, err := snapshotstate.Check(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"})
+	found, taskset, err := snapshotstate.Check(st, 42, []string{"a-snap", "b-snap"}, []string{"a-user"}, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(found, check.DeepEquals, []string{"a-snap"})
 	tasks := taskset.Tasks()
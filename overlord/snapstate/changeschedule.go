@@ -0,0 +1,283 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// ChangeSchedule describes when a change is allowed to start: either at a
+// given time or inside the windows of a schedule, in the same syntax as
+// refresh.timer.
+type ChangeSchedule struct {
+	At     time.Time `json:"at,omitempty"`
+	Window string    `json:"window,omitempty"`
+}
+
+// windowSlack is how long after the end of the window the change that was
+// woken up for it can still start, so that windows that are a single point
+// in time are not missed.
+var windowSlack = time.Minute
+
+// Validate checks that exactly one of At or Window is set, that Window is a
+// valid schedule and that At is not in the past.
+func (sched *ChangeSchedule) Validate() error {
+	switch {
+	case sched.At.IsZero() && sched.Window == "":
+		return fmt.Errorf("cannot schedule change: either a time or a window is required")
+	case !sched.At.IsZero() && sched.Window != "":
+		return fmt.Errorf("cannot schedule change: cannot use both a time and a window")
+	case sched.Window != "":
+		if _, err := timeutil.ParseSchedule(sched.Window); err != nil {
+			return fmt.Errorf("cannot schedule change: %v", err)
+		}
+	case sched.At.Before(time.Now()):
+		return fmt.Errorf("cannot schedule change: time %s is in the past", sched.At.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (sched *ChangeSchedule) String() string {
+	if sched.Window != "" {
+		return fmt.Sprintf("in window %q", sched.Window)
+	}
+	return fmt.Sprintf("at %s", sched.At.Format(time.RFC3339))
+}
+
+// ScheduledChange describes an operation whose tasks are only created
+// once its schedule allows it, so that until then it neither conflicts
+// with other changes nor fixes the revisions to use.
+type ScheduledChange struct {
+	// Kind is the operation, install, refresh, remove or connect.
+	Kind     string        `json:"kind"`
+	Snaps    []string      `json:"snaps,omitempty"`
+	Channel  string        `json:"channel,omitempty"`
+	Revision snap.Revision `json:"revision,omitempty"`
+	Flags    Flags         `json:"flags,omitempty"`
+	UserID   int           `json:"user-id,omitempty"`
+
+	// PlugSnap, PlugName, SlotSnap, SlotName and Restrictions describe
+	// the connection of connect operations.
+	PlugSnap     string                 `json:"plug-snap,omitempty"`
+	PlugName     string                 `json:"plug-name,omitempty"`
+	SlotSnap     string                 `json:"slot-snap,omitempty"`
+	SlotName     string                 `json:"slot-name,omitempty"`
+	Restrictions map[string]interface{} `json:"restrictions,omitempty"`
+
+	Schedule ChangeSchedule `json:"schedule"`
+}
+
+// ScheduledChangeFunc creates the task sets of a scheduled change once it
+// is due.
+type ScheduledChangeFunc func(st *state.State, sc *ScheduledChange) ([]*state.TaskSet, error)
+
+var scheduledChangeKinds = map[string]ScheduledChangeFunc{
+	"install": scheduledInstall,
+	"refresh": scheduledRefresh,
+	"remove":  scheduledRemove,
+}
+
+// AddScheduledChangeKind registers how to create the task sets of
+// scheduled changes of the given kind.
+func AddScheduledChangeKind(kind string, f ScheduledChangeFunc) {
+	scheduledChangeKinds[kind] = f
+}
+
+func scheduledInstall(st *state.State, sc *ScheduledChange) ([]*state.TaskSet, error) {
+	if len(sc.Snaps) == 1 {
+		ts, err := Install(st, sc.Snaps[0], sc.Channel, sc.Revision, sc.UserID, sc.Flags)
+		if err != nil {
+			return nil, err
+		}
+		return []*state.TaskSet{ts}, nil
+	}
+	_, tss, err := InstallMany(st, sc.Snaps, sc.UserID)
+	return tss, err
+}
+
+func scheduledRefresh(st *state.State, sc *ScheduledChange) ([]*state.TaskSet, error) {
+	if AutoRefreshAssertions != nil {
+		if err := AutoRefreshAssertions(st, sc.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if len(sc.Snaps) == 1 {
+		ts, err := Update(st, sc.Snaps[0], sc.Channel, sc.Revision, sc.UserID, sc.Flags)
+		if err != nil {
+			return nil, err
+		}
+		return []*state.TaskSet{ts}, nil
+	}
+	flags := sc.Flags
+	_, tss, err := UpdateMany(context.TODO(), st, sc.Snaps, sc.UserID, &flags)
+	return tss, err
+}
+
+func scheduledRemove(st *state.State, sc *ScheduledChange) ([]*state.TaskSet, error) {
+	if len(sc.Snaps) == 1 {
+		ts, err := Remove(st, sc.Snaps[0], sc.Revision)
+		if err != nil {
+			return nil, err
+		}
+		return []*state.TaskSet{ts}, nil
+	}
+	_, tss, err := RemoveMany(st, sc.Snaps)
+	return tss, err
+}
+
+// ScheduleChange returns the task set of a change that waits for the
+// schedule of the given operation, its other tasks are only added by the
+// snap manager once the schedule allows it. The schedule is expected to
+// be valid.
+func ScheduleChange(st *state.State, sc *ScheduledChange) *state.TaskSet {
+	var summary string
+	if sc.Schedule.Window != "" {
+		summary = fmt.Sprintf("Wait for window %q", sc.Schedule.Window)
+	} else {
+		summary = fmt.Sprintf("Wait until %s", sc.Schedule.At.Format(time.RFC3339))
+	}
+	wait := st.NewTask("wait-for-schedule", summary)
+	wait.Set("scheduled-change", sc)
+	return state.NewTaskSet(wait)
+}
+
+// scheduleDue returns whether the schedule allows the change of the given
+// wait task to start now and otherwise how long to wait before checking
+// again. The window that is waited for is kept in the task.
+func scheduleDue(t *state.Task, sched *ChangeSchedule, now time.Time) (bool, time.Duration, error) {
+	if sched.Window == "" {
+		if now.Before(sched.At) {
+			return false, sched.At.Sub(now), nil
+		}
+		// includes the case where the device was off at the
+		// scheduled time
+		return true, 0, nil
+	}
+
+	schedule, err := timeutil.ParseSchedule(sched.Window)
+	if err != nil {
+		return false, 0, err
+	}
+	if timeutil.Includes(schedule, now) {
+		return true, 0, nil
+	}
+	// the window we were woken up for
+	var window timeutil.ScheduleWindow
+	if err := t.Get("window", &window); err != nil && err != state.ErrNoState {
+		return false, 0, err
+	}
+	if !window.IsZero() && !now.Before(window.Start) && now.Before(window.End.Add(windowSlack)) {
+		return true, 0, nil
+	}
+
+	// otherwise wait for the next window, this also covers windows
+	// that were missed while the device was off
+	window = timeutil.ScheduleWindow{}
+	for _, s := range schedule {
+		next := s.Next(now)
+		if window.IsZero() || next.Start.Before(window.Start) {
+			window = next
+		}
+	}
+	t.Set("window", window)
+	after := window.Start.Sub(now)
+	if after <= 0 {
+		after = windowSlack
+	}
+	return false, after, nil
+}
+
+// ensureScheduledChanges creates the tasks of the scheduled changes that
+// are due. Conflicts with other changes are only checked then, and make
+// the scheduled change fail.
+func (m *SnapManager) ensureScheduledChanges() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	now := time.Now()
+	var next time.Duration
+	for _, t := range m.state.Tasks() {
+		if t.Kind() != "wait-for-schedule" || t.Status() != state.DoStatus || t.Has("started") {
+			continue
+		}
+		var sc ScheduledChange
+		if err := t.Get("scheduled-change", &sc); err != nil {
+			return err
+		}
+		due, after, err := scheduleDue(t, &sc.Schedule, now)
+		if err != nil {
+			return err
+		}
+		if !due {
+			if next == 0 || after < next {
+				next = after
+			}
+			continue
+		}
+		startScheduledChange(t, &sc)
+	}
+	if next > 0 {
+		m.state.EnsureBefore(next)
+	}
+	return nil
+}
+
+func startScheduledChange(t *state.Task, sc *ScheduledChange) {
+	st := t.State()
+
+	var tss []*state.TaskSet
+	var err error
+	if f := scheduledChangeKinds[sc.Kind]; f != nil {
+		// this might unlock the state to talk to the store
+		tss, err = f(st, sc)
+	} else {
+		err = fmt.Errorf("internal error: unknown kind of scheduled change %q", sc.Kind)
+	}
+	if t.Status() != state.DoStatus {
+		// aborted meanwhile
+		return
+	}
+	if err != nil {
+		t.Errorf("cannot start scheduled change: %v", err)
+		t.SetStatus(state.ErrorStatus)
+		return
+	}
+	chg := t.Change()
+	for _, ts := range tss {
+		ts.WaitFor(t)
+		chg.AddAll(ts)
+	}
+	t.Set("started", true)
+	st.EnsureBefore(0)
+}
+
+// doWaitForSchedule completes the wait task once ensureScheduledChanges
+// created the other tasks of its change, it is blocked until then.
+func (m *SnapManager) doWaitForSchedule(t *state.Task, _ *tomb.Tomb) error {
+	return nil
+}
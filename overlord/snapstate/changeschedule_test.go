@@ -0,0 +1,269 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+type changeScheduleSuite struct {
+	baseHandlerSuite
+
+	restoreKind func()
+}
+
+var _ = Suite(&changeScheduleSuite{})
+
+// weekday returns the abbreviated name of the day that is days away from
+// today, as used in schedules.
+func weekday(days int) string {
+	return strings.ToLower(time.Now().AddDate(0, 0, days).Weekday().String()[:3])
+}
+
+func (s *changeScheduleSuite) SetUpTest(c *C) {
+	s.baseHandlerSuite.SetUpTest(c)
+
+	// the test kind of scheduled change just adds a task
+	s.restoreKind = snapstate.MockScheduledChangeKind("test", func(st *state.State, sc *snapstate.ScheduledChange) ([]*state.TaskSet, error) {
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("nop", "..."))}, nil
+	})
+}
+
+func (s *changeScheduleSuite) TearDownTest(c *C) {
+	s.restoreKind()
+	s.baseHandlerSuite.TearDownTest(c)
+}
+
+func (s *changeScheduleSuite) scheduledChange(sched *snapstate.ChangeSchedule) *state.Task {
+	return s.scheduledChangeOfKind("test", sched)
+}
+
+func (s *changeScheduleSuite) scheduledChangeOfKind(kind string, sched *snapstate.ChangeSchedule) *state.Task {
+	chg := s.state.NewChange("sample", "...")
+	ts := snapstate.ScheduleChange(s.state, &snapstate.ScheduledChange{Kind: kind, Snaps: []string{"some-snap"}, Schedule: *sched})
+	chg.AddAll(ts)
+	return ts.Tasks()[0]
+}
+
+func (s *changeScheduleSuite) settle(c *C) {
+	s.se.Ensure()
+	s.se.Wait()
+	s.se.Ensure()
+	s.se.Wait()
+}
+
+// checkStarted checks whether the change of the wait task got its tasks
+// and ran them.
+func (s *changeScheduleSuite) checkStarted(c *C, wait *state.Task, started bool) {
+	tasks := wait.Change().Tasks()
+	if !started {
+		c.Check(wait.Status(), Equals, state.DoStatus)
+		c.Check(tasks, HasLen, 1)
+		return
+	}
+	c.Check(wait.Status(), Equals, state.DoneStatus)
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[1].Kind(), Equals, "nop")
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{wait})
+}
+
+func (s *changeScheduleSuite) TestValidate(c *C) {
+	for _, t := range []struct {
+		sched snapstate.ChangeSchedule
+		err   string
+	}{
+		{snapstate.ChangeSchedule{At: time.Now().Add(time.Hour)}, ""},
+		{snapstate.ChangeSchedule{Window: "mon,10:00-12:00"}, ""},
+		{snapstate.ChangeSchedule{}, "cannot schedule change: either a time or a window is required"},
+		{snapstate.ChangeSchedule{At: time.Now().Add(time.Hour), Window: "mon"}, "cannot schedule change: cannot use both a time and a window"},
+		{snapstate.ChangeSchedule{Window: "10:00-"}, `cannot schedule change: cannot parse "10:00-": .*`},
+		{snapstate.ChangeSchedule{At: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}, "cannot schedule change: time 2019-01-01T00:00:00Z is in the past"},
+	} {
+		err := t.sched.Validate()
+		if t.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (s *changeScheduleSuite) TestScheduleChange(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	at := time.Now().Add(time.Hour)
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{At: at})
+
+	c.Check(wait.Kind(), Equals, "wait-for-schedule")
+	c.Check(wait.Summary(), Equals, "Wait until "+at.Format(time.RFC3339))
+	var sc snapstate.ScheduledChange
+	c.Assert(wait.Get("scheduled-change", &sc), IsNil)
+	c.Check(sc.Kind, Equals, "test")
+	c.Check(sc.Snaps, DeepEquals, []string{"some-snap"})
+	c.Check(sc.Schedule.At.Equal(at), Equals, true)
+	// nothing else until it is due
+	c.Check(wait.Change().Tasks(), HasLen, 1)
+
+	wait = s.scheduledChange(&snapstate.ChangeSchedule{Window: "mon"})
+	c.Check(wait.Summary(), Equals, `Wait for window "mon"`)
+}
+
+func (s *changeScheduleSuite) TestScheduledChangeDoesNotConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.scheduledChangeOfKind("remove", &snapstate.ChangeSchedule{At: time.Now().Add(time.Hour)})
+	c.Check(snapstate.CheckChangeConflictMany(s.state, []string{"some-snap"}, ""), IsNil)
+}
+
+func (s *changeScheduleSuite) TestWaitForTimeInTheFuture(c *C) {
+	s.state.Lock()
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{At: time.Now().Add(time.Hour)})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.checkStarted(c, wait, false)
+}
+
+func (s *changeScheduleSuite) TestWaitForTimeThatPassed(c *C) {
+	s.state.Lock()
+	// the device was off at the scheduled time
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{At: time.Now().Add(-time.Hour)})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.checkStarted(c, wait, true)
+}
+
+func (s *changeScheduleSuite) TestWaitForWindowIncludingNow(c *C) {
+	s.state.Lock()
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{Window: weekday(0) + ",0:00-24:00"})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.checkStarted(c, wait, true)
+}
+
+func (s *changeScheduleSuite) TestWaitForNextWindow(c *C) {
+	s.state.Lock()
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{Window: weekday(2)})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.checkStarted(c, wait, false)
+
+	var window timeutil.ScheduleWindow
+	c.Assert(wait.Get("window", &window), IsNil)
+	c.Check(window.Start.Weekday(), Equals, time.Now().AddDate(0, 0, 2).Weekday())
+}
+
+func (s *changeScheduleSuite) TestWaitForWindowWokenUpFor(c *C) {
+	s.state.Lock()
+	// a window that is a single point in time can be over by the
+	// time the snap manager looks at it
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{Window: weekday(2)})
+	now := time.Now()
+	wait.Set("window", timeutil.ScheduleWindow{Start: now.Add(-time.Second), End: now.Add(-time.Second)})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.checkStarted(c, wait, true)
+}
+
+func (s *changeScheduleSuite) TestWaitForWindowMissedWhileOff(c *C) {
+	s.state.Lock()
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{Window: weekday(2)})
+	yesterday := time.Now().AddDate(0, 0, -1)
+	wait.Set("window", timeutil.ScheduleWindow{Start: yesterday, End: yesterday.Add(time.Hour)})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	// waits for the following window instead
+	s.checkStarted(c, wait, false)
+	var window timeutil.ScheduleWindow
+	c.Assert(wait.Get("window", &window), IsNil)
+	c.Check(window.Start.After(time.Now()), Equals, true)
+}
+
+func (s *changeScheduleSuite) TestScheduledChangeConflictsWhenDue(c *C) {
+	s.state.Lock()
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+	wait := s.scheduledChangeOfKind("remove", &snapstate.ChangeSchedule{At: time.Now().Add(-time.Minute)})
+	// another change is using the snap by the time it is due
+	other := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "some-snap"}})
+	other.AddTask(t)
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(wait.Status(), Equals, state.ErrorStatus)
+	c.Check(wait.Change().Status(), Equals, state.ErrorStatus)
+	c.Check(wait.Change().Tasks(), HasLen, 1)
+	c.Check(wait.Change().Err(), ErrorMatches, `(?s).*cannot start scheduled change: snap "some-snap" has "refresh-snap" change in progress.*`)
+}
+
+func (s *changeScheduleSuite) TestScheduledChangeAborted(c *C) {
+	s.state.Lock()
+	wait := s.scheduledChange(&snapstate.ChangeSchedule{At: time.Now().Add(time.Hour)})
+	wait.Change().Abort()
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(wait.Change().Status(), Equals, state.HoldStatus)
+	c.Check(wait.Change().Tasks(), HasLen, 1)
+}
@@ -244,3 +244,15 @@ var (
 )
 
 type AuxStoreInfo = auxStoreInfo
+
+func MockScheduledChangeKind(kind string, f ScheduledChangeFunc) (restore func()) {
+	old, ok := scheduledChangeKinds[kind]
+	scheduledChangeKinds[kind] = f
+	return func() {
+		if ok {
+			scheduledChangeKinds[kind] = old
+		} else {
+			delete(scheduledChangeKinds, kind)
+		}
+	}
+}
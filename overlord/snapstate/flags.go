@@ -19,6 +19,10 @@
 
 package snapstate
 
+import (
+	"github.com/snapcore/snapd/client"
+)
+
 // Flags are used to pass additional flags to operations and to keep track of snap modes.
 type Flags struct {
 	// DevMode switches confinement to non-enforcing mode.
@@ -65,6 +69,11 @@ type Flags struct {
 	// re-refresh tasks. This allows refresh to work offline, as
 	// long as refresh assets are cached.
 	NoReRefresh bool `json:"no-rerefresh,omitempty"`
+
+	// Transaction is set to client.TransactionAllSnaps to have the
+	// snaps of a multi-snap operation share one lane, so that a
+	// failure in any of them undoes all of them.
+	Transaction client.TransactionType `json:"transaction,omitempty"`
 }
 
 // DevModeAllowed returns whether a snap can be installed with devmode confinement (either set or overridden)
@@ -76,5 +85,6 @@ func (f Flags) DevModeAllowed() bool {
 func (f Flags) ForSnapSetup() Flags {
 	f.SkipConfigure = false
 	f.NoReRefresh = false
+	f.Transaction = ""
 	return f
 }
@@ -1063,6 +1063,12 @@ func snapdSnapInstalled(st *state.State) bool {
 func maybeRestart(t *state.Task, info *snap.Info) {
 	st := t.State()
 
+	if release.PreseedMode {
+		// the daemon, as well as the system, start afresh on first
+		// boot of the preseeded image
+		return
+	}
+
 	if release.OnClassic {
 		if (info.Type == snap.TypeOS && !snapdSnapInstalled(st)) ||
 			info.InstanceName() == "snapd" {
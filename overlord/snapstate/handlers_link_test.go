@@ -393,6 +393,36 @@ func (s *linkSnapSuite) TestDoLinkSnapSuccessCoreRestarts(c *C) {
 	c.Check(t.Log()[0], Matches, `.*INFO Requested daemon restart\.`)
 }
 
+func (s *linkSnapSuite) TestDoLinkSnapCoreNoRestartWhenPreseeding(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+	restore = release.MockPreseedMode(true)
+	defer restore()
+
+	s.state.Lock()
+	si := &snap.SideInfo{
+		RealName: "core",
+		Revision: snap.R(33),
+	}
+	t := s.state.NewTask("link-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+	})
+	s.state.NewChange("dummy", "...").AddTask(t)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(s.stateBackend.restartRequested, HasLen, 0)
+	c.Check(t.Log(), HasLen, 0)
+}
+
 func (s *linkSnapSuite) TestDoLinkSnapSuccessSnapdRestartsOnCoreWithBase(c *C) {
 	restore := release.MockOnClassic(false)
 	defer restore()
@@ -400,6 +400,7 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 
 	// misc
 	runner.AddHandler("switch-snap", m.doSwitchSnap, nil)
+	runner.AddHandler("wait-for-schedule", m.doWaitForSchedule, nil)
 
 	// control serialisation
 	runner.AddBlocked(m.blockedTask)
@@ -448,6 +449,11 @@ func (m *SnapManager) blockedTask(cand *state.Task, running []*state.Task) bool
 		}
 	}
 
+	// scheduled changes are started by ensureScheduledChanges
+	if cand.Kind() == "wait-for-schedule" && !cand.Has("started") {
+		return true
+	}
+
 	return false
 }
 
@@ -665,6 +671,7 @@ func (m *SnapManager) Ensure() error {
 		m.refreshHints.Ensure(),
 		m.catalogRefresh.Ensure(),
 		m.localInstallCleanup(),
+		m.ensureScheduledChanges(),
 	}
 
 	//FIXME: use firstErr helper
@@ -32,6 +32,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/gadget"
@@ -63,6 +64,10 @@ const (
 
 const (
 	DownloadAndChecksDoneEdge = state.TaskSetEdge("download-and-checks-done")
+	// HooksEdge marks the first hook to run once the new revision of
+	// the snap is linked, the tasks from it on need the snap to be able
+	// to run.
+	HooksEdge = state.TaskSetEdge("hooks")
 )
 
 var ErrNothingToDo = errors.New("nothing to do")
@@ -249,10 +254,12 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	addTask(setupAliases)
 	prev = setupAliases
 
+	var firstHook *state.Task
 	if runRefreshHooks {
 		postRefreshHook := SetupPostRefreshHook(st, snapsup.InstanceName())
 		addTask(postRefreshHook)
 		prev = postRefreshHook
+		firstHook = postRefreshHook
 	}
 
 	// only run install hook if installing the snap for the first time
@@ -260,6 +267,7 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 		installHook := SetupInstallHook(st, snapsup.InstanceName())
 		addTask(installHook)
 		prev = installHook
+		firstHook = installHook
 	}
 
 	// run new services
@@ -331,9 +339,12 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	if checkAsserts != nil {
 		ts.MarkEdge(checkAsserts, DownloadAndChecksDoneEdge)
 	}
+	if firstHook != nil {
+		ts.MarkEdge(firstHook, HooksEdge)
+	}
 
 	if flags&skipConfigure != 0 {
-		return installSet, nil
+		return ts, nil
 	}
 
 	var confFlags int
@@ -820,8 +831,9 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, us
 	if ValidateRefreshes != nil && len(updates) != 0 {
 		updates, err = ValidateRefreshes(st, updates, ignoreValidation, userID)
 		if err != nil {
-			// not doing "refresh all" report the error
-			if len(names) != 0 {
+			// not doing "refresh all", or refreshing all the snaps
+			// or none, report the error
+			if len(names) != 0 || flags.Transaction == client.TransactionAllSnaps {
 				return nil, nil, err
 			}
 			// doing "refresh all", log the problems
@@ -850,7 +862,24 @@ func doUpdate(ctx context.Context, st *state.State, names []string, updates []*s
 
 	tasksets := make([]*state.TaskSet, 0, len(updates)+2) // 1 for auto-aliases, 1 for re-refresh
 
-	refreshAll := len(names) == 0
+	// with an all-snaps transaction every snap goes in the same lane,
+	// so that a failure in any of them undoes all of them
+	allSnaps := globalFlags.Transaction == client.TransactionAllSnaps
+	var transactionLane int
+	if allSnaps {
+		transactionLane = st.NewLane()
+	}
+	joinLane := func(ts *state.TaskSet) {
+		if allSnaps {
+			ts.JoinLane(transactionLane)
+		} else {
+			ts.JoinLane(st.NewLane())
+		}
+	}
+
+	// with "refresh all" snaps that cannot be refreshed are skipped,
+	// unless they are all refreshed or none is
+	skipErrors := len(names) == 0 && !allSnaps
 	var nameSet map[string]bool
 	if len(names) != 0 {
 		nameSet = make(map[string]bool, len(names))
@@ -869,7 +898,7 @@ func doUpdate(ctx context.Context, st *state.State, names []string, updates []*s
 
 	if len(mustPruneAutoAliases) != 0 {
 		var err error
-		pruningAutoAliasesTs, err = applyAutoAliasesDelta(st, mustPruneAutoAliases, "prune", refreshAll, func(snapName string, _ *state.TaskSet) {
+		pruningAutoAliasesTs, err = applyAutoAliasesDelta(st, mustPruneAutoAliases, "prune", skipErrors, func(snapName string, _ *state.TaskSet) {
 			if nameSet[snapName] {
 				reportUpdated[snapName] = true
 			}
@@ -877,6 +906,9 @@ func doUpdate(ctx context.Context, st *state.State, names []string, updates []*s
 		if err != nil {
 			return nil, nil, err
 		}
+		if allSnaps {
+			pruningAutoAliasesTs.JoinLane(transactionLane)
+		}
 		tasksets = append(tasksets, pruningAutoAliasesTs)
 	}
 
@@ -905,7 +937,7 @@ func doUpdate(ctx context.Context, st *state.State, names []string, updates []*s
 		flags.IsAutoRefresh = globalFlags.IsAutoRefresh
 
 		if err := checkInstallPreconditions(st, update, flags, snapst); err != nil {
-			if refreshAll {
+			if skipErrors {
 				logger.Noticef("cannot update %q: %v", update.InstanceName(), err)
 				continue
 			}
@@ -913,7 +945,7 @@ func doUpdate(ctx context.Context, st *state.State, names []string, updates []*s
 		}
 
 		if err := earlyEpochCheck(update, snapst); err != nil {
-			if refreshAll {
+			if skipErrors {
 				logger.Noticef("cannot update %q: %v", update.InstanceName(), err)
 				continue
 			}
@@ -943,14 +975,14 @@ func doUpdate(ctx context.Context, st *state.State, names []string, updates []*s
 
 		ts, err := doInstall(st, snapst, snapsup, 0, fromChange)
 		if err != nil {
-			if refreshAll {
+			if skipErrors {
 				// doing "refresh all", just skip this snap
 				logger.Noticef("cannot refresh snap %q: %v", update.InstanceName(), err)
 				continue
 			}
 			return nil, nil, err
 		}
-		ts.JoinLane(st.NewLane())
+		joinLane(ts)
 
 		// because of the sorting of updates we fill prereqs
 		// first (if branch) and only then use it to setup
@@ -976,10 +1008,13 @@ func doUpdate(ctx context.Context, st *state.State, names []string, updates []*s
 	}
 
 	if len(newAutoAliases) != 0 {
-		addAutoAliasesTs, err := applyAutoAliasesDelta(st, newAutoAliases, "refresh", refreshAll, scheduleUpdate)
+		addAutoAliasesTs, err := applyAutoAliasesDelta(st, newAutoAliases, "refresh", skipErrors, scheduleUpdate)
 		if err != nil {
 			return nil, nil, err
 		}
+		if allSnaps {
+			addAutoAliasesTs.JoinLane(transactionLane)
+		}
 		tasksets = append(tasksets, addAutoAliasesTs)
 	}
 
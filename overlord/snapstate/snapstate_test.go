@@ -37,6 +37,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/interfaces"
@@ -980,6 +981,143 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 	checkIsAutoRefresh(c, ts.Tasks(), false)
 }
 
+func (s *snapmgrTestSuite) setupTwoSnapsForUpdate() {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "services-snap", SnapID: "services-snap-id", Revision: snap.R(2)},
+		},
+		Current:  snap.R(2),
+		SnapType: "app",
+	})
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyPerSnapTransaction(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupTwoSnapsForUpdate()
+
+	updates, tts, err := snapstate.UpdateMany(context.TODO(), s.state, []string{"some-snap", "services-snap"}, 0, &snapstate.Flags{Transaction: client.TransactionPerSnap})
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 3)
+	verifyLastTasksetIsReRefresh(c, tts)
+	c.Check(updates, HasLen, 2)
+
+	// each snap is in its own lane
+	for i, ts := range tts[:2] {
+		for _, t := range ts.Tasks() {
+			c.Assert(t.Lanes(), DeepEquals, []int{i + 1})
+		}
+	}
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyAllSnapsTransaction(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupTwoSnapsForUpdate()
+
+	updates, tts, err := snapstate.UpdateMany(context.TODO(), s.state, []string{"some-snap", "services-snap"}, 0, &snapstate.Flags{Transaction: client.TransactionAllSnaps})
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 3)
+	verifyLastTasksetIsReRefresh(c, tts)
+	c.Check(updates, HasLen, 2)
+
+	// all the snaps share one lane
+	for _, ts := range tts[:2] {
+		for _, t := range ts.Tasks() {
+			c.Assert(t.Lanes(), DeepEquals, []int{1})
+		}
+	}
+
+	// the re-refresh keeps the transaction type
+	var rerefreshFlags snapstate.Flags
+	rerefresh := tts[2].Tasks()[0]
+	c.Assert(rerefresh.Get("rerefresh-setup", &rerefreshFlags), IsNil)
+	c.Check(rerefreshFlags.Transaction, Equals, client.TransactionAllSnaps)
+
+	// but it is not part of the per-snap setup
+	snapsup, err := snapstate.TaskSnapSetup(tts[0].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Flags.Transaction, Equals, client.TransactionType(""))
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyAllSnapsTransactionFailureUndoesAll(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupTwoSnapsForUpdate()
+
+	chg := s.state.NewChange("refresh", "refresh some snaps")
+	updates, tts, err := snapstate.UpdateMany(context.TODO(), s.state, []string{"some-snap", "services-snap"}, 0, &snapstate.Flags{Transaction: client.TransactionAllSnaps})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 2)
+	for _, ts := range tts {
+		chg.AddAll(ts)
+	}
+
+	// make the refresh of one of the snaps fail once it is done
+	last := lastWithLane(tts[1].Tasks())
+	c.Assert(last, NotNil)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(last)
+	terr.JoinLane(last.Lanes()[0])
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	defer s.se.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+
+	// both snaps are back to their original revision
+	for name, rev := range map[string]snap.Revision{"some-snap": snap.R(1), "services-snap": snap.R(2)} {
+		var snapst snapstate.SnapState
+		c.Assert(snapstate.Get(s.state, name, &snapst), IsNil)
+		c.Check(snapst.Current, Equals, rev, Commentf(name))
+		c.Check(snapst.Sequence, HasLen, 1, Commentf(name))
+	}
+	for _, ts := range tts[:2] {
+		linkSnap := tasksWithKind(ts, "link-snap")
+		c.Assert(linkSnap, HasLen, 1)
+		c.Check(linkSnap[0].Status(), Equals, state.UndoneStatus)
+	}
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyAllSnapsTransactionRefreshAllFailsOnPreconditions(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupTwoSnapsForUpdate()
+	snapstate.Set(s.state, "some-snap-now-classic", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap-now-classic", SnapID: "some-snap-now-classic-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	// with per-snap transactions the snap needing classic is skipped
+	updates, _, err := snapstate.UpdateMany(context.TODO(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 2)
+
+	// with one transaction nothing is refreshed
+	_, _, err = snapstate.UpdateMany(context.TODO(), s.state, nil, 0, &snapstate.Flags{Transaction: client.TransactionAllSnaps})
+	c.Check(err, DeepEquals, &snapstate.SnapNeedsClassicError{Snap: "some-snap-now-classic"})
+}
+
 func (s *snapmgrTestSuite) TestParallelInstanceUpdateMany(c *C) {
 	restore := release.MockOnClassic(false)
 	defer restore()
@@ -11270,6 +11408,23 @@ func (s *snapmgrTestSuite) TestInstallPathSkipConfigure(c *C) {
 	c.Check(snapsup.Flags.SkipConfigure, Equals, false)
 }
 
+func (s *snapmgrTestSuite) TestInstallTasksHooksEdge(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	hooksTask, err := ts.Edge(snapstate.HooksEdge)
+	c.Assert(err, IsNil)
+	c.Check(hooksTask.Kind(), Equals, "run-hook")
+	var hooksup hookstate.HookSetup
+	c.Assert(hooksTask.Get("hook-setup", &hooksup), IsNil)
+	c.Check(hooksup.Hook, Equals, "install")
+	// it comes right after the snap was set up
+	c.Check(hooksTask.WaitTasks()[0].Kind(), Equals, "setup-aliases")
+}
+
 func (s *snapmgrTestSuite) TestNoReRefreshInUpdate(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -35,6 +35,15 @@ func MockCheckpointRetryDelay(retryInterval, retryMaxTime time.Duration) (restor
 	}
 }
 
+// MockJournalMinStateSize changes the state size from which a JournalBackend is written incrementally.
+func MockJournalMinStateSize(size int) (restore func()) {
+	old := journalMinStateSize
+	journalMinStateSize = size
+	return func() {
+		journalMinStateSize = old
+	}
+}
+
 func MockChangeTimes(chg *Change, spawnTime, readyTime time.Time) {
 	chg.spawnTime = spawnTime
 	chg.readyTime = readyTime
@@ -0,0 +1,376 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// A JournalBackend is a Backend that can also persist the state
+// incrementally.
+//
+// Once the state is large enough, Unlock only hands the top-level entries
+// (data keys, changes, tasks, warnings and counters) that changed since the
+// previous unlock to AppendJournal, as a single line of JSON which must be
+// durable when AppendJournal returns. Every so often the journal is compacted
+// by checkpointing a full snapshot, after which Checkpoint must discard all
+// journal entries appended before it.
+//
+// The snapshot is in the same format ReadState has always read, and the
+// pair can be turned back into a single snapshot with ReplayJournal.
+type JournalBackend interface {
+	Backend
+	AppendJournal(entry []byte) error
+}
+
+// journalMinStateSize is the snapshot size below which the state is simply
+// rewritten in full on every unlock.
+var journalMinStateSize = 256 * 1024
+
+// journalTracker keeps track of what was last persisted through a
+// JournalBackend.
+type journalTracker struct {
+	// hashes of the persisted entries
+	hashes map[entryKey][sha256.Size]byte
+	// size of the last full snapshot
+	snapshotSize int
+	// size of the journal entries appended since that snapshot
+	journalSize int
+}
+
+type entryKey struct {
+	kind string
+	id   string
+}
+
+const (
+	dataEntry     = "data"
+	changeEntry   = "change"
+	taskEntry     = "task"
+	warningsEntry = "warnings"
+	countersEntry = "counters"
+)
+
+// rawState is marshalledState with every entry already serialized.
+type rawState struct {
+	Data     map[string]*json.RawMessage `json:"data"`
+	Changes  map[string]*json.RawMessage `json:"changes"`
+	Tasks    map[string]*json.RawMessage `json:"tasks"`
+	Warnings *json.RawMessage            `json:"warnings,omitempty"`
+
+	LastChangeId int `json:"last-change-id"`
+	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id"`
+
+	JournalSeq int `json:"journal-seq,omitempty"`
+}
+
+// journalEntry holds what changed in the state during one unlock.
+type journalEntry struct {
+	Seq int `json:"seq"`
+
+	Data     map[string]*json.RawMessage `json:"data,omitempty"`
+	Changes  map[string]*json.RawMessage `json:"changes,omitempty"`
+	Tasks    map[string]*json.RawMessage `json:"tasks,omitempty"`
+	Warnings *json.RawMessage            `json:"warnings,omitempty"`
+
+	RemovedData    []string `json:"removed-data,omitempty"`
+	RemovedChanges []string `json:"removed-changes,omitempty"`
+	RemovedTasks   []string `json:"removed-tasks,omitempty"`
+
+	LastChangeId int `json:"last-change-id"`
+	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id"`
+}
+
+func mustMarshalRaw(v interface{}) *json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// this shouldn't happen, because the actual delicate serializing happens at various Set()s
+		logger.Panicf("internal error: could not marshal state for checkpointing: %v", err)
+	}
+	raw := json.RawMessage(data)
+	return &raw
+}
+
+// rawState serializes every entry of the state separately.
+func (s *State) rawState() *rawState {
+	raw := &rawState{
+		Data:         make(map[string]*json.RawMessage, len(s.data)),
+		Changes:      make(map[string]*json.RawMessage, len(s.changes)),
+		Tasks:        make(map[string]*json.RawMessage, len(s.tasks)),
+		LastChangeId: s.lastChangeId,
+		LastTaskId:   s.lastTaskId,
+		LastLaneId:   s.lastLaneId,
+		JournalSeq:   s.journalSeq,
+	}
+	for k, v := range s.data {
+		raw.Data[k] = v
+	}
+	for id, chg := range s.changes {
+		raw.Changes[id] = mustMarshalRaw(chg)
+	}
+	for id, t := range s.tasks {
+		raw.Tasks[id] = mustMarshalRaw(t)
+	}
+	if warnings := s.flattenWarnings(); len(warnings) > 0 {
+		// keep the serialization stable so that unchanged warnings
+		// are not journaled again
+		sort.Slice(warnings, func(i, j int) bool {
+			return warnings[i].message < warnings[j].message
+		})
+		raw.Warnings = mustMarshalRaw(warnings)
+	}
+	return raw
+}
+
+func (raw *rawState) hashes() map[entryKey][sha256.Size]byte {
+	hashes := make(map[entryKey][sha256.Size]byte, len(raw.Data)+len(raw.Changes)+len(raw.Tasks)+2)
+	for k, v := range raw.Data {
+		hashes[entryKey{dataEntry, k}] = sha256.Sum256(*v)
+	}
+	for id, v := range raw.Changes {
+		hashes[entryKey{changeEntry, id}] = sha256.Sum256(*v)
+	}
+	for id, v := range raw.Tasks {
+		hashes[entryKey{taskEntry, id}] = sha256.Sum256(*v)
+	}
+	if raw.Warnings != nil {
+		hashes[entryKey{warningsEntry, ""}] = sha256.Sum256(*raw.Warnings)
+	}
+	counters := fmt.Sprintf("%d %d %d", raw.LastChangeId, raw.LastTaskId, raw.LastLaneId)
+	hashes[entryKey{countersEntry, ""}] = sha256.Sum256([]byte(counters))
+	return hashes
+}
+
+// delta returns the journal entry that brings the persisted state in line
+// with raw, or nil if nothing changed.
+func (t *journalTracker) delta(raw *rawState, hashes map[entryKey][sha256.Size]byte, seq int) *journalEntry {
+	entry := &journalEntry{
+		Seq:          seq,
+		LastChangeId: raw.LastChangeId,
+		LastTaskId:   raw.LastTaskId,
+		LastLaneId:   raw.LastLaneId,
+	}
+	changed := false
+	for key, h := range hashes {
+		if old, ok := t.hashes[key]; ok && old == h {
+			continue
+		}
+		changed = true
+		switch key.kind {
+		case dataEntry:
+			if entry.Data == nil {
+				entry.Data = make(map[string]*json.RawMessage)
+			}
+			entry.Data[key.id] = raw.Data[key.id]
+		case changeEntry:
+			if entry.Changes == nil {
+				entry.Changes = make(map[string]*json.RawMessage)
+			}
+			entry.Changes[key.id] = raw.Changes[key.id]
+		case taskEntry:
+			if entry.Tasks == nil {
+				entry.Tasks = make(map[string]*json.RawMessage)
+			}
+			entry.Tasks[key.id] = raw.Tasks[key.id]
+		case warningsEntry:
+			entry.Warnings = raw.Warnings
+		}
+	}
+	for key := range t.hashes {
+		if _, ok := hashes[key]; ok {
+			continue
+		}
+		changed = true
+		switch key.kind {
+		case dataEntry:
+			entry.RemovedData = append(entry.RemovedData, key.id)
+		case changeEntry:
+			entry.RemovedChanges = append(entry.RemovedChanges, key.id)
+		case taskEntry:
+			entry.RemovedTasks = append(entry.RemovedTasks, key.id)
+		case warningsEntry:
+			noWarnings := json.RawMessage("[]")
+			entry.Warnings = &noWarnings
+		}
+	}
+	if !changed {
+		return nil
+	}
+	sort.Strings(entry.RemovedData)
+	sort.Strings(entry.RemovedChanges)
+	sort.Strings(entry.RemovedTasks)
+	return entry
+}
+
+// journaledCheckpoint prepares persisting the state through a
+// JournalBackend, either as a journal entry or as a full snapshot (always
+// the latter if compact is set), and returns the function to do it. The
+// function can be retried and only updates the bookkeeping once it
+// succeeds.
+func (s *State) journaledCheckpoint(backend JournalBackend, compact bool) func() error {
+	raw := s.rawState()
+	hashes := raw.hashes()
+
+	t := s.journal
+	if compact && t != nil && t.journalSize == 0 && t.delta(raw, hashes, s.journalSeq+1) == nil {
+		// the last snapshot is complete already
+		return func() error { return nil }
+	}
+	if !compact && t != nil && t.snapshotSize >= journalMinStateSize {
+		entry := t.delta(raw, hashes, s.journalSeq+1)
+		if entry == nil {
+			return func() error { return nil }
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			logger.Panicf("internal error: could not marshal state journal entry: %v", err)
+		}
+		data = append(data, '\n')
+		// compact once the journal outgrows the snapshot
+		if t.journalSize+len(data) <= t.snapshotSize {
+			return func() error {
+				if err := backend.AppendJournal(data); err != nil {
+					return err
+				}
+				s.journalSeq = entry.Seq
+				t.hashes = hashes
+				t.journalSize += len(data)
+				return nil
+			}
+		}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		logger.Panicf("internal error: could not marshal state for checkpointing: %v", err)
+	}
+	return func() error {
+		if err := backend.Checkpoint(data); err != nil {
+			return err
+		}
+		s.journal = &journalTracker{
+			hashes:       hashes,
+			snapshotSize: len(data),
+		}
+		return nil
+	}
+}
+
+// ReplayJournal applies the entries of a state journal to a state
+// snapshot, as handed to a JournalBackend, and returns the resulting state
+// as a single snapshot that can be read with ReadState.
+//
+// Entries already included in the snapshot are skipped, and an incomplete
+// last entry, as left behind by an interrupted write, is ignored.
+func ReplayJournal(snapshot, journal io.Reader) ([]byte, error) {
+	var raw rawState
+	if err := json.NewDecoder(snapshot).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("cannot read state: %s", err)
+	}
+	if raw.Data == nil {
+		raw.Data = make(map[string]*json.RawMessage)
+	}
+	if raw.Changes == nil {
+		raw.Changes = make(map[string]*json.RawMessage)
+	}
+	if raw.Tasks == nil {
+		raw.Tasks = make(map[string]*json.RawMessage)
+	}
+
+	r := bufio.NewReader(journal)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// a last line without a newline is an interrupted write
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read state journal: %v", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("cannot replay state journal: invalid entry on line %d: %v", n, err)
+		}
+		if entry.Seq <= raw.JournalSeq {
+			continue
+		}
+		if entry.Seq != raw.JournalSeq+1 {
+			return nil, fmt.Errorf("cannot replay state journal: expected entry %d, got %d", raw.JournalSeq+1, entry.Seq)
+		}
+		raw.apply(&entry)
+	}
+
+	data, err := json.Marshal(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("cannot replay state journal: %v", err)
+	}
+	return data, nil
+}
+
+func (raw *rawState) apply(entry *journalEntry) {
+	for k, v := range entry.Data {
+		raw.Data[k] = v
+	}
+	for id, v := range entry.Changes {
+		raw.Changes[id] = v
+	}
+	for id, v := range entry.Tasks {
+		raw.Tasks[id] = v
+	}
+	if entry.Warnings != nil {
+		raw.Warnings = entry.Warnings
+	}
+	for _, k := range entry.RemovedData {
+		delete(raw.Data, k)
+	}
+	for _, id := range entry.RemovedChanges {
+		delete(raw.Changes, id)
+	}
+	for _, id := range entry.RemovedTasks {
+		delete(raw.Tasks, id)
+	}
+	raw.LastChangeId = entry.LastChangeId
+	raw.LastTaskId = entry.LastTaskId
+	raw.LastLaneId = entry.LastLaneId
+	raw.JournalSeq = entry.Seq
+}
+
+// ReadJournaledState returns the state deserialized from a snapshot and
+// the journal of changes appended after it.
+func ReadJournaledState(backend Backend, snapshot, journal io.Reader) (*State, error) {
+	data, err := ReplayJournal(snapshot, journal)
+	if err != nil {
+		return nil, err
+	}
+	return ReadState(backend, bytes.NewReader(data))
+}
@@ -0,0 +1,354 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type journalSuite struct {
+	restore func()
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.restore = state.MockJournalMinStateSize(0)
+}
+
+func (s *journalSuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+type fakeJournalBackend struct {
+	fakeStateBackend
+	journal      []string
+	journalError func() error
+}
+
+func (b *fakeJournalBackend) Checkpoint(data []byte) error {
+	if err := b.fakeStateBackend.Checkpoint(data); err != nil {
+		return err
+	}
+	b.journal = nil
+	return nil
+}
+
+func (b *fakeJournalBackend) AppendJournal(entry []byte) error {
+	if b.journalError != nil {
+		if err := b.journalError(); err != nil {
+			return err
+		}
+	}
+	b.journal = append(b.journal, string(entry))
+	return nil
+}
+
+func (b *fakeJournalBackend) snapshot() []byte {
+	return b.checkpoints[len(b.checkpoints)-1]
+}
+
+func (b *fakeJournalBackend) read(c *C) *state.State {
+	st, err := state.ReadJournaledState(nil, bytes.NewReader(b.snapshot()), strings.NewReader(strings.Join(b.journal, "")))
+	c.Assert(err, IsNil)
+	return st
+}
+
+// newJournaledState returns a state, large enough to be journaled for a
+// while, that was checkpointed once.
+func newJournaledState(b *fakeJournalBackend) *state.State {
+	st := state.New(b)
+	st.Lock()
+	st.Set("padding", strings.Repeat("x", 4096))
+	st.Set("a", 1)
+	st.Unlock()
+	return st
+}
+
+func (s *journalSuite) TestSmallStateIsCheckpointed(c *C) {
+	restore := state.MockJournalMinStateSize(1024)
+	defer restore()
+
+	b := new(fakeJournalBackend)
+	st := state.New(b)
+	for i := 0; i < 3; i++ {
+		st.Lock()
+		st.Set("v", i)
+		st.Unlock()
+	}
+
+	c.Check(b.checkpoints, HasLen, 3)
+	c.Check(b.journal, HasLen, 0)
+}
+
+func (s *journalSuite) TestAppendsOnlyChanges(c *C) {
+	b := new(fakeJournalBackend)
+	st := newJournaledState(b)
+	st.Lock()
+	st.Set("b", "value")
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.journal, HasLen, 1)
+	c.Check(b.journal[0], Equals, `{"seq":1,"data":{"b":"value"},"last-change-id":0,"last-task-id":0,"last-lane-id":0}`+"\n")
+
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.journal, HasLen, 2)
+	c.Check(b.journal[1], Equals, `{"seq":2,"data":{"a":2},"last-change-id":0,"last-task-id":0,"last-lane-id":0}`+"\n")
+
+	st.Lock()
+	st.Set("b", nil)
+	st.Unlock()
+
+	c.Assert(b.journal, HasLen, 3)
+	c.Check(b.journal[2], Equals, `{"seq":3,"removed-data":["b"],"last-change-id":0,"last-task-id":0,"last-lane-id":0}`+"\n")
+
+	// modifying the state without changing it writes nothing
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	c.Check(b.checkpoints, HasLen, 1)
+	c.Check(b.journal, HasLen, 3)
+
+	st2 := b.read(c)
+	st2.Lock()
+	defer st2.Unlock()
+	var a int
+	c.Assert(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, 2)
+	var v string
+	c.Check(st2.Get("b", &v), Equals, state.ErrNoState)
+}
+
+func (s *journalSuite) TestReplayChangesTasksAndWarnings(c *C) {
+	b := new(fakeJournalBackend)
+	st := newJournaledState(b)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "1...")
+	t2 := st.NewTask("install", "2...")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Warnf("hello")
+	st.Unlock()
+
+	st.Lock()
+	t1.SetStatus(state.DoneStatus)
+	t1.Set("k", "v")
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.journal, HasLen, 2)
+	// the untouched change and task were not written again
+	c.Check(b.journal[1], Not(Matches), `(?s).*"changes".*`)
+	c.Check(b.journal[1], Matches, `\{"seq":2,"tasks":\{"1":.*\n`)
+
+	st2 := b.read(c)
+	st2.Lock()
+	defer st2.Unlock()
+
+	c.Assert(st2.Changes(), HasLen, 1)
+	chg2 := st2.Change(chg.ID())
+	c.Assert(chg2, NotNil)
+	c.Check(chg2.Kind(), Equals, "install")
+	c.Assert(chg2.Tasks(), HasLen, 2)
+	t12 := st2.Task(t1.ID())
+	c.Check(t12.Status(), Equals, state.DoneStatus)
+	var v string
+	c.Assert(t12.Get("k", &v), IsNil)
+	c.Check(v, Equals, "v")
+	c.Check(st2.Task(t2.ID()).WaitTasks(), HasLen, 1)
+	c.Assert(st2.AllWarnings(), HasLen, 1)
+	c.Check(st2.AllWarnings()[0].String(), Equals, "hello")
+
+	// ids keep going from where they were
+	c.Check(st2.NewChange("other", "...").ID(), Equals, "2")
+	c.Check(st2.NewTask("other", "...").ID(), Equals, "3")
+}
+
+func (s *journalSuite) TestCompaction(c *C) {
+	b := new(fakeJournalBackend)
+	st := newJournaledState(b)
+
+	// keep appending until the journal outgrows the snapshot
+	for i := 1; len(b.checkpoints) == 1; i++ {
+		c.Assert(i < 1000, Equals, true)
+		st.Lock()
+		st.Set("a", i)
+		st.Unlock()
+	}
+
+	c.Check(b.journal, HasLen, 0)
+	c.Check(string(b.snapshot()), Matches, `.*"journal-seq":[1-9][0-9]*.*`)
+
+	// appending resumes with the following sequence number
+	st.Lock()
+	st.Set("a", -1)
+	st.Unlock()
+
+	c.Assert(b.journal, HasLen, 1)
+	var snapshot struct {
+		Seq int `json:"journal-seq"`
+	}
+	c.Assert(json.Unmarshal(b.snapshot(), &snapshot), IsNil)
+	var entry struct {
+		Seq int `json:"seq"`
+	}
+	c.Assert(json.Unmarshal([]byte(b.journal[0]), &entry), IsNil)
+	c.Check(entry.Seq, Equals, snapshot.Seq+1)
+
+	st2 := b.read(c)
+	st2.Lock()
+	defer st2.Unlock()
+	var a int
+	c.Assert(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, -1)
+}
+
+func (s *journalSuite) TestCompactJournal(c *C) {
+	b := new(fakeJournalBackend)
+	st := newJournaledState(b)
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.journal, HasLen, 1)
+
+	// compacting writes a full snapshot even if nothing changed
+	st.Lock()
+	st.CompactJournal()
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 2)
+	c.Check(b.journal, HasLen, 0)
+	c.Check(string(b.snapshot()), Matches, `.*"a":2.*`)
+
+	// but only if there is a journal to fold in
+	st.Lock()
+	st.CompactJournal()
+	st.Unlock()
+
+	c.Check(b.checkpoints, HasLen, 2)
+}
+
+func (s *journalSuite) TestRequestRestartCompactsJournal(c *C) {
+	b := new(fakeJournalBackend)
+	st := newJournaledState(b)
+
+	st.Lock()
+	st.Set("a", 2)
+	st.RequestRestart(state.RestartDaemon)
+	st.Unlock()
+
+	c.Check(b.restartRequested, Equals, true)
+	c.Assert(b.checkpoints, HasLen, 2)
+	c.Check(b.journal, HasLen, 0)
+	c.Check(string(b.snapshot()), Matches, `.*"a":2.*`)
+}
+
+func (s *journalSuite) TestAppendJournalRetries(c *C) {
+	restore := state.MockCheckpointRetryDelay(time.Millisecond, time.Second)
+	defer restore()
+
+	b := new(fakeJournalBackend)
+	st := newJournaledState(b)
+
+	n := 0
+	b.journalError = func() error {
+		n++
+		if n < 3 {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+
+	c.Check(n, Equals, 3)
+	c.Assert(b.journal, HasLen, 1)
+	c.Check(b.journal[0], Matches, `\{"seq":1,.*\n`)
+	c.Check(st.Modified(), Equals, false)
+}
+
+const journalSnapshot = `{"data":{"a":1},"changes":{},"tasks":{},"last-change-id":0,"last-task-id":0,"last-lane-id":0,"journal-seq":1}`
+
+func (s *journalSuite) TestReplayJournal(c *C) {
+	journal := `{"seq":1,"data":{"a":0},"last-change-id":0,"last-task-id":0,"last-lane-id":0}
+{"seq":2,"data":{"a":2,"b":3},"last-change-id":0,"last-task-id":0,"last-lane-id":0}
+{"seq":2,"data":{"a":2,"b":3},"last-change-id":0,"last-task-id":0,"last-lane-id":0}
+{"seq":3,"removed-data":["b"],"last-change-id":0,"last-task-id":0,"last-lane-id":1}
+{"seq":4,"data":{"a":`
+
+	data, err := state.ReplayJournal(strings.NewReader(journalSnapshot), strings.NewReader(journal))
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"data":{"a":2},"changes":{},"tasks":{},"last-change-id":0,"last-task-id":0,"last-lane-id":1,"journal-seq":3}`)
+}
+
+func (s *journalSuite) TestReplayJournalCurrentFormat(c *C) {
+	b := new(fakeStateBackend)
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.NewChange("install", "...")
+	st.Unlock()
+
+	data, err := state.ReplayJournal(bytes.NewReader(b.checkpoints[0]), strings.NewReader(""))
+	c.Assert(err, IsNil)
+
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+	var a int
+	c.Assert(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, 1)
+	c.Check(st2.Changes(), HasLen, 1)
+}
+
+func (s *journalSuite) TestReplayJournalErrors(c *C) {
+	for _, t := range []struct {
+		snapshot string
+		journal  string
+		err      string
+	}{
+		{"{", "", "cannot read state: unexpected EOF"},
+		{journalSnapshot, "{\"seq\":2\n{}\n", "cannot replay state journal: invalid entry on line 1: .*"},
+		{journalSnapshot, "{\"seq\":3}\n", "cannot replay state journal: expected entry 2, got 3"},
+	} {
+		_, err := state.ReplayJournal(strings.NewReader(t.snapshot), strings.NewReader(t.journal))
+		c.Check(err, ErrorMatches, t.err, Commentf("journal: %q", t.journal))
+	}
+}
@@ -43,6 +43,15 @@ type Backend interface {
 	RequestRestart(t RestartType)
 }
 
+// An ArchiveBackend is a Backend that wants to keep a record of the
+// changes that Prune removes from the state.
+type ArchiveBackend interface {
+	Backend
+	// ArchiveChanges is called by Prune, with the state locked, with
+	// the ready changes it is about to remove.
+	ArchiveChanges(changes []*Change)
+}
+
 type customData map[string]*json.RawMessage
 
 func (data customData) get(key string, value interface{}) error {
@@ -83,6 +92,9 @@ const (
 	// RestartSocket will restart the daemon so that it goes into
 	// socket activation mode.
 	RestartSocket
+	// StopDaemon will stop the daemon without it being restarted,
+	// used when preseeding an image.
+	StopDaemon
 )
 
 // State represents an evolving system state that persists across restarts.
@@ -109,6 +121,15 @@ type State struct {
 
 	modified bool
 
+	// journalSeq is the sequence number of the last journal entry
+	// included in the state, and journal tracks what was persisted
+	// through a JournalBackend
+	journalSeq int
+	journal    *journalTracker
+	// compactJournal is set when the next checkpoint needs to be a
+	// full snapshot
+	compactJournal int32
+
 	cache map[interface{}]interface{}
 
 	restarting RestartType
@@ -166,6 +187,8 @@ type marshalledState struct {
 	LastChangeId int `json:"last-change-id"`
 	LastTaskId   int `json:"last-task-id"`
 	LastLaneId   int `json:"last-lane-id"`
+
+	JournalSeq int `json:"journal-seq,omitempty"`
 }
 
 // MarshalJSON makes State a json.Marshaller
@@ -180,6 +203,8 @@ func (s *State) MarshalJSON() ([]byte, error) {
 		LastTaskId:   s.lastTaskId,
 		LastChangeId: s.lastChangeId,
 		LastLaneId:   s.lastLaneId,
+
+		JournalSeq: s.journalSeq,
 	})
 }
 
@@ -198,6 +223,7 @@ func (s *State) UnmarshalJSON(data []byte) error {
 	s.lastChangeId = unmarshalled.LastChangeId
 	s.lastTaskId = unmarshalled.LastTaskId
 	s.lastLaneId = unmarshalled.LastLaneId
+	s.journalSeq = unmarshalled.JournalSeq
 	// backlink state again
 	for _, t := range s.tasks {
 		t.state = s
@@ -227,18 +253,29 @@ var (
 // Unlock releases the state lock and checkpoints the state.
 // It does not return until the state is correctly checkpointed.
 // After too many unsuccessful checkpoint attempts, it panics.
+//
+// With a JournalBackend, only the changes since the last checkpoint
+// are persisted once the state is large enough, unless CompactJournal
+// was called.
 func (s *State) Unlock() {
 	defer s.unlock()
 
-	if !s.modified || s.backend == nil {
+	compact := atomic.SwapInt32(&s.compactJournal, 0) == 1
+	if (!s.modified && !compact) || s.backend == nil {
 		return
 	}
 
-	data := s.checkpointData()
+	var checkpoint func() error
+	if backend, ok := s.backend.(JournalBackend); ok {
+		checkpoint = s.journaledCheckpoint(backend, compact)
+	} else {
+		data := s.checkpointData()
+		checkpoint = func() error { return s.backend.Checkpoint(data) }
+	}
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
-		if err = s.backend.Checkpoint(data); err == nil {
+		if err = checkpoint(); err == nil {
 			s.modified = false
 			return
 		}
@@ -254,9 +291,19 @@ func (s *State) EnsureBefore(d time.Duration) {
 	}
 }
 
+// CompactJournal makes the next unlock persist the whole state as a
+// single snapshot, folding in the journal, so that it can be read by
+// snapd versions that do not know about the journal.
+func (s *State) CompactJournal() {
+	atomic.StoreInt32(&s.compactJournal, 1)
+}
+
 // RequestRestart asks for a restart of the managing process.
+// The state is compacted on the next unlock, as what is started next
+// might not know about the journal.
 func (s *State) RequestRestart(t RestartType) {
 	if s.backend != nil {
+		s.CompactJournal()
 		s.restartLck.Lock()
 		s.restarting = t
 		s.restartLck.Unlock()
@@ -408,6 +455,9 @@ func (s *State) tasksIn(tids []string) []*Task {
 //    state will also removed even if they are below the pruneWait duration.
 //
 //  * it removes expired warnings.
+//
+// Ready changes are handed to the backend before being removed, if it is
+// an ArchiveBackend.
 func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	now := time.Now()
 	pruneLimit := now.Add(-pruneWait)
@@ -435,6 +485,7 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 		}
 	}
 
+	var pruned []*Change
 	for _, chg := range changes {
 		spawnTime := chg.SpawnTime()
 		readyTime := chg.ReadyTime()
@@ -449,15 +500,22 @@ func (s *State) Prune(pruneWait, abortWait time.Duration, maxReadyChanges int) {
 		}
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
-			s.writing()
-			for _, t := range chg.Tasks() {
-				delete(s.tasks, t.ID())
-			}
-			delete(s.changes, chg.ID())
+			pruned = append(pruned, chg)
 			readyChangesCount--
 		}
 	}
 
+	if archiver, ok := s.backend.(ArchiveBackend); ok && len(pruned) > 0 {
+		archiver.ArchiveChanges(pruned)
+	}
+	for _, chg := range pruned {
+		s.writing()
+		for _, t := range chg.Tasks() {
+			delete(s.tasks, t.ID())
+		}
+		delete(s.changes, chg.ID())
+	}
+
 	for tid, t := range s.tasks {
 		// TODO: this could be done more aggressively
 		if t.Change() == nil && t.SpawnTime().Before(pruneLimit) {
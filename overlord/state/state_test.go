@@ -190,6 +190,19 @@ func (b *fakeStateBackend) RequestRestart(t state.RestartType) {
 	b.restartRequested = true
 }
 
+type fakeArchiveBackend struct {
+	fakeStateBackend
+	archived      []*state.Change
+	archivedTasks int
+}
+
+func (b *fakeArchiveBackend) ArchiveChanges(changes []*state.Change) {
+	b.archived = append(b.archived, changes...)
+	for _, chg := range changes {
+		b.archivedTasks += len(chg.Tasks())
+	}
+}
+
 func (ss *stateSuite) TestImplicitCheckpointAndRead(c *C) {
 	b := new(fakeStateBackend)
 	st := state.New(b)
@@ -818,6 +831,44 @@ func (ss *stateSuite) TestPruneEmptyChange(c *C) {
 	c.Assert(st.Change(chg.ID()), IsNil)
 }
 
+func (ss *stateSuite) TestPruneArchivesChanges(c *C) {
+	b := &fakeArchiveBackend{}
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Now()
+	pruneWait := 1 * time.Hour
+	abortWait := 3 * time.Hour
+
+	chg1 := st.NewChange("prune", "...")
+	t1 := st.NewTask("foo", "...")
+	chg1.AddTask(t1)
+	state.MockChangeTimes(chg1, now.Add(-pruneWait), now.Add(-pruneWait))
+
+	chg2 := st.NewChange("ready-but-recent", "...")
+	chg2.AddTask(st.NewTask("foo", "..."))
+	state.MockChangeTimes(chg2, now.Add(-pruneWait), now.Add(-pruneWait/2))
+
+	// empty changes never became ready, there is nothing to archive
+	chg3 := st.NewChange("empty", "...")
+	state.MockChangeTimes(chg3, now.Add(-pruneWait), time.Time{})
+
+	st.Prune(pruneWait, abortWait, 100)
+
+	c.Assert(b.archived, DeepEquals, []*state.Change{chg1})
+	// the change was still whole when archived
+	c.Check(b.archivedTasks, Equals, 1)
+	c.Check(st.Change(chg1.ID()), IsNil)
+	c.Check(st.Task(t1.ID()), IsNil)
+	c.Check(st.Change(chg3.ID()), IsNil)
+
+	// nothing left to prune, nothing archived
+	b.archived = nil
+	st.Prune(pruneWait, abortWait, 100)
+	c.Check(b.archived, HasLen, 0)
+}
+
 func (ss *stateSuite) TestPruneMaxChangesHappy(c *C) {
 	st := state.New(&fakeStateBackend{})
 	st.Lock()
@@ -133,12 +133,20 @@ var OnWSL bool
 // ReleaseInfo contains data loaded from /etc/os-release on startup.
 var ReleaseInfo OS
 
+// PreseedMode states whether the process is running in preseed mode,
+// that is in a chroot of an image being prepared, to perform ahead of
+// time the steps of first boot seeding that do not need the actual
+// device. It is requested by setting SNAPD_PRESEED=1.
+var PreseedMode bool
+
 func init() {
 	ReleaseInfo = readOSRelease()
 
 	OnClassic = (ReleaseInfo.ID != "ubuntu-core")
 
 	OnWSL = isWSL()
+
+	PreseedMode = os.Getenv("SNAPD_PRESEED") == "1"
 }
 
 // MockOnClassic forces the process to appear inside a classic
@@ -149,6 +157,14 @@ func MockOnClassic(onClassic bool) (restore func()) {
 	return func() { OnClassic = old }
 }
 
+// MockPreseedMode forces the process to appear to be running in
+// preseed mode or not for testing purposes.
+func MockPreseedMode(preseed bool) (restore func()) {
+	old := PreseedMode
+	PreseedMode = preseed
+	return func() { PreseedMode = old }
+}
+
 // MockReleaseInfo fakes a given information to appear in ReleaseInfo,
 // as if it was read /etc/os-release on startup.
 func MockReleaseInfo(osRelease *OS) (restore func()) {
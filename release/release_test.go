@@ -140,6 +140,16 @@ func (s *ReleaseTestSuite) TestOnClassic(c *C) {
 	c.Assert(release.OnClassic, Equals, false)
 }
 
+func (s *ReleaseTestSuite) TestPreseedMode(c *C) {
+	reset := release.MockPreseedMode(true)
+	defer reset()
+	c.Assert(release.PreseedMode, Equals, true)
+
+	reset = release.MockPreseedMode(false)
+	defer reset()
+	c.Assert(release.PreseedMode, Equals, false)
+}
+
 func (s *ReleaseTestSuite) TestReleaseInfo(c *C) {
 	reset := release.MockReleaseInfo(&release.OS{
 		ID: "distro-id",
@@ -0,0 +1,430 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigSchema holds the configuration options a snap declares in the
+// config-schema section of its snap.yaml, indexed by option name.
+type ConfigSchema map[string]*ConfigOption
+
+// ConfigOption describes a single configuration option declared by a snap.
+type ConfigOption struct {
+	Type        string                   `yaml:"type" json:"type"`
+	Description string                   `yaml:"description,omitempty" json:"description,omitempty"`
+	Default     interface{}              `yaml:"default,omitempty" json:"default,omitempty"`
+	Enum        []interface{}            `yaml:"enum,omitempty" json:"enum,omitempty"`
+	Minimum     *float64                 `yaml:"minimum,omitempty" json:"minimum,omitempty"`
+	Maximum     *float64                 `yaml:"maximum,omitempty" json:"maximum,omitempty"`
+	Pattern     string                   `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	Items       *ConfigOption            `yaml:"items,omitempty" json:"items,omitempty"`
+	Properties  map[string]*ConfigOption `yaml:"properties,omitempty" json:"properties,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// The supported option types, modelled after JSON schema.
+const (
+	ConfigTypeString  = "string"
+	ConfigTypeInteger = "integer"
+	ConfigTypeNumber  = "number"
+	ConfigTypeBoolean = "boolean"
+	ConfigTypeArray   = "array"
+	ConfigTypeObject  = "object"
+)
+
+// validConfigOptionName mirrors the validation of configuration keys
+var validConfigOptionName = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+
+// ValidateConfigSchema checks that the declared configuration schema is
+// well formed, including that defaults and enumerations match the
+// declared types.
+func ValidateConfigSchema(schema ConfigSchema) error {
+	for name, opt := range schema {
+		if err := validateConfigOption(name, opt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateConfigOption(key string, opt *ConfigOption) error {
+	name := key[strings.LastIndex(key, ".")+1:]
+	if !validConfigOptionName.MatchString(name) {
+		return fmt.Errorf("invalid configuration option name: %q", key)
+	}
+	if opt == nil {
+		return fmt.Errorf("configuration option %q must declare a type", key)
+	}
+
+	switch opt.Type {
+	case ConfigTypeString, ConfigTypeInteger, ConfigTypeNumber, ConfigTypeBoolean, ConfigTypeArray, ConfigTypeObject:
+	case "":
+		return fmt.Errorf("configuration option %q must declare a type", key)
+	default:
+		return fmt.Errorf("configuration option %q has unsupported type %q", key, opt.Type)
+	}
+
+	if (opt.Minimum != nil || opt.Maximum != nil) && opt.Type != ConfigTypeInteger && opt.Type != ConfigTypeNumber {
+		return fmt.Errorf("configuration option %q of type %s cannot have a minimum or maximum", key, opt.Type)
+	}
+	if opt.Minimum != nil && opt.Maximum != nil && *opt.Minimum > *opt.Maximum {
+		return fmt.Errorf("configuration option %q has a minimum greater than its maximum", key)
+	}
+	if opt.Pattern != "" {
+		if opt.Type != ConfigTypeString {
+			return fmt.Errorf("configuration option %q of type %s cannot have a pattern", key, opt.Type)
+		}
+		if _, err := opt.compiledPattern(); err != nil {
+			return fmt.Errorf("configuration option %q has an invalid pattern: %v", key, err)
+		}
+	}
+	if opt.Items != nil {
+		if opt.Type != ConfigTypeArray {
+			return fmt.Errorf("configuration option %q of type %s cannot have items", key, opt.Type)
+		}
+		if err := validateConfigOption(key+".items", opt.Items); err != nil {
+			return err
+		}
+	}
+	if opt.Properties != nil {
+		if opt.Type != ConfigTypeObject {
+			return fmt.Errorf("configuration option %q of type %s cannot have properties", key, opt.Type)
+		}
+		for name, prop := range opt.Properties {
+			if err := validateConfigOption(key+"."+name, prop); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, v := range opt.Enum {
+		if err := opt.checkType(v); err != nil {
+			return fmt.Errorf("configuration option %q has an invalid enum value: %v", key, err)
+		}
+	}
+	if opt.Default != nil {
+		if err := opt.Validate(opt.Default); err != nil {
+			return fmt.Errorf("configuration option %q has an invalid default: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func (opt *ConfigOption) compiledPattern() (*regexp.Regexp, error) {
+	if opt.pattern == nil && opt.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + opt.Pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		opt.pattern = re
+	}
+	return opt.pattern, nil
+}
+
+// normalize converts the default and enumeration values as decoded from
+// YAML into the form used for configuration values.
+func (opt *ConfigOption) normalize() {
+	if opt == nil {
+		return
+	}
+	opt.Default = normalizeConfigValue(opt.Default)
+	for i, v := range opt.Enum {
+		opt.Enum[i] = normalizeConfigValue(v)
+	}
+	opt.Items.normalize()
+	for _, prop := range opt.Properties {
+		prop.normalize()
+	}
+}
+
+// normalizeConfigValue converts values as decoded from YAML into the
+// form used for values decoded from JSON.
+func normalizeConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			m[fmt.Sprintf("%v", k)] = normalizeConfigValue(vv)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			m[k] = normalizeConfigValue(vv)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, vv := range v {
+			l[i] = normalizeConfigValue(vv)
+		}
+		return l
+	case int:
+		return json.Number(strconv.Itoa(v))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return v
+}
+
+func configNumber(v interface{}) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+func (opt *ConfigOption) checkType(value interface{}) error {
+	switch opt.Type {
+	case ConfigTypeString:
+		if _, ok := value.(string); ok {
+			return nil
+		}
+	case ConfigTypeBoolean:
+		if _, ok := value.(bool); ok {
+			return nil
+		}
+	case ConfigTypeNumber:
+		if _, ok := configNumber(value); ok {
+			return nil
+		}
+	case ConfigTypeInteger:
+		if f, ok := configNumber(value); ok && f == math.Trunc(f) {
+			return nil
+		}
+	case ConfigTypeArray:
+		if _, ok := value.([]interface{}); ok {
+			return nil
+		}
+	case ConfigTypeObject:
+		if _, ok := value.(map[string]interface{}); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("value must be of type %s", opt.Type)
+}
+
+func configValueString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Validate checks the given value, in the form produced by decoding JSON
+// with numbers kept as json.Number, against the option declaration. A nil
+// value means the option is unset and is always valid.
+func (opt *ConfigOption) Validate(value interface{}) error {
+	return opt.validate(value, true)
+}
+
+func (opt *ConfigOption) validate(value interface{}, strict bool) error {
+	if value == nil {
+		return nil
+	}
+	if err := opt.checkType(value); err != nil {
+		return err
+	}
+
+	if len(opt.Enum) > 0 {
+		found := false
+		for _, e := range opt.Enum {
+			if configValueString(e) == configValueString(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			choices := make([]string, len(opt.Enum))
+			for i, e := range opt.Enum {
+				choices[i] = configValueString(e)
+			}
+			return fmt.Errorf("value must be one of %s", strings.Join(choices, ", "))
+		}
+	}
+
+	switch value := value.(type) {
+	case json.Number:
+		f, _ := configNumber(value)
+		if opt.Minimum != nil && f < *opt.Minimum {
+			return fmt.Errorf("value must be at least %v", *opt.Minimum)
+		}
+		if opt.Maximum != nil && f > *opt.Maximum {
+			return fmt.Errorf("value must be at most %v", *opt.Maximum)
+		}
+	case string:
+		re, err := opt.compiledPattern()
+		if err != nil {
+			return err
+		}
+		if re != nil && !re.MatchString(value) {
+			return fmt.Errorf("value must match %q", opt.Pattern)
+		}
+	case []interface{}:
+		if opt.Items != nil {
+			for i, item := range value {
+				if err := opt.Items.validate(item, strict); err != nil {
+					return fmt.Errorf("item %d: %v", i, err)
+				}
+			}
+		}
+	case map[string]interface{}:
+		if opt.Properties != nil {
+			if err := validateConfigProperties(opt.Properties, value, strict); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateConfigProperties checks the values of an object against its
+// declared properties; unless strict, undeclared ones are let through.
+func validateConfigProperties(props map[string]*ConfigOption, value map[string]interface{}, strict bool) error {
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop, ok := props[name]
+		if !ok {
+			if !strict {
+				continue
+			}
+			return fmt.Errorf("option %q is not declared", name)
+		}
+		if err := prop.validate(value[name], strict); err != nil {
+			return fmt.Errorf("option %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks a value being set for the given dotted configuration
+// key against the schema. The empty key refers to the whole configuration
+// document. Options nested under an object that does not declare its
+// properties are not checked.
+func (schema ConfigSchema) Validate(key string, value interface{}) error {
+	return schema.validate(key, value, true)
+}
+
+// LenientConfigSchema is a ConfigSchema that lets undeclared options be
+// set, while still checking the values of the declared ones.
+type LenientConfigSchema ConfigSchema
+
+// Lenient returns the schema as a LenientConfigSchema. It is meant for
+// the options set by the snap itself, which can keep internal state in
+// options it does not document.
+func (schema ConfigSchema) Lenient() LenientConfigSchema {
+	return LenientConfigSchema(schema)
+}
+
+// Validate checks a value being set for the given dotted configuration
+// key against the declared options, if any.
+func (schema LenientConfigSchema) Validate(key string, value interface{}) error {
+	return ConfigSchema(schema).validate(key, value, false)
+}
+
+func (schema ConfigSchema) validate(key string, value interface{}, strict bool) error {
+	if key == "" {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("configuration must be an object")
+		}
+		return validateConfigProperties(schema, m, strict)
+	}
+
+	props := map[string]*ConfigOption(schema)
+	subkeys := strings.Split(key, ".")
+	for i, subkey := range subkeys {
+		opt, ok := props[subkey]
+		if !ok {
+			if !strict {
+				return nil
+			}
+			return fmt.Errorf("option %q is not declared", strings.Join(subkeys[:i+1], "."))
+		}
+		if i == len(subkeys)-1 {
+			return opt.validate(value, strict)
+		}
+		if opt.Type != ConfigTypeObject {
+			return fmt.Errorf("option %q is not an object", strings.Join(subkeys[:i+1], "."))
+		}
+		if opt.Properties == nil {
+			// free-form object
+			return nil
+		}
+		props = opt.Properties
+	}
+	return nil
+}
+
+// Defaults returns the default values declared in the schema as a map of
+// dotted option keys to values.
+func (schema ConfigSchema) Defaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+	schema.Walk(func(key string, opt *ConfigOption) {
+		if opt.Default != nil {
+			defaults[key] = opt.Default
+		}
+	})
+	return defaults
+}
+
+// Walk calls f for every declared option, including nested properties,
+// in key order.
+func (schema ConfigSchema) Walk(f func(key string, opt *ConfigOption)) {
+	walkConfigOptions("", schema, f)
+}
+
+func walkConfigOptions(prefix string, props map[string]*ConfigOption, f func(key string, opt *ConfigOption)) {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opt := props[name]
+		key := prefix + name
+		f(key, opt)
+		if opt.Properties != nil {
+			walkConfigOptions(key+".", opt.Properties, f)
+		}
+	}
+}
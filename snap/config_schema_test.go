@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type configSchemaSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&configSchemaSuite{})
+
+func (s *configSchemaSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.BaseTest.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+}
+
+func (s *configSchemaSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+const configSchemaYaml = `
+name: foo
+version: 1.0
+config-schema:
+  port:
+    type: integer
+    default: 8080
+    minimum: 1
+    maximum: 65535
+  mode:
+    type: string
+    enum: [fast, slow]
+  name:
+    type: string
+    pattern: "[a-z]+"
+  tags:
+    type: array
+    items:
+      type: string
+  server:
+    type: object
+    properties:
+      ratio:
+        type: number
+        default: 0.5
+      debug:
+        type: boolean
+  extra:
+    type: object
+`
+
+func (s *configSchemaSuite) schema(c *C) snap.ConfigSchema {
+	info, err := snap.InfoFromSnapYaml([]byte(configSchemaYaml))
+	c.Assert(err, IsNil)
+	c.Assert(snap.Validate(info), IsNil)
+	return info.ConfigSchema
+}
+
+func (s *configSchemaSuite) TestValidateHappy(c *C) {
+	schema := s.schema(c)
+
+	for _, t := range []struct {
+		key   string
+		value interface{}
+	}{
+		{"port", json.Number("80")},
+		{"mode", "fast"},
+		{"name", "foo"},
+		{"tags", []interface{}{"a", "b"}},
+		{"server", map[string]interface{}{"ratio": json.Number("1.5"), "debug": true}},
+		{"server.debug", false},
+		{"extra", map[string]interface{}{"anything": "goes"}},
+		{"extra.anything.goes", json.Number("1")},
+		{"port", nil},
+		{"", map[string]interface{}{"port": json.Number("1"), "mode": "slow"}},
+	} {
+		c.Check(schema.Validate(t.key, t.value), IsNil, Commentf("%s: %v", t.key, t.value))
+	}
+}
+
+func (s *configSchemaSuite) TestValidateUnhappy(c *C) {
+	schema := s.schema(c)
+
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"port", "80", `value must be of type integer`},
+		{"port", json.Number("1.5"), `value must be of type integer`},
+		{"port", json.Number("0"), `value must be at least 1`},
+		{"port", json.Number("70000"), `value must be at most 65535`},
+		{"mode", "medium", `value must be one of "fast", "slow"`},
+		{"name", "Foo", `value must match "\[a-z\]\+"`},
+		{"tags", []interface{}{"a", true}, `item 1: value must be of type string`},
+		{"server", map[string]interface{}{"ratio": "high"}, `option "ratio": value must be of type number`},
+		{"server", map[string]interface{}{"other": true}, `option "other" is not declared`},
+		{"server.other", true, `option "server.other" is not declared`},
+		{"port.foo", true, `option "port" is not an object`},
+		{"unknown", true, `option "unknown" is not declared`},
+		{"", "foo", `configuration must be an object`},
+	} {
+		c.Check(schema.Validate(t.key, t.value), ErrorMatches, t.err, Commentf("%s: %v", t.key, t.value))
+	}
+}
+
+func (s *configSchemaSuite) TestValidateLenient(c *C) {
+	schema := s.schema(c).Lenient()
+
+	// undeclared options can be set
+	for _, t := range []struct {
+		key   string
+		value interface{}
+	}{
+		{"unknown", true},
+		{"unknown.nested", "foo"},
+		{"server.other", true},
+		{"server", map[string]interface{}{"other": true, "debug": false}},
+		{"", map[string]interface{}{"port": json.Number("1"), "internal": "state"}},
+	} {
+		c.Check(schema.Validate(t.key, t.value), IsNil, Commentf("%s: %v", t.key, t.value))
+	}
+
+	// but declared ones are still checked
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"port", json.Number("70000"), `value must be at most 65535`},
+		{"server", map[string]interface{}{"ratio": "high", "other": true}, `option "ratio": value must be of type number`},
+		{"port.foo", true, `option "port" is not an object`},
+		{"", map[string]interface{}{"mode": "medium"}, `option "mode": value must be one of "fast", "slow"`},
+	} {
+		c.Check(schema.Validate(t.key, t.value), ErrorMatches, t.err, Commentf("%s: %v", t.key, t.value))
+	}
+}
+
+func (s *configSchemaSuite) TestDefaults(c *C) {
+	schema := s.schema(c)
+	c.Check(schema.Defaults(), DeepEquals, map[string]interface{}{
+		"port":         json.Number("8080"),
+		"server.ratio": json.Number("0.5"),
+	})
+}
+
+func (s *configSchemaSuite) TestWalk(c *C) {
+	schema := s.schema(c)
+	var keys []string
+	schema.Walk(func(key string, opt *snap.ConfigOption) {
+		keys = append(keys, key)
+	})
+	c.Check(keys, DeepEquals, []string{"extra", "mode", "name", "port", "server", "server.debug", "server.ratio", "tags"})
+}
+
+func (s *configSchemaSuite) TestValidateConfigSchema(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"Foo: {type: string}", `invalid configuration option name: "Foo"`},
+		{"foo: {}", `configuration option "foo" must declare a type`},
+		{"foo: {type: float}", `configuration option "foo" has unsupported type "float"`},
+		{"foo: {type: string, minimum: 1}", `configuration option "foo" of type string cannot have a minimum or maximum`},
+		{"foo: {type: integer, minimum: 2, maximum: 1}", `configuration option "foo" has a minimum greater than its maximum`},
+		{"foo: {type: integer, pattern: a}", `configuration option "foo" of type integer cannot have a pattern`},
+		{"foo: {type: string, pattern: '('}", `configuration option "foo" has an invalid pattern: .*`},
+		{"foo: {type: string, items: {type: string}}", `configuration option "foo" of type string cannot have items`},
+		{"foo: {type: array, items: {type: bar}}", `configuration option "foo.items" has unsupported type "bar"`},
+		{"foo: {type: string, properties: {bar: {type: string}}}", `configuration option "foo" of type string cannot have properties`},
+		{"foo: {type: object, properties: {Bar: {type: string}}}", `invalid configuration option name: "foo.Bar"`},
+		{"foo: {type: string, enum: [a, 1]}", `configuration option "foo" has an invalid enum value: value must be of type string`},
+		{"foo: {type: string, default: 1}", `configuration option "foo" has an invalid default: value must be of type string`},
+		{"foo: {type: integer, default: 5, maximum: 3}", `configuration option "foo" has an invalid default: value must be at most 3`},
+	} {
+		info, err := snap.InfoFromSnapYaml([]byte("name: foo\nversion: 1.0\nconfig-schema:\n  " + t.yaml))
+		c.Assert(err, IsNil, Commentf(t.yaml))
+		c.Check(snap.Validate(info), ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
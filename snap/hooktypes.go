@@ -30,6 +30,9 @@ var supportedHooks = []*HookType{
 	NewHookType(regexp.MustCompile("^pre-refresh$")),
 	NewHookType(regexp.MustCompile("^post-refresh$")),
 	NewHookType(regexp.MustCompile("^remove$")),
+	NewHookType(regexp.MustCompile("^pre-snapshot$")),
+	NewHookType(regexp.MustCompile("^post-snapshot$")),
+	NewHookType(regexp.MustCompile("^post-restore$")),
 	NewHookType(regexp.MustCompile("^prepare-(?:plug|slot)-[-a-z0-9]+$")),
 	NewHookType(regexp.MustCompile("^unprepare-(?:plug|slot)-[-a-z0-9]+$")),
 	NewHookType(regexp.MustCompile("^connect-(?:plug|slot)-[-a-z0-9]+$")),
@@ -252,6 +252,9 @@ type Info struct {
 
 	Layout map[string]*Layout
 
+	// ConfigSchema holds the configuration options declared by the snap.
+	ConfigSchema ConfigSchema
+
 	// The list of common-ids from all apps of the snap
 	CommonIDs []string
 }
@@ -277,6 +280,7 @@ type Layout struct {
 	Group    string      `json:"group,omitempty"`
 	Mode     os.FileMode `json:"mode,omitempty"`
 	Symlink  string      `json:"symlink,omitempty"`
+	Upper    string      `json:"upper,omitempty"`
 }
 
 // String returns a simple textual representation of a layout.
@@ -292,6 +296,9 @@ func (l *Layout) String() string {
 		fmt.Fprintf(&buf, "symlink %s", l.Symlink)
 	case l.Type != "":
 		fmt.Fprintf(&buf, "type %s", l.Type)
+		if l.Upper != "" {
+			fmt.Fprintf(&buf, ", upper: %s", l.Upper)
+		}
 	default:
 		fmt.Fprintf(&buf, "???")
 	}
@@ -307,6 +314,15 @@ func (l *Layout) String() string {
 	return buf.String()
 }
 
+// OverlayDirs returns the upper and work directories of an overlay layout,
+// with snap variables expanded. The work directory must be on the same
+// filesystem as the upper directory so it is kept right next to it.
+func (l *Layout) OverlayDirs() (upper, work string) {
+	upper = l.Snap.ExpandSnapVariables(l.Upper)
+	work = filepath.Join(filepath.Dir(upper), "."+filepath.Base(upper)+".overlay-work")
+	return upper, work
+}
+
 // ChannelSnapInfo is the minimum information that can be used to clearly
 // distinguish different revisions of the same snap.
 type ChannelSnapInfo struct {
@@ -52,6 +52,7 @@ type snapYaml struct {
 	Apps          map[string]appYaml     `yaml:"apps,omitempty"`
 	Hooks         map[string]hookYaml    `yaml:"hooks,omitempty"`
 	Layout        map[string]layoutYaml  `yaml:"layout,omitempty"`
+	ConfigSchema  ConfigSchema           `yaml:"config-schema,omitempty"`
 
 	// TypoLayouts is used to detect the use of the incorrect plural form of "layout"
 	TypoLayouts typoDetector `yaml:"layouts,omitempty"`
@@ -118,6 +119,7 @@ type layoutYaml struct {
 	Group    string `yaml:"group,omitempty"`
 	Mode     string `yaml:"mode,omitempty"`
 	Symlink  string `yaml:"symlink,omitempty"`
+	Upper    string `yaml:"upper,omitempty"`
 }
 
 type socketsYaml struct {
@@ -212,11 +214,19 @@ func infoFromSnapYaml(yamlData []byte, strk *scopedTracker) (*Info, error) {
 			snap.Layout[path] = &Layout{
 				Snap: snap, Path: path,
 				Bind: l.Bind, Type: l.Type, Symlink: l.Symlink, BindFile: l.BindFile,
-				User: user, Group: group, Mode: mode,
+				User: user, Group: group, Mode: mode, Upper: l.Upper,
 			}
 		}
 	}
 
+	// Collect the declared configuration options.
+	if y.ConfigSchema != nil {
+		for _, opt := range y.ConfigSchema {
+			opt.normalize()
+		}
+		snap.ConfigSchema = y.ConfigSchema
+	}
+
 	// Rename specific plugs on the core snap.
 	snap.renameClashingCorePlugs()
 
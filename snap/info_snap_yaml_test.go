@@ -20,6 +20,7 @@
 package snap_test
 
 import (
+	"encoding/json"
 	"regexp"
 	"testing"
 	"time"
@@ -1704,6 +1705,41 @@ layout:
 	})
 }
 
+func (s *YamlSuite) TestConfigSchema(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+config-schema:
+  port:
+    type: integer
+    description: the port to listen on
+    default: 8080
+    minimum: 1
+    maximum: 65535
+  mode:
+    type: string
+    enum: [fast, slow]
+  server:
+    type: object
+    properties:
+      ratio:
+        type: number
+        default: 0.5
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Assert(info.ConfigSchema, HasLen, 3)
+
+	port := info.ConfigSchema["port"]
+	c.Check(port.Type, Equals, "integer")
+	c.Check(port.Description, Equals, "the port to listen on")
+	c.Check(port.Default, Equals, json.Number("8080"))
+	c.Check(*port.Minimum, Equals, float64(1))
+	c.Check(*port.Maximum, Equals, float64(65535))
+	c.Check(info.ConfigSchema["mode"].Enum, DeepEquals, []interface{}{"fast", "slow"})
+	c.Check(info.ConfigSchema["server"].Properties["ratio"].Default, Equals, json.Number("0.5"))
+}
+
 func (s *YamlSuite) TestLayoutsWithTypo(c *C) {
 	y := []byte(`
 name: foo
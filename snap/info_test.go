@@ -1242,6 +1242,9 @@ layout:
     mode: 1777
   /mylink:
     symlink: /link/target
+  $SNAP/etc:
+    type: overlay
+    upper: $SNAP_DATA/etc
 `))
 	c.Assert(err, IsNil)
 
@@ -1271,6 +1274,16 @@ layout:
 		Mode:    0755,
 		Symlink: "/link/target",
 	})
+	c.Check(layout["$SNAP/etc"], DeepEquals, &snap.Layout{
+		Snap:  info,
+		Path:  "$SNAP/etc",
+		Type:  "overlay",
+		Upper: "$SNAP_DATA/etc",
+		User:  "root",
+		Group: "root",
+		Mode:  0755,
+	})
+	c.Check(layout["$SNAP/etc"].String(), Equals, "$SNAP/etc: type overlay, upper: $SNAP_DATA/etc")
 }
 
 func (s *infoSuite) TestPlugInfoString(c *C) {
@@ -0,0 +1,95 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// SeedManifestSnap records exactly what went into the seed of an image
+// for one of its snaps.
+type SeedManifestSnap struct {
+	Name      string   `yaml:"name"`
+	SnapID    string   `yaml:"snap-id,omitempty"`
+	Revision  Revision `yaml:"revision"`
+	Channel   string   `yaml:"channel,omitempty"`
+	File      string   `yaml:"file"`
+	SHA3_384  string   `yaml:"sha3-384"`
+	Publisher string   `yaml:"publisher,omitempty"`
+	Base      string   `yaml:"base,omitempty"`
+
+	// License is the SPDX license expression of the snap, normalized
+	// if it could be parsed.
+	License string `yaml:"license,omitempty"`
+	// LicenseError flags a license that is not a valid SPDX
+	// expression.
+	LicenseError string `yaml:"license-error,omitempty"`
+}
+
+// SeedManifest lists the snaps that went into the seed of an image
+// for the given model.
+type SeedManifest struct {
+	Series  string `yaml:"series"`
+	BrandID string `yaml:"brand-id"`
+	Model   string `yaml:"model"`
+
+	Snaps []*SeedManifestSnap `yaml:"snaps"`
+}
+
+func ReadSeedManifest(fn string) (*SeedManifest, error) {
+	errPrefix := "cannot read seed manifest"
+
+	yamlData, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", errPrefix, err)
+	}
+
+	var manifest SeedManifest
+	if err := yaml.Unmarshal(yamlData, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: cannot unmarshal %q: %s", errPrefix, yamlData, err)
+	}
+
+	for _, sn := range manifest.Snaps {
+		if sn == nil {
+			return nil, fmt.Errorf("%s: empty element in manifest", errPrefix)
+		}
+		if err := ValidateInstanceName(sn.Name); err != nil {
+			return nil, fmt.Errorf("%s: %v", errPrefix, err)
+		}
+		if sn.File == "" {
+			return nil, fmt.Errorf(`%s: "file" attribute for %q cannot be empty`, errPrefix, sn.Name)
+		}
+	}
+
+	return &manifest, nil
+}
+
+func (manifest *SeedManifest) Write(fn string) error {
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(fn, data, 0644, 0)
+}
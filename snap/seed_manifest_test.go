@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type seedManifestTestSuite struct{}
+
+var _ = Suite(&seedManifestTestSuite{})
+
+func (s *seedManifestTestSuite) TestRoundTrip(c *C) {
+	manifest := &snap.SeedManifest{
+		Series:  "16",
+		BrandID: "my-brand",
+		Model:   "my-model",
+		Snaps: []*snap.SeedManifestSnap{{
+			Name:      "core",
+			SnapID:    "core-id",
+			Revision:  snap.R(3),
+			Channel:   "stable",
+			File:      "core_3.snap",
+			SHA3_384:  "sha3",
+			Publisher: "canonical",
+			License:   "GPL-3.0",
+		}, {
+			Name:         "local",
+			Revision:     snap.R(-1),
+			File:         "local_x1.snap",
+			SHA3_384:     "sha3",
+			Base:         "core18",
+			License:      "Other",
+			LicenseError: "unknown license: Other",
+		}},
+	}
+	fn := filepath.Join(c.MkDir(), "manifest.yaml")
+	c.Assert(manifest.Write(fn), IsNil)
+
+	read, err := snap.ReadSeedManifest(fn)
+	c.Assert(err, IsNil)
+	c.Check(read, DeepEquals, manifest)
+}
+
+func (s *seedManifestTestSuite) TestReadErrors(c *C) {
+	fn := filepath.Join(c.MkDir(), "manifest.yaml")
+	for _, t := range []struct {
+		yaml   string
+		errStr string
+	}{
+		{"snaps:\n - \n", `cannot read seed manifest: empty element in manifest`},
+		{"snaps:\n - name: foo_\n   file: foo.snap\n", `cannot read seed manifest: invalid instance key: ""`},
+		{"snaps:\n - name: foo\n", `cannot read seed manifest: "file" attribute for "foo" cannot be empty`},
+	} {
+		c.Assert(ioutil.WriteFile(fn, []byte(t.yaml), 0644), IsNil)
+		_, err := snap.ReadSeedManifest(fn)
+		c.Check(err, ErrorMatches, t.errStr, Commentf("yaml: %q", t.yaml))
+	}
+}
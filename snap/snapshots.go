@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SnapshotOptions holds the snapshot-specific metadata of a snap, as
+// declared in meta/snapshots.yaml.
+type SnapshotOptions struct {
+	// Exclude is a list of globs of paths to leave out of snapshots;
+	// each one is rooted at one of the snap's data directories, like
+	// $SNAP_DATA/cache/* or $SNAP_USER_COMMON/*.tmp.
+	Exclude []string `yaml:"exclude" json:"exclude,omitempty"`
+}
+
+// snapshotExcludeVars are the variables an exclusion can be rooted at.
+var snapshotExcludeVars = []string{"$SNAP_DATA", "$SNAP_COMMON", "$SNAP_USER_DATA", "$SNAP_USER_COMMON"}
+
+// SplitSnapshotExclude splits an exclusion into the variable it is
+// rooted at and the glob relative to it.
+func SplitSnapshotExclude(exclude string) (variable, glob string, err error) {
+	idx := strings.IndexRune(exclude, '/')
+	if idx < 0 {
+		return "", "", fmt.Errorf("snapshot exclusion %q must be a path below one of %s", exclude, strings.Join(snapshotExcludeVars, ", "))
+	}
+	variable, glob = exclude[:idx], exclude[idx+1:]
+	found := false
+	for _, v := range snapshotExcludeVars {
+		if v == variable {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", "", fmt.Errorf("snapshot exclusion %q must be a path below one of %s", exclude, strings.Join(snapshotExcludeVars, ", "))
+	}
+	if glob == "" || path.Clean(glob) != glob || glob == ".." || strings.HasPrefix(glob, "../") {
+		return "", "", fmt.Errorf("snapshot exclusion %q must be a clean path below %s", exclude, variable)
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return "", "", fmt.Errorf("snapshot exclusion %q is not a valid glob: %v", exclude, err)
+	}
+	return variable, glob, nil
+}
+
+// Validate checks the snapshot options are valid.
+func (opts *SnapshotOptions) Validate() error {
+	for _, exclude := range opts.Exclude {
+		if _, _, err := SplitSnapshotExclude(exclude); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSnapshotYaml reads the snapshot options from meta/snapshots.yaml
+// in the snap. The file is optional; if it is missing, empty options
+// are returned.
+func ReadSnapshotYaml(info *Info) (*SnapshotOptions, error) {
+	const errorFormat = "cannot read snapshot options of snap %q: %v"
+
+	var opts SnapshotOptions
+	content, err := ioutil.ReadFile(filepath.Join(info.MountDir(), "meta", "snapshots.yaml"))
+	if os.IsNotExist(err) {
+		return &opts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(errorFormat, info.InstanceName(), err)
+	}
+	if err := yaml.UnmarshalStrict(content, &opts); err != nil {
+		return nil, fmt.Errorf(errorFormat, info.InstanceName(), err)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf(errorFormat, info.InstanceName(), err)
+	}
+	return &opts, nil
+}
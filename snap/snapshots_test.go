@@ -0,0 +1,97 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type snapshotYamlSuite struct{}
+
+var _ = Suite(&snapshotYamlSuite{})
+
+func (s *snapshotYamlSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *snapshotYamlSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (s *snapshotYamlSuite) mockSnap(c *C, snapshotsYaml string) *snap.Info {
+	info := snaptest.MockSnap(c, "name: foo\nversion: 1\n", &snap.SideInfo{Revision: snap.R(42)})
+	if snapshotsYaml != "" {
+		err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "snapshots.yaml"), []byte(snapshotsYaml), 0644)
+		c.Assert(err, IsNil)
+	}
+	return info
+}
+
+func (s *snapshotYamlSuite) TestReadSnapshotYamlMissing(c *C) {
+	opts, err := snap.ReadSnapshotYaml(s.mockSnap(c, ""))
+	c.Assert(err, IsNil)
+	c.Check(opts, DeepEquals, &snap.SnapshotOptions{})
+}
+
+func (s *snapshotYamlSuite) TestReadSnapshotYaml(c *C) {
+	opts, err := snap.ReadSnapshotYaml(s.mockSnap(c, `
+exclude:
+  - $SNAP_DATA/cache/*
+  - $SNAP_COMMON/*.tmp
+  - $SNAP_USER_DATA/.cache
+`))
+	c.Assert(err, IsNil)
+	c.Check(opts, DeepEquals, &snap.SnapshotOptions{
+		Exclude: []string{"$SNAP_DATA/cache/*", "$SNAP_COMMON/*.tmp", "$SNAP_USER_DATA/.cache"},
+	})
+}
+
+func (s *snapshotYamlSuite) TestReadSnapshotYamlErrors(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"exclude: [$SNAP_DATA/*]\nfoo: bar\n", `(?s)cannot read snapshot options of snap "foo": .*field foo not found.*`},
+		{"exclude: [/var/snap/foo/*]\n", `cannot read snapshot options of snap "foo": snapshot exclusion "/var/snap/foo/\*" must be a path below one of \$SNAP_DATA, \$SNAP_COMMON, \$SNAP_USER_DATA, \$SNAP_USER_COMMON`},
+		{"exclude: [$HOME/foo]\n", `cannot read snapshot options of snap "foo": snapshot exclusion "\$HOME/foo" must be a path below one of .*`},
+		{"exclude: [$SNAP_DATA]\n", `cannot read snapshot options of snap "foo": snapshot exclusion "\$SNAP_DATA" must be a path below one of .*`},
+		{"exclude: [$SNAP_DATA/]\n", `cannot read snapshot options of snap "foo": snapshot exclusion "\$SNAP_DATA/" must be a clean path below \$SNAP_DATA`},
+		{"exclude: [$SNAP_DATA/../foo]\n", `cannot read snapshot options of snap "foo": snapshot exclusion "\$SNAP_DATA/../foo" must be a clean path below \$SNAP_DATA`},
+		{"exclude: [$SNAP_COMMON/a//b]\n", `cannot read snapshot options of snap "foo": snapshot exclusion "\$SNAP_COMMON/a//b" must be a clean path below \$SNAP_COMMON`},
+		{"exclude: ['$SNAP_DATA/[']\n", `cannot read snapshot options of snap "foo": snapshot exclusion "\$SNAP_DATA/\[" is not a valid glob: syntax error in pattern`},
+	} {
+		_, err := snap.ReadSnapshotYaml(s.mockSnap(c, t.yaml))
+		c.Check(err, ErrorMatches, t.err, Commentf(t.yaml))
+	}
+}
+
+func (s *snapshotYamlSuite) TestSplitSnapshotExclude(c *C) {
+	variable, glob, err := snap.SplitSnapshotExclude("$SNAP_USER_COMMON/a/b*")
+	c.Assert(err, IsNil)
+	c.Check(variable, Equals, "$SNAP_USER_COMMON")
+	c.Check(glob, Equals, "a/b*")
+}
@@ -331,6 +331,11 @@ func Validate(info *Info) error {
 		}
 	}
 
+	// validate the declared configuration options
+	if err := ValidateConfigSchema(info.ConfigSchema); err != nil {
+		return err
+	}
+
 	// Ensure that plugs and slots have appropriate names and interface names.
 	if err := plugsSlotsInterfacesNames(info); err != nil {
 		return err
@@ -778,11 +783,18 @@ func ValidateLayout(layout *Layout, constraints []LayoutConstraint) error {
 
 	switch layout.Type {
 	case "tmpfs":
+	case "overlay":
+		if err := validateOverlayLayout(layout, mountPoint); err != nil {
+			return err
+		}
 	case "":
 		// nothing to do
 	default:
 		return fmt.Errorf("layout %q uses invalid filesystem %q", layout.Path, layout.Type)
 	}
+	if layout.Upper != "" && layout.Type != "overlay" {
+		return fmt.Errorf("layout %q can only use an upper directory with an overlay filesystem", layout.Path)
+	}
 
 	if layout.Symlink != "" {
 		oldname := layout.Symlink
@@ -824,6 +836,44 @@ func ValidateLayout(layout *Layout, constraints []LayoutConstraint) error {
 	return nil
 }
 
+// validateOverlayLayout checks the overlay specific parts of a layout.
+//
+// Overlays make a read-only directory of the snap writable by stacking a
+// writable upper directory on top of it. The lower layer is the directory
+// itself, so the mount point must be inside $SNAP, and the upper directory
+// must be inside $SNAP_DATA or $SNAP_COMMON so that it is preserved (and, for
+// $SNAP_DATA, copied) across refreshes.
+func validateOverlayLayout(layout *Layout, mountPoint string) error {
+	si := layout.Snap
+	snapDir := si.ExpandSnapVariables("$SNAP")
+	if !strings.HasPrefix(mountPoint, snapDir+"/") {
+		return fmt.Errorf("layout %q uses invalid overlay mount point: must be inside $SNAP", layout.Path)
+	}
+	if layout.Upper == "" {
+		return fmt.Errorf("layout %q must define an upper directory for the overlay filesystem", layout.Path)
+	}
+	upper := layout.Upper
+	if err := ValidatePathVariables(upper); err != nil {
+		return fmt.Errorf("layout %q uses invalid overlay upper directory %q: %s", layout.Path, upper, err)
+	}
+	upper = si.ExpandSnapVariables(upper)
+	if !isAbsAndClean(upper) {
+		return fmt.Errorf("layout %q uses invalid overlay upper directory %q: must be absolute and clean", layout.Path, upper)
+	}
+	if !strings.HasPrefix(upper, si.ExpandSnapVariables("$SNAP_DATA")+"/") &&
+		!strings.HasPrefix(upper, si.ExpandSnapVariables("$SNAP_COMMON")+"/") {
+		return fmt.Errorf("layout %q uses invalid overlay upper directory %q: must be inside $SNAP_DATA or $SNAP_COMMON", layout.Path, upper)
+	}
+	// Overlay mount options are separated by commas and lower directories
+	// by colons, neither can be escaped.
+	for _, path := range []string{mountPoint, upper} {
+		if strings.ContainsAny(path, ",:") {
+			return fmt.Errorf("layout %q cannot use overlay filesystem with path %q: must not contain ',' or ':'", layout.Path, path)
+		}
+	}
+	return nil
+}
+
 func ValidateCommonIDs(info *Info) error {
 	seen := make(map[string]string, len(info.Apps))
 	for _, app := range info.Apps {
@@ -710,6 +710,37 @@ func (s *ValidateSuite) TestValidateLayout(c *C) {
 	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/data", Symlink: "$SNAP_DATA"}, nil), IsNil)
 }
 
+func (s *ValidateSuite) TestValidateLayoutOverlay(c *C) {
+	si := &Info{SuggestedName: "foo"}
+	// Several invalid overlay layouts.
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/etc/foo", Type: "overlay", Upper: "$SNAP_DATA/foo"}, nil),
+		ErrorMatches, `layout "/etc/foo" uses invalid overlay mount point: must be inside \$SNAP`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP", Type: "overlay", Upper: "$SNAP_DATA/foo"}, nil),
+		ErrorMatches, `layout "\$SNAP" uses invalid overlay mount point: must be inside \$SNAP`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/etc", Type: "overlay"}, nil),
+		ErrorMatches, `layout "\$SNAP/etc" must define an upper directory for the overlay filesystem`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/etc", Type: "overlay", Upper: "$BAR"}, nil),
+		ErrorMatches, `layout "\$SNAP/etc" uses invalid overlay upper directory "\$BAR": reference to unknown variable "\$BAR"`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/etc", Type: "overlay", Upper: "$SNAP_DATA/../etc"}, nil),
+		ErrorMatches, `layout "\$SNAP/etc" uses invalid overlay upper directory ".*": must be absolute and clean`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/etc", Type: "overlay", Upper: "$SNAP/upper"}, nil),
+		ErrorMatches, `layout "\$SNAP/etc" uses invalid overlay upper directory ".*": must be inside \$SNAP_DATA or \$SNAP_COMMON`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/etc", Type: "overlay", Upper: "$SNAP_DATA"}, nil),
+		ErrorMatches, `layout "\$SNAP/etc" uses invalid overlay upper directory ".*": must be inside \$SNAP_DATA or \$SNAP_COMMON`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/etc", Type: "overlay", Upper: "$SNAP_DATA/a,b"}, nil),
+		ErrorMatches, `layout "\$SNAP/etc" cannot use overlay filesystem with path ".*/a,b": must not contain ',' or ':'`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/a:b", Type: "overlay", Upper: "$SNAP_DATA/etc"}, nil),
+		ErrorMatches, `layout "\$SNAP/a:b" cannot use overlay filesystem with path ".*/a:b": must not contain ',' or ':'`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Type: "tmpfs", Upper: "$SNAP_DATA/foo"}, nil),
+		ErrorMatches, `layout "/foo" can only use an upper directory with an overlay filesystem`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Bind: "$SNAP/foo", Upper: "$SNAP_DATA/foo"}, nil),
+		ErrorMatches, `layout "/foo" can only use an upper directory with an overlay filesystem`)
+
+	// Several valid overlay layouts.
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/etc", Type: "overlay", Upper: "$SNAP_DATA/etc"}, nil), IsNil)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/usr/share/foo", Type: "overlay", Upper: "$SNAP_COMMON/overlay/foo"}, nil), IsNil)
+}
+
 func (s *ValidateSuite) TestValidateLayoutAll(c *C) {
 	// /usr/foo prevents /usr/foo/bar from being valid (tmpfs)
 	const yaml1 = `
@@ -75,3 +75,37 @@ func (s *spdxSuite) TestParseError(c *C) {
 		c.Check(err, ErrorMatches, t.errStr, Commentf("input: %q", t.inp))
 	}
 }
+
+func (s *spdxSuite) TestNormalizeLicense(c *C) {
+	for _, t := range []struct {
+		inp  string
+		norm string
+	}{
+		{"GPL-2.0", "GPL-2.0"},
+		{"gpl-2.0+", "GPL-2.0+"},
+		{"mit or apache-2.0", "MIT OR Apache-2.0"},
+		{"  GPL-2.0  with  gcc-exception-3.1 ", "GPL-2.0 WITH GCC-exception-3.1"},
+		{"( GPL-2.0 and (bsd-2-clause OR 0bsd))", "(GPL-2.0 AND (BSD-2-Clause OR 0BSD))"},
+		{"proprietary", "Proprietary"},
+	} {
+		norm, err := spdx.NormalizeLicense(t.inp)
+		c.Check(err, IsNil, Commentf("input: %q", t.inp))
+		c.Check(norm, Equals, t.norm, Commentf("input: %q", t.inp))
+	}
+}
+
+func (s *spdxSuite) TestNormalizeLicenseError(c *C) {
+	for _, t := range []struct {
+		inp    string
+		norm   string
+		errStr string
+	}{
+		{"", "", "empty expression"},
+		{"Other Open Source", "Other Open Source", "unknown license: Other"},
+		{"mit  gpl-3.0", "MIT GPL-3.0", `missing AND or OR between "MIT" and "GPL-3.0"`},
+	} {
+		norm, err := spdx.NormalizeLicense(t.inp)
+		c.Check(err, ErrorMatches, t.errStr, Commentf("input: %q", t.inp))
+		c.Check(norm, Equals, t.norm, Commentf("input: %q", t.inp))
+	}
+}
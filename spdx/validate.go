@@ -19,7 +19,10 @@
 
 package spdx
 
-import "bytes"
+import (
+	"bytes"
+	"strings"
+)
 
 // ValidateLicense implements license validation for SPDX 2.1 License
 // Expressions as described in Appendix IV of
@@ -32,3 +35,52 @@ import "bytes"
 func ValidateLicense(license string) error {
 	return newParser(bytes.NewBufferString(license)).Validate()
 }
+
+// canonicalID returns the known license or exception id matching s
+// case-insensitively in its canonical spelling, or s itself.
+func canonicalID(s string) string {
+	needle := strings.TrimSuffix(s, "+")
+	suffix := s[len(needle):]
+	for _, known := range allLicenses {
+		if strings.EqualFold(needle, known) {
+			return known + suffix
+		}
+	}
+	for _, known := range licenseExceptions {
+		if strings.EqualFold(s, known) {
+			return known
+		}
+	}
+	return s
+}
+
+// NormalizeLicense returns the given SPDX License Expression in a
+// canonical form: license and exception identifiers are spelled as in
+// the SPDX list, operators are upper case and tokens are separated by
+// single spaces.
+//
+// The normalized expression is returned together with the error from
+// ValidateLicense if it is not conforming.
+func NormalizeLicense(license string) (string, error) {
+	s := NewScanner(bytes.NewBufferString(license))
+	var buf bytes.Buffer
+	last := ""
+	for s.Scan() {
+		tok := s.Text()
+		if up := strings.ToUpper(tok); isOperator(up) {
+			tok = up
+		} else {
+			tok = canonicalID(tok)
+		}
+		if last != "" && last != "(" && tok != ")" {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(tok)
+		last = tok
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	normalized := buf.String()
+	return normalized, ValidateLicense(normalized)
+}
@@ -296,6 +296,7 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 		"Channels", // handled at a different level (see TestInfo)
 		"Tracks",   // handled at a different level (see TestInfo)
 		"Layout",
+		"ConfigSchema",
 		"SideInfo.Channel",
 		"DownloadInfo.AnonDownloadURL", // TODO: going away at some point
 	}
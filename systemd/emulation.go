@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// emulation is a Systemd for when there is no running systemd to talk
+// to, as when preseeding an image in a chroot. Units are only enabled
+// or disabled under the root directory, operations on running units
+// are not supported.
+type emulation struct {
+	rootDir string
+}
+
+type notImplementedError struct {
+	op string
+}
+
+func (e *notImplementedError) Error() string {
+	return fmt.Sprintf("%q is not implemented in emulation mode", e.op)
+}
+
+// NewEmulationMode returns a Systemd that only manipulates the unit
+// files under the given rootDir, without a running systemd.
+func NewEmulationMode(rootDir string) Systemd {
+	if rootDir == "" {
+		rootDir = dirs.GlobalRootDir
+	}
+	return &emulation{rootDir: rootDir}
+}
+
+func (s *emulation) DaemonReload() error {
+	return nil
+}
+
+func (s *emulation) Enable(service string) error {
+	_, err := systemctlCmd("--root", s.rootDir, "enable", service)
+	return err
+}
+
+func (s *emulation) Disable(service string) error {
+	_, err := systemctlCmd("--root", s.rootDir, "disable", service)
+	return err
+}
+
+func (s *emulation) Mask(service string) error {
+	_, err := systemctlCmd("--root", s.rootDir, "mask", service)
+	return err
+}
+
+func (s *emulation) Unmask(service string) error {
+	_, err := systemctlCmd("--root", s.rootDir, "unmask", service)
+	return err
+}
+
+func (s *emulation) Start(service ...string) error {
+	return &notImplementedError{"start"}
+}
+
+func (s *emulation) StartNoBlock(service ...string) error {
+	return &notImplementedError{"start"}
+}
+
+func (s *emulation) Stop(service string, timeout time.Duration) error {
+	return &notImplementedError{"stop"}
+}
+
+func (s *emulation) Kill(service, signal, who string) error {
+	return &notImplementedError{"kill"}
+}
+
+func (s *emulation) Restart(service string, timeout time.Duration) error {
+	return &notImplementedError{"restart"}
+}
+
+func (s *emulation) Status(units ...string) ([]*UnitStatus, error) {
+	return nil, &notImplementedError{"status"}
+}
+
+func (s *emulation) IsEnabled(service string) (bool, error) {
+	return false, &notImplementedError{"is-enabled"}
+}
+
+func (s *emulation) IsActive(service string) (bool, error) {
+	return false, &notImplementedError{"is-active"}
+}
+
+func (s *emulation) LogReader(services []string, n int, follow bool) (io.ReadCloser, error) {
+	return nil, &notImplementedError{"logs"}
+}
+
+// AddMountUnitFile writes and enables the mount unit and performs the
+// mount directly as systemd would.
+func (s *emulation) AddMountUnitFile(snapName, revision, what, where, fstype string) (string, error) {
+	mountUnitName, mountFsType, options, err := writeMountUnitFile(snapName, revision, what, where, fstype)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.Enable(mountUnitName); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("mount", "-t", mountFsType, what, where, "-o", strings.Join(options, ","))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("cannot mount %s at %s in preseed mode: %v", what, where, osutil.OutputErr(output, err))
+	}
+
+	return mountUnitName, nil
+}
+
+func (s *emulation) AddMountUnitFileWithOptions(unitOptions *MountUnitOptions) (string, error) {
+	return "", &notImplementedError{"mount"}
+}
+
+// RemoveMountUnitFile unmounts directly what was mounted with
+// AddMountUnitFile and removes the mount unit.
+func (s *emulation) RemoveMountUnitFile(mountedDir string) error {
+	unit := existingMountUnitPath(mountedDir)
+	if unit == "" {
+		return nil
+	}
+
+	isMounted, err := osutil.IsMounted(mountedDir)
+	if err != nil {
+		return err
+	}
+	if isMounted {
+		if output, err := exec.Command("umount", "-d", "-l", mountedDir).CombinedOutput(); err != nil {
+			return osutil.OutputErr(output, err)
+		}
+	}
+	if err := s.Disable(filepath.Base(unit)); err != nil {
+		return err
+	}
+	return os.Remove(unit)
+}
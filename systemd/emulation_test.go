@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd_test
+
+import (
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil/squashfs"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+
+	. "github.com/snapcore/snapd/systemd"
+)
+
+func (s *SystemdTestSuite) TestEmulationModeUsedWhenPreseeding(c *C) {
+	restore := release.MockPreseedMode(true)
+	defer restore()
+
+	sysd := New(dirs.GlobalRootDir, s.rep)
+	c.Check(sysd.DaemonReload(), IsNil)
+	c.Check(sysd.Start("foo"), ErrorMatches, `"start" is not implemented in emulation mode`)
+	c.Check(s.argses, HasLen, 0)
+}
+
+func (s *SystemdTestSuite) TestEmulationModeEnableDisable(c *C) {
+	sysd := NewEmulationMode(dirs.GlobalRootDir)
+
+	c.Assert(sysd.Enable("foo.service"), IsNil)
+	c.Assert(sysd.Disable("foo.service"), IsNil)
+	c.Assert(sysd.Mask("foo.service"), IsNil)
+	c.Assert(sysd.Unmask("foo.service"), IsNil)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", "foo.service"},
+		{"--root", dirs.GlobalRootDir, "disable", "foo.service"},
+		{"--root", dirs.GlobalRootDir, "mask", "foo.service"},
+		{"--root", dirs.GlobalRootDir, "unmask", "foo.service"},
+	})
+}
+
+func (s *SystemdTestSuite) TestEmulationModeNotImplemented(c *C) {
+	sysd := NewEmulationMode(dirs.GlobalRootDir)
+
+	c.Check(sysd.StartNoBlock("foo"), ErrorMatches, `"start" is not implemented in emulation mode`)
+	c.Check(sysd.Stop("foo", time.Second), ErrorMatches, `"stop" is not implemented in emulation mode`)
+	c.Check(sysd.Restart("foo", time.Second), ErrorMatches, `"restart" is not implemented in emulation mode`)
+	c.Check(sysd.Kill("foo", "HUP", ""), ErrorMatches, `"kill" is not implemented in emulation mode`)
+	_, err := sysd.Status("foo")
+	c.Check(err, ErrorMatches, `"status" is not implemented in emulation mode`)
+	_, err = sysd.IsEnabled("foo")
+	c.Check(err, ErrorMatches, `"is-enabled" is not implemented in emulation mode`)
+	_, err = sysd.IsActive("foo")
+	c.Check(err, ErrorMatches, `"is-active" is not implemented in emulation mode`)
+	_, err = sysd.LogReader([]string{"foo"}, 1, false)
+	c.Check(err, ErrorMatches, `"logs" is not implemented in emulation mode`)
+	_, err = sysd.AddMountUnitFileWithOptions(&MountUnitOptions{Lifetime: Transient, Where: "/foo"})
+	c.Check(err, ErrorMatches, `"mount" is not implemented in emulation mode`)
+	c.Check(s.argses, HasLen, 0)
+}
+
+func (s *SystemdTestSuite) TestEmulationModeAddRemoveMountUnit(c *C) {
+	restore := squashfs.MockUseFuse(false)
+	defer restore()
+
+	mockMount := testutil.MockCommand(c, "mount", "")
+	defer mockMount.Restore()
+
+	mockSnapPath := filepath.Join(c.MkDir(), "/var/lib/snapd/snaps/foo_1.0.snap")
+	makeMockFile(c, mockSnapPath)
+
+	sysd := NewEmulationMode(dirs.GlobalRootDir)
+	mountUnitName, err := sysd.AddMountUnitFile("foo", "42", mockSnapPath, "/snap/snapname/123", "squashfs")
+	c.Assert(err, IsNil)
+	c.Check(mountUnitName, Equals, "snap-snapname-123.mount")
+
+	mountUnit := filepath.Join(dirs.SnapServicesDir, mountUnitName)
+	c.Check(mountUnit, testutil.FileContains, "Where=/snap/snapname/123\nType=squashfs\nOptions=nodev,ro,x-gdu.hide\n")
+	c.Check(mockMount.Calls(), DeepEquals, [][]string{
+		{"mount", "-t", "squashfs", mockSnapPath, "/snap/snapname/123", "-o", "nodev,ro,x-gdu.hide"},
+	})
+
+	err = sysd.RemoveMountUnitFile(filepath.Join(dirs.GlobalRootDir, "/snap/snapname/123"))
+	c.Assert(err, IsNil)
+	c.Check(mountUnit, testutil.FileAbsent)
+
+	// no daemon-reload or start/stop happened
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", "snap-snapname-123.mount"},
+		{"--root", dirs.GlobalRootDir, "disable", "snap-snapname-123.mount"},
+	})
+}
+
+func (s *SystemdTestSuite) TestEmulationModeAddMountUnitMountFails(c *C) {
+	restore := squashfs.MockUseFuse(false)
+	defer restore()
+
+	mockMount := testutil.MockCommand(c, "mount", "echo boom; exit 1")
+	defer mockMount.Restore()
+
+	mockSnapPath := filepath.Join(c.MkDir(), "/var/lib/snapd/snaps/foo_1.0.snap")
+	makeMockFile(c, mockSnapPath)
+
+	sysd := NewEmulationMode(dirs.GlobalRootDir)
+	_, err := sysd.AddMountUnitFile("foo", "42", mockSnapPath, "/snap/snapname/123", "squashfs")
+	c.Check(err, ErrorMatches, `cannot mount .*/foo_1.0.snap at /snap/snapname/123 in preseed mode: boom`)
+}
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -32,6 +33,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
 
 	_ "github.com/snapcore/squashfuse"
 
@@ -175,6 +177,7 @@ type Systemd interface {
 	IsActive(service string) (bool, error)
 	LogReader(services []string, n int, follow bool) (io.ReadCloser, error)
 	AddMountUnitFile(name, revision, what, where, fstype string) (string, error)
+	AddMountUnitFileWithOptions(unitOptions *MountUnitOptions) (string, error)
 	RemoveMountUnitFile(baseDir string) error
 	Mask(service string) error
 	Unmask(service string) error
@@ -201,8 +204,13 @@ type reporter interface {
 	Notify(string)
 }
 
-// New returns a Systemd that uses the given rootDir
+// New returns a Systemd that uses the given rootDir. In preseed mode,
+// where there is no running systemd, the emulation returned by
+// NewEmulationMode is used instead.
 func New(rootDir string, rep reporter) Systemd {
+	if release.PreseedMode {
+		return NewEmulationMode(rootDir)
+	}
 	return &systemd{rootDir: rootDir, reporter: rep}
 }
 
@@ -537,16 +545,88 @@ func MountUnitPath(baseDir string) string {
 	return filepath.Join(dirs.SnapServicesDir, escapedPath+".mount")
 }
 
-// AddMountUnitFile adds/enables/starts a mount unit.
-func (s *systemd) AddMountUnitFile(snapName, revision, what, where, fstype string) (string, error) {
-	daemonReloadLock.Lock()
-	defer daemonReloadLock.Unlock()
+// MountUnitLifetime is the lifetime of a mount unit.
+type MountUnitLifetime int
+
+const (
+	// Persistent mount units are kept across reboots.
+	Persistent MountUnitLifetime = iota
+	// Transient mount units are only kept until the next reboot.
+	Transient
+)
+
+// MountUnitOptions describes a mount unit added with
+// AddMountUnitFileWithOptions.
+type MountUnitOptions struct {
+	Lifetime MountUnitLifetime
+	SnapName string
+	Revision string
+	What     string
+	Where    string
+	Fstype   string
+	Options  []string
+	// Origin is the snapd feature that requested the mount, if any.
+	Origin string
+}
+
+func (u *MountUnitOptions) unitPath() string {
+	if u.Lifetime == Transient {
+		return filepath.Join(dirs.SnapRuntimeServicesDir, EscapeUnitNamePath(u.Where)+".mount")
+	}
+	return MountUnitPath(u.Where)
+}
+
+// hasControlChars returns whether s contains control characters, which
+// would let it add lines to a unit file.
+func hasControlChars(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
+
+func writeMountUnit(u *MountUnitOptions) (mountUnitName string, err error) {
+	for _, v := range append([]string{u.SnapName, u.Revision, u.What, u.Where, u.Fstype, u.Origin}, u.Options...) {
+		if hasControlChars(v) {
+			return "", fmt.Errorf("cannot write mount unit: %q contains control characters", v)
+		}
+	}
+
+	var origin string
+	if u.Origin != "" {
+		origin = fmt.Sprintf("X-SnapdOrigin=%s\n", u.Origin)
+	}
+
+	c := fmt.Sprintf(`[Unit]
+Description=Mount unit for %s, revision %s
+Before=snapd.service
 
-	options := []string{"nodev"}
+[Mount]
+What=%s
+Where=%s
+Type=%s
+Options=%s
+%s
+[Install]
+WantedBy=multi-user.target
+`, u.SnapName, u.Revision, u.What, u.Where, u.Fstype, strings.Join(u.Options, ","), origin)
+
+	mu := u.unitPath()
+	if err := os.MkdirAll(filepath.Dir(mu), 0755); err != nil {
+		return "", err
+	}
+	if err := osutil.AtomicWriteFile(mu, []byte(c), 0644, 0); err != nil {
+		return "", err
+	}
+	return filepath.Base(mu), nil
+}
+
+// writeMountUnitFile writes the mount unit of a snap, it returns its
+// name as well as the filesystem type and the options used for the
+// mount.
+func writeMountUnitFile(snapName, revision, what, where, fstype string) (mountUnitName, mountFsType string, options []string, err error) {
+	options = []string{"nodev"}
 	if fstype == "squashfs" {
 		newFsType, newOptions, err := squashfs.FsType()
 		if err != nil {
-			return "", err
+			return "", "", nil, err
 		}
 		options = append(options, newOptions...)
 		fstype = newFsType
@@ -561,22 +641,26 @@ func (s *systemd) AddMountUnitFile(snapName, revision, what, where, fstype strin
 		fstype = "none"
 	}
 
-	c := fmt.Sprintf(`[Unit]
-Description=Mount unit for %s, revision %s
-Before=snapd.service
-
-[Mount]
-What=%s
-Where=%s
-Type=%s
-Options=%s
+	mountUnitName, err = writeMountUnit(&MountUnitOptions{
+		SnapName: snapName,
+		Revision: revision,
+		What:     what,
+		Where:    where,
+		Fstype:   fstype,
+		Options:  options,
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return mountUnitName, fstype, options, nil
+}
 
-[Install]
-WantedBy=multi-user.target
-`, snapName, revision, what, where, fstype, strings.Join(options, ","))
+// AddMountUnitFile adds/enables/starts a mount unit.
+func (s *systemd) AddMountUnitFile(snapName, revision, what, where, fstype string) (string, error) {
+	daemonReloadLock.Lock()
+	defer daemonReloadLock.Unlock()
 
-	mu := MountUnitPath(where)
-	mountUnitName, err := filepath.Base(mu), osutil.AtomicWriteFile(mu, []byte(c), 0644, 0)
+	mountUnitName, _, _, err := writeMountUnitFile(snapName, revision, what, where, fstype)
 	if err != nil {
 		return "", err
 	}
@@ -597,12 +681,95 @@ WantedBy=multi-user.target
 	return mountUnitName, nil
 }
 
+// AddMountUnitFileWithOptions adds and starts a mount unit as
+// described by the given options. Persistent units are also enabled.
+func (s *systemd) AddMountUnitFileWithOptions(unitOptions *MountUnitOptions) (string, error) {
+	daemonReloadLock.Lock()
+	defer daemonReloadLock.Unlock()
+
+	mountUnitName, err := writeMountUnit(unitOptions)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.daemonReloadNoLock(); err != nil {
+		return "", err
+	}
+
+	if unitOptions.Lifetime == Persistent {
+		if err := s.Enable(mountUnitName); err != nil {
+			return "", err
+		}
+	}
+	if err := s.Start(mountUnitName); err != nil {
+		return "", err
+	}
+
+	return mountUnitName, nil
+}
+
+// existingMountUnitPath returns the path of the persistent or transient
+// mount unit for the given mount point, or "" if there is none.
+func existingMountUnitPath(mountedDir string) string {
+	where := dirs.StripRootDir(mountedDir)
+	for _, lifetime := range []MountUnitLifetime{Persistent, Transient} {
+		unit := (&MountUnitOptions{Lifetime: lifetime, Where: where}).unitPath()
+		if osutil.FileExists(unit) {
+			return unit
+		}
+	}
+	return ""
+}
+
+// ExistingMountUnit returns the options of the persistent or transient
+// mount unit for the given mount point, as read back from the unit
+// file, or nil if there is no such unit.
+func ExistingMountUnit(mountedDir string) (*MountUnitOptions, error) {
+	unit := existingMountUnitPath(mountedDir)
+	if unit == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &MountUnitOptions{Lifetime: Persistent}
+	if filepath.Dir(unit) == dirs.SnapRuntimeServicesDir {
+		u.Lifetime = Transient
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Description":
+			fmt.Sscanf(kv[1], "Mount unit for %s revision %s", &u.SnapName, &u.Revision)
+			u.SnapName = strings.TrimSuffix(u.SnapName, ",")
+		case "What":
+			u.What = kv[1]
+		case "Where":
+			u.Where = kv[1]
+		case "Type":
+			u.Fstype = kv[1]
+		case "Options":
+			if kv[1] != "" {
+				u.Options = strings.Split(kv[1], ",")
+			}
+		case "X-SnapdOrigin":
+			u.Origin = kv[1]
+		}
+	}
+	return u, nil
+}
+
 func (s *systemd) RemoveMountUnitFile(mountedDir string) error {
 	daemonReloadLock.Lock()
 	defer daemonReloadLock.Unlock()
 
-	unit := MountUnitPath(dirs.StripRootDir(mountedDir))
-	if !osutil.FileExists(unit) {
+	unit := existingMountUnitPath(mountedDir)
+	if unit == "" {
 		return nil
 	}
 
@@ -754,6 +754,150 @@ func (s *SystemdTestSuite) TestRemoveMountUnit(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestAddMountUnitWithOptionsPersistent(c *C) {
+	rootDir := dirs.GlobalRootDir
+
+	mountUnitName, err := New(rootDir, nil).AddMountUnitFileWithOptions(&MountUnitOptions{
+		Lifetime: Persistent,
+		SnapName: "foo",
+		Revision: "42",
+		What:     "/dev/sdb1",
+		Where:    "/var/snap/foo/common/data",
+		Fstype:   "ext4",
+		Options:  []string{"rw", "noexec"},
+		Origin:   "mount-control",
+	})
+	c.Assert(err, IsNil)
+	c.Check(mountUnitName, Equals, "var-snap-foo-common-data.mount")
+
+	c.Check(filepath.Join(dirs.SnapServicesDir, mountUnitName), testutil.FileEquals, `[Unit]
+Description=Mount unit for foo, revision 42
+Before=snapd.service
+
+[Mount]
+What=/dev/sdb1
+Where=/var/snap/foo/common/data
+Type=ext4
+Options=rw,noexec
+X-SnapdOrigin=mount-control
+
+[Install]
+WantedBy=multi-user.target
+`)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"--root", rootDir, "enable", "var-snap-foo-common-data.mount"},
+		{"start", "var-snap-foo-common-data.mount"},
+	})
+}
+
+func (s *SystemdTestSuite) TestAddMountUnitWithOptionsTransient(c *C) {
+	rootDir := dirs.GlobalRootDir
+
+	mountUnitName, err := New(rootDir, nil).AddMountUnitFileWithOptions(&MountUnitOptions{
+		Lifetime: Transient,
+		SnapName: "foo",
+		Revision: "42",
+		What:     "tmpfs",
+		Where:    "/var/snap/foo/common/tmp",
+		Fstype:   "tmpfs",
+	})
+	c.Assert(err, IsNil)
+	c.Check(mountUnitName, Equals, "var-snap-foo-common-tmp.mount")
+
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, mountUnitName), testutil.FileContains, "What=tmpfs\nWhere=/var/snap/foo/common/tmp\nType=tmpfs\n")
+	c.Check(filepath.Join(dirs.SnapServicesDir, mountUnitName), testutil.FileAbsent)
+	// transient units are not enabled
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"start", "var-snap-foo-common-tmp.mount"},
+	})
+}
+
+func (s *SystemdTestSuite) TestAddMountUnitWithOptionsControlChars(c *C) {
+	rootDir := dirs.GlobalRootDir
+
+	for _, opts := range []*MountUnitOptions{
+		{What: "/dev/sdb1", Where: "/media/x\nWhat=/dev/sda1", Fstype: "ext4"},
+		{What: "/dev/sdb1\r", Where: "/media/x", Fstype: "ext4"},
+		{What: "/dev/sdb1", Where: "/media/x", Fstype: "ext4", Options: []string{"ro\n[Install]"}},
+	} {
+		opts.Lifetime = Transient
+		_, err := New(rootDir, nil).AddMountUnitFileWithOptions(opts)
+		c.Check(err, ErrorMatches, `cannot write mount unit: ".*" contains control characters`)
+	}
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, "media-x.mount"), testutil.FileAbsent)
+	c.Check(s.argses, HasLen, 0)
+}
+
+func (s *SystemdTestSuite) TestRemoveTransientMountUnit(c *C) {
+	rootDir := dirs.GlobalRootDir
+
+	_, err := New(rootDir, nil).AddMountUnitFileWithOptions(&MountUnitOptions{
+		Lifetime: Transient,
+		What:     "tmpfs",
+		Where:    "/var/snap/foo/common/tmp",
+		Fstype:   "tmpfs",
+	})
+	c.Assert(err, IsNil)
+	s.argses = nil
+
+	err = New(rootDir, nil).RemoveMountUnitFile(rootDir + "/var/snap/foo/common/tmp")
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, "var-snap-foo-common-tmp.mount"), testutil.FileAbsent)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"--root", rootDir, "disable", "var-snap-foo-common-tmp.mount"},
+		{"daemon-reload"},
+	})
+}
+
+func (s *SystemdTestSuite) TestExistingMountUnit(c *C) {
+	rootDir := dirs.GlobalRootDir
+	sysd := New(rootDir, nil)
+
+	_, err := sysd.AddMountUnitFileWithOptions(&MountUnitOptions{
+		Lifetime: Transient,
+		SnapName: "foo",
+		Revision: "42",
+		What:     "/dev/sdb1",
+		Where:    "/media/foo",
+		Fstype:   "ext4",
+		Options:  []string{"rw", "nosuid", "nodev"},
+		Origin:   "mount-control",
+	})
+	c.Assert(err, IsNil)
+	u, err := ExistingMountUnit(rootDir + "/media/foo")
+	c.Assert(err, IsNil)
+	c.Check(u, DeepEquals, &MountUnitOptions{
+		Lifetime: Transient,
+		SnapName: "foo",
+		Revision: "42",
+		What:     "/dev/sdb1",
+		Where:    "/media/foo",
+		Fstype:   "ext4",
+		Options:  []string{"rw", "nosuid", "nodev"},
+		Origin:   "mount-control",
+	})
+
+	// snap mount units have no origin
+	mockSnapPath := filepath.Join(c.MkDir(), "/var/lib/snappy/snaps/bar_1.0.snap")
+	makeMockFile(c, mockSnapPath)
+	_, err = sysd.AddMountUnitFile("bar", "13", mockSnapPath, "/snap/bar/13", "squashfs")
+	c.Assert(err, IsNil)
+	u, err = ExistingMountUnit(rootDir + "/snap/bar/13")
+	c.Assert(err, IsNil)
+	c.Check(u.Lifetime, Equals, Persistent)
+	c.Check(u.SnapName, Equals, "bar")
+	c.Check(u.Revision, Equals, "13")
+	c.Check(u.Where, Equals, "/snap/bar/13")
+	c.Check(u.Origin, Equals, "")
+
+	// no unit
+	u, err = ExistingMountUnit(rootDir + "/media/other")
+	c.Assert(err, IsNil)
+	c.Check(u, IsNil)
+}
+
 func (s *SystemdTestSuite) TestDaemonReloadMutex(c *C) {
 	rootDir := dirs.GlobalRootDir
 	sysd := New(rootDir, nil)